@@ -0,0 +1,228 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"sort"
+	"sync"
+)
+
+// selectedAccount is the name of the account shown by the GUI, whose
+// addresses, balances and transactions are requested and which sends
+// new transactions.  The default account is named by the empty string.
+var selectedAccount struct {
+	sync.RWMutex
+	name string
+}
+
+// currentAccount returns the name of the selected account.
+func currentAccount() string {
+	selectedAccount.RLock()
+	defer selectedAccount.RUnlock()
+	return selectedAccount.name
+}
+
+// setCurrentAccount sets the name of the selected account.  Only
+// showAccount should call this, so the state of the account is requested
+// again.
+func setCurrentAccount(name string) {
+	selectedAccount.Lock()
+	selectedAccount.name = name
+	selectedAccount.Unlock()
+}
+
+// accountDisplayName returns the name of an account as shown to the
+// user.
+func accountDisplayName(name string) string {
+	if name == "" {
+		return "Default"
+	}
+	return name
+}
+
+// accountWidgets holds the account selector of the main window.  It must
+// only be accessed from the GTK main event loop.
+var accountWidgets struct {
+	combo *gtk.ComboBoxText
+
+	// names holds the name of each account in the order of the combo
+	// box entries.
+	names []string
+
+	// updating is set while the entries are replaced, so the changed
+	// signal does not select another account.
+	updating bool
+}
+
+// accountListed returns whether the account selector has an entry for
+// the named account.
+//
+// This must be run from the GTK main event loop.
+func accountListed(name string) bool {
+	for _, n := range accountWidgets.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// selectAccount shows the named account, clearing the state shown for
+// the previously selected account before requesting it for the new one.
+//
+// This must be run from the GTK main event loop.
+func selectAccount(name string) {
+	for i, n := range accountWidgets.names {
+		if n == name {
+			accountWidgets.updating = true
+			accountWidgets.combo.SetActive(i)
+			accountWidgets.updating = false
+		}
+	}
+	if name == currentAccount() {
+		return
+	}
+	setStatus("Showing account " + accountDisplayName(name))
+	showAccount(name)
+}
+
+// showAccount makes name the selected account.  Everything shown for the
+// previous account is cleared before the state of the new account is
+// requested from the active session.  If not connected, the state is
+// requested once connected.
+func showAccount(name string) {
+	setCurrentAccount(name)
+	reloadWalletState()
+}
+
+// setAccountNames replaces the entries of the account selector.  The
+// default account is always listed first, and the selected account is
+// kept listed even if btcwallet did not report it yet.
+//
+// This must be run from the GTK main event loop.
+func setAccountNames(names []string) {
+	sort.Strings(names)
+	list := []string{""}
+	selected := currentAccount()
+	found := selected == ""
+	for _, n := range names {
+		if n == "" {
+			continue
+		}
+		if n == selected {
+			found = true
+		}
+		list = append(list, n)
+	}
+	if !found {
+		list = append(list, selected)
+	}
+
+	accountWidgets.updating = true
+	defer func() {
+		accountWidgets.updating = false
+	}()
+	accountWidgets.combo.RemoveAll()
+	for i, n := range list {
+		accountWidgets.combo.AppendText(accountDisplayName(n))
+		if n == selected {
+			accountWidgets.combo.SetActive(i)
+		}
+	}
+	accountWidgets.names = list
+	accountWidgets.combo.SetSensitive(len(list) > 1)
+}
+
+// requestAccounts requests the names of every account of the wallet.
+func requestAccounts(b WalletBackend) ([]string, error) {
+	m, err := b.ListAccounts()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// fetchAccounts requests the names of every account of the wallet from b
+// and lists them in the account selector.
+func fetchAccounts(b WalletBackend) {
+	names, err := requestAccounts(b)
+	if err != nil {
+		log.Printf("[ERR] listaccounts: %v", err)
+		return
+	}
+	glib.IdleAdd(func() {
+		setAccountNames(names)
+	})
+}
+
+// refreshAccounts lists the accounts of the active session in the
+// account selector, such as after a notification for an account which
+// was not listed.
+//
+// This is written to be run as a goroutine executing outside of the GTK
+// main event loop.
+func refreshAccounts() {
+	if b := activeBackend(); b != nil {
+		fetchAccounts(b)
+	}
+}
+
+// createAccountSelector creates the bar of the main window choosing the
+// account shown by every tab and used for sending.  It is insensitive
+// until the wallet is known to have more than the default account.
+func createAccountSelector() *gtk.Widget {
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	grid.SetColumnSpacing(6)
+
+	l, err := gtk.LabelNew("Account:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	grid.Add(l)
+
+	combo, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	combo.SetTooltipText("The account whose addresses, balances and " +
+		"transactions are shown, and which new addresses are created " +
+		"for and payments are sent from")
+	combo.Connect("changed", func() {
+		if accountWidgets.updating {
+			return
+		}
+		i := combo.GetActive()
+		if i >= 0 && i < len(accountWidgets.names) {
+			selectAccount(accountWidgets.names[i])
+		}
+	})
+	grid.Add(combo)
+	accountWidgets.combo = combo
+	setAccountNames(nil)
+
+	return &grid.Container.Widget
+}
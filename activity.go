@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"time"
+)
+
+// activityDays is the number of days, including today, of the trailing
+// totals of the Overview activity summary.
+const activityDays = 7
+
+// activityTotals are the amounts received and sent today, and over the
+// trailing activityDays days.  Sent amounts do not include fees.
+type activityTotals struct {
+	recvToday btcutil.Amount
+	sentToday btcutil.Amount
+	recvWeek  btcutil.Amount
+	sentWeek  btcutil.Amount
+}
+
+// computeActivity totals the amounts of attrs received and sent since the
+// local midnight of now, and since midnight activityDays-1 days earlier.
+func computeActivity(attrs []*TxAttributes, now time.Time) activityTotals {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	weekStart := today.AddDate(0, 0, -(activityDays - 1))
+
+	var t activityTotals
+	for _, attr := range attrs {
+		if attr.Date.Before(weekStart) {
+			continue
+		}
+		amt := attr.Amount
+		if amt < 0 {
+			amt = -amt
+		}
+		isToday := !attr.Date.Before(today)
+		switch attr.Direction {
+		case Recv:
+			t.recvWeek += amt
+			if isToday {
+				t.recvToday += amt
+			}
+		case Send:
+			t.sentWeek += amt
+			if isToday {
+				t.sentToday += amt
+			}
+		}
+	}
+	return t
+}
+
+// activity holds the labels of the Overview activity summary, and
+// whether an update is already scheduled.  This must only be accessed
+// from the GTK main event loop.
+var activity struct {
+	recvToday *gtk.Label
+	sentToday *gtk.Label
+	recvWeek  *gtk.Label
+	sentWeek  *gtk.Label
+	scheduled bool
+}
+
+// createActivitySummary creates the Overview summary of the amounts
+// received and sent today and over the last activityDays days.
+func createActivitySummary() *gtk.Widget {
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	grid.SetColumnSpacing(12)
+
+	header, err := gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	header.SetMarkup("<b>Activity</b>")
+	header.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(header, 0, 0, 2, 1)
+
+	rows := []struct {
+		text  string
+		label **gtk.Label
+	}{
+		{"Received today:", &activity.recvToday},
+		{"Sent today:", &activity.sentToday},
+		{"Received (7 days):", &activity.recvWeek},
+		{"Sent (7 days):", &activity.sentWeek},
+	}
+	for i, r := range rows {
+		l, err := gtk.LabelNew(r.text)
+		if err != nil {
+			log.Fatal(err)
+		}
+		l.SetHAlign(gtk.ALIGN_START)
+		grid.Attach(l, 0, i+1, 1, 1)
+
+		v, err := gtk.LabelNew(formatAmount(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		v.SetHAlign(gtk.ALIGN_START)
+		grid.Attach(v, 1, i+1, 1, 1)
+		*r.label = v
+	}
+	grid.SetTooltipText("Totals of the transaction history by local " +
+		"date.  Sent amounts do not include fees.")
+
+	return &grid.Container.Widget
+}
+
+// updateActivity recomputes the activity summary from every transaction
+// shown in the transactions tab.
+//
+// This must be run from the GTK main event loop.
+func updateActivity() {
+	t := computeActivity(allTxAttrs(), time.Now())
+	activity.recvToday.SetText(formatAmount(t.recvToday))
+	activity.sentToday.SetText(formatAmount(t.sentToday))
+	activity.recvWeek.SetText(formatAmount(t.recvWeek))
+	activity.sentWeek.SetText(formatAmount(t.sentWeek))
+}
+
+// scheduleActivityUpdate updates the activity summary once the GTK main
+// event loop is idle, so a burst of new transactions, such as after
+// connecting, only recomputes it once.
+//
+// This must be run from the GTK main event loop.
+func scheduleActivityUpdate() {
+	if activity.scheduled || activity.recvToday == nil {
+		return
+	}
+	activity.scheduled = true
+	glib.IdleAdd(func() {
+		activity.scheduled = false
+		updateActivity()
+	})
+}
+
+// activityRefresher updates the activity summary every minute, so the
+// totals move to the next day at midnight without new transactions.
+// This must be run as a goroutine.
+func activityRefresher() {
+	for _ = range time.Tick(time.Minute) {
+		glib.IdleAdd(scheduleActivityUpdate)
+	}
+}
@@ -17,15 +17,116 @@
 package main
 
 import (
-	"github.com/conformal/gotk3/gdk"
+	"fmt"
+	"github.com/conformal/btcutil"
 	"github.com/conformal/gotk3/glib"
 	"github.com/conformal/gotk3/gtk"
 	"log"
 )
 
+// Columns of the address book's tree store.
+const (
+	abColLabel = iota
+	abColAddress
+	abColEditable
+)
+
 var addrBookWidgets struct {
-	store    *gtk.ListStore
+	store    *gtk.TreeStore
 	treeview *gtk.TreeView
+
+	// own and contacts are the top level rows of the wallet's own
+	// addresses and of external contacts.  Wallet addresses are synced
+	// from btcwallet and may not be edited, while contacts are saved
+	// to the metadata store.
+	own      *gtk.TreeIter
+	contacts *gtk.TreeIter
+}
+
+// addrBookString returns the string in column col of the address book
+// row referenced by iter.
+func addrBookString(iter *gtk.TreeIter, col int) string {
+	val, err := addrBookWidgets.store.GetValue(iter, col)
+	if err != nil {
+		return ""
+	}
+	s, _ := val.GetString()
+	return s
+}
+
+// addrBookEditable returns whether the address book row referenced by
+// iter is an external contact which may be edited.
+func addrBookEditable(iter *gtk.TreeIter) bool {
+	val, err := addrBookWidgets.store.GetValue(iter, abColEditable)
+	if err != nil {
+		return false
+	}
+	editable, err := val.GoValue()
+	if err != nil {
+		return false
+	}
+	b, ok := editable.(bool)
+	return ok && b
+}
+
+// setOwnAddresses replaces the wallet addresses shown in the address
+// book.  This does nothing if the address book is not enabled.
+//
+// This must be run from the GTK main event loop.
+func setOwnAddresses(addrs []string) {
+	if addrBookWidgets.store == nil {
+		return
+	}
+	store := addrBookWidgets.store
+	var child gtk.TreeIter
+	for store.IterChildren(addrBookWidgets.own, &child) {
+		store.Remove(&child)
+	}
+	for _, addr := range addrs {
+		iter := store.Append(addrBookWidgets.own)
+		store.Set(iter, []int{abColLabel, abColAddress, abColEditable},
+			[]interface{}{metadata.AddrLabel(addr), addr, false})
+	}
+	addrBookWidgets.treeview.ExpandAll()
+}
+
+// saveAddrBookContacts saves all external contacts with an address to
+// the metadata store.
+//
+// This must be run from the GTK main event loop.
+func saveAddrBookContacts() {
+	var contacts []addressBookContact
+	var iter gtk.TreeIter
+	ok := addrBookWidgets.store.IterChildren(addrBookWidgets.contacts, &iter)
+	for ok {
+		c := addressBookContact{
+			Label:   addrBookString(&iter, abColLabel),
+			Address: addrBookString(&iter, abColAddress),
+		}
+		if c.Address != "" {
+			contacts = append(contacts, c)
+		}
+		ok = addrBookWidgets.store.IterNext(&iter)
+	}
+	if err := metadata.SetAddressBookContacts(contacts); err != nil {
+		log.Printf("[ERR] cannot save address book: %v", err)
+	}
+}
+
+// validateContactAddress returns an error if addr may not be saved as an
+// external contact.
+func validateContactAddress(addr string) error {
+	a, err := btcutil.DecodeAddress(addr, activeNet.Params)
+	if err != nil {
+		return fmt.Errorf("'%v' is not a valid address", addr)
+	}
+	if !a.IsForNet(activeNet.Params) {
+		return fmt.Errorf("Address '%v' is for the wrong bitcoin network", addr)
+	}
+	if isOwnAddr(addr) {
+		return fmt.Errorf("Address '%v' belongs to this wallet", addr)
+	}
+	return nil
 }
 
 func createAddrBook() *gtk.Widget {
@@ -35,7 +136,8 @@ func createAddrBook() *gtk.Widget {
 	}
 	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
 
-	store, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING)
+	store, err := gtk.TreeStoreNew(glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_BOOLEAN)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -57,23 +159,25 @@ func createAddrBook() *gtk.Widget {
 	sw.SetVExpand(true)
 	grid.Add(sw)
 
+	// Only contacts are editable, so wallet addresses and the section
+	// rows can not be changed by accident.
 	renderer, err := gtk.CellRendererTextNew()
 	if err != nil {
 		log.Fatal(err)
 	}
-	renderer.Set("editable", true)
-	renderer.Set("editable-set", true)
 	renderer.Connect("edited", func(_ *gtk.CellRendererText, path, text string) {
 		iter, err := store.GetIterFromString(path)
-		if err == nil {
-			store.Set(iter, []int{0}, []interface{}{text})
+		if err == nil && addrBookEditable(iter) {
+			store.SetValue(iter, abColLabel, text)
+			saveAddrBookContacts()
 		}
 	})
 
-	col, err := gtk.TreeViewColumnNewWithAttribute("Label", renderer, "text", 0)
+	col, err := gtk.TreeViewColumnNewWithAttribute("Label", renderer, "text", abColLabel)
 	if err != nil {
 		log.Fatal(err)
 	}
+	col.AddAttribute(renderer, "editable", abColEditable)
 	col.SetExpand(true)
 	tv.AppendColumn(col)
 
@@ -81,42 +185,80 @@ func createAddrBook() *gtk.Widget {
 	if err != nil {
 		log.Fatal(err)
 	}
-	renderer.Set("editable", true)
-	renderer.Set("editable-set", true)
 	renderer.Connect("edited", func(_ *gtk.CellRendererText, path, text string) {
 		iter, err := store.GetIterFromString(path)
-		if err == nil {
-			// TODO(jrick): verify this is a valid address
-			store.Set(iter, []int{1}, []interface{}{text})
+		if err != nil || !addrBookEditable(iter) {
+			return
 		}
+		if err := validateContactAddress(text); err != nil {
+			d := errorDialog("Invalid contact address", err.Error())
+			d.Run()
+			d.Destroy()
+			return
+		}
+		store.SetValue(iter, abColAddress, text)
+		saveAddrBookContacts()
 	})
 	col, err = gtk.TreeViewColumnNewWithAttribute("Address", renderer,
-		"text", 1)
+		"text", abColAddress)
 	if err != nil {
 		log.Fatal(err)
 	}
+	col.AddAttribute(renderer, "editable", abColEditable)
 	col.SetMinWidth(350)
 	tv.AppendColumn(col)
 
-	// put in an example address
-	iter := store.Append()
-	store.Set(iter, []int{0, 1}, []interface{}{"editable label", "01234567890"})
+	addrBookWidgets.own = store.Append(nil)
+	store.Set(addrBookWidgets.own, []int{abColLabel, abColEditable},
+		[]interface{}{"My Addresses", false})
+	addrBookWidgets.contacts = store.Append(nil)
+	store.Set(addrBookWidgets.contacts, []int{abColLabel, abColEditable},
+		[]interface{}{"Contacts", false})
+	for _, c := range metadata.AddressBookContacts() {
+		iter := store.Append(addrBookWidgets.contacts)
+		store.Set(iter, []int{abColLabel, abColAddress, abColEditable},
+			[]interface{}{c.Label, c.Address, true})
+	}
+	tv.ExpandAll()
 
 	buttons, err := gtk.GridNew()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	newAddr, err := gtk.ButtonNewWithLabel("New Address")
+	newContact, err := gtk.ButtonNewWithLabel("New Contact")
 	if err != nil {
 		log.Fatal(err)
 	}
-	newAddr.SetSizeRequest(150, -1)
-	newAddr.Connect("clicked", func() {
-		iter := store.Append()
-		store.Set(iter, []int{0, 1}, []interface{}{"", "new address"})
+	newContact.SetSizeRequest(150, -1)
+	newContact.Connect("clicked", func() {
+		iter := store.Append(addrBookWidgets.contacts)
+		store.Set(iter, []int{abColLabel, abColAddress, abColEditable},
+			[]interface{}{"", "", true})
+		tv.ExpandAll()
+		if path, err := store.GetPath(iter); err == nil {
+			tv.SetCursor(path, tv.GetColumn(abColLabel), true)
+		}
 	})
-	buttons.Add(newAddr)
+	buttons.Add(newContact)
+
+	delContact, err := gtk.ButtonNewWithLabel("Delete Contact")
+	if err != nil {
+		log.Fatal(err)
+	}
+	delContact.SetSizeRequest(150, -1)
+	delContact.Connect("clicked", func() {
+		sel, err := tv.GetSelection()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var iter gtk.TreeIter
+		if sel.GetSelected(nil, &iter) && addrBookEditable(&iter) {
+			store.Remove(&iter)
+			saveAddrBookContacts()
+		}
+	})
+	buttons.Add(delContact)
 
 	cpyAddr, err := gtk.ButtonNewWithLabel("Copy Address")
 	if err != nil {
@@ -130,33 +272,33 @@ func createAddrBook() *gtk.Widget {
 		}
 		var iter gtk.TreeIter
 		if sel.GetSelected(nil, &iter) {
-			val, err := store.GetValue(&iter, 1)
-			if err != nil {
-				log.Fatal(err)
-			}
-			display, err := gdk.DisplayGetDefault()
-			if err != nil {
-				log.Fatal(err)
+			s := addrBookString(&iter, abColAddress)
+			if s == "" {
+				return
 			}
+			copyToClipboard(s)
+		}
+	})
+	buttons.Add(cpyAddr)
 
-			clipboard, err := gtk.ClipboardGetForDisplay(
-				display,
-				gdk.SELECTION_CLIPBOARD)
-			if err != nil {
-				log.Fatal(err)
-			}
-			primary, err := gtk.ClipboardGetForDisplay(
-				display,
-				gdk.SELECTION_PRIMARY)
-			if err != nil {
-				log.Fatal(err)
+	showTxs, err := gtk.ButtonNewWithLabel("Show Transactions")
+	if err != nil {
+		log.Fatal(err)
+	}
+	showTxs.SetSizeRequest(150, -1)
+	showTxs.Connect("clicked", func() {
+		sel, err := tv.GetSelection()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var iter gtk.TreeIter
+		if sel.GetSelected(nil, &iter) {
+			if s := addrBookString(&iter, abColAddress); s != "" {
+				showAddressTxs(s)
 			}
-			s, _ := val.GetString()
-			clipboard.SetText(s)
-			primary.SetText(s)
 		}
 	})
-	buttons.Add(cpyAddr)
+	buttons.Add(showTxs)
 	grid.Add(buttons)
 
 	return &grid.Container.Widget
@@ -0,0 +1,170 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcgui/rpc"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+)
+
+// Columns of the address groupings tree store.
+const (
+	agColAddress = iota
+	agColAmount
+	agColAccount
+)
+
+// createAddrGroupsDialog creates a dialog listing the groups of wallet
+// addresses which have been linked on-chain by spending from several of
+// them in a single transaction.  Addresses in different groups have not
+// been publicly linked, so users may pick addresses from a group other
+// than one they wish to keep private.  The groupings are requested from
+// btcwallet and shown once the reply is received.
+func createAddrGroupsDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Address groupings")
+	dialog.SetDefaultSize(600, 400)
+
+	dialog.AddButton("_Close", gtk.RESPONSE_CLOSE)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	l, err := gtk.LabelNew("Addresses in the same group have been linked " +
+		"by transactions spending from more than one of them at " +
+		"once, so anyone can tell they belong to the same owner.")
+	if err != nil {
+		return nil, err
+	}
+	l.SetLineWrap(true)
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Add(l)
+
+	status, err := gtk.LabelNew("Requesting address groupings from btcwallet...")
+	if err != nil {
+		return nil, err
+	}
+	status.SetHAlign(gtk.ALIGN_START)
+	grid.Add(status)
+
+	store, err := gtk.TreeStoreNew(glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_STRING)
+	if err != nil {
+		return nil, err
+	}
+	tv, err := gtk.TreeViewNew()
+	if err != nil {
+		return nil, err
+	}
+	tv.SetModel(store)
+
+	columns := []struct {
+		title string
+		col   int
+	}{
+		{"Address", agColAddress},
+		{"Amount", agColAmount},
+		{"Account", agColAccount},
+	}
+	for _, c := range columns {
+		cr, err := gtk.CellRendererTextNew()
+		if err != nil {
+			return nil, err
+		}
+		col, err := gtk.TreeViewColumnNewWithAttribute(c.title, cr, "text", c.col)
+		if err != nil {
+			return nil, err
+		}
+		if c.col == agColAddress {
+			col.SetExpand(true)
+		}
+		tv.AppendColumn(col)
+	}
+
+	sw, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	sw.Add(tv)
+	sw.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	sw.SetHExpand(true)
+	sw.SetVExpand(true)
+	grid.Add(sw)
+
+	go func() {
+		groups, err := addrGroupings()
+		glib.IdleAdd(func() {
+			if err != nil {
+				status.SetText("Unable to list address groupings: " +
+					err.Error())
+				return
+			}
+			status.SetText(fmt.Sprintf("%d groups", len(groups)))
+			setAddrGroups(store, groups)
+			tv.ExpandAll()
+		})
+	}()
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		dialog.Destroy()
+	})
+
+	return dialog, nil
+}
+
+// setAddrGroups adds a top level row to store for each address grouping,
+// summarizing the total amount of the group, with a child row for each
+// address in the group.
+//
+// This must be run from the GTK main event loop.
+func setAddrGroups(store *gtk.TreeStore, groups [][]rpc.AddressGroupEntry) {
+	for i, group := range groups {
+		var total btcutil.Amount
+		for _, e := range group {
+			total += e.Amount
+		}
+		parent := store.Append(nil)
+		store.Set(parent, []int{agColAddress, agColAmount},
+			[]interface{}{
+				fmt.Sprintf("Group %d (%d addresses)", i+1, len(group)),
+				formatAmount(total),
+			})
+		for _, e := range group {
+			iter := store.Append(parent)
+			store.Set(iter, []int{agColAddress, agColAmount, agColAccount},
+				[]interface{}{e.Address, formatAmount(e.Amount), e.Account})
+		}
+	}
+}
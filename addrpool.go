@@ -0,0 +1,236 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+)
+
+// Default and maximum number of receive addresses generated at once by
+// the pre-generate dialog.
+const (
+	defaultPregenAddrs = 10
+	maxPregenAddrs     = 100
+)
+
+// unusedAddrs holds the receive addresses which have never received a
+// payment.  It is filled from the addresses listed by btcwallet, and
+// kept up to date as payments are received and new addresses generated.
+// This must only be accessed from the GTK main event loop.
+var unusedAddrs = make(map[string]struct{})
+
+// requestUnusedAddrs requests every receive address of the wallet,
+// including those without payments, and returns the addresses of the
+// selected account which have never received any payment, confirmed or
+// not.
+func requestUnusedAddrs() ([]string, error) {
+	b := activeBackend()
+	if b == nil {
+		return nil, ErrConnectionLost
+	}
+
+	entries, err := b.ListReceivedByAddress(0, true)
+	if err != nil {
+		return nil, err
+	}
+	var unused []string
+	for _, m := range entries {
+		addr, _ := m["address"].(string)
+		amount, _ := m["amount"].(float64)
+		account, _ := m["account"].(string)
+		if addr != "" && amount == 0 && account == currentAccount() {
+			unused = append(unused, addr)
+		}
+	}
+	return unused, nil
+}
+
+// refreshUnusedAddrs requests the unused receive addresses and shows
+// how many remain.
+//
+// This is written to be run as a goroutine executing outside of the GTK
+// main event loop.
+func refreshUnusedAddrs() {
+	addrs, err := requestUnusedAddrs()
+	if err != nil {
+		log.Printf("[ERR] cannot list unused addresses: %v", err)
+		return
+	}
+	glib.IdleAdd(func() {
+		unusedAddrs = make(map[string]struct{}, len(addrs))
+		for _, addr := range addrs {
+			unusedAddrs[addr] = struct{}{}
+		}
+		updateUnusedLabel()
+	})
+}
+
+// noteAddrUsed removes addr from the unused receive addresses after it
+// received a payment.
+//
+// This must be run from the GTK main event loop.
+func noteAddrUsed(addr string) {
+	if _, ok := unusedAddrs[addr]; ok {
+		delete(unusedAddrs, addr)
+		updateUnusedLabel()
+	}
+}
+
+// noteAddrUnused adds a newly generated receive address to the unused
+// addresses.
+//
+// This must be run from the GTK main event loop.
+func noteAddrUnused(addr string) {
+	unusedAddrs[addr] = struct{}{}
+	updateUnusedLabel()
+}
+
+// updateUnusedLabel shows the number of unused receive addresses in the
+// receive coins tab.
+//
+// This must be run from the GTK main event loop.
+func updateUnusedLabel() {
+	switch n := len(unusedAddrs); n {
+	case 1:
+		RecvCoins.Unused.SetText("1 unused address")
+	default:
+		RecvCoins.Unused.SetText(fmt.Sprintf("%d unused addresses", n))
+	}
+}
+
+// createPregenDialog creates a dialog asking how many receive addresses
+// to generate ahead of time.  Handing out pre-generated addresses, such
+// as at a point of sale, avoids waiting for btcwallet to generate a new
+// address for every payment.
+func createPregenDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Pre-generate addresses")
+
+	dialog.AddButton("_Generate", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetColumnSpacing(6)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	l, err := gtk.LabelNew("Number of addresses:")
+	if err != nil {
+		return nil, err
+	}
+	grid.Attach(l, 0, 0, 1, 1)
+	count, err := gtk.SpinButtonNewWithRange(1, maxPregenAddrs, 1)
+	if err != nil {
+		return nil, err
+	}
+	count.SetValue(defaultPregenAddrs)
+	count.SetActivatesDefault(true)
+	grid.Attach(count, 1, 0, 1, 1)
+
+	progress, err := gtk.LabelNew("")
+	if err != nil {
+		return nil, err
+	}
+	progress.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(progress, 0, 1, 2, 1)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	// Closing the dialog stops generating further addresses.
+	stop := make(chan struct{})
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		switch rt {
+		case gtk.RESPONSE_OK:
+			grid.SetSensitive(false)
+			dialog.SetResponseSensitive(gtk.RESPONSE_OK, false)
+			go pregenAddrs(dialog, progress, count.GetValueAsInt(), stop)
+
+		default:
+			close(stop)
+			dialog.Destroy()
+		}
+	})
+
+	return dialog, nil
+}
+
+// pregenAddrs generates n new receive addresses one at a time, adding
+// each to the receive coins list as it is received, and closes the
+// dialog once done.  Generation stops at the first error, or when stop
+// is closed because the dialog was closed.
+//
+// This is written to be run as a goroutine executing outside of the GTK
+// main event loop.
+func pregenAddrs(dialog *gtk.Dialog, progress *gtk.Label, n int,
+	stop chan struct{}) {
+
+	closed := func() bool {
+		select {
+		case <-stop:
+			return true
+		default:
+			return false
+		}
+	}
+	for i := 0; i < n && !closed(); i++ {
+		addr, err := newAddress()
+		if err != nil {
+			glib.IdleAdd(func() {
+				if closed() {
+					return
+				}
+				dialog.Destroy()
+				d := errorDialog("Address generation failed",
+					err.Error())
+				d.Run()
+				d.Destroy()
+			})
+			return
+		}
+		done := i + 1
+		glib.IdleAdd(func() {
+			appendRecvAddr(addr, "")
+			noteAddrUnused(addr)
+			if closed() {
+				return
+			}
+			progress.SetText(fmt.Sprintf("Generated %d of %d "+
+				"addresses", done, n))
+		})
+	}
+	glib.IdleAdd(func() {
+		if !closed() {
+			dialog.Destroy()
+		}
+	})
+}
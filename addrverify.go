@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"sort"
+)
+
+// addrVerifyChars is the number of characters at each end of a payment
+// address which must be confirmed before a large amount is sent to it.
+const addrVerifyChars = 5
+
+// verifyLargeSends asks the user to confirm the first and last
+// characters of every address paid at least the amount set by the
+// verifyabove option.  An attacker substituting an address usually only
+// matches a few characters at its ends, so the characters are compared
+// against the address as given by the payee over a different channel.
+// It returns whether every address was confirmed.
+//
+// This must be run from the GTK main event loop.
+func verifyLargeSends(sendTo map[string]float64) bool {
+	if cfg.VerifyAbove <= 0 {
+		return true
+	}
+	addrs := make([]string, 0, len(sendTo))
+	for addr, amt := range sendTo {
+		if amt >= cfg.VerifyAbove {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		if !confirmAddrChars(addr, sendTo[addr]) {
+			return false
+		}
+	}
+	return true
+}
+
+// confirmAddrChars shows the first and last characters of addr
+// prominently and requires them to be typed in before the payment of
+// amt BTC may be sent.  It returns whether the characters were confirmed.
+//
+// This must be run from the GTK main event loop.
+func confirmAddrChars(addr string, amt float64) bool {
+	if len(addr) < 2*addrVerifyChars {
+		return true
+	}
+	head := addr[:addrVerifyChars]
+	tail := addr[len(addr)-addrVerifyChars:]
+	middle := addr[addrVerifyChars : len(addr)-addrVerifyChars]
+
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	defer dialog.Destroy()
+	dialog.SetTitle("Verify payment address")
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	dialog.AddButton("_Confirm", gtk.RESPONSE_OK)
+	dialog.SetResponseSensitive(gtk.RESPONSE_OK, false)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	grid.SetColumnSpacing(6)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	b.Add(grid)
+
+	btc, _ := btcutil.NewAmount(amt)
+	l, err := gtk.LabelNew(fmt.Sprintf("You are about to send %s "+
+		"to the address below.  Compare its highlighted first and "+
+		"last characters with the address as the payee gave it to "+
+		"you over a different channel, such as by phone, and type "+
+		"them in to confirm.", formatAmount(btc)))
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	l.SetLineWrap(true)
+	l.SetWidthChars(60)
+	grid.Attach(l, 0, 0, 2, 1)
+
+	l, err = gtk.LabelNew("")
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	l.SetMarkup(fmt.Sprintf("<span font_family=\"monospace\" "+
+		"size=\"xx-large\"><b><u>%s</u></b>%s<b><u>%s</u></b></span>",
+		head, middle, tail))
+	l.SetSelectable(true)
+	grid.Attach(l, 0, 1, 2, 1)
+
+	entries := make([]*gtk.Entry, 2)
+	want := []string{head, tail}
+	for i, name := range []string{"First", "Last"} {
+		l, err = gtk.LabelNew(fmt.Sprintf("%s %d characters:", name,
+			addrVerifyChars))
+		if err != nil {
+			log.Print(err)
+			return false
+		}
+		l.SetHAlign(gtk.ALIGN_START)
+		grid.Attach(l, 0, i+2, 1, 1)
+		e, err := gtk.EntryNew()
+		if err != nil {
+			log.Print(err)
+			return false
+		}
+		e.SetMaxLength(addrVerifyChars)
+		e.SetWidthChars(addrVerifyChars + 2)
+		e.SetHAlign(gtk.ALIGN_START)
+		e.SetActivatesDefault(true)
+		grid.Attach(e, 1, i+2, 1, 1)
+		entries[i] = e
+	}
+	matches := func() bool {
+		for i, e := range entries {
+			if s, err := e.GetText(); err != nil || s != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+	for _, e := range entries {
+		e.Connect("changed", func() {
+			dialog.SetResponseSensitive(gtk.RESPONSE_OK, matches())
+		})
+	}
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+	return gtk.ResponseType(dialog.Run()) == gtk.RESPONSE_OK && matches()
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcutil"
+	"strconv"
+	"strings"
+)
+
+// defaultDecimals is the default number of decimal places amounts are
+// shown with, the full precision of a satoshi.
+const defaultDecimals = 8
+
+// formatAmount formats a as a BTC amount for showing to users, rounded to
+// the number of decimal places set by the decimals option.  Trailing
+// zeros are trimmed unless the notrimzeros option is set.
+func formatAmount(a btcutil.Amount) string {
+	return formatBTC(a, cfg.Decimals, !cfg.NoTrimZeros) + " BTC"
+}
+
+// formatBTC formats a in BTC with decimals decimal places, rounding half
+// away from zero, and trims trailing zeros and any trailing decimal point
+// if trim is set.  The amount is formatted from the integer number of
+// satoshis, so no precision is lost to floating point.
+func formatBTC(a btcutil.Amount, decimals int, trim bool) string {
+	switch {
+	case decimals < 0:
+		decimals = 0
+	case decimals > defaultDecimals:
+		decimals = defaultDecimals
+	}
+	var unit, scale int64 = 1, 1
+	for i := decimals; i < defaultDecimals; i++ {
+		unit *= 10
+	}
+	for i := 0; i < decimals; i++ {
+		scale *= 10
+	}
+
+	n := int64(a)
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	n = (n + unit/2) / unit
+	if n == 0 {
+		sign = ""
+	}
+
+	s := sign + strconv.FormatInt(n/scale, 10)
+	if decimals == 0 {
+		return s
+	}
+	frac := fmt.Sprintf("%0*d", decimals, n%scale)
+	if trim {
+		frac = strings.TrimRight(frac, "0")
+	}
+	if frac == "" {
+		return s
+	}
+	return s + "." + frac
+}
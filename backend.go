@@ -0,0 +1,175 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/btcgui/rpc"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcutil"
+)
+
+// WalletBackend is the wallet shown and controlled by the GUI.  Each
+// request blocks until the wallet replies.  Requests for an account take
+// its name, where the default account is named by the empty string.  It
+// is implemented by an rpc.Client session with btcwallet, and by
+// mockBackend, which serves canned state so the update logic can be run
+// without a live btcwallet.
+type WalletBackend interface {
+	// GetBalance returns the balance of account with at least one
+	// confirmation.
+	GetBalance(account string) (btcutil.Amount, error)
+
+	// GetUnconfirmedBalance returns the balance of unconfirmed
+	// transactions of account.
+	GetUnconfirmedBalance(account string) (btcutil.Amount, error)
+
+	// GetBlockCount returns the height of the best chain.
+	GetBlockCount() (int32, error)
+
+	// GetInfo returns the state of btcwallet and the chain as the JSON
+	// object of a getinfo reply.
+	GetInfo() (map[string]interface{}, error)
+
+	// GetWalletInfo returns the state of the wallet as the JSON object
+	// of a getwalletinfo reply.
+	GetWalletInfo() (map[string]interface{}, error)
+
+	// ListAccounts returns the balance of every account, keyed by
+	// account name.
+	ListAccounts() (map[string]btcutil.Amount, error)
+
+	// ListTransactions returns every transaction of account as the
+	// JSON objects of a listalltransactions reply.
+	ListTransactions(account string) ([]map[string]interface{}, error)
+
+	// SendMany creates and sends a transaction from account paying each
+	// address of pairs the amount in bitcoin it maps to, and returns
+	// its ID.
+	SendMany(account string, pairs map[string]float64, comment string) (string, error)
+
+	// GetNewAddress returns a new payment address of account.
+	GetNewAddress(account string) (string, error)
+
+	// CreateEncryptedWallet creates a new wallet encrypted with
+	// passphrase.
+	CreateEncryptedWallet(passphrase string) error
+
+	// GetAddressesByAccount returns every payment address of account.
+	GetAddressesByAccount(account string) ([]string, error)
+
+	// ListLockUnspent returns the outpoints of every unspent output
+	// locked against spending.
+	ListLockUnspent() ([]rpc.OutPoint, error)
+
+	// GetTxOutValue returns the value of an unspent transaction
+	// output, or zero if it was spent.
+	GetTxOutValue(txid string, vout uint32) (btcutil.Amount, error)
+
+	// WalletIsLocked returns whether the wallet is locked.
+	WalletIsLocked() (bool, error)
+
+	// WalletLock locks the wallet.
+	WalletLock() error
+
+	// WalletPassphrase unlocks the wallet with passphrase for timeout
+	// seconds.
+	WalletPassphrase(passphrase string, timeout int64) error
+
+	// SetTxFee sets the fee per kilobyte added to new transactions.
+	SetTxFee(fee btcutil.Amount) error
+
+	// ValidateAddress returns the details of addr as the JSON object
+	// of a validateaddress reply.
+	ValidateAddress(addr string) (map[string]interface{}, error)
+
+	// ListAddressGroupings returns the groups of wallet addresses
+	// whose common ownership has been made public.
+	ListAddressGroupings() ([][]rpc.AddressGroupEntry, error)
+
+	// ImportPrivKey imports a WIF-encoded private key with label,
+	// rescanning the blockchain if rescan is set.
+	ImportPrivKey(key, label string, rescan bool) error
+
+	// ImportAddress imports an address to be watched with label,
+	// rescanning the blockchain if rescan is set.
+	ImportAddress(addr, label string, rescan bool) error
+
+	// ExportWalletSeed returns the seed of the opened wallet, which must
+	// be unlocked.
+	ExportWalletSeed() (string, error)
+
+	// RecoverWallet creates the wallet from seed, encrypted with
+	// passphrase.
+	RecoverWallet(seed, passphrase string) error
+
+	// GetTransaction returns the details of the wallet transaction
+	// txid as the JSON object of a gettransaction reply.
+	GetTransaction(txid string) (map[string]interface{}, error)
+
+	// ListReceivedByAddress returns the amount received by each
+	// address in transactions with at least minConf confirmations, as
+	// the JSON objects of a listreceivedbyaddress reply.  Addresses
+	// which never received a payment are only included if includeEmpty
+	// is set.
+	ListReceivedByAddress(minConf int, includeEmpty bool) ([]map[string]interface{}, error)
+
+	// GetRawMempool returns every transaction in the mempool as the
+	// JSON object of a verbose getrawmempool reply.
+	GetRawMempool() (map[string]interface{}, error)
+
+	// GetBlock returns the block with hash as the JSON object of a
+	// getblock reply.
+	GetBlock(hash string) (map[string]interface{}, error)
+
+	// GetBestBlockHash returns the hash of the best block.
+	GetBestBlockHash() (string, error)
+
+	// ListSinceBlock returns every transaction in blocks after the
+	// block with hash, or unconfirmed, as the JSON objects of a
+	// listsinceblock reply, along with the hash of the best block.
+	ListSinceBlock(hash string) ([]map[string]interface{}, string, error)
+
+	// SignRawTransaction signs the inputs of the hex-encoded raw
+	// transaction tx the wallet holds keys for, and returns the signed
+	// transaction and whether every input is now signed.
+	SignRawTransaction(tx string) (string, bool, error)
+
+	// SendRawTransaction sends the signed hex-encoded raw transaction tx
+	// to the network and returns its ID.
+	SendRawTransaction(tx string) (string, error)
+
+	// Notifications returns the channel notifications are received
+	// from, in the order they were sent.
+	Notifications() <-chan btcjson.Cmd
+
+	// Done returns a channel which is closed once the wallet is no
+	// longer connected.
+	Done() <-chan struct{}
+
+	// Go runs f in a new goroutine which is waited for before the
+	// session is shut down, so that f never outlives the connection.
+	Go(f func())
+}
+
+// activeBackend returns the active session as a WalletBackend, or nil if
+// not connected.
+func activeBackend() WalletBackend {
+	if c := activeClient(); c != nil {
+		return c
+	}
+	return nil
+}
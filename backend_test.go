@@ -0,0 +1,342 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"github.com/conformal/btcutil"
+	"testing"
+	"time"
+)
+
+// testTimeout is the longest time tests wait for the GUI to be updated.
+const testTimeout = 5 * time.Second
+
+// runAsync runs f in a new goroutine and returns a channel closed once f
+// returns.  Fetching wallet state blocks on publishing the results, so
+// the results are read while f runs.
+func runAsync(f func()) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	return done
+}
+
+// waitDone fails the test if done is not closed in time.
+func waitDone(t *testing.T, done <-chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for request to finish")
+	}
+}
+
+// testEvents receives every wallet event published by the tested
+// functions.  All tests share one subscription, as publishing blocks
+// until each subscriber has received the event.
+var testEvents = walletEvents.Subscribe(AddressesChanged{},
+	BalanceChanged{}, BlockHeightChanged{}, LockStateChanged{},
+	TxAppended{}, TxMined{})
+
+// recvEvent returns the next published event, failing the test if none
+// is published in time.
+func recvEvent(t *testing.T, name string) Event {
+	select {
+	case e := <-testEvents:
+		return e
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for %s", name)
+		return nil
+	}
+}
+
+// recvTx returns the next published event, failing the test unless it
+// is a TxAppended event.
+func recvTx(t *testing.T, name string) TxAppended {
+	ev, ok := recvEvent(t, name).(TxAppended)
+	if !ok {
+		t.Fatalf("got %T, want %s", ev, name)
+	}
+	return ev
+}
+
+// recvAmount returns the amount of the next published event, failing the
+// test unless it is a BalanceChanged event of kind.
+func recvAmount(t *testing.T, kind BalanceKind, name string) btcutil.Amount {
+	ev, ok := recvEvent(t, name).(BalanceChanged)
+	if !ok || ev.Kind != kind {
+		t.Fatalf("got %#v, want %s", ev, name)
+	}
+	return ev.Amount
+}
+
+// mockTx returns a transaction of a listalltransactions reply.
+func mockTx(txid, category string, amount float64, confs float64) map[string]interface{} {
+	return map[string]interface{}{
+		"txid":          txid,
+		"category":      category,
+		"address":       "addr" + txid,
+		"amount":        amount,
+		"confirmations": confs,
+		"timereceived":  float64(1400000000),
+	}
+}
+
+func TestFetchBalances(t *testing.T) {
+	b := newMockBackend()
+	b.balance = 150000000
+	b.unconfirmed = 25000000
+	b.height = 300000
+
+	done := runAsync(func() { fetchBalance(b) })
+	if bal := recvAmount(t, BalanceConfirmed, "balance"); bal != b.balance {
+		t.Errorf("got balance %v, want %v", bal, b.balance)
+	}
+	waitDone(t, done)
+
+	done = runAsync(func() { fetchUnconfirmedBalance(b) })
+	bal := recvAmount(t, BalanceUnconfirmed, "unconfirmed balance")
+	if bal != b.unconfirmed {
+		t.Errorf("got unconfirmed balance %v, want %v", bal,
+			b.unconfirmed)
+	}
+	waitDone(t, done)
+
+	done = runAsync(func() { fetchBlockCount(b) })
+	ev, ok := recvEvent(t, "block height").(BlockHeightChanged)
+	if !ok || ev.Height != b.height {
+		t.Errorf("got %#v, want height %v", ev, b.height)
+	}
+	waitDone(t, done)
+}
+
+func TestFetchErrors(t *testing.T) {
+	b := newMockBackend()
+	b.err = errors.New("wallet error")
+
+	// Failed requests must not update the GUI, so each fetch returns
+	// without publishing anything.
+	fetches := []func(WalletBackend){
+		fetchBalance,
+		fetchUnconfirmedBalance,
+		fetchBlockCount,
+		fetchLockedBalance,
+		fetchLockState,
+	}
+	for _, f := range fetches {
+		f := f
+		waitDone(t, runAsync(func() { f(b) }))
+	}
+}
+
+func TestFetchLockedBalance(t *testing.T) {
+	b := newMockBackend()
+	b.lockedOutputs = []mockOutput{
+		{txid: "a", vout: 0, value: 1000},
+		{txid: "b", vout: 2, value: 500},
+	}
+
+	done := runAsync(func() { fetchLockedBalance(b) })
+	if locked := recvAmount(t, BalanceLocked, "locked balance"); locked != 1500 {
+		t.Errorf("got locked balance %v, want 1500", locked)
+	}
+	waitDone(t, done)
+}
+
+func TestFetchTxFee(t *testing.T) {
+	b := newMockBackend()
+	b.txFee = 10000
+	fetchTxFee(b)
+	feePerKB, ok := currentTxFeeRate()
+	if !ok {
+		t.Fatal("transaction fee not recorded")
+	}
+	if feePerKB != 10000 {
+		t.Errorf("got fee %v per kilobyte, want 10000", feePerKB)
+	}
+}
+
+func TestFetchTransactions(t *testing.T) {
+	resetSeenTxs()
+	b := newMockBackend()
+	b.txs = []map[string]interface{}{
+		mockTx("b", "receive", 2, 1),
+		mockTx("coinbase", "immature", 50, 10),
+		mockTx("a", "send", -1, 0),
+	}
+
+	// Loading the transaction history appends transactions in the
+	// order listed, newest first.
+	done := runAsync(func() { fetchTransactions(b, false) })
+	for _, txid := range []string{"b", "a"} {
+		ev := recvTx(t, "appended transaction")
+		if ev.Tx.TxID != txid || ev.New || !ev.Overview {
+			t.Errorf("got %v (new %v, overview %v), want %v "+
+				"appended to overview", ev.Tx.TxID, ev.New,
+				ev.Overview, txid)
+		}
+	}
+	if immature := recvAmount(t, BalanceImmature, "immature balance"); immature != 5000000000 {
+		t.Errorf("got immature balance %v, want 50 BTC", immature)
+	}
+	waitDone(t, done)
+
+	// Polling prepends transactions not yet shown, oldest first, and
+	// updates those since mined.
+	b.txs = []map[string]interface{}{
+		mockTx("d", "receive", 4, 0),
+		mockTx("c", "receive", 3, 0),
+		mockTx("b", "receive", 2, 2),
+		mockTx("a", "send", -1, 1),
+	}
+	done = runAsync(func() { fetchTransactions(b, true) })
+	mined, ok := recvEvent(t, "mined transaction").(TxMined)
+	if !ok || mined.Tx.TxID != "a" || mined.Tx.Pending() {
+		t.Fatalf("got %#v, want mined transaction a", mined)
+	}
+	for _, txid := range []string{"c", "d"} {
+		ev := recvTx(t, "prepended transaction")
+		if ev.Tx.TxID != txid || !ev.New || !ev.Overview {
+			t.Errorf("got %v (new %v, overview %v), want new %v",
+				ev.Tx.TxID, ev.New, ev.Overview, txid)
+		}
+	}
+	if immature := recvAmount(t, BalanceImmature, "immature balance"); immature != 0 {
+		t.Errorf("got immature balance %v, want 0", immature)
+	}
+	waitDone(t, done)
+}
+
+func TestReconcileSinceBlock(t *testing.T) {
+	resetSeenTxs()
+	resetSyncBlock()
+	b := newMockBackend()
+	b.height = 10
+	b.txs = []map[string]interface{}{
+		mockTx("a", "send", -1, 0),
+	}
+
+	// Nothing is reconciled on the first connection, only the best
+	// block recorded.
+	waitDone(t, runAsync(func() { reconcileSinceBlock(b) }))
+	done := runAsync(func() { fetchTransactions(b, false) })
+	recvTx(t, "appended transaction")
+	recvAmount(t, BalanceImmature, "immature balance")
+	waitDone(t, done)
+
+	// After reconnecting, missing transactions are shown as new, and
+	// those since mined are updated.
+	b.height = 12
+	b.txs = []map[string]interface{}{
+		mockTx("b", "receive", 2, 0),
+		mockTx("a", "send", -1, 2),
+	}
+	done = runAsync(func() { reconcileSinceBlock(b) })
+	if ev := recvTx(t, "missing transaction"); ev.Tx.TxID != "b" || !ev.New {
+		t.Errorf("got %v (new %v), want new b", ev.Tx.TxID, ev.New)
+	}
+	mined, ok := recvEvent(t, "mined transaction").(TxMined)
+	if !ok || mined.Tx.TxID != "a" {
+		t.Errorf("got %#v, want mined transaction a", mined)
+	}
+	waitDone(t, done)
+
+	syncBlock.Lock()
+	hash := syncBlock.hash
+	syncBlock.Unlock()
+	if hash != "block12" {
+		t.Errorf("synchronized to %q, want block12", hash)
+	}
+}
+
+func TestSendMany(t *testing.T) {
+	cfg = &config{}
+	b := newMockBackend()
+	params := &SendParams{
+		pairs:   map[string]float64{"addr": 1.5},
+		comment: "rent",
+	}
+	if err := sendMany(b, params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(b.sent) != 1 || b.sent[0]["addr"] != 1.5 {
+		t.Errorf("got sent transactions %v, want one paying 1.5 BTC",
+			b.sent)
+	}
+
+	cfg.WatchOnly = true
+	defer func() { cfg.WatchOnly = false }()
+	if err := sendMany(b, params); err != ErrWatchOnly {
+		t.Errorf("got error %v, want %v", err, ErrWatchOnly)
+	}
+	if len(b.sent) != 1 {
+		t.Errorf("sent a transaction in watch-only mode")
+	}
+}
+
+func TestImportKeys(t *testing.T) {
+	cfg = &config{}
+	b := newMockBackend()
+	params := &ImportParams{
+		entries: []importEntry{
+			{key: "watched", label: "a"},
+			{key: "key", label: "b", isKey: true},
+		},
+		results: make(chan error, 2),
+	}
+
+	done := runAsync(func() { importKeys(b, params) })
+	ev, ok := recvEvent(t, "addresses").(AddressesChanged)
+	if !ok || len(ev.Addrs) != 1 || ev.Addrs[0] != "watched" {
+		t.Errorf("got %#v, want addresses [watched]", ev)
+	}
+	waitDone(t, done)
+	for i := range params.entries {
+		if err := <-params.results; err != nil {
+			t.Errorf("entry %d: unexpected error: %v", i, err)
+		}
+	}
+	if len(b.imported) != 2 {
+		t.Errorf("got imported %v, want both entries", b.imported)
+	}
+}
+
+func TestExportSeed(t *testing.T) {
+	b := newMockBackend()
+	b.passphrase = "pass"
+	if _, err := exportSeed(b, "pass"); err != ErrSeedUnsupported {
+		t.Errorf("got error %v, want ErrSeedUnsupported", err)
+	}
+
+	b.seed = "seed words"
+	b.locked = true
+	if _, err := exportSeed(b, "wrong"); err == nil {
+		t.Error("seed exported with a wrong passphrase")
+	}
+	seed, err := exportSeed(b, "pass")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if seed != "seed words" {
+		t.Errorf("got seed %q, want %q", seed, "seed words")
+	}
+	if !b.locked {
+		t.Error("wallet left unlocked after exporting the seed")
+	}
+}
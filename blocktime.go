@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"github.com/conformal/gotk3/glib"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// syncBehindAge is how old the newest connected block must be for
+	// btcd to be considered catching up with the block chain.
+	syncBehindAge = 2 * time.Hour
+
+	// blockTimeInterval is the shortest time between requests for the
+	// timestamp of newly connected blocks, so a sync connecting many
+	// blocks a second is not slowed down by them.
+	blockTimeInterval = time.Second
+)
+
+// latestBlock is the most recently connected block, and whether its
+// timestamp is being requested.
+var latestBlock struct {
+	sync.Mutex
+	hash     string
+	fetching bool
+}
+
+// noteBlockConnected records the hash of a newly connected block, and
+// starts requesting block timestamps if not already doing so.
+func noteBlockConnected(hash string) {
+	latestBlock.Lock()
+	latestBlock.hash = hash
+	start := !latestBlock.fetching
+	latestBlock.fetching = true
+	latestBlock.Unlock()
+
+	if start {
+		go fetchBlockTimes()
+	}
+}
+
+// fetchBlockTimes requests the timestamp of the most recently connected
+// block, showing it while btcd is catching up, until no newer block has
+// been connected.
+func fetchBlockTimes() {
+	var done string
+	for {
+		latestBlock.Lock()
+		hash := latestBlock.hash
+		if hash == done {
+			latestBlock.fetching = false
+			latestBlock.Unlock()
+			return
+		}
+		latestBlock.Unlock()
+
+		t, err := requestBlockTime(hash)
+		if err != nil {
+			log.Printf("[ERR] cannot get block time: %v", err)
+			latestBlock.Lock()
+			latestBlock.fetching = false
+			latestBlock.Unlock()
+			return
+		}
+		done = hash
+		glib.IdleAdd(func() {
+			showBlockTime(t)
+		})
+		time.Sleep(blockTimeInterval)
+	}
+}
+
+// requestBlockTime requests the block with hash from btcd, passed
+// through by btcwallet, and returns its timestamp.
+func requestBlockTime(hash string) (time.Time, error) {
+	b := activeBackend()
+	if b == nil {
+		return time.Time{}, ErrConnectionLost
+	}
+
+	block, err := b.GetBlock(hash)
+	if err != nil {
+		return time.Time{}, err
+	}
+	secs, ok := block["time"].(float64)
+	if !ok {
+		return time.Time{}, errors.New("getblock reply has no time")
+	}
+	return time.Unix(int64(secs), 0), nil
+}
+
+// showBlockTime shows the timestamp of the newest block beside the
+// progress bar while btcd is catching up, so users can see how far
+// behind it is.  The progress bar is hidden once the block is recent.
+//
+// This must be run from the GTK main event loop.
+func showBlockTime(t time.Time) {
+	if time.Since(t) < syncBehindAge {
+		StatusElems.Pb.Hide()
+		return
+	}
+	StatusElems.Pb.SetText("Processing blocks from " +
+		t.Format("2006-01-02 15:04") + "...")
+	StatusElems.Pb.Pulse()
+	StatusElems.Pb.Show()
+}
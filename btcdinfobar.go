@@ -0,0 +1,226 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"time"
+)
+
+// Responses of the infobar shown while btcwallet is disconnected from
+// btcd.
+const (
+	btcdRetryResponse    gtk.ResponseType = 1
+	btcdDiagnoseResponse gtk.ResponseType = 2
+)
+
+// Polling of getinfo by the Diagnose action of the btcd infobar.
+const (
+	btcdDiagnoseAttempts = 10
+	btcdDiagnoseInterval = 3 * time.Second
+)
+
+// btcdDownCauses explains the likely causes of btcwallet losing its
+// connection to btcd.
+const btcdDownCauses = "btcwallet lost its connection to btcd, so " +
+	"transactions can not be sent.  Likely causes are:\n" +
+	"  • btcd was stopped, or is still starting up\n" +
+	"  • btcwallet is configured with the wrong btcd address, " +
+	"credentials, or certificate\n" +
+	"  • the network between btcwallet and btcd is down"
+
+// btcdInfoBar holds the widgets of the infobar shown while btcwallet is
+// disconnected from btcd.
+var btcdInfoBar struct {
+	bar      *gtk.InfoBar
+	causes   *gtk.Label
+	progress *gtk.Label
+
+	// checking is set while getinfo is polled.  This must only be
+	// accessed from the GTK main event loop.
+	checking bool
+}
+
+// createBtcdInfoBar creates the infobar explaining why btcwallet may be
+// disconnected from btcd, with actions to check the connection again.
+// It is hidden until btcwallet reports that btcd is disconnected.
+func createBtcdInfoBar() *gtk.Widget {
+	bar, err := gtk.InfoBarNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	bar.SetMessageType(gtk.MESSAGE_WARNING)
+	bar.SetNoShowAll(true)
+	btcdInfoBar.bar = bar
+
+	content, err := bar.GetContentArea()
+	if err != nil {
+		log.Fatal(err)
+	}
+	box, err := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 3)
+	if err != nil {
+		log.Fatal(err)
+	}
+	content.Add(box)
+
+	causes, err := gtk.LabelNew(btcdDownCauses)
+	if err != nil {
+		log.Fatal(err)
+	}
+	causes.SetHAlign(gtk.ALIGN_START)
+	causes.SetLineWrap(true)
+	box.PackStart(causes, false, false, 0)
+	btcdInfoBar.causes = causes
+
+	progress, err := gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	progress.SetHAlign(gtk.ALIGN_START)
+	progress.SetNoShowAll(true)
+	box.PackStart(progress, false, false, 0)
+	btcdInfoBar.progress = progress
+	box.ShowAll()
+
+	b, err := bar.AddButton("_Retry", btcdRetryResponse)
+	if err != nil {
+		log.Fatal(err)
+	}
+	b.SetTooltipText("Check once whether btcd can be reached")
+	b, err = bar.AddButton("_Diagnose", btcdDiagnoseResponse)
+	if err != nil {
+		log.Fatal(err)
+	}
+	b.SetTooltipText("Keep checking whether btcd can be reached, " +
+		"showing the progress")
+
+	bar.Connect("response", func(_ *gtk.InfoBar, rt gtk.ResponseType) {
+		switch rt {
+		case btcdRetryResponse:
+			checkBtcd(1)
+		case btcdDiagnoseResponse:
+			checkBtcd(btcdDiagnoseAttempts)
+		}
+	})
+
+	return &bar.Box.Container.Widget
+}
+
+// showBtcdInfoBar shows the infobar if btcwallet is disconnected from
+// btcd, or hides it once connected.
+//
+// This must be run from the GTK main event loop.
+func showBtcdInfoBar(disconnected bool) {
+	if !disconnected {
+		btcdInfoBar.bar.Hide()
+		return
+	}
+	if !btcdInfoBar.bar.GetVisible() {
+		btcdInfoBar.progress.SetText("")
+		btcdInfoBar.progress.Hide()
+	}
+	btcdInfoBar.bar.Show()
+}
+
+// setBtcdProgress shows text as the progress of checking btcd.
+//
+// This must be run from the GTK main event loop.
+func setBtcdProgress(text string) {
+	btcdInfoBar.progress.SetText(text)
+	btcdInfoBar.progress.Show()
+}
+
+// checkBtcd requests getinfo from btcwallet up to attempts times, which
+// only succeeds if btcwallet can reach btcd, reporting the progress in
+// the infobar.  Once btcd is reached, btcd is reported as connected.
+//
+// This must be run from the GTK main event loop.
+func checkBtcd(attempts int) {
+	if btcdInfoBar.checking {
+		return
+	}
+	btcdInfoBar.checking = true
+	btcdInfoBar.bar.SetResponseSensitive(btcdRetryResponse, false)
+	btcdInfoBar.bar.SetResponseSensitive(btcdDiagnoseResponse, false)
+	setBtcdProgress("Checking whether btcd can be reached...")
+
+	go func() {
+		msg := pollBtcd(attempts)
+		glib.IdleAdd(func() {
+			btcdInfoBar.checking = false
+			btcdInfoBar.bar.SetResponseSensitive(btcdRetryResponse, true)
+			btcdInfoBar.bar.SetResponseSensitive(btcdDiagnoseResponse, true)
+			setBtcdProgress(msg)
+		})
+	}()
+}
+
+// pollBtcd requests getinfo up to attempts times, waiting
+// btcdDiagnoseInterval between attempts, and returns a message
+// describing the final result.  Each failed attempt is shown as the
+// progress of the infobar.
+//
+// This is written to be run outside of the GTK main event loop.
+func pollBtcd(attempts int) string {
+	for i := 1; ; i++ {
+		b := activeBackend()
+		if b == nil {
+			return "Not connected to btcwallet."
+		}
+		info, err := b.GetInfo()
+		if err == nil {
+			height, _ := info["blocks"].(float64)
+			peers, _ := info["connections"].(float64)
+			walletEvents.Publish(ConnStateChanged{
+				Btcd:      true,
+				Connected: true,
+			})
+			return fmt.Sprintf("btcd is reachable at height %.0f "+
+				"with %.0f peers.", height, peers)
+		}
+
+		reason := err.Error()
+		if jsonErr, ok := err.(*btcjson.Error); ok &&
+			jsonErr.Code == btcjson.ErrClientNotConnected.Code {
+			reason = "btcwallet is not connected to btcd"
+		}
+		if i >= attempts {
+			if attempts == 1 {
+				return "btcd can not be reached: " + reason + "."
+			}
+			return fmt.Sprintf("btcd could not be reached after %d "+
+				"attempts: %s.  Check that btcd is running and "+
+				"that btcwallet's btcd settings are correct.",
+				attempts, reason)
+		}
+
+		msg := fmt.Sprintf("Attempt %d of %d failed: %s.  Trying "+
+			"again...", i, attempts, reason)
+		glib.IdleAdd(func() {
+			setBtcdProgress(msg)
+		})
+		select {
+		case <-time.After(btcdDiagnoseInterval):
+		case <-b.Done():
+			return "Lost the connection to btcwallet."
+		}
+	}
+}
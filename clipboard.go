@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/gdk"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+)
+
+// copyToClipboard copies s to the selections chosen by the clipboard
+// option: the clipboard, the primary selection, or both.
+//
+// This must be run from the GTK main event loop.
+func copyToClipboard(s string) {
+	display, err := gdk.DisplayGetDefault()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.Clipboard != "primary" {
+		clipboard, err := gtk.ClipboardGetForDisplay(
+			display,
+			gdk.SELECTION_CLIPBOARD)
+		if err != nil {
+			log.Fatal(err)
+		}
+		clipboard.SetText(s)
+	}
+
+	if cfg.Clipboard != "clipboard" {
+		primary, err := gtk.ClipboardGetForDisplay(
+			display,
+			gdk.SELECTION_PRIMARY)
+		if err != nil {
+			log.Fatal(err)
+		}
+		primary.SetText(s)
+	}
+}
@@ -0,0 +1,316 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/gotk3/gtk"
+	"strconv"
+	"strings"
+)
+
+// configEditSettings is the response of the configuration problems
+// dialog to open the settings dialog.
+const configEditSettings gtk.ResponseType = 1
+
+// settingsEntries describes the text options which may be changed with
+// the settings dialog.  Hidden options are passwords, which are not shown
+// as they are typed.
+var settingsEntries = []struct {
+	long   string
+	label  string
+	hidden bool
+	field  func(*config) *string
+}{
+	{"rpcconnect", "btcwallet server:", false, func(c *config) *string { return &c.RPCConnect }},
+	{"username", "Username:", false, func(c *config) *string { return &c.Username }},
+	{"password", "Password:", true, func(c *config) *string { return &c.Password }},
+	{"cafile", "CA file:", false, func(c *config) *string { return &c.CAFile }},
+	{"proxy", "SOCKS5 proxy:", false, func(c *config) *string { return &c.Proxy }},
+	{"proxyuser", "Proxy username:", false, func(c *config) *string { return &c.ProxyUser }},
+	{"proxypass", "Proxy password:", true, func(c *config) *string { return &c.ProxyPass }},
+	{"tutorialdir", "Tutorial directory:", false, func(c *config) *string { return &c.TutorialDir }},
+}
+
+// settingsNetworks holds the option and name of each network which may be
+// chosen with the settings dialog.  Testnet has no option as it is used
+// when neither mainnet nor simnet is set.
+var settingsNetworks = []struct {
+	long string
+	name string
+}{
+	{"", "Testnet"},
+	{"mainnet", "Mainnet"},
+	{"simnet", "Simnet"},
+}
+
+// fixConfig shows every problem in errs found validating cfg, and lets
+// the user change the settings of cfg until none remain.  This returns
+// false if the user gives up instead.
+//
+// This must be run after GTK is initialized, but may be run before the
+// main event loop is started.
+func fixConfig(cfg *config, errs configErrors) bool {
+	for len(errs) != 0 {
+		dialog, err := createConfigErrorsDialog(errs)
+		if err != nil {
+			return false
+		}
+		response := gtk.ResponseType(dialog.Run())
+		dialog.Destroy()
+		if response != configEditSettings {
+			return false
+		}
+
+		dialog, err = createSettingsDialog(cfg)
+		if err != nil {
+			return false
+		}
+		dialog.Run()
+		dialog.Destroy()
+		errs = finishConfig(cfg)
+	}
+	return true
+}
+
+// createConfigErrorsDialog creates a dialog listing every problem in errs
+// with a button to open the settings dialog.
+func createConfigErrorsDialog(errs configErrors) (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Configuration problems")
+	dialog.SetPosition(gtk.WIN_POS_CENTER)
+
+	dialog.AddButton("_Edit Settings...", configEditSettings)
+	dialog.AddButton("_Quit", gtk.RESPONSE_CANCEL)
+	dialog.SetDefaultResponse(configEditSettings)
+
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = "• " + err.Error()
+	}
+	l, err := gtk.LabelNew(fmt.Sprintf("btcgui can not start until the "+
+		"following problems with the configuration are fixed:\n\n%s",
+		strings.Join(lines, "\n")))
+	if err != nil {
+		return nil, err
+	}
+	l.SetLineWrap(true)
+	l.SetSelectable(true)
+	l.SetHAlign(gtk.ALIGN_START)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(l)
+
+	dialog.ShowAll()
+	return dialog, nil
+}
+
+// createSettingsDialog creates a dialog to change the network, connection,
+// and interface settings of cfg.  When accepted, cfg is updated and the
+// settings are optionally saved to the config file.
+func createSettingsDialog(cfg *config) (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Settings")
+	dialog.SetPosition(gtk.WIN_POS_CENTER)
+
+	dialog.AddButton("_OK", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetColumnSpacing(6)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	row := 0
+	addRow := func(label string, w gtk.IWidget) error {
+		l, err := gtk.LabelNew(label)
+		if err != nil {
+			return err
+		}
+		l.SetHAlign(gtk.ALIGN_START)
+		grid.Attach(l, 0, row, 1, 1)
+		grid.Attach(w, 1, row, 1, 1)
+		row++
+		return nil
+	}
+
+	network, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range settingsNetworks {
+		network.AppendText(n.name)
+	}
+	switch {
+	case cfg.MainNet:
+		network.SetActive(1)
+	case cfg.SimNet:
+		network.SetActive(2)
+	default:
+		network.SetActive(0)
+	}
+	if err := addRow("Network:", network); err != nil {
+		return nil, err
+	}
+
+	entries := make([]*gtk.Entry, len(settingsEntries))
+	for i, s := range settingsEntries {
+		e, err := gtk.EntryNew()
+		if err != nil {
+			return nil, err
+		}
+		e.SetText(*s.field(cfg))
+		e.SetVisibility(!s.hidden)
+		e.SetWidthChars(40)
+		e.SetActivatesDefault(true)
+		if err := addRow(s.label, e); err != nil {
+			return nil, err
+		}
+		entries[i] = e
+	}
+
+	clipboard, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range []string{"clipboard", "primary", "both"} {
+		clipboard.Append(c, c)
+	}
+	if !clipboard.SetActiveID(cfg.Clipboard) {
+		clipboard.SetActiveID(defaultClipboard)
+	}
+	if err := addRow("Copy to:", clipboard); err != nil {
+		return nil, err
+	}
+
+	idle, err := gtk.SpinButtonNewWithRange(0, 24*60, 1)
+	if err != nil {
+		return nil, err
+	}
+	idle.SetValue(float64(cfg.SessionIdle))
+	idle.SetTooltipText("Minutes without activity before a session " +
+		"unlock ends, or zero to never end it when idle")
+	if err := addRow("Session idle minutes:", idle); err != nil {
+		return nil, err
+	}
+
+	decimals, err := gtk.SpinButtonNewWithRange(0, defaultDecimals, 1)
+	if err != nil {
+		return nil, err
+	}
+	decimals.SetValue(float64(cfg.Decimals))
+	decimals.SetTooltipText("Decimal places amounts are rounded to " +
+		"when shown")
+	if err := addRow("Amount decimals:", decimals); err != nil {
+		return nil, err
+	}
+
+	trimZeros, err := gtk.CheckButtonNewWithLabel("Trim trailing zeros " +
+		"from amounts")
+	if err != nil {
+		return nil, err
+	}
+	trimZeros.SetActive(!cfg.NoTrimZeros)
+	grid.Attach(trimZeros, 0, row, 2, 1)
+	row++
+
+	save, err := gtk.CheckButtonNewWithLabel("Save to " + cfg.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	save.SetActive(true)
+	grid.Attach(save, 0, row, 2, 1)
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		if rt != gtk.RESPONSE_OK {
+			return
+		}
+
+		net := settingsNetworks[network.GetActive()].long
+		cfg.MainNet = net == "mainnet"
+		cfg.SimNet = net == "simnet"
+		opts := []configOption{
+			{"mainnet", boolOption(cfg.MainNet)},
+			{"simnet", boolOption(cfg.SimNet)},
+		}
+		for i, s := range settingsEntries {
+			text, _ := entries[i].GetText()
+			*s.field(cfg) = text
+			opts = append(opts, configOption{s.long, text})
+		}
+
+		// The username and password entries replace any rpcuser and
+		// rpcpass options, so they can not disagree.
+		cfg.RPCUser = ""
+		cfg.RPCPass = ""
+		opts = append(opts, configOption{"rpcuser", ""},
+			configOption{"rpcpass", ""})
+
+		cfg.Clipboard = clipboard.GetActiveID()
+		cfg.SessionIdle = idle.GetValueAsInt()
+		opts = append(opts, configOption{"clipboard", cfg.Clipboard},
+			configOption{"sessionidle", strconv.Itoa(cfg.SessionIdle)})
+
+		// Amounts already shown are formatted again by requesting
+		// the wallet state anew.
+		newDecimals := decimals.GetValueAsInt()
+		noTrimZeros := !trimZeros.GetActive()
+		if newDecimals != cfg.Decimals || noTrimZeros != cfg.NoTrimZeros {
+			cfg.Decimals = newDecimals
+			cfg.NoTrimZeros = noTrimZeros
+			reloadWalletState()
+		}
+		opts = append(opts,
+			configOption{"decimals", strconv.Itoa(cfg.Decimals)},
+			configOption{"notrimzeros", boolOption(cfg.NoTrimZeros)})
+
+		if save.GetActive() {
+			if err := saveConfigOptions(cfg.ConfigFile, opts); err != nil {
+				d := errorDialog("Cannot save settings", err.Error())
+				d.Run()
+				d.Destroy()
+			}
+		}
+	})
+
+	dialog.ShowAll()
+	return dialog, nil
+}
+
+// boolOption returns the config file value of a boolean option.
+func boolOption(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"time"
+)
+
+// createConnDetailsDialog creates a dialog showing details of the
+// connection to btcwallet.  The details are refreshed every second while
+// the dialog is open.
+func createConnDetailsDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Connection details")
+
+	dialog.AddButton("_Close", gtk.RESPONSE_CLOSE)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetHExpand(true)
+	grid.SetVExpand(true)
+	grid.SetColumnSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	names := []string{
+		"Endpoint:",
+		"Transport:",
+		"Proxy:",
+		"Certificate:",
+		"Certificate expires:",
+		"Connected for:",
+		"Last error:",
+		"Requests sent:",
+		"Replies received:",
+		"Requests timed out:",
+		"Notifications received:",
+		"Average latency:",
+		"Recent error rate:",
+	}
+	values := make([]*gtk.Label, len(names))
+	for i, name := range names {
+		values[i], err = addDetailsRow(grid, i, name, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	update := func() {
+		for i, s := range connDetailsText(connDetails()) {
+			values[i].SetText(s)
+		}
+	}
+	update()
+
+	quit := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				glib.IdleAdd(update)
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		close(quit)
+		dialog.Destroy()
+	})
+
+	return dialog, nil
+}
+
+// connDetailsText formats connection details as the values shown by each
+// row of the connection details dialog.
+func connDetailsText(info connInfo) []string {
+	const layout = "Jan 2, 2006 at 3:04 PM"
+
+	proxy := info.proxy
+	switch {
+	case proxy == "":
+		proxy = "None"
+	case cfg.NoProxyDNS:
+		proxy += " (local DNS)"
+	default:
+		proxy += " (DNS through proxy)"
+	}
+	cert, expires := "Unknown", "Unknown"
+	if info.certSubject != "" {
+		cert = info.certSubject
+	}
+	if !info.certExpiry.IsZero() {
+		expires = info.certExpiry.Format(layout)
+		if info.certExpiry.Before(time.Now()) {
+			expires += " (expired)"
+		}
+	}
+	uptime := "Not connected"
+	if !info.connected.IsZero() {
+		d := time.Since(info.connected)
+		uptime = (d - d%time.Second).String()
+	}
+	lastErr := "None"
+	if info.lastErr != "" {
+		lastErr = fmt.Sprintf("%s (%s)", info.lastErr,
+			info.lastErrTime.Format(layout))
+	}
+	transport := info.transport
+	if transport == "" {
+		transport = "Unknown"
+	}
+	return []string{
+		info.endpoint,
+		transport,
+		proxy,
+		cert,
+		expires,
+		uptime,
+		lastErr,
+		fmt.Sprintf("%d", info.requests),
+		fmt.Sprintf("%d", info.replies),
+		fmt.Sprintf("%d", info.timeouts),
+		fmt.Sprintf("%d", info.notifications),
+		(info.avgRTT - info.avgRTT%time.Millisecond).String(),
+		fmt.Sprintf("%.0f%%", info.errorRate()*100),
+	}
+}
@@ -0,0 +1,293 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"github.com/conformal/btcgui/rpc"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/go-socks"
+	"github.com/conformal/gotk3/glib"
+	"net"
+	"sync"
+	"time"
+)
+
+// clientHooks connect the connection statistics, the restrictions of a
+// monitoring connection, and retrying requests after unlocking the
+// wallet to every session with btcwallet.
+var clientHooks = rpc.Hooks{
+	CheckRequest: checkMonitoring,
+	Sent:         noteRequest,
+	Replied:      noteReply,
+	Notified:     noteNotification,
+	TimedOut:     noteTimeout,
+	Refused:      retryAfterUnlock,
+}
+
+// activeConn is the currently active session, or nil if not connected.
+var activeConn struct {
+	sync.Mutex
+	c *rpc.Client
+}
+
+// newSession creates a session for an established connection, makes it
+// the active session, and starts its notifier, which holds notifications
+// until synced is closed.  At most one session is ever active, and each
+// is shut down with endSession before a new one is created.
+func newSession(ws rpc.Transport, synced <-chan struct{}) *rpc.Client {
+	c := rpc.New(ws, clientHooks)
+	activeConn.Lock()
+	activeConn.c = c
+	activeConn.Unlock()
+	c.Go(func() {
+		notifier(c, synced)
+	})
+	return c
+}
+
+// endSession shuts down the session c, failing every request still
+// waiting for a reply, and waits for all goroutines of the session to
+// exit.
+func endSession(c *rpc.Client) {
+	activeConn.Lock()
+	if activeConn.c == c {
+		activeConn.c = nil
+	}
+	activeConn.Unlock()
+	c.Shutdown()
+}
+
+// activeClient returns the active session, or nil if not connected or
+// the session is being closed.
+func activeClient() *rpc.Client {
+	activeConn.Lock()
+	c := activeConn.c
+	activeConn.Unlock()
+	if c == nil {
+		return nil
+	}
+	select {
+	case <-c.Done():
+		return nil
+	default:
+		return c
+	}
+}
+
+// requestWalletState requests all wallet state shown by the GUI from b.
+// The requests are always sent in the order of walletReqFuncs so the
+// state is filled in the same way on every connection.
+func requestWalletState(b WalletBackend) {
+	for _, f := range walletReqFuncs {
+		f(b)
+	}
+}
+
+// reloadWalletState clears all wallet state shown by the GUI and
+// requests it again over the active session, if connected.
+func reloadWalletState() {
+	b := activeBackend()
+	if b == nil {
+		return
+	}
+	b.Go(func() {
+		resetWalletState()
+		requestWalletState(b)
+	})
+}
+
+// resetWalletState clears all wallet state shown by the GUI so it can be
+// requested again after reconnecting, without showing transactions
+// twice.  It returns after the GUI has been reset.
+//
+// This is written to be run outside of the GTK main event loop.
+func resetWalletState() {
+	done := make(chan struct{})
+	glib.IdleAdd(func() {
+		defer close(done)
+
+		txWidgets.store.Clear()
+		txWidgets.attrs = nil
+		txWidgets.pending = make(map[string]struct{})
+		txWidgets.fees = make(map[string]btcutil.Amount)
+		txWidgets.feeTotal = 0
+		txWidgets.totalFees.SetText("")
+		txConfsHeight = -1
+		updatePendingCount()
+		scheduleActivityUpdate()
+
+		for _, w := range Overview.TxList {
+			Overview.Txs.Remove(w)
+			w.Destroy()
+		}
+		Overview.TxList = Overview.TxList[:0]
+
+		recvAddrUsage = make(map[string]*addrUsage)
+	})
+	<-done
+	resetSeenTxs()
+}
+
+// resolveAddr returns addr with its host resolved to an IP address using
+// the local resolver.
+func resolveAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(host) != nil {
+		return addr, nil
+	}
+	ips, err := net.LookupHost(host)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ips[0], port), nil
+}
+
+// walletTLSConfig returns the TLS configuration verifying the certificate
+// of btcwallet with the PEM-encoded CA certificates.
+func walletTLSConfig(certificates []byte) *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certificates)
+	return &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// walletAuth returns the Authorization header of requests to btcwallet.
+// btcwallet requires basic authorization.
+func walletAuth() string {
+	login := cfg.Username + ":" + cfg.Password
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+}
+
+// walletDialer returns the host to connect to btcwallet at addr, and the
+// function to dial it with, or nil to dial directly.  A unix domain
+// socket is dialed directly, and the returned host is only used to verify
+// the certificate, which must be valid for localhost.  Otherwise,
+// connections are made through the proxy option if set.
+func walletDialer(addr string) (string, func(network, addr string) (net.Conn, error)) {
+	if path, ok := unixSocketPath(addr); ok {
+		return "localhost", func(string, string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		}
+	}
+	if cfg.Proxy == "" {
+		return addr, nil
+	}
+
+	proxy := &socks.Proxy{
+		Addr:     cfg.Proxy,
+		Username: cfg.ProxyUser,
+		Password: cfg.ProxyPass,
+	}
+
+	// The btcwallet hostname is resolved by the proxy unless disabled
+	// for proxies unable to resolve names, so no DNS queries for it are
+	// made outside the proxy.
+	if cfg.NoProxyDNS {
+		return addr, func(network, addr string) (net.Conn, error) {
+			addr, err := resolveAddr(addr)
+			if err != nil {
+				return nil, err
+			}
+			return proxy.Dial(network, addr)
+		}
+	}
+	return addr, proxy.Dial
+}
+
+// ListenAndUpdate opens a websocket connection to a btcwallet
+// instance, or falls back to HTTP POST requests if the websocket can not
+// be opened, and initiates requests to fill the GUI with relevant
+// information.
+func ListenAndUpdate(certificates []byte, c chan error) {
+	// Start each updater of the GUI.  Use a sync.Once to ensure there
+	// are no duplicate updaters running.
+	updateOnce.Do(startUpdaters)
+
+	// Connect to the wallet selected for sending.
+	addr := selectedWallet().addr
+	host, dial := walletDialer(addr)
+	wc, err := rpc.Connect(&rpc.Endpoint{
+		Host:         host,
+		TLSConfig:    walletTLSConfig(certificates),
+		Dial:         dial,
+		Auth:         walletAuth(),
+		CheckRequest: checkMonitoring,
+	})
+	if err != nil {
+		noteConnError(err)
+		c <- ErrConnectionRefused
+		return
+	}
+	noteConnected(addr, wc.Kind, wc.State)
+	c <- nil
+	noteWalletAddr(addr)
+
+	// All goroutines for this connection are run by the session, which
+	// is shut down before returning so no goroutine outlives it.
+	// Notifications are held until the wallet state is resynchronized.
+	synced := make(chan struct{})
+	conn := newSession(wc.Transport, synced)
+	defer endSession(conn)
+
+	// Any state shown from a previous connection is replaced by the
+	// state requested again.  State kept about btcwallet itself is
+	// only forgotten if btcwallet was restarted since then.
+	conn.Go(func() {
+		if detectWalletRestart(conn) {
+			resetSessionState()
+		}
+		resyncWalletState(conn)
+		close(synced)
+		checkWalletHealth(conn)
+	})
+	if wc.Polling {
+		conn.Go(func() {
+			pollWalletState(conn)
+		})
+	}
+
+	// Replies and notifications are handled until the btcwallet
+	// connection is lost.
+	noteConnError(conn.Serve())
+	c <- ErrConnectionLost
+}
+
+// pollWalletState periodically requests the wallet state which may have
+// changed until the session c is closed.  This replaces notifications
+// when connected with HTTP POST requests.
+func pollWalletState(c *rpc.Client) {
+	ticker := time.NewTicker(httpPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, f := range pollReqFuncs {
+				f(c)
+			}
+		case <-c.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"crypto/tls"
+	"sync"
+	"time"
+)
+
+// connInfo describes the connection to btcwallet, as shown by the
+// connection details dialog.
+type connInfo struct {
+	endpoint string
+	proxy    string
+
+	// transport describes how requests are sent, either over a
+	// websocket or with HTTP POST requests while polling for changes.
+	transport string
+
+	// certSubject and certExpiry describe the TLS certificate presented
+	// by btcwallet.
+	certSubject string
+	certExpiry  time.Time
+
+	// connected is the time the current connection was established, or
+	// the zero time if not connected.
+	connected time.Time
+
+	lastErr     string
+	lastErrTime time.Time
+
+	requests      uint64
+	replies       uint64
+	notifications uint64
+
+	// timeouts counts requests never answered by btcwallet.
+	timeouts uint64
+
+	// connects counts every connection established since btcgui was
+	// started, including reconnects.
+	connects uint64
+
+	// avgRTT is a moving average of the round-trip time of requests,
+	// and recentErrs holds whether each of the most recent replies was
+	// an error, oldest first.
+	avgRTT     time.Duration
+	recentErrs []bool
+}
+
+// Thresholds above which the connection is reported as unhealthy.
+const (
+	slowRTT         = 2 * time.Second
+	highErrorRate   = 0.25
+	healthWindow    = 20
+	rttSmoothFactor = 8
+)
+
+// connHealth describes the responsiveness of the connection.
+type connHealth int
+
+const (
+	healthUnknown connHealth = iota
+	healthGood
+	healthSlow
+	healthErrors
+)
+
+// health returns the health of the connection based on the recent
+// round-trip times and error rate of requests.
+func (c *connInfo) health() connHealth {
+	if c.connected.IsZero() || len(c.recentErrs) == 0 {
+		return healthUnknown
+	}
+	if c.errorRate() > highErrorRate {
+		return healthErrors
+	}
+	if c.avgRTT > slowRTT {
+		return healthSlow
+	}
+	return healthGood
+}
+
+// errorRate returns the fraction of recent replies which were errors.
+func (c *connInfo) errorRate() float64 {
+	if len(c.recentErrs) == 0 {
+		return 0
+	}
+	n := 0
+	for _, e := range c.recentErrs {
+		if e {
+			n++
+		}
+	}
+	return float64(n) / float64(len(c.recentErrs))
+}
+
+// connStats holds details and counters of the connection to btcwallet.
+// sent maps the IDs of requests awaiting a reply to the time they were
+// written.
+var connStats = struct {
+	sync.Mutex
+	connInfo
+	sent map[uint64]time.Time
+}{
+	sent: make(map[uint64]time.Time),
+}
+
+// connDetails returns a copy of the current connection details.
+func connDetails() connInfo {
+	connStats.Lock()
+	defer connStats.Unlock()
+	info := connStats.connInfo
+	info.recentErrs = append([]bool(nil), info.recentErrs...)
+	return info
+}
+
+// noteConnected records a newly established connection to endpoint using
+// transport, with the TLS connection state state, or nil if unknown.
+func noteConnected(endpoint, transport string, state *tls.ConnectionState) {
+	connStats.Lock()
+	defer connStats.Unlock()
+	connStats.endpoint = endpoint
+	connStats.proxy = cfg.Proxy
+	connStats.transport = transport
+	connStats.connected = time.Now()
+	connStats.connects++
+	connStats.avgRTT = 0
+	connStats.recentErrs = nil
+	connStats.sent = make(map[uint64]time.Time)
+	connStats.certSubject = ""
+	connStats.certExpiry = time.Time{}
+	if state != nil && len(state.PeerCertificates) != 0 {
+		cert := state.PeerCertificates[0]
+		connStats.certSubject = cert.Subject.CommonName
+		connStats.certExpiry = cert.NotAfter
+	}
+}
+
+// noteConnError records an error connecting to btcwallet or an error
+// ending the connection.
+func noteConnError(err error) {
+	connStats.Lock()
+	defer connStats.Unlock()
+	connStats.connected = time.Time{}
+	connStats.lastErr = err.Error()
+	connStats.lastErrTime = time.Now()
+}
+
+// noteRequest counts a request written to btcwallet, recording the time
+// it was sent to measure the round-trip time once replied to.
+func noteRequest(id uint64) {
+	connStats.Lock()
+	connStats.requests++
+	connStats.sent[id] = time.Now()
+	connStats.Unlock()
+}
+
+// noteReply counts a reply received from btcwallet, updating the
+// average round-trip time and recent error rate.
+func noteReply(id uint64, failed bool) {
+	connStats.Lock()
+	defer connStats.Unlock()
+	connStats.replies++
+
+	sent, ok := connStats.sent[id]
+	if !ok {
+		return
+	}
+	delete(connStats.sent, id)
+	rtt := time.Since(sent)
+	if connStats.avgRTT == 0 {
+		connStats.avgRTT = rtt
+	} else {
+		connStats.avgRTT += (rtt - connStats.avgRTT) / rttSmoothFactor
+	}
+	connStats.recentErrs = append(connStats.recentErrs, failed)
+	if len(connStats.recentErrs) > healthWindow {
+		connStats.recentErrs = connStats.recentErrs[1:]
+	}
+}
+
+// noteTimeout counts a request to btcwallet which was not answered in
+// time as an error of the connection.
+func noteTimeout(id uint64) {
+	connStats.Lock()
+	defer connStats.Unlock()
+	connStats.timeouts++
+	delete(connStats.sent, id)
+	connStats.recentErrs = append(connStats.recentErrs, true)
+	if len(connStats.recentErrs) > healthWindow {
+		connStats.recentErrs = connStats.recentErrs[1:]
+	}
+}
+
+// noteNotification counts a notification received from btcwallet.
+func noteNotification() {
+	connStats.Lock()
+	connStats.notifications++
+	connStats.Unlock()
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"code.google.com/p/go.crypto/scrypt"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// Files encrypted by btcgui begin with cryptMagic, followed by the
+// scrypt salt used to derive the key from the passphrase, and the
+// AES-256-GCM nonce and sealed data.  The header is authenticated as
+// additional data.
+var cryptMagic = []byte("btcguienc1")
+
+// Parameters for scrypt key derivation.
+const (
+	cryptSaltSize = 32
+	cryptKeySize  = 32
+	scryptN       = 16384
+	scryptR       = 8
+	scryptP       = 1
+)
+
+// ErrBadPassphrase describes the error returned when encrypted data can
+// not be opened with the provided passphrase, or was modified.
+var ErrBadPassphrase = errors.New("incorrect passphrase or corrupted data")
+
+// cryptKey is an encryption key derived from a passphrase.  Deriving the
+// key is intentionally slow, so it is kept to encrypt data many times.
+type cryptKey struct {
+	salt []byte
+	aead cipher.AEAD
+}
+
+// deriveCryptKey derives a key from passphrase using scrypt.  If salt is
+// nil, a new random salt is created.
+func deriveCryptKey(passphrase, salt []byte) (*cryptKey, error) {
+	if salt == nil {
+		salt = make([]byte, cryptSaltSize)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return nil, err
+		}
+	}
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP,
+		cryptKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cryptKey{salt: salt, aead: aead}, nil
+}
+
+// header returns the header of data encrypted with k.
+func (k *cryptKey) header() []byte {
+	h := make([]byte, 0, len(cryptMagic)+len(k.salt))
+	h = append(h, cryptMagic...)
+	return append(h, k.salt...)
+}
+
+// Seal encrypts and authenticates plaintext, returning the encrypted
+// data along with the header needed to derive the key again.
+func (k *cryptKey) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	header := k.header()
+	out := append(header, nonce...)
+	return k.aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// isEncrypted returns whether data was encrypted by btcgui.
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, cryptMagic)
+}
+
+// decryptData opens data encrypted with a key derived from passphrase.
+// The derived key is returned so the data may be encrypted again without
+// repeating the key derivation.
+func decryptData(passphrase, data []byte) ([]byte, *cryptKey, error) {
+	if !isEncrypted(data) || len(data) < len(cryptMagic)+cryptSaltSize {
+		return nil, nil, ErrBadPassphrase
+	}
+	salt := data[len(cryptMagic) : len(cryptMagic)+cryptSaltSize]
+	k, err := deriveCryptKey(passphrase, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := data[:len(cryptMagic)+cryptSaltSize]
+	rest := data[len(header):]
+	if len(rest) < k.aead.NonceSize() {
+		return nil, nil, ErrBadPassphrase
+	}
+	nonce := rest[:k.aead.NonceSize()]
+	plaintext, err := k.aead.Open(nil, nonce, rest[len(nonce):], header)
+	if err != nil {
+		return nil, nil, ErrBadPassphrase
+	}
+	return plaintext, k, nil
+}
+
+// encryptData encrypts plaintext with a key derived from passphrase
+// using a new random salt.
+func encryptData(passphrase, plaintext []byte) ([]byte, error) {
+	k, err := deriveCryptKey(passphrase, nil)
+	if err != nil {
+		return nil, err
+	}
+	return k.Seal(plaintext)
+}
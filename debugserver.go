@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+)
+
+// startDebugServer serves the Go runtime profiles registered by
+// net/http/pprof, and the metrics published with expvar at /debug/vars,
+// on the localhost port set by the pprof option.  It is meant for
+// developers capturing CPU and heap profiles, e.g. when the GUI lags with
+// a large wallet, and is never reachable from other hosts.
+func startDebugServer() {
+	if cfg.Pprof == "" {
+		return
+	}
+	addr := net.JoinHostPort("localhost", cfg.Pprof)
+	log.Printf("[INF] serving profiles on http://%s/debug/pprof/", addr)
+	go func() {
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			log.Printf("[ERR] cannot serve profiles: %v", err)
+		}
+	}()
+}
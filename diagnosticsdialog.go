@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/gdk"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"strconv"
+)
+
+// diagnosticsRefreshResponse is the response of the diagnostics dialog
+// button collecting the metrics again, which does not close the dialog.
+const diagnosticsRefreshResponse gtk.ResponseType = 1
+
+// keyD is the key value of the D key, which opens the diagnostics dialog
+// with Ctrl+Shift.
+const keyD = 0x044
+
+// diagnosticsShortcut opens the diagnostics dialog on a Ctrl+Shift+D key
+// press, returning whether the key press was handled.  The dialog is not
+// listed in any menu, as it is only meant to help debug connection
+// problems.
+//
+// This must be run from the GTK main event loop.
+func diagnosticsShortcut(ev *gdk.EventKey) bool {
+	const mask = gdk.CONTROL_MASK | gdk.SHIFT_MASK
+	if gdk.ModifierType(ev.State())&mask != mask || ev.KeyVal() != keyD {
+		return false
+	}
+	dialog, err := createDiagnosticsDialog()
+	if err != nil {
+		log.Print(err)
+		return true
+	}
+	dialog.Run()
+	return true
+}
+
+// createDiagnosticsDialog creates a dialog listing the counters of the
+// connection to btcwallet and the depths of the internal queues.
+func createDiagnosticsDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Diagnostics")
+	dialog.SetDefaultSize(300, 300)
+
+	dialog.AddButton("_Refresh", diagnosticsRefreshResponse)
+	dialog.AddButton("_Close", gtk.RESPONSE_CLOSE)
+
+	store, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		return nil, err
+	}
+	fill := func() {
+		store.Clear()
+		for _, m := range collectMetrics() {
+			store.Set(store.Append(), []int{0, 1},
+				[]interface{}{m.name, strconv.FormatInt(m.value, 10)})
+		}
+	}
+	fill()
+
+	tv, err := gtk.TreeViewNewWithModel(store)
+	if err != nil {
+		return nil, err
+	}
+	for i, title := range []string{"Metric", "Value"} {
+		cr, err := gtk.CellRendererTextNew()
+		if err != nil {
+			return nil, err
+		}
+		col, err := gtk.TreeViewColumnNewWithAttribute(title, cr, "text", i)
+		if err != nil {
+			return nil, err
+		}
+		tv.AppendColumn(col)
+	}
+
+	sw, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	sw.Add(tv)
+	sw.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	sw.SetHExpand(true)
+	sw.SetVExpand(true)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(sw)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		switch rt {
+		case diagnosticsRefreshResponse:
+			fill()
+		default:
+			dialog.Destroy()
+		}
+	})
+
+	return dialog, nil
+}
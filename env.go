@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix is prepended to the upper cased long name of an option to
+// form the name of the environment variable overriding it, for example
+// BTCGUI_RPCCONNECT for the rpcconnect option.
+const envPrefix = "BTCGUI_"
+
+// envVarName returns the environment variable overriding the option with
+// the long name long.
+func envVarName(long string) string {
+	return envPrefix + strings.ToUpper(long)
+}
+
+// applyEnvOverrides sets every option of cfg for which a non-empty
+// environment variable is set.  Boolean options accept any value parsed
+// by strconv.ParseBool, such as 1 or false.  The version option can only
+// be set on the command line.
+func applyEnvOverrides(cfg *config) error {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		long := t.Field(i).Tag.Get("long")
+		if long == "" || long == "version" {
+			continue
+		}
+		name := envVarName(long)
+		s := os.Getenv(name)
+		if s == "" {
+			continue
+		}
+
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(s)
+
+		case reflect.Bool:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("%s: '%s' is not a boolean", name, s)
+			}
+			field.SetBool(b)
+
+		case reflect.Int:
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return fmt.Errorf("%s: '%s' is not a number", name, s)
+			}
+			field.SetInt(int64(n))
+		}
+	}
+	return nil
+}
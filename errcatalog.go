@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"github.com/conformal/btcjson"
+)
+
+// walletErrorMessages maps the error codes of btcwallet replies to
+// messages explaining the problem and what can be done about it.
+var walletErrorMessages = map[int]string{
+	btcjson.ErrWalletInsufficientFunds.Code: "The wallet does not " +
+		"have enough spendable funds for this transaction and its " +
+		"fee.  Received coins can only be spent once confirmed.",
+	btcjson.ErrInvalidAddressOrKey.Code: "The address or key is " +
+		"not valid.  Check that it was copied completely and is for " +
+		"the right bitcoin network.",
+	btcjson.ErrWalletUnlockNeeded.Code: "The wallet is locked.  " +
+		"Unlock it from the Settings menu and try again.",
+	btcjson.ErrWalletKeypoolRanOut.Code: "The wallet has no unused " +
+		"addresses left.  Unlock the wallet so new addresses can be " +
+		"generated, and try again.",
+	btcjson.ErrWalletPassphraseIncorrect.Code: "The passphrase is " +
+		"not correct.  Check that Caps Lock is off and try again.",
+	btcjson.ErrWalletAlreadyUnlocked.Code: "The wallet is already " +
+		"unlocked.",
+	btcjson.ErrWalletWrongEncState.Code: "This can not be done " +
+		"while the wallet is encrypted this way.",
+	btcjson.ErrWalletInvalidAccountName.Code: "The wallet or account " +
+		"does not exist.",
+	btcjson.ErrClientNotConnected.Code: "btcwallet is not connected " +
+		"to btcd.  Check that btcd is running and try again.",
+	btcjson.ErrClientInInitialDownload.Code: "btcd is still " +
+		"downloading the block chain.  Try again once it is in sync.",
+	btcjson.ErrInvalidParameter.Code: "btcwallet refused a value " +
+		"of the request.  Check the amounts and addresses entered.",
+	btcjson.ErrMethodNotFound.Code: "This version of btcwallet " +
+		"does not support the request.  Upgrading btcwallet may " +
+		"help.",
+}
+
+// walletErrorMessage returns a message describing err for showing to
+// users.  Errors returned by btcwallet are explained by their error
+// code, followed by the message of btcwallet itself, rather than only
+// showing the code.
+func walletErrorMessage(err error) string {
+	jsonErr, ok := err.(*btcjson.Error)
+	if !ok {
+		return err.Error()
+	}
+	msg, ok := walletErrorMessages[jsonErr.Code]
+	if !ok {
+		return jsonErr.Message
+	}
+	return msg + "\n\nbtcwallet: " + jsonErr.Message
+}
+
+// walletError returns err with the message of a btcwallet reply error
+// explained for showing to users.  Any other error, including nil, is
+// returned unchanged.
+func walletError(err error) error {
+	if _, ok := err.(*btcjson.Error); !ok {
+		return err
+	}
+	return errors.New(walletErrorMessage(err))
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/btcutil"
+	"reflect"
+	"sync"
+)
+
+// Event is a change of wallet or connection state published on an
+// EventBus.  Each kind of event is its own type.
+type Event interface{}
+
+// BalanceKind describes which balance of the selected account changed.
+type BalanceKind int
+
+// Balances of the selected account.
+const (
+	BalanceConfirmed BalanceKind = iota
+	BalanceUnconfirmed
+	BalanceImmature
+	BalanceLocked
+)
+
+// BalanceChanged is published when a balance of the selected account is
+// received.  BalanceLocked is the total of all unspent outputs locked
+// against spending.
+type BalanceChanged struct {
+	Kind   BalanceKind
+	Amount btcutil.Amount
+}
+
+// TxAppended is published for each transaction of the selected account
+// to be shown.  New is set for transactions first seen by a notification,
+// which are shown before all others, rather than listed from the wallet
+// history.  Overview is set if the transaction is also one of the most
+// recent shown in the Overview.
+type TxAppended struct {
+	Tx       *TxAttributes
+	New      bool
+	Overview bool
+}
+
+// TxMined is published when a transaction already shown is reported
+// again after being mined, so its confirmations are updated.
+type TxMined struct {
+	Tx *TxAttributes
+}
+
+// LockStateChanged is published when the lock state of the wallet is
+// received.
+type LockStateChanged struct {
+	Locked bool
+}
+
+// ConnStateChanged is published when the connection to btcwallet is
+// established or lost, or when btcwallet reports that its connection to
+// btcd changed, in which case Btcd is set.
+type ConnStateChanged struct {
+	Btcd      bool
+	Connected bool
+}
+
+// AddressesChanged is published with all addresses of the selected
+// account.
+type AddressesChanged struct {
+	Addrs []string
+}
+
+// BlockHeightChanged is published with the height of the best chain.
+type BlockHeightChanged struct {
+	Height int32
+}
+
+// EventBus delivers each published event to every subscriber of its type.
+// Events are delivered in the order they were published, and publishing
+// blocks until every subscriber has received the event, so subscribers
+// are never far behind the state they show.
+type EventBus struct {
+	mu   sync.RWMutex
+	subs map[reflect.Type][]chan Event
+}
+
+// NewEventBus returns a new EventBus without subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[reflect.Type][]chan Event)}
+}
+
+// Subscribe returns a channel receiving every event published with the
+// same type as any of the passed events, such as BalanceChanged{} to
+// receive each BalanceChanged event.  Events published before subscribing
+// are not received.
+func (b *EventBus) Subscribe(events ...Event) <-chan Event {
+	c := make(chan Event)
+	b.mu.Lock()
+	for _, e := range events {
+		t := reflect.TypeOf(e)
+		b.subs[t] = append(b.subs[t], c)
+	}
+	b.mu.Unlock()
+	return c
+}
+
+// Publish delivers e to each subscriber of its type, waiting until every
+// subscriber has received it.  Events without subscribers are dropped.
+func (b *EventBus) Publish(e Event) {
+	b.mu.RLock()
+	subs := b.subs[reflect.TypeOf(e)]
+	b.mu.RUnlock()
+	for _, c := range subs {
+		c <- e
+	}
+}
+
+// walletEvents is the event bus of all wallet and connection state shown
+// by the GUI.
+var walletEvents = NewEventBus()
@@ -0,0 +1,286 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/conformal/btcutil"
+	"io"
+	"strings"
+	"time"
+)
+
+type exportFormat int
+
+// Supported formats for exporting transaction history.
+const (
+	exportCSV exportFormat = iota
+	exportJSON
+	exportQIF
+	exportOFX
+)
+
+// exportFormats holds the name and default file extension of each
+// export format, indexed by the format.
+var exportFormats = []struct {
+	name string
+	ext  string
+}{
+	exportCSV:  {"CSV (spreadsheet)", "csv"},
+	exportJSON: {"JSON", "json"},
+	exportQIF:  {"QIF (Quicken Interchange Format)", "qif"},
+	exportOFX:  {"OFX (Open Financial Exchange)", "ofx"},
+}
+
+// exportOptions holds the per-format options used when exporting
+// transaction history.  Options for formats other than the one being
+// exported are ignored.
+type exportOptions struct {
+	// CSVHeader specifies whether a header row naming each column is
+	// written before any transactions.
+	CSVHeader bool
+
+	// JSONIndent specifies whether JSON output is indented to be
+	// human readable.
+	JSONIndent bool
+
+	// QIFAccountType is the QIF account type header, such as "Bank"
+	// or "Cash".
+	QIFAccountType string
+
+	// OFXAccountID identifies the account the statement belongs to
+	// when imported into accounting software.
+	OFXAccountID string
+
+	// Passphrase, if non-empty, is used to encrypt the exported file.
+	Passphrase string
+}
+
+// defaultExportOptions returns the export options selected by default.
+func defaultExportOptions() *exportOptions {
+	return &exportOptions{
+		CSVHeader:      true,
+		JSONIndent:     true,
+		QIFAccountType: "Bank",
+		OFXAccountID:   "btcgui",
+	}
+}
+
+// btcString formats an amount as a decimal number of bitcoins without any
+// unit suffix, as expected by the export formats.
+func btcString(a btcutil.Amount) string {
+	return fmt.Sprintf("%.8f", a.ToUnit(btcutil.AmountBitcoin))
+}
+
+// exportMemo returns the memo of an exported transaction for formats
+// which only support a single memo field.  The wallet comment is used
+// when one was recorded, otherwise the txid identifies the transaction.
+func exportMemo(attr *TxAttributes) string {
+	if attr.Comment != "" {
+		return attr.Comment
+	}
+	return attr.TxID
+}
+
+// exportTxs writes all transactions in attrs to w using the specified
+// format and options.
+func exportTxs(w io.Writer, format exportFormat, attrs []*TxAttributes,
+	opts *exportOptions) error {
+
+	switch format {
+	case exportCSV:
+		return exportTxsCSV(w, attrs, opts)
+	case exportJSON:
+		return exportTxsJSON(w, attrs, opts)
+	case exportQIF:
+		return exportTxsQIF(w, attrs, opts)
+	case exportOFX:
+		return exportTxsOFX(w, attrs, opts)
+	default:
+		return fmt.Errorf("unknown export format %d", format)
+	}
+}
+
+func exportTxsCSV(w io.Writer, attrs []*TxAttributes, opts *exportOptions) error {
+	cw := csv.NewWriter(w)
+	if opts.CSVHeader {
+		err := cw.Write([]string{"Date", "Type", "Address", "Amount",
+			"Fee", "Transaction ID", "Confirmations", "Comment",
+			"Tags"})
+		if err != nil {
+			return err
+		}
+	}
+	for _, attr := range attrs {
+		err := cw.Write([]string{
+			attr.Date.Format(time.RFC3339),
+			attr.Direction.String(),
+			attr.Address,
+			btcString(attr.Amount),
+			btcString(attr.Fee),
+			attr.TxID,
+			fmt.Sprintf("%d", attr.Confirmations),
+			attr.Comment,
+			strings.Join(attr.Tags(), ", "),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// jsonTx is the JSON representation of an exported transaction.
+type jsonTx struct {
+	Date          time.Time `json:"date"`
+	Type          string    `json:"type"`
+	Address       string    `json:"address"`
+	Amount        float64   `json:"amount"`
+	Fee           float64   `json:"fee"`
+	TxID          string    `json:"txid"`
+	Confirmations int64     `json:"confirmations"`
+	Comment       string    `json:"comment,omitempty"`
+	Tags          []string  `json:"tags,omitempty"`
+}
+
+func exportTxsJSON(w io.Writer, attrs []*TxAttributes, opts *exportOptions) error {
+	txs := make([]jsonTx, 0, len(attrs))
+	for _, attr := range attrs {
+		txs = append(txs, jsonTx{
+			Date:          attr.Date,
+			Type:          attr.Direction.String(),
+			Address:       attr.Address,
+			Amount:        attr.Amount.ToUnit(btcutil.AmountBitcoin),
+			Fee:           attr.Fee.ToUnit(btcutil.AmountBitcoin),
+			TxID:          attr.TxID,
+			Confirmations: attr.Confirmations,
+			Comment:       attr.Comment,
+			Tags:          attr.Tags(),
+		})
+	}
+
+	var b []byte
+	var err error
+	if opts.JSONIndent {
+		b, err = json.MarshalIndent(txs, "", "\t")
+	} else {
+		b, err = json.Marshal(txs)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+func exportTxsQIF(w io.Writer, attrs []*TxAttributes, opts *exportOptions) error {
+	if _, err := fmt.Fprintf(w, "!Type:%s\n", opts.QIFAccountType); err != nil {
+		return err
+	}
+	for _, attr := range attrs {
+		_, err := fmt.Fprintf(w, "D%s\nT%s\nP%s\nM%s\n^\n",
+			attr.Date.Format("01/02/2006"),
+			btcString(attr.Amount),
+			attr.Address,
+			exportMemo(attr))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ofxEscape escapes characters which may not appear in OFX element
+// values.
+var ofxEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func exportTxsOFX(w io.Writer, attrs []*TxAttributes, opts *exportOptions) error {
+	const dateLayout = "20060102150405"
+
+	start, end := time.Now(), time.Now()
+	for _, attr := range attrs {
+		if attr.Date.Before(start) {
+			start = attr.Date
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "OFXHEADER:100\n"+
+		"DATA:OFXSGML\n"+
+		"VERSION:102\n"+
+		"SECURITY:NONE\n"+
+		"ENCODING:USASCII\n"+
+		"CHARSET:1252\n"+
+		"COMPRESSION:NONE\n"+
+		"OLDFILEUID:NONE\n"+
+		"NEWFILEUID:NONE\n"+
+		"\n"+
+		"<OFX>\n"+
+		"<BANKMSGSRSV1>\n"+
+		"<STMTTRNRS>\n"+
+		"<TRNUID>0\n"+
+		"<STATUS><CODE>0<SEVERITY>INFO</STATUS>\n"+
+		"<STMTRS>\n"+
+		"<CURDEF>XBT\n"+
+		"<BANKACCTFROM><BANKID>btcgui<ACCTID>%s<ACCTTYPE>CHECKING</BANKACCTFROM>\n"+
+		"<BANKTRANLIST>\n"+
+		"<DTSTART>%s\n"+
+		"<DTEND>%s\n",
+		ofxEscape.Replace(opts.OFXAccountID),
+		start.UTC().Format(dateLayout),
+		end.UTC().Format(dateLayout))
+	if err != nil {
+		return err
+	}
+
+	for i, attr := range attrs {
+		trnType := "CREDIT"
+		if attr.Direction == Send {
+			trnType = "DEBIT"
+		}
+
+		// The FITID must be unique for every transaction, but a
+		// transaction sending to multiple recipients is exported
+		// once for each recipient, so include the index.
+		_, err := fmt.Fprintf(w, "<STMTTRN>\n"+
+			"<TRNTYPE>%s\n"+
+			"<DTPOSTED>%s\n"+
+			"<TRNAMT>%s\n"+
+			"<FITID>%s-%d\n"+
+			"<NAME>%.32s\n"+
+			"<MEMO>%s\n"+
+			"</STMTTRN>\n",
+			trnType,
+			attr.Date.UTC().Format(dateLayout),
+			btcString(attr.Amount),
+			attr.TxID, i,
+			ofxEscape.Replace(attr.Address),
+			ofxEscape.Replace(exportMemo(attr)))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprint(w, "</BANKTRANLIST>\n"+
+		"</STMTRS>\n"+
+		"</STMTTRNRS>\n"+
+		"</BANKMSGSRSV1>\n"+
+		"</OFX>\n")
+	return err
+}
@@ -0,0 +1,270 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"io/ioutil"
+	"log"
+)
+
+// createExportDialog creates a dialog to choose an export format and
+// per-format options, and then a file to write the transactions in attrs
+// to.
+func createExportDialog(attrs []*TxAttributes) (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Export Transactions")
+
+	dialog.AddButton("_OK", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetHExpand(true)
+	grid.SetVExpand(true)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	l, err := gtk.LabelNew("Format:")
+	if err != nil {
+		return nil, err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(l, 0, 0, 1, 1)
+
+	formats, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range exportFormats {
+		formats.AppendText(f.name)
+	}
+	formats.SetHExpand(true)
+	grid.Attach(formats, 1, 0, 1, 1)
+
+	opts := defaultExportOptions()
+
+	// Each format's options are kept in a separate grid, and only the
+	// grid for the selected format is shown.
+	optGrids := make([]*gtk.Grid, len(exportFormats))
+	for i := range optGrids {
+		g, err := gtk.GridNew()
+		if err != nil {
+			return nil, err
+		}
+		g.SetNoShowAll(true)
+		grid.Attach(g, 0, 1+i, 2, 1)
+		optGrids[i] = g
+	}
+
+	csvHeader, err := gtk.CheckButtonNewWithLabel("Include a header row")
+	if err != nil {
+		return nil, err
+	}
+	csvHeader.SetActive(opts.CSVHeader)
+	csvHeader.Show()
+	optGrids[exportCSV].Add(csvHeader)
+
+	jsonIndent, err := gtk.CheckButtonNewWithLabel("Indent output")
+	if err != nil {
+		return nil, err
+	}
+	jsonIndent.SetActive(opts.JSONIndent)
+	jsonIndent.Show()
+	optGrids[exportJSON].Add(jsonIndent)
+
+	l, err = gtk.LabelNew("Account type:")
+	if err != nil {
+		return nil, err
+	}
+	l.Show()
+	optGrids[exportQIF].Add(l)
+	qifType, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	qifType.SetText(opts.QIFAccountType)
+	qifType.Show()
+	optGrids[exportQIF].Add(qifType)
+
+	l, err = gtk.LabelNew("Account ID:")
+	if err != nil {
+		return nil, err
+	}
+	l.Show()
+	optGrids[exportOFX].Add(l)
+	ofxAcct, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	ofxAcct.SetText(opts.OFXAccountID)
+	ofxAcct.Show()
+	optGrids[exportOFX].Add(ofxAcct)
+
+	// Encryption applies to every format, so it is always shown below
+	// the format options.
+	encGrid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.Attach(encGrid, 0, 1+len(optGrids), 2, 1)
+	encrypt, err := gtk.CheckButtonNewWithLabel("Encrypt with passphrase")
+	if err != nil {
+		return nil, err
+	}
+	encGrid.Attach(encrypt, 0, 0, 2, 1)
+	l, err = gtk.LabelNew("Passphrase:")
+	if err != nil {
+		return nil, err
+	}
+	encGrid.Attach(l, 0, 1, 1, 1)
+	passphrase, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	passphrase.SetVisibility(false)
+	passphrase.SetHExpand(true)
+	passphrase.SetSensitive(false)
+	encGrid.Attach(passphrase, 1, 1, 1, 1)
+	l, err = gtk.LabelNew("Repeat passphrase:")
+	if err != nil {
+		return nil, err
+	}
+	encGrid.Attach(l, 0, 2, 1, 1)
+	repeated, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	repeated.SetVisibility(false)
+	repeated.SetSensitive(false)
+	encGrid.Attach(repeated, 1, 2, 1, 1)
+	encrypt.Connect("toggled", func() {
+		passphrase.SetSensitive(encrypt.GetActive())
+		repeated.SetSensitive(encrypt.GetActive())
+	})
+
+	formats.Connect("changed", func() {
+		active := formats.GetActive()
+		for i, g := range optGrids {
+			g.SetVisible(i == active)
+		}
+	})
+	formats.SetActive(int(exportCSV))
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	// Use an IObject as the receiver object.  This may be called with both
+	// a *glib.Object and *gtk.Dialog due to where the signals originate
+	// from.
+	dialog.Connect("response", func(_ glib.IObject, rt gtk.ResponseType) {
+		switch rt {
+		case gtk.RESPONSE_OK:
+			format := exportFormat(formats.GetActive())
+			opts.CSVHeader = csvHeader.GetActive()
+			opts.JSONIndent = jsonIndent.GetActive()
+			if s, err := qifType.GetText(); err == nil && s != "" {
+				opts.QIFAccountType = s
+			}
+			if s, err := ofxAcct.GetText(); err == nil && s != "" {
+				opts.OFXAccountID = s
+			}
+			opts.Passphrase = ""
+			if encrypt.GetActive() {
+				p1, _ := passphrase.GetText()
+				p2, _ := repeated.GetText()
+				if p1 == "" || p1 != p2 {
+					d := errorDialog("Invalid passphrase",
+						"The passphrases must match and may not be empty.")
+					d.Run()
+					d.Destroy()
+					return
+				}
+				opts.Passphrase = p1
+			}
+
+			if exportToFile(&dialog.Window, format, attrs, opts) {
+				dialog.Destroy()
+			}
+
+		case gtk.RESPONSE_CANCEL:
+			dialog.Destroy()
+		}
+	})
+
+	return dialog, nil
+}
+
+// exportToFile asks for a file to save exported transactions to, and
+// writes the transactions in the chosen format.  Returns false if no file
+// was chosen or the export failed.
+//
+// This must be run from the GTK main event loop.
+func exportToFile(parent *gtk.Window, format exportFormat,
+	attrs []*TxAttributes, opts *exportOptions) bool {
+
+	fc, err := gtk.FileChooserDialogNewWith2Buttons("Save Exported Transactions",
+		parent, gtk.FILE_CHOOSER_ACTION_SAVE,
+		"_Cancel", gtk.RESPONSE_CANCEL,
+		"_Save", gtk.RESPONSE_ACCEPT)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	defer fc.Destroy()
+	fc.SetDoOverwriteConfirmation(true)
+	name := "transactions." + exportFormats[format].ext
+	if opts.Passphrase != "" {
+		name += ".enc"
+	}
+	fc.SetCurrentName(name)
+
+	if gtk.ResponseType(fc.Run()) != gtk.RESPONSE_ACCEPT {
+		return false
+	}
+	filename := fc.GetFilename()
+
+	// Encrypted exports are written to a buffer first, so the whole
+	// file can be sealed at once.
+	var buf bytes.Buffer
+	err = exportTxs(&buf, format, attrs, opts)
+	data := buf.Bytes()
+	if err == nil && opts.Passphrase != "" {
+		data, err = encryptData([]byte(opts.Passphrase), data)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(filename, data, 0600)
+	}
+	if err != nil {
+		d := errorDialog("Export failed", err.Error())
+		d.Run()
+		d.Destroy()
+		return false
+	}
+	return true
+}
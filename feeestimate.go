@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"log"
+	"sync"
+)
+
+// Sizes in bytes used to estimate the size of a transaction spending
+// and paying pay-to-pubkey-hash outputs, the only kind btcwallet creates.
+const (
+	txOverheadSize  = 10
+	p2pkhInputSize  = 148
+	p2pkhOutputSize = 34
+)
+
+// estimateTxSize returns the estimated size in bytes of a transaction
+// with nIn inputs and nOut outputs.
+func estimateTxSize(nIn, nOut int) int {
+	return txOverheadSize + nIn*p2pkhInputSize + nOut*p2pkhOutputSize
+}
+
+// estimateTxFee returns the fee btcwallet adds to a transaction of size
+// bytes when the transaction fee is set to feePerKB.  btcwallet charges
+// the fee once for every started kilobyte, so a small transaction pays as
+// much as a transaction of almost a kilobyte.
+func estimateTxFee(feePerKB btcutil.Amount, size int) btcutil.Amount {
+	return feePerKB * btcutil.Amount(1+size/1000)
+}
+
+// feeEstimateText describes the estimated size and fee of a transaction
+// with nIn inputs and nOut outputs, and the resulting fee rate, when the
+// transaction fee is set to feePerKB.
+func feeEstimateText(feePerKB btcutil.Amount, nIn, nOut int) string {
+	size := estimateTxSize(nIn, nOut)
+	fee := estimateTxFee(feePerKB, size)
+	return fmt.Sprintf("Estimated size %d bytes, fee %s (%.1f sat/byte)",
+		size, formatAmount(fee), float64(fee)/float64(size))
+}
+
+// txFeeRate is the transaction fee per kilobyte set in btcwallet, if
+// known.
+var txFeeRate struct {
+	sync.Mutex
+	feePerKB btcutil.Amount
+	known    bool
+}
+
+// setTxFeeRate records the transaction fee per kilobyte set in
+// btcwallet, and updates the fee estimate of the send coins tab.
+func setTxFeeRate(feePerKB btcutil.Amount) {
+	txFeeRate.Lock()
+	txFeeRate.feePerKB = feePerKB
+	txFeeRate.known = true
+	txFeeRate.Unlock()
+	glib.IdleAdd(updateFeeEstimate)
+}
+
+// currentTxFeeRate returns the transaction fee per kilobyte set in
+// btcwallet, and whether it is known.
+func currentTxFeeRate() (btcutil.Amount, bool) {
+	txFeeRate.Lock()
+	defer txFeeRate.Unlock()
+	return txFeeRate.feePerKB, txFeeRate.known
+}
+
+// updateFeeEstimate shows the estimated size and fee of the transaction
+// being entered in the send coins tab.  The transaction is assumed to
+// spend a single input and pay each recipient and a change output.
+//
+// This must be run from the GTK main event loop.
+func updateFeeEstimate() {
+	if SendCoins.FeeEstimate == nil {
+		return
+	}
+	feePerKB, ok := currentTxFeeRate()
+	if !ok {
+		SendCoins.FeeEstimate.SetText("")
+		return
+	}
+	SendCoins.FeeEstimate.SetText(feeEstimateText(feePerKB, 1,
+		recipients.Len()+1))
+}
+
+// fetchTxFee requests the transaction fee per kilobyte set in btcwallet
+// from b.
+func fetchTxFee(b WalletBackend) {
+	info, err := b.GetInfo()
+	if err != nil {
+		log.Printf("[ERR] getinfo: %v", err)
+		return
+	}
+	f, ok := info["paytxfee"].(float64)
+	if !ok {
+		log.Print("[ERR] getinfo reply has no transaction fee")
+		return
+	}
+	feePerKB, err := btcutil.NewAmount(f)
+	if err != nil {
+		log.Printf("[ERR] getinfo: invalid transaction fee: %v", err)
+		return
+	}
+	setTxFeeRate(feePerKB)
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"math"
+)
+
+// ErrNoRateYet describes the error where an amount entered in the fiat
+// currency can not be sent as no exchange rate has been received.
+var ErrNoRateYet = errors.New("no exchange rate has been received yet " +
+	"to convert amounts entered in the fiat currency")
+
+// addFiatEntry adds an entry for the amount in the fiat currency after
+// the BTC amount of recipient r, with the conversion shown beside it.
+// Entering either amount updates the other.
+func (r *recipient) addFiatEntry(amounts *gtk.Grid) {
+	fiat, err := gtk.SpinButtonNewWithRange(0, 1e12, 0.01)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fiat.SetDigits(2)
+	fiat.SetHAlign(gtk.ALIGN_START)
+	fiat.SetTooltipText("Amount in " + cfg.Fiat + ", converted to BTC " +
+		"at the current exchange rate until sent")
+	r.fiat = fiat
+	amounts.Add(fiat)
+
+	l, err := gtk.LabelNew(cfg.Fiat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	amounts.Add(l)
+
+	conversion, err := gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conversion.SetHAlign(gtk.ALIGN_START)
+	r.conversion = conversion
+	amounts.Add(conversion)
+
+	fiat.Connect("value-changed", func() {
+		if r.converting {
+			return
+		}
+		r.fiatEntered = true
+		r.convertFiat()
+	})
+	r.amount.Connect("value-changed", func() {
+		if r.converting {
+			return
+		}
+		r.fiatEntered = false
+		r.showFiatValue()
+	})
+	r.showFiatValue()
+}
+
+// convertFiat sets the BTC amount of recipient r from the amount entered
+// in the fiat currency at the current exchange rate.
+//
+// This must be run from the GTK main event loop.
+func (r *recipient) convertFiat() {
+	rate, ok := currentRate()
+	if !ok {
+		r.conversion.SetText("Waiting for exchange rate")
+		return
+	}
+	btc := math.Floor(r.fiat.GetValue()/rate*1e8+0.5) / 1e8
+	r.converting = true
+	r.amount.SetValue(btc)
+	r.converting = false
+	amt, _ := btcutil.NewAmount(btc)
+	r.conversion.SetText(fmt.Sprintf("= %s at %s", formatAmount(amt),
+		rateText()))
+}
+
+// showFiatValue sets the fiat amount of recipient r from the BTC amount
+// at the current exchange rate.
+//
+// This must be run from the GTK main event loop.
+func (r *recipient) showFiatValue() {
+	rate, ok := currentRate()
+	if !ok {
+		r.conversion.SetText("Waiting for exchange rate")
+		return
+	}
+	r.converting = true
+	r.fiat.SetValue(r.amount.GetValue() * rate)
+	r.converting = false
+	r.conversion.SetText("at " + rateText())
+}
+
+// updateFiatAmounts converts the amounts of all recipients again after
+// the exchange rate changes.  Recipients with an amount entered in the
+// fiat currency have their BTC amount changed, while the others only
+// have the fiat value updated.
+//
+// This must be run from the GTK main event loop.
+func updateFiatAmounts() {
+	for e := recipients.Front(); e != nil; e = e.Next() {
+		r := e.Value.(*recipient)
+		if r.fiat == nil {
+			continue
+		}
+		if r.fiatEntered {
+			r.convertFiat()
+		} else {
+			r.showFiatValue()
+		}
+	}
+}
+
+// lockFiatAmounts fixes the BTC amounts of all recipients with amounts
+// entered in the fiat currency, so exchange rate changes while the
+// transaction is being sent do not change what is paid.
+//
+// This must be run from the GTK main event loop.
+func lockFiatAmounts() error {
+	for e := recipients.Front(); e != nil; e = e.Next() {
+		r := e.Value.(*recipient)
+		if !r.fiatEntered {
+			continue
+		}
+		if _, ok := currentRate(); !ok {
+			return ErrNoRateYet
+		}
+		r.convertFiat()
+		r.fiatEntered = false
+		amt, _ := btcutil.NewAmount(r.amount.GetValue())
+		r.conversion.SetText(fmt.Sprintf("Locked at %s (%s)",
+			formatAmount(amt), rateText()))
+	}
+	return nil
+}
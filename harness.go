@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/btcgui/rpc"
+	"log"
+	"time"
+)
+
+// Delays and timeouts which are shortened by the harness option, so
+// automated end-to-end runs against a local simnet chain notice changes
+// and failures quickly.
+var (
+	// reconnectDelay is the time waited before connecting to btcwallet
+	// again after a connection is refused or lost.  The delay doubles
+	// after each failed attempt, up to reconnectMaxDelay.
+	reconnectDelay = 5 * time.Second
+
+	// reconnectMaxDelay is the longest time waited between attempts to
+	// connect to btcwallet, as set by the reconnectmax option.
+	reconnectMaxDelay = defaultReconnectMax * time.Second
+
+	// lockOnExitWait is the longest time waited for btcwallet to lock
+	// the wallet when exiting.
+	lockOnExitWait = 5 * time.Second
+
+	// httpPollInterval is how often wallet state is requested when
+	// connected with HTTP POST requests, as no notifications are
+	// received.
+	httpPollInterval = 15 * time.Second
+
+	// recoverPollInterval is how often the balance is requested while
+	// waiting for the rescan of a recovered wallet to find funds.
+	recoverPollInterval = 5 * time.Second
+)
+
+// useHarnessTimings shortens all delays and timeouts for automated runs.
+//
+// In harness mode, no interactive dialogs are shown before the main
+// window, and JSON-RPC request IDs are deterministic: they start at zero
+// and the requests filling the GUI after connecting are always sent one
+// at a time in the same order, so logged requests of a scripted run can
+// be compared with earlier runs.
+//
+// The longest wait between reconnect attempts is only shortened when
+// --reconnectmax was left at its default, so a run may still choose it.
+func useHarnessTimings() {
+	reconnectDelay = 500 * time.Millisecond
+	if cfg.ReconnectMax == defaultReconnectMax {
+		reconnectMaxDelay = 2 * time.Second
+	}
+	lockOnExitWait = time.Second
+	httpPollInterval = time.Second
+	recoverPollInterval = 500 * time.Millisecond
+	rpc.RequestTimeout = 10 * time.Second
+	log.Print("[INF] Harness mode: using short timeouts for simnet")
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"html"
+	"log"
+	"strings"
+	"time"
+)
+
+// Thresholds of the wallet health check.
+const (
+	// minKeypoolSize is the fewest unused keys in the keypool not
+	// flagged, as new addresses can not be generated once it runs out
+	// while the wallet is locked.
+	minKeypoolSize = 10
+
+	// maxBackupAge is the age of the last backup after which making a
+	// new one is suggested.
+	maxBackupAge = 180 * 24 * time.Hour
+)
+
+// healthResult is the result of one check of the wallet health check.
+type healthResult struct {
+	name   string
+	result string
+
+	// concern is set if the result should be looked into.
+	concern bool
+}
+
+// walletHealth holds the widgets of the Overview showing the results of
+// the wallet health check.
+var walletHealth struct {
+	expander *gtk.Expander
+	details  *gtk.Label
+}
+
+// createWalletHealth creates the expander of the Overview showing a one
+// line summary of the wallet health check, with the result of each check
+// shown when expanded.  It is hidden until the first check completes.
+func createWalletHealth() *gtk.Widget {
+	e, err := gtk.ExpanderNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	e.SetNoShowAll(true)
+	walletHealth.expander = e
+
+	l, err := gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	l.SetSelectable(true)
+	l.Show()
+	e.Add(l)
+	walletHealth.details = l
+
+	return &e.Bin.Container.Widget
+}
+
+// checkWalletHealth runs a diagnostic pass over the wallet of b,
+// checking the wallet and chain state reported by btcwallet, the size of
+// the keypool, and the age of the last backup, and shows the results in
+// the Overview.
+//
+// This is written to be run outside of the GTK main event loop.
+func checkWalletHealth(b WalletBackend) {
+	var results []healthResult
+	add := func(name string, concern bool, format string,
+		args ...interface{}) {
+
+		results = append(results, healthResult{
+			name:    name,
+			result:  fmt.Sprintf(format, args...),
+			concern: concern,
+		})
+	}
+
+	// getwalletinfo is not supported by every btcwallet, in which case
+	// the keypool size is looked for in the getinfo reply instead.
+	walletFields, err := b.GetWalletInfo()
+	switch jsonErr, _ := err.(*btcjson.Error); {
+	case err == nil:
+		version, _ := walletFields["walletversion"].(float64)
+		add("Wallet", false, "Wallet version %.0f", version)
+	case jsonErr != nil && jsonErr.Code == btcjson.ErrMethodNotFound.Code:
+		add("Wallet", false, "No wallet details reported by this "+
+			"btcwallet")
+	default:
+		add("Wallet", true, "Cannot get wallet details: %v", err)
+	}
+
+	info, err := b.GetInfo()
+	if err != nil {
+		add("btcwallet", true, "Cannot get info: %v", err)
+		showWalletHealth(results)
+		return
+	}
+	version, _ := info["version"].(float64)
+	if errs, _ := info["errors"].(string); errs != "" {
+		add("btcwallet", true, "Version %.0f reports: %s", version, errs)
+	} else {
+		add("btcwallet", false, "Version %.0f", version)
+	}
+
+	// A block may be connected between the two requests, so the
+	// heights may differ by one.
+	height, err := b.GetBlockCount()
+	infoHeight, hasHeight := info["blocks"].(float64)
+	diff := int32(infoHeight) - height
+	switch {
+	case err != nil:
+		add("Block height", true, "Cannot get block count: %v", err)
+	case height <= 0:
+		add("Block height", true, "No blocks in the chain yet")
+	case hasHeight && (diff < -1 || diff > 1):
+		add("Block height", true, "getinfo reports height %.0f "+
+			"but getblockcount reports %d", infoHeight, height)
+	default:
+		add("Block height", false, "%d", height)
+	}
+
+	keypool, ok := walletFields["keypoolsize"].(float64)
+	if !ok {
+		keypool, ok = info["keypoolsize"].(float64)
+	}
+	switch {
+	case !ok:
+		add("Keypool", false, "Size not reported")
+	case keypool < minKeypoolSize:
+		add("Keypool", true, "Only %.0f unused keys left.  Unlock "+
+			"the wallet so more can be generated.", keypool)
+	default:
+		add("Keypool", false, "%.0f unused keys", keypool)
+	}
+
+	backup := metadata.BackupTime()
+	switch {
+	case backup.IsZero():
+		add("Backup", true, "The wallet seed has not been written "+
+			"down from btcgui.  Show it from the Settings menu to "+
+			"back up the wallet.")
+	case time.Since(backup) > maxBackupAge:
+		add("Backup", true, "Last backed up on %s.  Check that the "+
+			"backup is still kept safe.", backup.Format("Jan 2, 2006"))
+	default:
+		add("Backup", false, "Last backed up on %s",
+			backup.Format("Jan 2, 2006"))
+	}
+
+	showWalletHealth(results)
+}
+
+// showWalletHealth shows the results of the wallet health check in the
+// Overview, summarized by whether any result is a concern.
+//
+// This is written to be run outside of the GTK main event loop.
+func showWalletHealth(results []healthResult) {
+	var concerns []string
+	lines := make([]string, len(results))
+	for i, r := range results {
+		line := fmt.Sprintf("<b>%s:</b> %s", html.EscapeString(r.name),
+			html.EscapeString(r.result))
+		if r.concern {
+			concerns = append(concerns, r.name)
+			line = "⚠ " + line
+		}
+		lines[i] = line
+	}
+
+	summary := "Wallet check: no problems found"
+	if len(concerns) != 0 {
+		summary = fmt.Sprintf("Wallet check: %s needs attention",
+			strings.Join(concerns, ", "))
+		log.Printf("[WRN] %s", summary)
+	}
+	details := strings.Join(lines, "\n")
+	glib.IdleAdd(func() {
+		walletHealth.expander.SetLabel(summary)
+		walletHealth.details.SetMarkup(details)
+		walletHealth.expander.Show()
+	})
+}
@@ -0,0 +1,306 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// Columns of the import dialog's list store.
+const (
+	importColType = iota
+	importColEntry
+	importColLabel
+	importColStatus
+)
+
+// importEntry is a private key or address read from an import file.
+type importEntry struct {
+	key   string
+	label string
+	isKey bool
+}
+
+// ImportParams holds the private keys and addresses to import into the
+// wallet.  The result of importing each entry is sent to results in
+// order, which is closed once every entry is done.
+type ImportParams struct {
+	entries []importEntry
+	results chan error
+}
+
+// parseImportFile reads the private keys and addresses to import from r.
+// Each line holds a WIF private key or an address, optionally followed
+// by a label.  Empty lines and lines starting with # are skipped.  Any
+// string which is not an address for the active network is taken to be
+// a private key, which btcwallet validates.  Addresses are ordered before
+// private keys, so the rescan done by the last import also finds the
+// transactions of watched addresses.
+func parseImportFile(r io.Reader) ([]importEntry, error) {
+	var addrs, keys []importEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		e := importEntry{
+			key:   fields[0],
+			label: strings.Join(fields[1:], " "),
+		}
+		a, err := btcutil.DecodeAddress(e.key, activeNet.Params)
+		if err != nil || !a.IsForNet(activeNet.Params) {
+			e.isKey = true
+			keys = append(keys, e)
+		} else {
+			addrs = append(addrs, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return append(addrs, keys...), nil
+}
+
+// maskKey returns key with all but its first characters hidden, so
+// private keys are not shown on screen in full.
+func maskKey(key string) string {
+	const shown = 4
+	if len(key) <= shown {
+		return key
+	}
+	return key[:shown] + strings.Repeat("*", 8)
+}
+
+// importFromFile asks for a file of private keys and addresses and opens
+// a dialog to import them.
+//
+// This must be run from the GTK main event loop.
+func importFromFile() {
+	fc, err := gtk.FileChooserDialogNewWith2Buttons("Import Keys and Addresses",
+		mainWindow, gtk.FILE_CHOOSER_ACTION_OPEN,
+		"_Cancel", gtk.RESPONSE_CANCEL,
+		"_Open", gtk.RESPONSE_ACCEPT)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if gtk.ResponseType(fc.Run()) != gtk.RESPONSE_ACCEPT {
+		fc.Destroy()
+		return
+	}
+	filename := fc.GetFilename()
+	fc.Destroy()
+
+	f, err := os.Open(filename)
+	if err == nil {
+		var entries []importEntry
+		entries, err = parseImportFile(f)
+		f.Close()
+		if err == nil && len(entries) == 0 {
+			err = fmt.Errorf("%s has no keys or addresses", filename)
+		}
+		if err == nil {
+			_, err = createImportDialog(entries)
+		}
+	}
+	if err != nil {
+		d := errorDialog("Cannot import keys", err.Error())
+		d.Run()
+		d.Destroy()
+	}
+}
+
+// createImportDialog creates a dialog listing entries to import.  When
+// accepted, the entries are imported one at a time, showing the progress
+// and the result of each entry.
+func createImportDialog(entries []importEntry) (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Import keys and addresses")
+	dialog.SetDefaultSize(600, 400)
+
+	importButton, err := dialog.AddButton("_Import", gtk.RESPONSE_OK)
+	if err != nil {
+		return nil, err
+	}
+	dialog.AddButton("_Close", gtk.RESPONSE_CLOSE)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	nkeys := 0
+	for _, e := range entries {
+		if e.isKey {
+			nkeys++
+		}
+	}
+	status, err := gtk.LabelNew(fmt.Sprintf("%d private keys and %d "+
+		"addresses will be imported, followed by a single rescan "+
+		"of the blockchain.", nkeys, len(entries)-nkeys))
+	if err != nil {
+		return nil, err
+	}
+	status.SetLineWrap(true)
+	status.SetHAlign(gtk.ALIGN_START)
+	grid.Add(status)
+
+	store, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		return nil, err
+	}
+	iters := make([]*gtk.TreeIter, len(entries))
+	for i, e := range entries {
+		typ, shown := "Address", e.key
+		if e.isKey {
+			typ, shown = "Private key", maskKey(e.key)
+		}
+		iters[i] = store.Append()
+		store.Set(iters[i], []int{importColType, importColEntry,
+			importColLabel, importColStatus},
+			[]interface{}{typ, shown, e.label, "Waiting"})
+	}
+
+	tv, err := gtk.TreeViewNew()
+	if err != nil {
+		return nil, err
+	}
+	tv.SetModel(store)
+	columns := []struct {
+		title string
+		col   int
+	}{
+		{"Type", importColType},
+		{"Key or Address", importColEntry},
+		{"Label", importColLabel},
+		{"Status", importColStatus},
+	}
+	for _, c := range columns {
+		cr, err := gtk.CellRendererTextNew()
+		if err != nil {
+			return nil, err
+		}
+		col, err := gtk.TreeViewColumnNewWithAttribute(c.title, cr, "text", c.col)
+		if err != nil {
+			return nil, err
+		}
+		if c.col == importColStatus {
+			col.SetExpand(true)
+		}
+		tv.AppendColumn(col)
+	}
+
+	sw, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	sw.Add(tv)
+	sw.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	sw.SetHExpand(true)
+	sw.SetVExpand(true)
+	grid.Add(sw)
+
+	progress, err := gtk.ProgressBarNew()
+	if err != nil {
+		return nil, err
+	}
+	progress.SetShowText(true)
+	grid.Add(progress)
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		if rt != gtk.RESPONSE_OK {
+			dialog.Destroy()
+			return
+		}
+		importButton.SetSensitive(false)
+		status.SetText("Importing...")
+
+		params := &ImportParams{
+			entries: entries,
+			results: make(chan error, len(entries)),
+		}
+		go func() {
+			go importKeys(activeBackend(), params)
+			i, failed := 0, 0
+			var lastErr error
+			for err := range params.results {
+				i, lastErr = i+1, err
+				if err != nil {
+					failed++
+				}
+				row, done := iters[i-1], i
+				glib.IdleAdd(func() {
+					text := "Imported"
+					if err != nil {
+						// Only the explanation fits the
+						// row, not the message of btcwallet.
+						msg := walletErrorMessage(err)
+						text = "Failed: " + strings.SplitN(msg, "\n", 2)[0]
+					}
+					store.SetValue(row, importColStatus, text)
+					progress.SetFraction(float64(done) / float64(len(entries)))
+					progress.SetText(fmt.Sprintf("%d of %d", done,
+						len(entries)))
+				})
+			}
+			glib.IdleAdd(func() {
+				text := fmt.Sprintf("%d imported, %d failed.",
+					i-failed, failed)
+				switch {
+				case i == failed:
+					// Nothing was imported, so there
+					// was nothing to rescan for.
+				case lastErr != nil:
+					text += "  The last entry failed, so the " +
+						"blockchain was not rescanned, and " +
+						"transactions of the imported keys " +
+						"and addresses made before now are " +
+						"not shown."
+				default:
+					text += "  btcwallet is rescanning the " +
+						"blockchain for transactions."
+				}
+				status.SetText(text)
+			})
+		}()
+	})
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+	return dialog, nil
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// journalFilename is the name of the file in the data directory which
+// holds the event journal.
+const journalFilename = "journal.log"
+
+// Events recorded in the journal.
+const (
+	eventSent         = "Sent"
+	eventReceived     = "Received"
+	eventLocked       = "Locked"
+	eventUnlocked     = "Unlocked"
+	eventConnected    = "Connected"
+	eventDisconnected = "Disconnected"
+	eventQueued       = "Queued"
+)
+
+// journalEntry is a single event recorded in the journal.
+type journalEntry struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Details string    `json:"details,omitempty"`
+}
+
+// eventJournal is an append-only log of significant wallet events, kept
+// independently of btcwallet's own logs.  Each event is written to the
+// file as a single line of JSON.
+type eventJournal struct {
+	sync.Mutex
+	filename string
+}
+
+// journal is the event journal used by the application.  Events are
+// not recorded until the filename is set when the main application is
+// started.
+var journal = &eventJournal{}
+
+// Add appends an event to the journal.
+func (j *eventJournal) Add(event, details string) error {
+	j.Lock()
+	defer j.Unlock()
+	if j.filename == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(&journalEntry{
+		Time:    time.Now(),
+		Event:   event,
+		Details: details,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.filename), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+		0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries reads every event recorded in the journal, oldest first.
+// Lines which cannot be parsed, such as a line partially written during
+// a crash, are skipped.
+func (j *eventJournal) Entries() ([]journalEntry, error) {
+	j.Lock()
+	defer j.Unlock()
+	if j.filename == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(j.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// logEvent records an event in the journal, logging any error writing
+// it.
+func logEvent(event, format string, args ...interface{}) {
+	if err := journal.Add(event, fmt.Sprintf(format, args...)); err != nil {
+		log.Printf("[ERR] cannot write event journal: %v", err)
+	}
+}
+
+// exportJournal writes journal entries to w as CSV.
+func exportJournal(w io.Writer, entries []journalEntry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"Time", "Event", "Details"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		err := cw.Write([]string{
+			e.Time.Format(time.RFC3339),
+			e.Event,
+			e.Details,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
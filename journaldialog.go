@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"os"
+)
+
+// journalExportResponse is the response of the journal dialog button
+// exporting the journal, which does not close the dialog.
+const journalExportResponse gtk.ResponseType = 1
+
+// createJournalDialog creates a dialog listing every event recorded in
+// the event journal, newest first.
+func createJournalDialog() (*gtk.Dialog, error) {
+	entries, err := journal.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Event journal")
+	dialog.SetDefaultSize(600, 400)
+
+	dialog.AddButton("_Export...", journalExportResponse)
+	dialog.AddButton("_Close", gtk.RESPONSE_CLOSE)
+
+	store, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_STRING)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := &entries[i]
+		store.Set(store.Append(), []int{0, 1, 2},
+			[]interface{}{e.Time.Format("01/02/2006 15:04:05"),
+				e.Event, e.Details})
+	}
+
+	tv, err := gtk.TreeViewNewWithModel(store)
+	if err != nil {
+		return nil, err
+	}
+	for i, title := range []string{"Time", "Event", "Details"} {
+		cr, err := gtk.CellRendererTextNew()
+		if err != nil {
+			return nil, err
+		}
+		col, err := gtk.TreeViewColumnNewWithAttribute(title, cr, "text", i)
+		if err != nil {
+			return nil, err
+		}
+		tv.AppendColumn(col)
+	}
+
+	sw, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	sw.Add(tv)
+	sw.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	sw.SetHExpand(true)
+	sw.SetVExpand(true)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(sw)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		switch rt {
+		case journalExportResponse:
+			saveJournalExport(&dialog.Window, entries)
+		default:
+			dialog.Destroy()
+		}
+	})
+
+	return dialog, nil
+}
+
+// saveJournalExport asks for a file to export the journal entries to
+// as CSV, and writes them.
+//
+// This must be run from the GTK main event loop.
+func saveJournalExport(parent *gtk.Window, entries []journalEntry) {
+	fc, err := gtk.FileChooserDialogNewWith2Buttons("Export Event Journal",
+		parent, gtk.FILE_CHOOSER_ACTION_SAVE,
+		"_Cancel", gtk.RESPONSE_CANCEL,
+		"_Save", gtk.RESPONSE_ACCEPT)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	defer fc.Destroy()
+	fc.SetDoOverwriteConfirmation(true)
+	fc.SetCurrentName("journal.csv")
+
+	if gtk.ResponseType(fc.Run()) != gtk.RESPONSE_ACCEPT {
+		return
+	}
+	f, err := os.OpenFile(fc.GetFilename(),
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err == nil {
+		err = exportJournal(f, entries)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		d := errorDialog("Cannot export event journal", err.Error())
+		d.Run()
+		d.Destroy()
+	}
+}
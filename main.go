@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -33,6 +34,10 @@ var cfg *config
 
 var PreGUIErrorDialog *gtk.MessageDialog
 
+// startupURI is the bitcoin: URI passed on the command line, or empty if
+// btcgui was started without one.
+var startupURI string
+
 // PreGUIError opens the pre-allocated error dialog for presenting errors
 // before the main window GUI has been completely constructed and shown.
 // The dialog is updated with the message in e.
@@ -59,6 +64,68 @@ func IdlePreGUIError(e error) {
 	select {}
 }
 
+// Responses of the dialog presenting recoverable errors before the main
+// window is shown.
+const (
+	preGUIRetry gtk.ResponseType = 2
+	preGUIQuit  gtk.ResponseType = 3
+)
+
+// RecoverablePreGUIError presents an error found before the main window
+// GUI has been shown which the user may be able to fix, such as a missing
+// CA file.  The user may retry, or when fixable is set, first change the
+// settings with the settings dialog.  This returns once the failed step
+// should be tried again, and exits if the user quits instead.
+//
+// This must be run after GTK is initialized, but may be run before the
+// main event loop is started.
+func RecoverablePreGUIError(e error, fixable bool) {
+	d := gtk.MessageDialogNew(nil, 0, gtk.MESSAGE_ERROR, gtk.BUTTONS_NONE,
+		e.Error())
+	d.SetTitle("btcgui")
+	d.SetPosition(gtk.WIN_POS_CENTER)
+	if fixable {
+		d.AddButton("_Edit Settings...", configEditSettings)
+	}
+	d.AddButton("_Retry", preGUIRetry)
+	d.AddButton("_Quit", preGUIQuit)
+	if fixable {
+		d.SetDefaultResponse(configEditSettings)
+	} else {
+		d.SetDefaultResponse(preGUIRetry)
+	}
+	response := gtk.ResponseType(d.Run())
+	d.Destroy()
+
+	switch response {
+	case preGUIRetry:
+	case configEditSettings:
+		dialog, err := createSettingsDialog(cfg)
+		if err != nil {
+			PreGUIError(fmt.Errorf("Cannot create settings dialog:\n%v", err))
+		}
+		dialog.Run()
+		dialog.Destroy()
+		if !fixConfig(cfg, finishConfig(cfg)) {
+			os.Exit(1)
+		}
+	default:
+		os.Exit(1)
+	}
+}
+
+// IdleRecoverablePreGUIError runs RecoverablePreGUIError within the
+// context of the GTK main event loop, returning once the failed step
+// should be tried again.
+func IdleRecoverablePreGUIError(e error, fixable bool) {
+	done := make(chan struct{})
+	glib.IdleAdd(func() {
+		RecoverablePreGUIError(e, fixable)
+		close(done)
+	})
+	<-done
+}
+
 func main() {
 	gtk.Init(nil)
 
@@ -73,7 +140,35 @@ func main() {
 		os.Exit(1)
 	})
 
-	tcfg, _, err := loadConfig()
+	// A configuration file which can not be parsed may be corrected
+	// while the error is shown, and read again.
+	tcfg, args, err := loadConfig()
+	for {
+		e, ok := err.(*badConfigFile)
+		if !ok {
+			break
+		}
+		RecoverablePreGUIError(fmt.Errorf("Cannot open configuration:\n%v",
+			e), false)
+		tcfg, args, err = loadConfig()
+	}
+	if err == errNoProfile {
+		os.Exit(0)
+	}
+
+	// Problems with an otherwise parsed configuration are shown all at
+	// once, and may be fixed with the settings dialog before continuing.
+	if errs, ok := err.(configErrors); ok {
+		// Automated runs can not fix the configuration, and the
+		// problems were already written to stderr.
+		if tcfg.Harness {
+			os.Exit(1)
+		}
+		if !fixConfig(tcfg, errs) {
+			os.Exit(1)
+		}
+		err = nil
+	}
 	if err != nil {
 		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
 			PreGUIError(fmt.Errorf("Cannot open configuration:\n%v", err))
@@ -82,6 +177,20 @@ func main() {
 		}
 	}
 	cfg = tcfg
+	initWallets()
+	reconnectMaxDelay = time.Duration(cfg.ReconnectMax) * time.Second
+	if cfg.Harness {
+		useHarnessTimings()
+	}
+	startDebugServer()
+
+	// A bitcoin: URI may be passed to open the send coins tab with the
+	// payment details filled in.
+	for _, arg := range args {
+		if isBitcoinURI(arg) {
+			startupURI = arg
+		}
+	}
 
 	// Load help dialog on first open.  Use current and previous versions
 	// can be used to control what level of new information must be
@@ -91,8 +200,10 @@ func main() {
 	// As currently implemented, if current > previous version, or if
 	// there are any errors opening and reading the file, any and all
 	// tutorial information is displayed.
+	//
+	// The tutorial is never shown during automated runs.
 	prevRunVers, err := GetPreviousAppVersion(cfg)
-	if err != nil || version.NewerThan(*prevRunVers) {
+	if !cfg.Harness && (err != nil || version.NewerThan(*prevRunVers)) {
 		d, err := CreateTutorialDialog(nil)
 		if err != nil {
 			// Nothing to show.
@@ -107,6 +218,33 @@ func main() {
 	}
 
 	gtk.Main()
+	saveViewState()
+	saveSendDraft()
+
+	// Closing the GUI should not leave the wallet unlocked, as
+	// btcwallet may keep running or be on a remote host.  Session
+	// unlocks always end when btcgui exits.
+	if sessionUnlockActive() || (!cfg.NoLockOnExit && unlockActive()) {
+		lockWalletOnExit()
+	}
+}
+
+// lockWalletOnExit requests btcwallet to lock the wallet, waiting a short
+// time for the request to be sent and answered before the application
+// exits.
+func lockWalletOnExit() {
+	done := make(chan error, 1)
+	go func() {
+		done <- lockWallet()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Printf("[ERR] cannot lock wallet on exit: %v", err)
+		}
+	case <-time.After(lockOnExitWait):
+		log.Print("[ERR] cannot lock wallet on exit: no reply from btcwallet")
+	}
 }
 
 // StartMainApplication creates and opens the main window appWindow.
@@ -117,10 +255,38 @@ func main() {
 // This is written to be called as a goroutine outside of the main GTK
 // loop.
 func StartMainApplication() {
-	// Read CA file to verify a btcwallet TLS connection.
+	// Read CA file to verify a btcwallet TLS connection.  A missing or
+	// unreadable file may be fixed by choosing another in the settings,
+	// except in automated runs.
 	cafile, err := ioutil.ReadFile(cfg.CAFile)
+	for err != nil {
+		err = fmt.Errorf("Cannot open CA file:\n%v", err)
+		if cfg.Harness {
+			IdlePreGUIError(err)
+		}
+		IdleRecoverablePreGUIError(err, true)
+		cafile, err = ioutil.ReadFile(cfg.CAFile)
+	}
+
+	// Read locally-saved metadata, such as transaction labels.  If
+	// the store is encrypted, ask for the passphrase until it is
+	// opened or the user gives up.  Changes are then kept in memory
+	// only, so the encrypted store is never overwritten.
+	mdFile := filepath.Join(cfg.DataDir, metadataFilename)
+	md, err := openMetadataStore(mdFile, nil)
+	if err == ErrMetadataEncrypted {
+		md, err = openEncryptedMetadata(mdFile)
+	}
 	if err != nil {
-		IdlePreGUIError(fmt.Errorf("Cannot open CA file:\n%v", err))
+		log.Printf("[ERR] cannot open metadata store: %v", err)
+	} else {
+		metadata = md
+	}
+
+	journal.filename = filepath.Join(cfg.DataDir, journalFilename)
+
+	if err := loadPIN(); err != nil {
+		log.Printf("[ERR] cannot read GUI PIN: %v", err)
 	}
 
 	glib.IdleAdd(func() {
@@ -128,7 +294,21 @@ func StartMainApplication() {
 		if err != nil {
 			PreGUIError(fmt.Errorf("Cannot create application window:\n%v", err))
 		}
-		w.ShowAll()
+
+		// When started hidden, only the tray icon is shown until it
+		// is clicked or a payment URI must be shown.
+		if cfg.Hidden {
+			statusIcon, err = createStatusIcon()
+			if err != nil {
+				PreGUIError(fmt.Errorf("Cannot create tray icon:\n%v", err))
+			}
+		}
+		if (!cfg.Hidden || startupURI != "") && unlockGUI() {
+			w.ShowAll()
+		}
+		if startupURI != "" {
+			showBitcoinURI(startupURI)
+		}
 	})
 
 	// Write current application version to file.
@@ -136,11 +316,40 @@ func StartMainApplication() {
 		log.Print(err)
 	}
 
-	// Begin generating new IDs for JSON calls.
-	go JSONIDGenerator(NewJSONID)
+	// Lock the wallet when the user is idle during a session unlock.
+	go sessionIdleLocker()
+
+	// Hide the window until the PIN is entered when the user is idle.
+	go pinIdleLocker()
+
+	// Remind of recurring payments when they are due.  Payments can
+	// not be sent in watch-only mode.
+	if !cfg.WatchOnly {
+		go reminderChecker()
+	}
+
+	// Move the Overview activity totals to the next day at midnight.
+	go activityRefresher()
+
+	// Keep the exchange rate current for fiat amounts.
+	if fiatEnabled() {
+		go rateUpdater()
+	}
+
+	// Poll the balances of any additional wallets for the Overview.
+	go monitorWallets(cafile)
+
+	// Compare the block height with a secondary source, if configured,
+	// to warn of a stalled or forked btcd.
+	startHeightCheck()
 
 	// Listen for updates and update GUI with new info.  Attempt
-	// reconnect if connection is lost or cannot be established.
+	// reconnect if connection is lost or cannot be established, waiting
+	// longer after each failed attempt.  Connection changes, but not
+	// repeated failures to reconnect, are recorded in the event journal.
+	connected := false
+	var connectedTo walletEndpoint
+	var backoff reconnectBackoff
 	for {
 		replies := make(chan error)
 		done := make(chan int)
@@ -156,15 +365,27 @@ func StartMainApplication() {
 			case err := <-replies:
 				switch err {
 				case ErrConnectionRefused:
-					updateChans.btcwalletConnected <- false
-					time.Sleep(5 * time.Second)
+					walletEvents.Publish(ConnStateChanged{})
+					waitReconnect(backoff.next())
 				case ErrConnectionLost:
-					updateChans.btcwalletConnected <- false
-					time.Sleep(5 * time.Second)
+					walletEvents.Publish(ConnStateChanged{})
+					if connected {
+						logEvent(eventDisconnected, "Lost connection to %v",
+							connectedTo)
+						connected = false
+					}
+					waitReconnect(backoff.next())
 				case nil:
 					// connected
-					updateChans.btcwalletConnected <- true
+					backoff.reset()
+					walletEvents.Publish(ConnStateChanged{
+						Connected: true,
+					})
 					log.Print("Established connection to btcwallet.")
+					connectedTo = selectedWallet()
+					logEvent(eventConnected, "Connected to %v",
+						connectedTo)
+					connected = true
 				default:
 					// TODO(jrick): present unknown error to user in the
 					// GUI somehow.
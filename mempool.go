@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+)
+
+// mempoolFeeBuckets are the lower bounds, in satoshis per byte, of the
+// fee rate ranges of the mempool fee histogram.
+var mempoolFeeBuckets = []float64{0, 1, 5, 10, 20, 50, 100}
+
+// mempoolBucket summarizes the mempool transactions paying a fee rate in
+// one range of the fee histogram.
+type mempoolBucket struct {
+	minRate float64
+	count   int
+	size    int64
+}
+
+// String describes the fee rate range of the bucket.
+func (b *mempoolBucket) String() string {
+	for i, min := range mempoolFeeBuckets {
+		if min == b.minRate && i+1 < len(mempoolFeeBuckets) {
+			return fmt.Sprintf("%g-%g sat/byte", min,
+				mempoolFeeBuckets[i+1])
+		}
+	}
+	return fmt.Sprintf("%g+ sat/byte", b.minRate)
+}
+
+// mempoolStats summarizes the transactions waiting in the mempool of
+// btcd to be mined.
+type mempoolStats struct {
+	count   int
+	size    int64
+	fees    float64
+	buckets []mempoolBucket
+}
+
+// computeMempoolStats summarizes the verbose getrawmempool result
+// entries, mapping the txid of each transaction to its details.
+func computeMempoolStats(entries map[string]interface{}) *mempoolStats {
+	stats := &mempoolStats{
+		buckets: make([]mempoolBucket, len(mempoolFeeBuckets)),
+	}
+	for i, min := range mempoolFeeBuckets {
+		stats.buckets[i].minRate = min
+	}
+	for _, e := range entries {
+		tx, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		size, _ := tx["size"].(float64)
+		fee, _ := tx["fee"].(float64)
+		if size <= 0 {
+			continue
+		}
+		stats.count++
+		stats.size += int64(size)
+		stats.fees += fee
+
+		rate := fee * 1e8 / size
+		i := len(mempoolFeeBuckets) - 1
+		for i > 0 && rate < mempoolFeeBuckets[i] {
+			i--
+		}
+		stats.buckets[i].count++
+		stats.buckets[i].size += int64(size)
+	}
+	return stats
+}
+
+// requestMempoolStats requests the transactions in the mempool of btcd,
+// passed through by btcwallet, and summarizes them.
+func requestMempoolStats() (*mempoolStats, error) {
+	b := activeBackend()
+	if b == nil {
+		return nil, ErrConnectionLost
+	}
+
+	entries, err := b.GetRawMempool()
+	if err != nil {
+		return nil, err
+	}
+	return computeMempoolStats(entries), nil
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+)
+
+// mempoolRefreshResponse is the response of the mempool dialog button
+// requesting the mempool again, which does not close the dialog.
+const mempoolRefreshResponse gtk.ResponseType = 1
+
+// Columns of the mempool fee histogram list store.
+const (
+	mpColRate = iota
+	mpColCount
+	mpColSize
+	mpColShare
+)
+
+// createMempoolDialog creates a dialog showing how many transactions
+// are waiting to be mined, and how many bytes of them pay each range of
+// fee rates, to help choose the transaction fee.  Transactions paying
+// higher rates than most of the mempool are usually mined sooner.
+func createMempoolDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Mempool fees")
+	dialog.SetDefaultSize(450, 350)
+
+	dialog.AddButton("_Refresh", mempoolRefreshResponse)
+	dialog.AddButton("_Close", gtk.RESPONSE_CLOSE)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	status, err := gtk.LabelNew("")
+	if err != nil {
+		return nil, err
+	}
+	status.SetHAlign(gtk.ALIGN_START)
+	status.SetLineWrap(true)
+	grid.Add(status)
+
+	store, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_INT,
+		glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		return nil, err
+	}
+	tv, err := gtk.TreeViewNewWithModel(store)
+	if err != nil {
+		return nil, err
+	}
+	columns := []struct {
+		title string
+		col   int
+	}{
+		{"Fee rate", mpColRate},
+		{"Transactions", mpColCount},
+		{"Size", mpColSize},
+		{"Share", mpColShare},
+	}
+	for _, c := range columns {
+		cr, err := gtk.CellRendererTextNew()
+		if err != nil {
+			return nil, err
+		}
+		col, err := gtk.TreeViewColumnNewWithAttribute(c.title, cr, "text", c.col)
+		if err != nil {
+			return nil, err
+		}
+		tv.AppendColumn(col)
+	}
+	tv.SetHExpand(true)
+	tv.SetVExpand(true)
+	grid.Add(tv)
+
+	refresh := func() {
+		status.SetText("Requesting the mempool from btcd...")
+		dialog.SetResponseSensitive(mempoolRefreshResponse, false)
+		go func() {
+			stats, err := requestMempoolStats()
+			glib.IdleAdd(func() {
+				dialog.SetResponseSensitive(mempoolRefreshResponse, true)
+				if err != nil {
+					status.SetText("Unable to get the mempool: " +
+						walletErrorMessage(err))
+					return
+				}
+				setMempoolStats(status, store, stats)
+			})
+		}()
+	}
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		if rt == mempoolRefreshResponse {
+			refresh()
+			return
+		}
+		dialog.Destroy()
+	})
+	refresh()
+
+	return dialog, nil
+}
+
+// setMempoolStats shows the summary of the mempool in the status label
+// and the fee histogram in store, highest fee rates first.
+//
+// This must be run from the GTK main event loop.
+func setMempoolStats(status *gtk.Label, store *gtk.ListStore, stats *mempoolStats) {
+	fees, _ := btcutil.NewAmount(stats.fees)
+	status.SetText(fmt.Sprintf("%d transactions waiting to be mined, "+
+		"%.1f kB in total paying %s in fees.", stats.count,
+		float64(stats.size)/1000, formatAmount(fees)))
+
+	store.Clear()
+	for i := len(stats.buckets) - 1; i >= 0; i-- {
+		bucket := &stats.buckets[i]
+		var share float64
+		if stats.size != 0 {
+			share = float64(bucket.size) / float64(stats.size) * 100
+		}
+		err := store.Set(store.Append(),
+			[]int{mpColRate, mpColCount, mpColSize, mpColShare},
+			[]interface{}{bucket.String(), bucket.count,
+				fmt.Sprintf("%.1f kB", float64(bucket.size)/1000),
+				fmt.Sprintf("%.0f%%", share)})
+		if err != nil {
+			log.Print(err)
+		}
+	}
+}
@@ -47,7 +47,45 @@ func createFileMenu() *gtk.MenuItem {
 
 	menu.SetSubmenu(dropdown)
 
-	mitem, err := gtk.MenuItemNewWithMnemonic("E_xit")
+	mitem, err := gtk.MenuItemNewWithMnemonic("_Export Transactions...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		if dialog, err := createExportDialog(allTxAttrs()); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	dropdown.Append(mitem)
+
+	mitem, err = gtk.MenuItemNewWithMnemonic("_Import Keys and Addresses...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		importFromFile()
+	})
+	dropdown.Append(mitem)
+
+	mitem, err = gtk.MenuItemNewWithMnemonic("_Sign Raw Transaction...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		signRawTxFromFile()
+	})
+	dropdown.Append(mitem)
+	mitem.SetSensitive(!cfg.WatchOnly)
+
+	sep, err := gtk.SeparatorMenuItemNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	dropdown.Append(sep)
+
+	mitem, err = gtk.MenuItemNewWithMnemonic("E_xit")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -60,6 +98,59 @@ func createFileMenu() *gtk.MenuItem {
 	return menu
 }
 
+func createViewMenu() *gtk.MenuItem {
+	menu, err := gtk.MenuItemNewWithMnemonic("_View")
+	if err != nil {
+		log.Fatal(err)
+	}
+	dropdown, err := gtk.MenuNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	menu.SetSubmenu(dropdown)
+
+	mitem, err := gtk.MenuItemNewWithLabel("Address Groupings...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		if dialog, err := createAddrGroupsDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	dropdown.Append(mitem)
+
+	mitem, err = gtk.MenuItemNewWithLabel("Event Journal...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		if dialog, err := createJournalDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	dropdown.Append(mitem)
+
+	mitem, err = gtk.MenuItemNewWithLabel("Mempool Fees...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		if dialog, err := createMempoolDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	dropdown.Append(mitem)
+
+	return menu
+}
+
 func createSettingsMenu() *gtk.MenuItem {
 	menu, err := gtk.MenuItemNewWithMnemonic("_Settings")
 	if err != nil {
@@ -117,7 +208,9 @@ func createSettingsMenu() *gtk.MenuItem {
 	}
 	mitem.Connect("activate", func() {
 		go func() {
-			triggers.lockWallet <- 1
+			if err := lockWallet(); err != nil {
+				log.Printf("[ERR] cannot lock wallet: %v", err)
+			}
 		}()
 	})
 	dropdown.Append(mitem)
@@ -139,6 +232,20 @@ func createSettingsMenu() *gtk.MenuItem {
 	mitem.SetSensitive(false)
 	MenuBar.Settings.Unlock = mitem
 
+	mitem, err = gtk.MenuItemNewWithLabel("Show Wallet Seed...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		if dialog, err := createSeedDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	dropdown.Append(mitem)
+	mitem.SetSensitive(!cfg.WatchOnly)
+
 	sep, err := gtk.SeparatorMenuItemNew()
 	if err != nil {
 		log.Fatal(err)
@@ -160,6 +267,73 @@ func createSettingsMenu() *gtk.MenuItem {
 	//mitem.SetSensitive(false)
 	MenuBar.Settings.TxFee = mitem
 
+	mitem, err = gtk.MenuItemNewWithLabel("Set GUI PIN...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		if dialog, err := createPINDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	dropdown.Append(mitem)
+
+	mitem, err = gtk.MenuItemNewWithLabel("Encrypt Local Data...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		if dialog, err := createMetadataPassphraseDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	dropdown.Append(mitem)
+
+	return menu
+}
+
+func createToolsMenu() *gtk.MenuItem {
+	menu, err := gtk.MenuItemNewWithMnemonic("_Tools")
+	if err != nil {
+		log.Fatal(err)
+	}
+	dropdown, err := gtk.MenuNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	menu.SetSubmenu(dropdown)
+
+	mitem, err := gtk.MenuItemNewWithLabel("Generate Report...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		if dialog, err := createReportDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	dropdown.Append(mitem)
+
+	mitem, err = gtk.MenuItemNewWithLabel("Payment Reminders...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		if dialog, err := createRemindersDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	dropdown.Append(mitem)
+	mitem.SetSensitive(!cfg.WatchOnly)
+
 	return menu
 }
 
@@ -198,7 +372,9 @@ func createMenuBar() *gtk.MenuBar {
 	}
 
 	m.Append(createFileMenu())
+	m.Append(createViewMenu())
 	m.Append(createSettingsMenu())
+	m.Append(createToolsMenu())
 	m.Append(createHelpMenu())
 
 	return m
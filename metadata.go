@@ -0,0 +1,440 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// metadataFilename is the name of the file in the data directory which
+// holds all GUI-side metadata not recorded by btcwallet, such as labels.
+const metadataFilename = "metadata.json"
+
+// ErrMetadataEncrypted describes the error returned when opening an
+// encrypted metadata store without a passphrase.
+var ErrMetadataEncrypted = errors.New("metadata store is encrypted")
+
+// metadataStore holds GUI-side metadata and saves it to disk after every
+// change.  If a passphrase is set, the store is encrypted on disk.
+type metadataStore struct {
+	sync.RWMutex
+	filename string
+	key      *cryptKey
+
+	// TxLabels maps a transaction address and txid, as created by
+	// txLabelKey, to a user-provided label.
+	TxLabels map[string]string `json:"txlabels"`
+
+	// Tags maps txids to the tags attached to the transaction, in the
+	// order they were entered.
+	Tags map[string][]string `json:"txtags"`
+
+	// AddrLabels maps wallet addresses to user-provided labels.
+	AddrLabels map[string]string `json:"addrlabels"`
+
+	// Contacts holds the external addresses saved in the address book.
+	Contacts []addressBookContact `json:"contacts"`
+
+	// PaymentRequests holds all payment requests, oldest first.
+	PaymentRequests []*paymentRequest `json:"paymentrequests"`
+
+	// View is the state of the main window restored on launch.
+	View viewState `json:"view"`
+
+	// Draft is the unsent contents of the send coins tab, or nil if
+	// nothing has been entered.
+	Draft *sendDraft `json:"draft,omitempty"`
+
+	// Templates holds the saved payee templates, in the order they
+	// were first saved.
+	Templates []payeeTemplate `json:"templates"`
+
+	// Reminders holds the reminders of recurring payments.
+	Reminders []paymentReminder `json:"reminders"`
+
+	// ArchiveBefore is the time before which transactions are archived
+	// and hidden from the transaction list, or the zero time if no
+	// transactions are archived.
+	ArchiveBefore time.Time `json:"archivebefore"`
+
+	// LastBackup is the time the wallet seed was last shown to be
+	// written down, or the zero time if it never was.
+	LastBackup time.Time `json:"lastbackup"`
+}
+
+// addressBookContact is an external address saved in the address book.
+type addressBookContact struct {
+	Label   string `json:"label"`
+	Address string `json:"address"`
+}
+
+// metadata is the metadata store used by the application.  It is
+// replaced by the store read from the data directory when the main
+// application is started.  Until then, changes are not saved to disk.
+var metadata = newMetadataStore("")
+
+// newMetadataStore returns an empty metadata store which will be saved
+// to filename.  An empty filename disables saving.
+func newMetadataStore(filename string) *metadataStore {
+	return &metadataStore{
+		filename:   filename,
+		TxLabels:   make(map[string]string),
+		Tags:       make(map[string][]string),
+		AddrLabels: make(map[string]string),
+	}
+}
+
+// openMetadataStore reads the metadata store from filename.  If the file
+// does not exist, an empty store is returned.  Encrypted stores are
+// decrypted with passphrase, and ErrMetadataEncrypted is returned if
+// passphrase is nil.
+func openMetadataStore(filename string, passphrase []byte) (*metadataStore, error) {
+	s := newMetadataStore(filename)
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if isEncrypted(b) {
+		if passphrase == nil {
+			return nil, ErrMetadataEncrypted
+		}
+		b, s.key, err = decryptData(passphrase, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+
+	// Maps missing from older files are unmarshaled as nil.
+	if s.TxLabels == nil {
+		s.TxLabels = make(map[string]string)
+	}
+	if s.AddrLabels == nil {
+		s.AddrLabels = make(map[string]string)
+	}
+	if s.Tags == nil {
+		s.Tags = make(map[string][]string)
+	}
+	return s, nil
+}
+
+// save writes the metadata store to disk.  The file is first written
+// to a temporary file and then renamed so a crash never leaves a
+// partially written store behind.  The store must be locked by the caller.
+func (s *metadataStore) save() error {
+	if s.filename == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if s.key != nil {
+		if b, err = s.key.Seal(b); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(s.filename), 0700); err != nil {
+		return err
+	}
+	tmp := s.filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.filename)
+}
+
+// Encrypted returns whether the store is encrypted on disk.
+func (s *metadataStore) Encrypted() bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.key != nil
+}
+
+// SetPassphrase sets the passphrase used to encrypt the store and saves
+// it.  An empty passphrase saves the store unencrypted.
+func (s *metadataStore) SetPassphrase(passphrase []byte) error {
+	var key *cryptKey
+	if len(passphrase) != 0 {
+		var err error
+		key, err = deriveCryptKey(passphrase, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.key = key
+	return s.save()
+}
+
+// txLabelKey returns the key used to look up the label of a transaction
+// to or from an address.
+func txLabelKey(address, txid string) string {
+	return address + ":" + txid
+}
+
+// TxLabel returns the label of a transaction to or from an address, or
+// an empty string if no label was set.
+func (s *metadataStore) TxLabel(address, txid string) string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.TxLabels[txLabelKey(address, txid)]
+}
+
+// SetTxLabels sets the labels of each address paid by a single
+// transaction and saves the store.  Empty labels remove any previously
+// set label.
+func (s *metadataStore) SetTxLabels(txid string, labels map[string]string) error {
+	s.Lock()
+	defer s.Unlock()
+	for address, label := range labels {
+		key := txLabelKey(address, txid)
+		if label == "" {
+			delete(s.TxLabels, key)
+		} else {
+			s.TxLabels[key] = label
+		}
+	}
+	return s.save()
+}
+
+// TxTags returns a copy of the tags attached to a transaction.
+func (s *metadataStore) TxTags(txid string) []string {
+	s.RLock()
+	defer s.RUnlock()
+	return append([]string(nil), s.Tags[txid]...)
+}
+
+// SetTxTags replaces the tags attached to a transaction and saves the
+// store.  No tags removes any previously attached tags.
+func (s *metadataStore) SetTxTags(txid string, tags []string) error {
+	s.Lock()
+	defer s.Unlock()
+	if len(tags) == 0 {
+		delete(s.Tags, txid)
+	} else {
+		s.Tags[txid] = append([]string(nil), tags...)
+	}
+	return s.save()
+}
+
+// AllTxTags returns every tag attached to any transaction, sorted and
+// without duplicates.
+func (s *metadataStore) AllTxTags() []string {
+	s.RLock()
+	defer s.RUnlock()
+	seen := make(map[string]struct{})
+	var tags []string
+	for _, tt := range s.Tags {
+		for _, t := range tt {
+			if _, ok := seen[t]; !ok {
+				seen[t] = struct{}{}
+				tags = append(tags, t)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// AddrLabel returns the label of a wallet address, or an empty string if
+// no label was set.
+func (s *metadataStore) AddrLabel(address string) string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.AddrLabels[address]
+}
+
+// SetAddrLabel sets the label of a wallet address and saves the store.
+// An empty label removes any previously set label.
+func (s *metadataStore) SetAddrLabel(address, label string) error {
+	s.Lock()
+	defer s.Unlock()
+	if label == "" {
+		delete(s.AddrLabels, address)
+	} else {
+		s.AddrLabels[address] = label
+	}
+	return s.save()
+}
+
+// AddressBookContacts returns a copy of all contacts saved in the
+// address book.
+func (s *metadataStore) AddressBookContacts() []addressBookContact {
+	s.RLock()
+	defer s.RUnlock()
+	contacts := make([]addressBookContact, len(s.Contacts))
+	copy(contacts, s.Contacts)
+	return contacts
+}
+
+// PaymentRequestList returns a copy of all payment requests.
+func (s *metadataStore) PaymentRequestList() []*paymentRequest {
+	s.RLock()
+	defer s.RUnlock()
+	reqs := make([]*paymentRequest, 0, len(s.PaymentRequests))
+	for _, r := range s.PaymentRequests {
+		c := *r
+		c.TxIDs = append([]string(nil), r.TxIDs...)
+		reqs = append(reqs, &c)
+	}
+	return reqs
+}
+
+// SetPaymentRequestList replaces all payment requests and saves the
+// store.
+func (s *metadataStore) SetPaymentRequestList(reqs []*paymentRequest) error {
+	s.Lock()
+	defer s.Unlock()
+	s.PaymentRequests = reqs
+	return s.save()
+}
+
+// SetAddressBookContacts replaces all contacts saved in the address book
+// and saves the store.
+func (s *metadataStore) SetAddressBookContacts(contacts []addressBookContact) error {
+	s.Lock()
+	defer s.Unlock()
+	s.Contacts = contacts
+	return s.save()
+}
+
+// ViewState returns the main window state saved by the last launch.
+func (s *metadataStore) ViewState() viewState {
+	s.RLock()
+	defer s.RUnlock()
+	return s.View
+}
+
+// SetViewState saves the main window state to restore on the next
+// launch.
+func (s *metadataStore) SetViewState(st viewState) error {
+	s.Lock()
+	defer s.Unlock()
+	s.View = st
+	return s.save()
+}
+
+// SendDraft returns a copy of the unsent contents of the send coins tab
+// saved by the last launch, or nil if there is none.
+func (s *metadataStore) SendDraft() *sendDraft {
+	s.RLock()
+	defer s.RUnlock()
+	if s.Draft == nil {
+		return nil
+	}
+	d := *s.Draft
+	d.Recipients = append([]draftRecipient(nil), s.Draft.Recipients...)
+	return &d
+}
+
+// SetSendDraft saves the unsent contents of the send coins tab, or
+// removes the saved draft if d is nil, and saves the store.
+func (s *metadataStore) SetSendDraft(d *sendDraft) error {
+	s.Lock()
+	defer s.Unlock()
+	s.Draft = d
+	return s.save()
+}
+
+// PayeeTemplates returns a copy of all saved payee templates.
+func (s *metadataStore) PayeeTemplates() []payeeTemplate {
+	s.RLock()
+	defer s.RUnlock()
+	templates := make([]payeeTemplate, len(s.Templates))
+	for i, t := range s.Templates {
+		t.Recipients = append([]draftRecipient(nil), t.Recipients...)
+		templates[i] = t
+	}
+	return templates
+}
+
+// SetPayeeTemplates replaces all saved payee templates and saves the
+// store.
+func (s *metadataStore) SetPayeeTemplates(templates []payeeTemplate) error {
+	s.Lock()
+	defer s.Unlock()
+	s.Templates = templates
+	return s.save()
+}
+
+// PaymentReminders returns a copy of all payment reminders.
+func (s *metadataStore) PaymentReminders() []paymentReminder {
+	s.RLock()
+	defer s.RUnlock()
+	reminders := make([]paymentReminder, len(s.Reminders))
+	copy(reminders, s.Reminders)
+	return reminders
+}
+
+// SetPaymentReminders replaces all payment reminders and saves the
+// store.
+func (s *metadataStore) SetPaymentReminders(reminders []paymentReminder) error {
+	s.Lock()
+	defer s.Unlock()
+	s.Reminders = reminders
+	return s.save()
+}
+
+// ArchiveDate returns the time before which transactions are archived,
+// or the zero time if no transactions are archived.
+func (s *metadataStore) ArchiveDate() time.Time {
+	s.RLock()
+	defer s.RUnlock()
+	return s.ArchiveBefore
+}
+
+// BackupTime returns the time the wallet seed was last shown to be
+// written down, or the zero time if it never was.
+func (s *metadataStore) BackupTime() time.Time {
+	s.RLock()
+	defer s.RUnlock()
+	return s.LastBackup
+}
+
+// SetBackupTime records that the wallet was backed up at t and saves the
+// store.
+func (s *metadataStore) SetBackupTime(t time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	s.LastBackup = t
+	return s.save()
+}
+
+// SetArchiveDate sets the time before which transactions are archived
+// and saves the store.  The zero time unarchives all transactions.
+func (s *metadataStore) SetArchiveDate(t time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	s.ArchiveBefore = t
+	return s.save()
+}
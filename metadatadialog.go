@@ -0,0 +1,244 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+)
+
+// errMetadataCancelled describes the error returned when the user gives
+// up opening the encrypted metadata store.
+var errMetadataCancelled = errors.New("metadata passphrase was not entered")
+
+// openEncryptedMetadata asks for the passphrase of the encrypted metadata
+// store at filename until the store is opened or the dialog is
+// cancelled.
+//
+// This is written to be run as a goroutine executing outside of the GTK
+// main event loop.
+func openEncryptedMetadata(filename string) (*metadataStore, error) {
+	retry := false
+	for {
+		pass := make(chan []byte)
+		r := retry
+		glib.IdleAdd(func() {
+			askMetadataPassphrase(r, pass)
+		})
+		passphrase, ok := <-pass
+		if !ok {
+			return nil, errMetadataCancelled
+		}
+		md, err := openMetadataStore(filename, passphrase)
+		if err != ErrBadPassphrase {
+			return md, err
+		}
+		retry = true
+	}
+}
+
+// askMetadataPassphrase shows a dialog asking for the passphrase of the
+// encrypted metadata store.  The entered passphrase is sent over pass,
+// or pass is closed if the dialog is cancelled.  If retry is true, the
+// dialog notes that the previous passphrase was incorrect.
+//
+// This must be run from the GTK main event loop.
+func askMetadataPassphrase(retry bool, pass chan []byte) {
+	s, err := runMetadataPassphraseDialog(retry)
+	if err != nil {
+		if err != errMetadataCancelled {
+			log.Print(err)
+		}
+		close(pass)
+		return
+	}
+	pass <- []byte(s)
+}
+
+// runMetadataPassphraseDialog runs a dialog asking for the passphrase of
+// the encrypted metadata store, returning the entered passphrase.
+//
+// This must be run from the GTK main event loop.
+func runMetadataPassphraseDialog(retry bool) (string, error) {
+	msg := "Labels, contacts, and other local data are encrypted.\n" +
+		"Enter the passphrase to open them."
+	if retry {
+		msg = "The passphrase was incorrect.\n" + msg
+	}
+
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return "", err
+	}
+	defer dialog.Destroy()
+	dialog.SetTitle("Open local data")
+	dialog.AddButton("_OK", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return "", err
+	}
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return "", err
+	}
+	b.Add(grid)
+
+	lbl, err := gtk.LabelNew(msg)
+	if err != nil {
+		return "", err
+	}
+	grid.Attach(lbl, 0, 0, 2, 1)
+
+	lbl, err = gtk.LabelNew("Passphrase")
+	if err != nil {
+		return "", err
+	}
+	grid.Attach(lbl, 0, 1, 1, 1)
+	passphrase, err := gtk.EntryNew()
+	if err != nil {
+		return "", err
+	}
+	passphrase.SetVisibility(false)
+	passphrase.SetHExpand(true)
+	passphrase.SetActivatesDefault(true)
+	grid.Attach(passphrase, 1, 1, 1, 1)
+
+	dialog.SetPosition(gtk.WIN_POS_CENTER)
+	dialog.ShowAll()
+
+	if gtk.ResponseType(dialog.Run()) != gtk.RESPONSE_OK {
+		return "", errMetadataCancelled
+	}
+	return passphrase.GetText()
+}
+
+// createMetadataPassphraseDialog creates a dialog to set or change the
+// passphrase used to encrypt the local metadata store.  An empty
+// passphrase removes the encryption.
+func createMetadataPassphraseDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Encrypt local data")
+
+	dialog.AddButton("_OK", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetHExpand(true)
+	grid.SetVExpand(true)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	msg := "Labels, contacts, and other data saved by btcgui can be\n" +
+		"encrypted with a passphrase.  The passphrase will be\n" +
+		"required each time btcgui is started.  Leave the passphrase\n" +
+		"empty to save the data unencrypted."
+	if metadata.Encrypted() {
+		msg = "Local data is currently encrypted.\n" + msg
+	}
+	lbl, err := gtk.LabelNew(msg)
+	if err != nil {
+		return nil, err
+	}
+	grid.Attach(lbl, 0, 0, 2, 1)
+
+	lbl, err = gtk.LabelNew("New passphrase")
+	if err != nil {
+		return nil, err
+	}
+	grid.Attach(lbl, 0, 1, 1, 1)
+	passphrase, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	passphrase.SetVisibility(false)
+	passphrase.SetHExpand(true)
+	grid.Attach(passphrase, 1, 1, 1, 1)
+
+	lbl, err = gtk.LabelNew("Repeat passphrase")
+	if err != nil {
+		return nil, err
+	}
+	grid.Attach(lbl, 0, 2, 1, 1)
+	repeated, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	repeated.SetVisibility(false)
+	repeated.SetHExpand(true)
+	grid.Attach(repeated, 1, 2, 1, 1)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	// Use an IObject as the receiver object.  This may be called with both
+	// a *glib.Object and *gtk.Dialog due to where the signals originate
+	// from.
+	dialog.Connect("response", func(_ glib.IObject, rt gtk.ResponseType) {
+		switch rt {
+		case gtk.RESPONSE_OK:
+			p1, err := passphrase.GetText()
+			if err != nil {
+				log.Print(err)
+				return
+			}
+			p2, err := repeated.GetText()
+			if err != nil {
+				log.Print(err)
+				return
+			}
+			if p1 != p2 {
+				mDialog := gtk.MessageDialogNew(dialog, 0,
+					gtk.MESSAGE_ERROR, gtk.BUTTONS_OK,
+					"Passphrases do not match.")
+				mDialog.SetTitle("Passphrases do not match")
+				mDialog.Run()
+				mDialog.Destroy()
+				return
+			}
+			if err := metadata.SetPassphrase([]byte(p1)); err != nil {
+				mDialog := gtk.MessageDialogNew(dialog, 0,
+					gtk.MESSAGE_ERROR, gtk.BUTTONS_OK,
+					err.Error())
+				mDialog.SetTitle("Cannot save local data")
+				mDialog.Run()
+				mDialog.Destroy()
+				return
+			}
+			dialog.Destroy()
+
+		case gtk.RESPONSE_CANCEL:
+			dialog.Destroy()
+		}
+	})
+
+	return dialog, nil
+}
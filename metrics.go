@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+// metric is a named counter or queue depth, reported by the diagnostics
+// window and the expvar endpoint of the debug server.
+type metric struct {
+	name  string
+	value int64
+}
+
+// queuedSendCount is the number of transactions waiting in the send
+// queue.  The queue itself is only accessed from the GTK main event loop,
+// so its length is mirrored here to be read from other goroutines.
+var queuedSendCount int64
+
+func init() {
+	expvar.Publish("btcgui", expvar.Func(func() interface{} {
+		m := make(map[string]int64)
+		for _, v := range collectMetrics() {
+			m[v.name] = v.value
+		}
+		return m
+	}))
+}
+
+// collectMetrics returns the current counters of the connection to
+// btcwallet and the depths of the internal queues, in the order they are
+// shown by the diagnostics window.
+func collectMetrics() []metric {
+	connStats.Lock()
+	info := connStats.connInfo
+	awaiting := len(connStats.sent)
+	connStats.Unlock()
+
+	reconnects := info.connects
+	if reconnects > 0 {
+		reconnects--
+	}
+
+	var handlers, writes, ntfns int
+	if c := activeClient(); c != nil {
+		handlers = c.Pending()
+		writes, ntfns = c.QueueLen()
+	}
+
+	return []metric{
+		{"rpcsSent", int64(info.requests)},
+		{"repliesReceived", int64(info.replies)},
+		{"requestTimeouts", int64(info.timeouts)},
+		{"notificationsReceived", int64(info.notifications)},
+		{"reconnects", int64(reconnects)},
+		{"awaitingReply", int64(awaiting)},
+		{"replyHandlers", int64(handlers)},
+		{"writeQueue", int64(writes)},
+		{"notificationQueue", int64(ntfns)},
+		{"queuedSends", atomic.LoadInt64(&queuedSendCount)},
+	}
+}
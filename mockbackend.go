@@ -0,0 +1,481 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcgui/rpc"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcutil"
+	"sync"
+)
+
+// mockBackend is a WalletBackend serving canned wallet state instead of
+// requesting it from btcwallet, so the update logic can be run without a
+// live btcwallet.  The same state is served for every account.
+// Transactions sent and keys imported are recorded rather than acted on.
+// If err is set, every request fails with it.
+type mockBackend struct {
+	sync.Mutex
+	balance       btcutil.Amount
+	unconfirmed   btcutil.Amount
+	height        int32
+	txs           []map[string]interface{}
+	sent          []map[string]float64
+	addrs         []string
+	lockedOutputs []mockOutput
+	locked        bool
+	passphrase    string
+	txFee         btcutil.Amount
+	imported      []string
+	seed          string
+	rawSent       []string
+	err           error
+	ntfns         chan btcjson.Cmd
+	done          chan struct{}
+}
+
+// mockOutput is an unspent transaction output of a mockBackend which is
+// locked against spending.
+type mockOutput struct {
+	txid  string
+	vout  uint32
+	value btcutil.Amount
+}
+
+// newMockBackend returns a mock wallet with no balance or transactions.
+func newMockBackend() *mockBackend {
+	return &mockBackend{
+		ntfns: make(chan btcjson.Cmd, rpc.NtfnQueueSize),
+		done:  make(chan struct{}),
+	}
+}
+
+// GetBalance satisfies the WalletBackend interface.
+func (m *mockBackend) GetBalance(account string) (btcutil.Amount, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.balance, m.err
+}
+
+// GetUnconfirmedBalance satisfies the WalletBackend interface.
+func (m *mockBackend) GetUnconfirmedBalance(account string) (btcutil.Amount, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.unconfirmed, m.err
+}
+
+// GetBlockCount satisfies the WalletBackend interface.
+func (m *mockBackend) GetBlockCount() (int32, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.height, m.err
+}
+
+// GetInfo satisfies the WalletBackend interface.  Only the height and
+// transaction fee are replied.
+func (m *mockBackend) GetInfo() (map[string]interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return map[string]interface{}{
+		"blocks":   float64(m.height),
+		"paytxfee": m.txFee.ToUnit(btcutil.AmountBitcoin),
+	}, nil
+}
+
+// GetWalletInfo satisfies the WalletBackend interface.  It replies as a
+// btcwallet without the getwalletinfo method.
+func (m *mockBackend) GetWalletInfo() (map[string]interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return nil, &btcjson.ErrMethodNotFound
+}
+
+// ListAccounts satisfies the WalletBackend interface.  Only the default
+// account is listed.
+func (m *mockBackend) ListAccounts() (map[string]btcutil.Amount, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return map[string]btcutil.Amount{"": m.balance}, nil
+}
+
+// ListTransactions satisfies the WalletBackend interface.
+func (m *mockBackend) ListTransactions(account string) ([]map[string]interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return append([]map[string]interface{}(nil), m.txs...), nil
+}
+
+// SendMany satisfies the WalletBackend interface.  The transaction is
+// recorded in sent and given an ID made up from its index.
+func (m *mockBackend) SendMany(account string, pairs map[string]float64, comment string) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return "", m.err
+	}
+	m.sent = append(m.sent, pairs)
+	return fmt.Sprintf("%064x", len(m.sent)), nil
+}
+
+// Notifications satisfies the WalletBackend interface.  Notifications
+// are delivered by sending them to m.ntfns.
+func (m *mockBackend) Notifications() <-chan btcjson.Cmd {
+	return m.ntfns
+}
+
+// Done satisfies the WalletBackend interface.  A disconnect is simulated
+// by closing m.done.
+func (m *mockBackend) Done() <-chan struct{} {
+	return m.done
+}
+
+// Go satisfies the WalletBackend interface.  f is run in a goroutine of
+// its own, as a mock wallet is never shut down.
+func (m *mockBackend) Go(f func()) {
+	go f()
+}
+
+// GetNewAddress satisfies the WalletBackend interface.  A made up address
+// is added to the addresses of the wallet.
+func (m *mockBackend) GetNewAddress(account string) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return "", m.err
+	}
+	addr := fmt.Sprintf("mockaddress%d", len(m.addrs))
+	m.addrs = append(m.addrs, addr)
+	return addr, nil
+}
+
+// CreateEncryptedWallet satisfies the WalletBackend interface.  The
+// created wallet is locked.
+func (m *mockBackend) CreateEncryptedWallet(passphrase string) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.passphrase = passphrase
+	m.locked = true
+	return nil
+}
+
+// GetAddressesByAccount satisfies the WalletBackend interface.
+func (m *mockBackend) GetAddressesByAccount(account string) ([]string, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return append([]string(nil), m.addrs...), nil
+}
+
+// ListLockUnspent satisfies the WalletBackend interface.
+func (m *mockBackend) ListLockUnspent() ([]rpc.OutPoint, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	outpoints := make([]rpc.OutPoint, 0, len(m.lockedOutputs))
+	for _, op := range m.lockedOutputs {
+		outpoints = append(outpoints, rpc.OutPoint{TxID: op.txid, Vout: op.vout})
+	}
+	return outpoints, nil
+}
+
+// GetTxOutValue satisfies the WalletBackend interface.  Only the value of
+// locked outputs is known, and zero is returned for any other output.
+func (m *mockBackend) GetTxOutValue(txid string, vout uint32) (btcutil.Amount, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return 0, m.err
+	}
+	for _, op := range m.lockedOutputs {
+		if op.txid == txid && op.vout == vout {
+			return op.value, nil
+		}
+	}
+	return 0, nil
+}
+
+// WalletIsLocked satisfies the WalletBackend interface.
+func (m *mockBackend) WalletIsLocked() (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.locked, m.err
+}
+
+// WalletLock satisfies the WalletBackend interface.
+func (m *mockBackend) WalletLock() error {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.locked = true
+	return nil
+}
+
+// WalletPassphrase satisfies the WalletBackend interface.  The wallet is
+// unlocked if passphrase is the one it was created with, and never locks
+// again by itself.
+func (m *mockBackend) WalletPassphrase(passphrase string, timeout int64) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	if passphrase != m.passphrase {
+		return &btcjson.ErrWalletPassphraseIncorrect
+	}
+	m.locked = false
+	return nil
+}
+
+// SetTxFee satisfies the WalletBackend interface.
+func (m *mockBackend) SetTxFee(fee btcutil.Amount) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.txFee = fee
+	return nil
+}
+
+// ValidateAddress satisfies the WalletBackend interface.  Every address
+// is reported valid, and those of the wallet as its own.
+func (m *mockBackend) ValidateAddress(addr string) (map[string]interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	mine := false
+	for _, a := range m.addrs {
+		if a == addr {
+			mine = true
+			break
+		}
+	}
+	return map[string]interface{}{
+		"isvalid": true,
+		"address": addr,
+		"ismine":  mine,
+	}, nil
+}
+
+// ListAddressGroupings satisfies the WalletBackend interface.  Each
+// address of the wallet is its own group.
+func (m *mockBackend) ListAddressGroupings() ([][]rpc.AddressGroupEntry, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	groups := make([][]rpc.AddressGroupEntry, 0, len(m.addrs))
+	for _, addr := range m.addrs {
+		groups = append(groups, []rpc.AddressGroupEntry{{Address: addr}})
+	}
+	return groups, nil
+}
+
+// ImportPrivKey satisfies the WalletBackend interface.  The key is
+// recorded in imported.
+func (m *mockBackend) ImportPrivKey(key, label string, rescan bool) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.imported = append(m.imported, key)
+	return nil
+}
+
+// ImportAddress satisfies the WalletBackend interface.  The address is
+// recorded in imported and added to the addresses of the wallet.
+func (m *mockBackend) ImportAddress(addr, label string, rescan bool) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.imported = append(m.imported, addr)
+	m.addrs = append(m.addrs, addr)
+	return nil
+}
+
+// ExportWalletSeed satisfies the WalletBackend interface.  A mock wallet
+// with no seed set replies as a btcwallet which can not export one.
+func (m *mockBackend) ExportWalletSeed() (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	switch {
+	case m.err != nil:
+		return "", m.err
+	case m.seed == "":
+		return "", &btcjson.ErrMethodNotFound
+	case m.locked:
+		return "", &btcjson.ErrWalletUnlockNeeded
+	}
+	return m.seed, nil
+}
+
+// RecoverWallet satisfies the WalletBackend interface.  The recovered
+// wallet is locked.
+func (m *mockBackend) RecoverWallet(seed, passphrase string) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.seed = seed
+	m.passphrase = passphrase
+	m.locked = true
+	return nil
+}
+
+// GetTransaction satisfies the WalletBackend interface.  The first
+// transaction of the wallet with ID txid is replied.
+func (m *mockBackend) GetTransaction(txid string) (map[string]interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	for _, tx := range m.txs {
+		if id, _ := tx["txid"].(string); id == txid {
+			return tx, nil
+		}
+	}
+	return nil, &btcjson.ErrInvalidAddressOrKey
+}
+
+// ListReceivedByAddress satisfies the WalletBackend interface.  Every
+// address of the wallet is replied with the amounts received by it in
+// the transactions of the wallet, regardless of their confirmations.
+func (m *mockBackend) ListReceivedByAddress(minConf int, includeEmpty bool) ([]map[string]interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	var entries []map[string]interface{}
+	for _, addr := range m.addrs {
+		var amount float64
+		for _, tx := range m.txs {
+			category, _ := tx["category"].(string)
+			if a, _ := tx["address"].(string); a == addr &&
+				category == "receive" {
+				f, _ := tx["amount"].(float64)
+				amount += f
+			}
+		}
+		if amount == 0 && !includeEmpty {
+			continue
+		}
+		entries = append(entries, map[string]interface{}{
+			"account": "",
+			"address": addr,
+			"amount":  amount,
+		})
+	}
+	return entries, nil
+}
+
+// GetRawMempool satisfies the WalletBackend interface.  The mempool is
+// always empty.
+func (m *mockBackend) GetRawMempool() (map[string]interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return map[string]interface{}{}, nil
+}
+
+// GetBlock satisfies the WalletBackend interface.  No blocks are known.
+func (m *mockBackend) GetBlock(hash string) (map[string]interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return nil, &btcjson.ErrInvalidAddressOrKey
+}
+
+// GetBestBlockHash satisfies the WalletBackend interface.  The hash is
+// made up from the height.
+func (m *mockBackend) GetBestBlockHash() (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return "", m.err
+	}
+	return fmt.Sprintf("block%d", m.height), nil
+}
+
+// ListSinceBlock satisfies the WalletBackend interface.  Every
+// transaction of the wallet is replied, whatever the block.
+func (m *mockBackend) ListSinceBlock(hash string) ([]map[string]interface{}, string, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return nil, "", m.err
+	}
+	return m.txs, fmt.Sprintf("block%d", m.height), nil
+}
+
+// SignRawTransaction satisfies the WalletBackend interface.  The
+// transaction is replied unchanged as completely signed.
+func (m *mockBackend) SignRawTransaction(tx string) (string, bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return "", false, m.err
+	}
+	return tx, true, nil
+}
+
+// SendRawTransaction satisfies the WalletBackend interface.  The
+// transaction is recorded in rawSent and given an ID made up from its
+// index.
+func (m *mockBackend) SendRawTransaction(tx string) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return "", m.err
+	}
+	m.rawSent = append(m.rawSent, tx)
+	return fmt.Sprintf("raw%061x", len(m.rawSent)), nil
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+)
+
+// ErrMonitoring describes an error where a request was refused because
+// btcgui is running as a monitoring connection.
+var ErrMonitoring = errors.New("not allowed by a monitoring connection")
+
+// monitoringMethods are the only btcwallet methods requested by a
+// monitoring connection.  None of them unlock the wallet, spend funds,
+// or create, import or reveal keys.  Methods are allowed rather than
+// refused, so requests added later are refused until they are known to
+// be safe.
+var monitoringMethods = map[string]bool{
+	"getaddressesbyaccount": true,
+	"getbalance":            true,
+	"getbestblockhash":      true,
+	"getblock":              true,
+	"getblockcount":         true,
+	"getinfo":               true,
+	"getrawmempool":         true,
+	"gettransaction":        true,
+	"gettxout":              true,
+	"getunconfirmedbalance": true,
+	"getwalletinfo":         true,
+	"listaccounts":          true,
+	"listaddressgroupings":  true,
+	"listalltransactions":   true,
+	"listlockunspent":       true,
+	"listsinceblock":        true,
+	"listreceivedbyaddress": true,
+	"validateaddress":       true,
+	"walletislocked":        true,
+	"walletlock":            true,
+}
+
+// checkMonitoring returns ErrMonitoring if btcgui is a monitoring
+// connection and the request msg is not for one of monitoringMethods.
+// Requests are checked just before being written to btcwallet, so no
+// other request can be sent however it was triggered by the GUI.
+// Messages which can not be parsed as a request are refused.
+func checkMonitoring(msg []byte) error {
+	if !cfg.Monitoring {
+		return nil
+	}
+	var req struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(msg, &req); err != nil ||
+		!monitoringMethods[req.Method] {
+		log.Printf("[WRN] Refused %q request of a monitoring "+
+			"connection", req.Method)
+		return ErrMonitoring
+	}
+	return nil
+}
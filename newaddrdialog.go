@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+)
+
+// createNewAddrDialog creates a dialog asking for the label of a new
+// receiving address.  When accepted, a new address is requested from
+// btcwallet, and once it is received, the label is saved and the address
+// is selected in the receive coins list and copied to the clipboard.
+//
+// TODO: ask for the account once multiple accounts are supported.
+func createNewAddrDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("New address")
+
+	dialog.AddButton("_OK", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetColumnSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	l, err := gtk.LabelNew("Label:")
+	if err != nil {
+		return nil, err
+	}
+	grid.Attach(l, 0, 0, 1, 1)
+	label, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	label.SetHExpand(true)
+	label.SetActivatesDefault(true)
+	label.SetTooltipText("Optional label to remember who this address " +
+		"was given to")
+	grid.Attach(label, 1, 0, 1, 1)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		switch rt {
+		case gtk.RESPONSE_OK:
+			labelStr, err := label.GetText()
+			if err != nil {
+				log.Print(err)
+			}
+			dialog.SetSensitive(false)
+			go func() {
+				addr, err := newAddress()
+				glib.IdleAdd(func() {
+					dialog.Destroy()
+					if err != nil {
+						mDialog := errorDialog("New address generation failed",
+							err.Error())
+						mDialog.Run()
+						mDialog.Destroy()
+					} else {
+						showNewAddr(addr, labelStr)
+					}
+				})
+			}()
+
+		case gtk.RESPONSE_CANCEL:
+			dialog.Destroy()
+		}
+	})
+
+	return dialog, nil
+}
+
+// showNewAddr saves the label of a newly generated address, adds the
+// address to the receive coins list, and selects and copies it.
+//
+// This must be run from the GTK main event loop.
+func showNewAddr(addr, label string) {
+	if err := metadata.SetAddrLabel(addr, label); err != nil {
+		log.Printf("[ERR] cannot save address label: %v", err)
+	}
+	iter := appendRecvAddr(addr, "")
+	noteAddrUnused(addr)
+
+	sel, err := RecvCoins.Treeview.GetSelection()
+	if err != nil {
+		log.Print(err)
+	} else {
+		sel.SelectIter(iter)
+	}
+	copyToClipboard(addr)
+}
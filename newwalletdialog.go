@@ -130,6 +130,26 @@ func createNewWalletDialog() (*gtk.Dialog, error) {
 	})
 	grid.Attach(showEntryText, 1, 3, 2, 1)
 
+	generate, err := gtk.ButtonNewWithLabel("Generate passphrase")
+	if err != nil {
+		return nil, err
+	}
+	generate.SetTooltipText("Create a strong passphrase of random words")
+	generate.SetHAlign(gtk.ALIGN_START)
+	generate.Connect("clicked", func() {
+		p, err := runGeneratedPassphraseDialog(dialog)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		if p == "" {
+			return
+		}
+		passphrase.SetText(p)
+		repeated.SetText(p)
+	})
+	grid.Attach(generate, 1, 4, 1, 1)
+
 	dialog.SetTransientFor(mainWindow)
 	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
 	dialog.ShowAll()
@@ -161,11 +181,10 @@ func createNewWalletDialog() (*gtk.Dialog, error) {
 			}
 			if pStr == rStr {
 				go func() {
-					triggers.newWallet <- &NewWalletParams{
+					err := createWallet(&NewWalletParams{
 						passphrase: pStr,
-					}
-
-					if err := <-triggerReplies.walletCreationErr; err != nil {
+					})
+					if err != nil {
 						glib.IdleAdd(func() {
 							mDialog := gtk.MessageDialogNew(dialog, 0,
 								gtk.MESSAGE_ERROR, gtk.BUTTONS_OK,
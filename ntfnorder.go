@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcgui/rpc"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcws"
+	"log"
+	"sync"
+)
+
+// notifier handles the notifications of the session c one at a time, in
+// the order they were received, so that block heights, balances, and
+// transactions are never applied out of order.  Stale block
+// notifications are dropped.
+//
+// Notifications received before synced is closed are held, rather than
+// applied to wallet state which is still being cleared and requested
+// again, and are handled in order once it is closed.  They are read
+// from c meanwhile so replies to the resynchronizing requests are never
+// blocked behind them.
+func notifier(c *rpc.Client, synced <-chan struct{}) {
+	var held []btcjson.Cmd
+	for synced != nil {
+		select {
+		case n := <-c.Notifications():
+			held = append(held, n)
+		case <-synced:
+			synced = nil
+		case <-c.Done():
+			return
+		}
+	}
+	for _, n := range held {
+		if ntfnOrder.accept(n) {
+			handleNotification(n)
+		}
+	}
+
+	for {
+		select {
+		case n := <-c.Notifications():
+			if ntfnOrder.accept(n) {
+				handleNotification(n)
+			}
+		case <-c.Done():
+			return
+		}
+	}
+}
+
+// ntfnTracker tracks the notifications applied to the GUI across
+// connections to detect stale or duplicate notifications.
+type ntfnTracker struct {
+	sync.Mutex
+
+	// height is the height of the last connected block.
+	height int32
+
+	// txs maps the keys, as created by txKey, of every transaction
+	// shown in the GUI to whether it is shown as mined.
+	txs map[string]bool
+}
+
+// ntfnOrder is the notification tracker used by the application.
+var ntfnOrder = &ntfnTracker{
+	txs: make(map[string]bool),
+}
+
+// txSeen describes whether a transaction is already shown in the GUI.
+type txSeen int
+
+// Transaction states returned by markTxSeen.
+const (
+	// txSeenNew describes a transaction which is not yet shown.
+	txSeenNew txSeen = iota
+
+	// txSeenMined describes a transaction which is shown as unconfirmed
+	// but has since been mined, so its rows must be updated.
+	txSeenMined
+
+	// txSeenDuplicate describes a transaction which is already shown as
+	// it is.
+	txSeenDuplicate
+)
+
+// txKey returns the key identifying a transaction output or input of
+// the transaction history.
+func txKey(attr *TxAttributes) string {
+	return fmt.Sprintf("%s:%s:%d:%d", attr.TxID, attr.Address,
+		attr.Direction, attr.Amount)
+}
+
+// markTxSeen records that a transaction is shown in the GUI, and returns
+// whether it was already shown.
+func markTxSeen(attr *TxAttributes) txSeen {
+	ntfnOrder.Lock()
+	defer ntfnOrder.Unlock()
+	return ntfnOrder.addTx(attr)
+}
+
+// addTx records that a transaction is shown in the GUI, returning whether
+// it was already shown.  The tracker must be locked by the caller.
+func (o *ntfnTracker) addTx(attr *TxAttributes) txSeen {
+	key := txKey(attr)
+	mined, ok := o.txs[key]
+	switch {
+	case !ok:
+		o.txs[key] = !attr.Pending()
+		return txSeenNew
+	case !mined && !attr.Pending():
+		o.txs[key] = true
+		return txSeenMined
+	}
+	return txSeenDuplicate
+}
+
+// resetSeenTxs forgets all transactions shown in the GUI after the
+// transaction history is cleared.
+func resetSeenTxs() {
+	ntfnOrder.Lock()
+	ntfnOrder.txs = make(map[string]bool)
+	ntfnOrder.Unlock()
+}
+
+// ntfnHeight returns the height of the last connected block.
+func ntfnHeight() int32 {
+	ntfnOrder.Lock()
+	defer ntfnOrder.Unlock()
+	return ntfnOrder.height
+}
+
+// resetNtfnHeight forgets the height of the last connected block, so
+// the blocks notified by another or a restarted btcwallet are applied.
+func resetNtfnHeight() {
+	ntfnOrder.Lock()
+	ntfnOrder.height = 0
+	ntfnOrder.Unlock()
+}
+
+// accept returns whether a notification should be applied to the GUI.
+// Blocks connected at or below the height of the last connected block
+// were already applied, such as when btcwallet repeats notifications
+// after a reconnect, and are dropped.  Transactions are checked by their
+// handler, as a transaction already shown must still be updated once it
+// is mined.
+func (o *ntfnTracker) accept(n btcjson.Cmd) bool {
+	o.Lock()
+	defer o.Unlock()
+
+	switch n := n.(type) {
+	case *btcws.BlockConnectedNtfn:
+		if n.Height <= o.height {
+			log.Printf("[WRN] dropping stale blockconnected "+
+				"notification for height %d", n.Height)
+			return false
+		}
+		o.height = n.Height
+
+	case *btcws.BlockDisconnectedNtfn:
+		if n.Height <= o.height {
+			o.height = n.Height - 1
+		}
+	}
+	return true
+}
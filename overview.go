@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"github.com/conformal/gotk3/glib"
 	"github.com/conformal/gotk3/gtk"
 	"log"
 )
@@ -27,8 +28,12 @@ const NOverviewTxs = 5
 var (
 	// Overview holds pointers to widgets shown in the overview tab.
 	Overview = struct {
+		BalanceName   *gtk.Label
 		Balance       *gtk.Label
+		AllAccounts   *gtk.CheckButton
 		Unconfirmed   *gtk.Label
+		Immature      *gtk.Label
+		Locked        *gtk.Label
 		NTransactions *gtk.Label // TODO(jrick): update with value from btcwallet, requires extension.
 		Txs           *gtk.Grid
 		TxList        []*gtk.Widget
@@ -37,8 +42,50 @@ var (
 	}
 
 	// Holds pointers to the latest tx label widgets.
+
+	// defaultBalance is the confirmed balance of the default account,
+	// shown unless the total of all accounts is chosen.  It must only
+	// be accessed from the GTK main event loop.
+	defaultBalance string
 )
 
+// showOverviewBalance shows either the confirmed balance of the default
+// account, or the total of every account of the wallet when chosen,
+// labeling which one is shown.
+//
+// This must be run from the GTK main event loop.
+func showOverviewBalance() {
+	if !Overview.AllAccounts.GetActive() {
+		Overview.BalanceName.SetText("Spendable:")
+		Overview.Balance.SetMarkup("<b>" + defaultBalance + "</b>")
+		return
+	}
+	Overview.BalanceName.SetText("Spendable (all accounts):")
+	go fetchTotalBalance()
+}
+
+// fetchTotalBalance requests the confirmed balance of every account of
+// the wallet, showing it if the total is still chosen.
+//
+// This is written to be run as a goroutine executing outside of the GTK
+// main event loop.
+func fetchTotalBalance() {
+	b := activeBackend()
+	if b == nil {
+		return
+	}
+	total, err := b.GetBalance("*")
+	if err != nil {
+		log.Printf("[ERR] getbalance *: %v", err)
+		return
+	}
+	glib.IdleAdd(func() {
+		if Overview.AllAccounts.GetActive() {
+			Overview.Balance.SetMarkup("<b>" + formatAmount(total) + "</b>")
+		}
+	})
+}
+
 func createWalletInfo() *gtk.Widget {
 	grid, err := gtk.GridNew()
 	if err != nil {
@@ -54,20 +101,39 @@ func createWalletInfo() *gtk.Widget {
 	header.SetHAlign(gtk.ALIGN_START)
 	grid.Attach(header, 0, 0, 1, 1)
 
-	balance, err := gtk.LabelNew("Balance:")
+	balance, err := gtk.LabelNew("Spendable:")
 	if err != nil {
 		log.Fatal(err)
 	}
 	balance.SetHAlign(gtk.ALIGN_START)
+	balance.SetTooltipText("Confirmed funds available to send")
 	grid.Attach(balance, 0, 1, 1, 1)
+	Overview.BalanceName = balance
 
 	unconfirmed, err := gtk.LabelNew("Unconfirmed:")
 	if err != nil {
 		log.Fatal(err)
 	}
 	unconfirmed.SetHAlign(gtk.ALIGN_START)
+	unconfirmed.SetTooltipText("Funds from transactions not yet mined into a block")
 	grid.Attach(unconfirmed, 0, 2, 1, 1)
 
+	immature, err := gtk.LabelNew("Immature:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	immature.SetHAlign(gtk.ALIGN_START)
+	immature.SetTooltipText("Mined coinbase funds which cannot be spent until they mature")
+	grid.Attach(immature, 0, 3, 1, 1)
+
+	locked, err := gtk.LabelNew("Locked:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	locked.SetHAlign(gtk.ALIGN_START)
+	locked.SetTooltipText("Unspent outputs locked against spending")
+	grid.Attach(locked, 0, 4, 1, 1)
+
 	balance, err = gtk.LabelNew("")
 	if err != nil {
 		log.Fatal(err)
@@ -83,20 +149,54 @@ func createWalletInfo() *gtk.Widget {
 	grid.Attach(unconfirmed, 1, 2, 1, 1)
 	Overview.Unconfirmed = unconfirmed
 
+	immature, err = gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	immature.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(immature, 1, 3, 1, 1)
+	Overview.Immature = immature
+
+	locked, err = gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	locked.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(locked, 1, 4, 1, 1)
+	Overview.Locked = locked
+
+	// Funds split across accounts are only all counted by the total,
+	// as the other balances are of the default account.
+	allAccounts, err := gtk.CheckButtonNewWithLabel("Spendable of all accounts")
+	if err != nil {
+		log.Fatal(err)
+	}
+	allAccounts.SetTooltipText("Show the confirmed funds of every " +
+		"account instead of only the default account")
+	allAccounts.Connect("toggled", showOverviewBalance)
+	grid.Attach(allAccounts, 0, 5, 2, 1)
+	Overview.AllAccounts = allAccounts
+
+	if breakdown := createWalletBreakdown(); breakdown != nil {
+		grid.Attach(breakdown, 0, 6, 2, 1)
+	}
+	grid.Attach(createActivitySummary(), 0, 7, 2, 1)
+	grid.Attach(createWalletHealth(), 0, 8, 2, 1)
+
 	/*
 		transactions, err := gtk.LabelNew("Number of transactions:")
 		if err != nil {
 			log.Fatal(err)
 		}
 		transactions.SetHAlign(gtk.ALIGN_START)
-		grid.Attach(transactions, 0, 3, 1, 1)
+		grid.Attach(transactions, 0, 5, 1, 1)
 
 		transactions, err = gtk.LabelNew("a lot")
 		if err != nil {
 			log.Fatal(err)
 		}
 		transactions.SetHAlign(gtk.ALIGN_START)
-		grid.Attach(transactions, 1, 3, 1, 1)
+		grid.Attach(transactions, 1, 5, 1, 1)
 		Overview.NTransactions = transactions
 	*/
 
@@ -143,7 +243,7 @@ func createTxLabel(attr *TxAttributes) (*gtk.Widget, error) {
 	var icon *gtk.Image
 	switch attr.Direction {
 	case Send:
-		amtLabel, err = gtk.LabelNew(attr.Amount.String())
+		amtLabel, err = gtk.LabelNew(formatAmount(attr.Amount))
 		if err != nil {
 			return nil, err
 		}
@@ -160,7 +260,7 @@ func createTxLabel(attr *TxAttributes) (*gtk.Widget, error) {
 		}
 
 	case Recv:
-		amtLabel, err = gtk.LabelNew(attr.Amount.String())
+		amtLabel, err = gtk.LabelNew(formatAmount(attr.Amount))
 		if err != nil {
 			return nil, err
 		}
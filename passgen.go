@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"crypto/rand"
+	"github.com/conformal/gotk3/gtk"
+	"math/big"
+	"strings"
+)
+
+// generatedPassphraseWords is the number of words in a generated
+// passphrase.  This follows the guidance of encryptMessage to use eight
+// or more words, and gives 88 bits of entropy.
+const generatedPassphraseWords = 8
+
+// passphraseWords holds each word of passphraseWordList.
+var passphraseWords = strings.Fields(passphraseWordList)
+
+// generatePassphrase returns a passphrase of random words chosen from
+// passphraseWords using a cryptographically secure random source.
+func generatePassphrase() (string, error) {
+	n := big.NewInt(int64(len(passphraseWords)))
+	words := make([]string, generatedPassphraseWords)
+	for i := range words {
+		idx, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		words[i] = passphraseWords[idx.Int64()]
+	}
+	return strings.Join(words, " "), nil
+}
+
+const generatedPassphraseMessage = "Your new passphrase is shown below.  " +
+	"<b>It will not be shown again.</b>\n" +
+	"\n" +
+	"Write it down on paper and keep it somewhere safe.  Do not save " +
+	"it in a file or take a screenshot.\n" +
+	"\n" +
+	"<span weight=\"bold\" fgcolor=\"red\">WARNING:</span> If this " +
+	"passphrase is lost, your Bitcoins will be unspendable."
+
+// runGeneratedPassphraseDialog generates a new passphrase and shows it
+// once, requiring the user to confirm it was written down.  The
+// passphrase is returned if it was accepted, or an empty string if the
+// dialog was cancelled.
+//
+// This must be run from the GTK main event loop.
+func runGeneratedPassphraseDialog(parent gtk.IWindow) (string, error) {
+	passphrase, err := generatePassphrase()
+	if err != nil {
+		return "", err
+	}
+
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return "", err
+	}
+	defer dialog.Destroy()
+	dialog.SetTitle("Generated passphrase")
+	dialog.SetTransientFor(parent)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+
+	dialog.AddButton("_Use Passphrase", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	dialog.SetResponseSensitive(gtk.RESPONSE_OK, false)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return "", err
+	}
+	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+	grid.SetSizeRequest(500, -1)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return "", err
+	}
+	b.Add(grid)
+
+	l, err := gtk.LabelNew("")
+	if err != nil {
+		return "", err
+	}
+	l.SetLineWrap(true)
+	l.SetMarkup(generatedPassphraseMessage)
+	l.SetAlignment(0, 0)
+	grid.Add(l)
+
+	l, err = gtk.LabelNew(passphrase)
+	if err != nil {
+		return "", err
+	}
+	l.SetSelectable(true)
+	l.SetLineWrap(true)
+	l.OverrideFont("monospace 14")
+	grid.Add(l)
+
+	written, err := gtk.CheckButtonNewWithLabel("I have written down this passphrase")
+	if err != nil {
+		return "", err
+	}
+	written.Connect("toggled", func() {
+		dialog.SetResponseSensitive(gtk.RESPONSE_OK, written.GetActive())
+	})
+	grid.Add(written)
+
+	dialog.ShowAll()
+	if gtk.ResponseType(dialog.Run()) != gtk.RESPONSE_OK {
+		return "", nil
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,253 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"strings"
+)
+
+// payeeTemplate is a named set of recipients, such as a monthly payroll,
+// which may be loaded into the send coins tab to be paid again.
+type payeeTemplate struct {
+	Name       string           `json:"name"`
+	Recipients []draftRecipient `json:"recipients"`
+}
+
+// showTemplatesMenu shows a menu to load one of the saved payee
+// templates into the send coins tab, to save the current recipients as a
+// template, or to delete a template.
+//
+// This must be run from the GTK main event loop.
+func showTemplatesMenu() {
+	menu, err := gtk.MenuNew()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	templates := metadata.PayeeTemplates()
+	for i := range templates {
+		t := &templates[i]
+		mitem, err := gtk.MenuItemNewWithLabel(t.Name)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		mitem.Connect("activate", func() {
+			loadPayeeTemplate(t)
+		})
+		menu.Append(mitem)
+	}
+	if len(templates) != 0 {
+		sep, err := gtk.SeparatorMenuItemNew()
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		menu.Append(sep)
+	}
+
+	mitem, err := gtk.MenuItemNewWithLabel("Save Recipients as Template...")
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	mitem.Connect("activate", func() {
+		if dialog, err := createSaveTemplateDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	menu.Append(mitem)
+
+	mitem, err = gtk.MenuItemNewWithLabel("Delete Template")
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	submenu, err := gtk.MenuNew()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	for _, t := range templates {
+		name := t.Name
+		item, err := gtk.MenuItemNewWithLabel(name)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		item.Connect("activate", func() {
+			deletePayeeTemplate(name)
+		})
+		submenu.Append(item)
+	}
+	mitem.SetSubmenu(submenu)
+	mitem.SetSensitive(len(templates) != 0)
+	menu.Append(mitem)
+
+	menu.ShowAll()
+	menu.PopupAtMouseCursor(nil, nil, 1, 0)
+}
+
+// loadPayeeTemplate replaces the recipients of the send coins tab with
+// the recipients of t, asking first if recipients were already entered.
+// The amounts may then be changed before sending.
+//
+// This must be run from the GTK main event loop.
+func loadPayeeTemplate(t *payeeTemplate) {
+	if d := currentSendDraft(); d != nil && hasRecipients(d) {
+		msg := "Replace the recipients entered in the Send tab with " +
+			"the recipients of " + t.Name + "?"
+		md := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL,
+			gtk.MESSAGE_QUESTION, gtk.BUTTONS_YES_NO, msg)
+		md.SetTitle("Load template")
+		ok := gtk.ResponseType(md.Run()) == gtk.RESPONSE_YES
+		md.Destroy()
+		if !ok {
+			return
+		}
+	}
+	fillRecipients(t.Recipients)
+	noteDraftChanged()
+}
+
+// hasRecipients returns whether any recipient of d has an address or
+// amount entered.
+func hasRecipients(d *sendDraft) bool {
+	for _, r := range d.Recipients {
+		if r.Address != "" || r.Amount != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// savePayeeTemplate saves the recipients rs as the template name,
+// replacing any template of the same name.
+func savePayeeTemplate(name string, rs []draftRecipient) error {
+	templates := metadata.PayeeTemplates()
+	t := payeeTemplate{Name: name, Recipients: rs}
+	for i := range templates {
+		if templates[i].Name == name {
+			templates[i] = t
+			return metadata.SetPayeeTemplates(templates)
+		}
+	}
+	return metadata.SetPayeeTemplates(append(templates, t))
+}
+
+// deletePayeeTemplate deletes the template name.
+//
+// This must be run from the GTK main event loop.
+func deletePayeeTemplate(name string) {
+	templates := metadata.PayeeTemplates()
+	for i := range templates {
+		if templates[i].Name == name {
+			templates = append(templates[:i], templates[i+1:]...)
+			break
+		}
+	}
+	if err := metadata.SetPayeeTemplates(templates); err != nil {
+		d := errorDialog("Cannot delete template", err.Error())
+		d.Run()
+		d.Destroy()
+	}
+}
+
+// createSaveTemplateDialog creates a dialog asking for the name to save
+// the recipients of the send coins tab as.  Recipients without an
+// address are not saved.
+func createSaveTemplateDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Save template")
+
+	dialog.AddButton("_Save", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetColumnSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	l, err := gtk.LabelNew("Name:")
+	if err != nil {
+		return nil, err
+	}
+	grid.Attach(l, 0, 0, 1, 1)
+	name, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	name.SetHExpand(true)
+	name.SetActivatesDefault(true)
+	name.SetTooltipText("Name of the template, such as Monthly payroll.  " +
+		"A template of the same name is replaced.")
+	grid.Attach(name, 1, 0, 1, 1)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		if rt != gtk.RESPONSE_OK {
+			dialog.Destroy()
+			return
+		}
+		s, _ := name.GetText()
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return
+		}
+		var rs []draftRecipient
+		if d := currentSendDraft(); d != nil {
+			for _, r := range d.Recipients {
+				if r.Address != "" {
+					rs = append(rs, r)
+				}
+			}
+		}
+		if len(rs) == 0 {
+			d := errorDialog("Cannot save template",
+				"Enter the address of at least one recipient.")
+			d.Run()
+			d.Destroy()
+			return
+		}
+		if err := savePayeeTemplate(s, rs); err != nil {
+			d := errorDialog("Cannot save template", err.Error())
+			d.Run()
+			d.Destroy()
+			return
+		}
+		dialog.Destroy()
+	})
+
+	return dialog, nil
+}
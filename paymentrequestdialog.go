@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"time"
+)
+
+// payReqExpiries holds the name and duration of each expiry time which
+// may be chosen for a new payment request.  A zero duration never
+// expires.
+var payReqExpiries = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"Never", 0},
+	{"1 hour", time.Hour},
+	{"1 day", 24 * time.Hour},
+	{"1 week", 7 * 24 * time.Hour},
+}
+
+// createPayReqDialog creates a dialog asking for the expected amount,
+// memo, and expiry time of a new payment request.  When accepted, a new
+// address dedicated to the request is requested from btcwallet, and the
+// request is added to the payment requests list once it is received.
+func createPayReqDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("New payment request")
+
+	dialog.AddButton("_OK", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetColumnSpacing(6)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	l, err := gtk.LabelNew("Amount:")
+	if err != nil {
+		return nil, err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(l, 0, 0, 1, 1)
+	amount, err := gtk.SpinButtonNewWithRange(0, 21000000, 0.00000001)
+	if err != nil {
+		return nil, err
+	}
+	amount.SetTooltipText("Expected amount, or zero to accept any amount")
+	grid.Attach(amount, 1, 0, 1, 1)
+
+	l, err = gtk.LabelNew("Memo:")
+	if err != nil {
+		return nil, err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(l, 0, 1, 1, 1)
+	memo, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	memo.SetHExpand(true)
+	memo.SetActivatesDefault(true)
+	grid.Attach(memo, 1, 1, 1, 1)
+
+	l, err = gtk.LabelNew("Expires:")
+	if err != nil {
+		return nil, err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(l, 0, 2, 1, 1)
+	expires, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range payReqExpiries {
+		expires.AppendText(e.name)
+	}
+	expires.SetActive(2)
+	grid.Attach(expires, 1, 2, 1, 1)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		switch rt {
+		case gtk.RESPONSE_OK:
+			amt, err := btcutil.NewAmount(amount.GetValue())
+			if err != nil {
+				d := errorDialog("Invalid amount", err.Error())
+				d.Run()
+				d.Destroy()
+				return
+			}
+			memoStr, err := memo.GetText()
+			if err != nil {
+				log.Print(err)
+			}
+			req := &paymentRequest{
+				Amount:  amt,
+				Memo:    memoStr,
+				Created: time.Now(),
+			}
+			i := expires.GetActive()
+			if i >= 0 && i < len(payReqExpiries) && payReqExpiries[i].duration != 0 {
+				req.Expires = req.Created.Add(payReqExpiries[i].duration)
+			}
+
+			dialog.SetSensitive(false)
+			go func() {
+				addr, err := newAddress()
+				glib.IdleAdd(func() {
+					dialog.Destroy()
+					if err != nil {
+						mDialog := errorDialog("New address generation failed",
+							err.Error())
+						mDialog.Run()
+						mDialog.Destroy()
+					} else {
+						req.Address = addr
+						addPayReq(req)
+						showNewAddr(addr, req.Memo)
+					}
+				})
+			}()
+
+		case gtk.RESPONSE_CANCEL:
+			dialog.Destroy()
+		}
+	})
+
+	return dialog, nil
+}
@@ -0,0 +1,380 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"time"
+)
+
+// paymentRequest is a request for an expected payment to a dedicated
+// address generated for the request.
+type paymentRequest struct {
+	Address string         `json:"address"`
+	Amount  btcutil.Amount `json:"amount"`
+	Memo    string         `json:"memo"`
+	Created time.Time      `json:"created"`
+
+	// Expires is the time after which the request is no longer
+	// expected to be paid, or the zero time if it never expires.
+	Expires time.Time `json:"expires"`
+
+	// Received is the total amount received by the address, and TxIDs
+	// holds the transactions paying it so none is counted twice.
+	Received btcutil.Amount `json:"received"`
+	TxIDs    []string       `json:"txids"`
+	Paid     bool           `json:"paid"`
+
+	// Notified is whether the user was alerted that the request expired
+	// without being paid.
+	Notified bool `json:"notified"`
+}
+
+// Status returns the status of a payment request as shown in the
+// payment requests list.
+func (r *paymentRequest) Status() string {
+	switch {
+	case r.Paid:
+		return "Paid"
+	case r.Expired():
+		return "Expired"
+	case r.Received > 0:
+		return "Partially paid"
+	default:
+		return "Pending"
+	}
+}
+
+// Expired returns whether an unpaid request expired.
+func (r *paymentRequest) Expired() bool {
+	return !r.Paid && !r.Expires.IsZero() && time.Now().After(r.Expires)
+}
+
+// addPayment records a transaction paying the request address, marking
+// the request paid once the expected amount has been received.  It
+// returns false if the transaction was already recorded.
+func (r *paymentRequest) addPayment(txid string, amount btcutil.Amount) bool {
+	for _, id := range r.TxIDs {
+		if id == txid {
+			return false
+		}
+	}
+	r.TxIDs = append(r.TxIDs, txid)
+	r.Received += amount
+	if r.Received >= r.Amount {
+		r.Paid = true
+	}
+	return true
+}
+
+// Columns of the payment requests list store.
+const (
+	payReqColCreated = iota
+	payReqColAddress
+	payReqColAmount
+	payReqColMemo
+	payReqColExpires
+	payReqColStatus
+)
+
+var payReqWidgets struct {
+	store    *gtk.ListStore
+	treeview *gtk.TreeView
+
+	// reqs holds every payment request, in the same order as the rows
+	// of the store.  This must only be accessed from the GTK main event
+	// loop.
+	reqs []*paymentRequest
+}
+
+// setPayReqRow sets the columns of the payment requests row referenced
+// by iter to the values of req.
+//
+// This must be run from the GTK main event loop.
+func setPayReqRow(iter *gtk.TreeIter, req *paymentRequest) {
+	const layout = "01/02/2006 15:04"
+	amount := "Any"
+	if req.Amount > 0 {
+		amount = formatAmount(req.Amount)
+	}
+	expires := "Never"
+	if !req.Expires.IsZero() {
+		expires = req.Expires.Format(layout)
+	}
+	payReqWidgets.store.Set(iter,
+		[]int{payReqColCreated, payReqColAddress, payReqColAmount,
+			payReqColMemo, payReqColExpires, payReqColStatus},
+		[]interface{}{req.Created.Format(layout), req.Address, amount,
+			req.Memo, expires, req.Status()})
+}
+
+// refreshPayReqs resets every row of the payment requests list, updating
+// the status of requests which have since expired.
+//
+// This must be run from the GTK main event loop.
+func refreshPayReqs() {
+	store := payReqWidgets.store
+	iter, ok := store.GetIterFirst()
+	for i := 0; ok && i < len(payReqWidgets.reqs); i++ {
+		setPayReqRow(iter, payReqWidgets.reqs[i])
+		ok = store.IterNext(iter)
+	}
+}
+
+// addPayReq adds a new payment request to the list and saves it.
+//
+// This must be run from the GTK main event loop.
+func addPayReq(req *paymentRequest) {
+	payReqWidgets.reqs = append(payReqWidgets.reqs, req)
+	setPayReqRow(payReqWidgets.store.Append(), req)
+	savePayReqs()
+}
+
+// savePayReqs saves all payment requests to the metadata store.
+//
+// This must be run from the GTK main event loop.
+func savePayReqs() {
+	reqs := make([]*paymentRequest, 0, len(payReqWidgets.reqs))
+	for _, r := range payReqWidgets.reqs {
+		c := *r
+		c.TxIDs = append([]string(nil), r.TxIDs...)
+		reqs = append(reqs, &c)
+	}
+	if err := metadata.SetPaymentRequestList(reqs); err != nil {
+		log.Printf("[ERR] cannot save payment requests: %v", err)
+	}
+}
+
+// notePayReqPayment checks whether a received transaction pays any
+// unpaid payment request, updating and saving the request if it does.
+//
+// This must be run from the GTK main event loop.
+func notePayReqPayment(attr *TxAttributes) {
+	if attr.Direction != Recv || attr.TxID == "" {
+		return
+	}
+	changed := false
+	for _, req := range payReqWidgets.reqs {
+		if req.Paid || req.Address != attr.Address {
+			continue
+		}
+		if req.addPayment(attr.TxID, attr.Amount) {
+			changed = true
+		}
+	}
+	if changed {
+		refreshPayReqs()
+		savePayReqs()
+	}
+}
+
+// selectedPayReq returns the index of the selected payment request, or
+// -1 if no request is selected.
+//
+// This must be run from the GTK main event loop.
+func selectedPayReq() int {
+	sel, err := payReqWidgets.treeview.GetSelection()
+	if err != nil {
+		log.Print(err)
+		return -1
+	}
+	var iter gtk.TreeIter
+	if !sel.GetSelected(nil, &iter) {
+		return -1
+	}
+	val, err := payReqWidgets.store.GetValue(&iter, payReqColAddress)
+	if err != nil {
+		log.Print(err)
+		return -1
+	}
+	addr, _ := val.GetString()
+	for i, req := range payReqWidgets.reqs {
+		if req.Address == addr {
+			return i
+		}
+	}
+	return -1
+}
+
+// refreshPayReqStatus periodically refreshes the payment requests list
+// so requests are shown as expired once their expiry time passes, and
+// alerts for requests which expired without being paid.
+//
+// This is written to be run as a goroutine executing outside of the GTK
+// main event loop.
+func refreshPayReqStatus() {
+	for _ = range time.Tick(time.Minute) {
+		glib.IdleAdd(func() {
+			refreshPayReqs()
+			checkPayReqExpiries()
+		})
+	}
+}
+
+func createPaymentRequests() *gtk.Widget {
+	store, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal(err)
+	}
+	payReqWidgets.store = store
+
+	tv, err := gtk.TreeViewNewWithModel(store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	payReqWidgets.treeview = tv
+
+	cols := []struct {
+		title string
+		col   int
+	}{
+		{"Created", payReqColCreated},
+		{"Address", payReqColAddress},
+		{"Amount", payReqColAmount},
+		{"Memo", payReqColMemo},
+		{"Expires", payReqColExpires},
+		{"Status", payReqColStatus},
+	}
+	for _, c := range cols {
+		cr, err := gtk.CellRendererTextNew()
+		if err != nil {
+			log.Fatal(err)
+		}
+		col, err := gtk.TreeViewColumnNewWithAttribute(c.title, cr,
+			"text", c.col)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if c.col == payReqColMemo {
+			col.SetExpand(true)
+		}
+		tv.AppendColumn(col)
+	}
+
+	payReqWidgets.reqs = metadata.PaymentRequestList()
+	for _, req := range payReqWidgets.reqs {
+		setPayReqRow(store.Append(), req)
+	}
+	go refreshPayReqStatus()
+
+	buttons, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	newReq, err := gtk.ButtonNewWithLabel("New Request...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	newReq.SetSizeRequest(150, -1)
+	newReq.Connect("clicked", func() {
+		if dialog, err := createPayReqDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	buttons.Add(newReq)
+
+	delReq, err := gtk.ButtonNewWithLabel("Delete Request")
+	if err != nil {
+		log.Fatal(err)
+	}
+	delReq.SetSizeRequest(150, -1)
+	delReq.Connect("clicked", func() {
+		i := selectedPayReq()
+		if i < 0 {
+			return
+		}
+		sel, err := tv.GetSelection()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var iter gtk.TreeIter
+		if sel.GetSelected(nil, &iter) {
+			store.Remove(&iter)
+		}
+		reqs := payReqWidgets.reqs
+		payReqWidgets.reqs = append(reqs[:i:i], reqs[i+1:]...)
+		savePayReqs()
+	})
+	buttons.Add(delReq)
+
+	cpyAddr, err := gtk.ButtonNewWithLabel("Copy Address")
+	if err != nil {
+		log.Fatal(err)
+	}
+	cpyAddr.SetSizeRequest(150, -1)
+	cpyAddr.Connect("clicked", func() {
+		if i := selectedPayReq(); i >= 0 {
+			copyToClipboard(payReqWidgets.reqs[i].Address)
+		}
+	})
+	buttons.Add(cpyAddr)
+
+	cpyURI, err := gtk.ButtonNewWithLabel("Copy Request")
+	if err != nil {
+		log.Fatal(err)
+	}
+	cpyURI.SetSizeRequest(150, -1)
+	cpyURI.SetTooltipText("Copy a bitcoin: payment link for the request, " +
+		"renewing it first if it expired")
+	cpyURI.Connect("clicked", func() {
+		if i := selectedPayReq(); i >= 0 {
+			resendPayReq(payReqWidgets.reqs[i])
+		}
+	})
+	buttons.Add(cpyURI)
+
+	regen, err := gtk.ButtonNewWithLabel("Regenerate")
+	if err != nil {
+		log.Fatal(err)
+	}
+	regen.SetSizeRequest(150, -1)
+	regen.SetTooltipText("Create a new request for the same amount and " +
+		"memo paid to a new address")
+	regen.Connect("clicked", func() {
+		if i := selectedPayReq(); i >= 0 {
+			regeneratePayReq(payReqWidgets.reqs[i])
+		}
+	})
+	buttons.Add(regen)
+
+	sw, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sw.Add(tv)
+	sw.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	sw.SetHExpand(true)
+	sw.SetVExpand(true)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+	grid.Add(sw)
+	grid.Add(buttons)
+
+	return &grid.Container.Widget
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"time"
+)
+
+// Responses of the buttons of a payment request expiry alert.
+const (
+	payReqDismiss gtk.ResponseType = iota + 1
+	payReqResend
+	payReqRegenerate
+)
+
+// payReqAlerts holds the addresses of the payment requests whose expiry
+// alert is currently shown.  It must only be accessed from the GTK main
+// event loop.
+var payReqAlerts = make(map[string]bool)
+
+// uri returns the bitcoin: payment URI of the request.
+func (r *paymentRequest) uri() string {
+	u := &bitcoinURI{
+		address: r.Address,
+		label:   metadata.AddrLabel(r.Address),
+		message: r.Memo,
+	}
+	if r.Amount > 0 {
+		u.amount = r.Amount.ToUnit(btcutil.AmountBitcoin)
+	}
+	return u.String()
+}
+
+// lifetime returns how long the request was valid for when it was
+// created, or a day if it never expires.
+func (r *paymentRequest) lifetime() time.Duration {
+	if r.Expires.IsZero() || !r.Expires.After(r.Created) {
+		return 24 * time.Hour
+	}
+	return r.Expires.Sub(r.Created)
+}
+
+// checkPayReqExpiries shows an alert for every payment request which
+// expired before being paid and was not yet alerted for.  Nothing is
+// shown while the GUI is locked until the PIN is entered.
+//
+// This must be run from the GTK main event loop.
+func checkPayReqExpiries() {
+	if mainWindow == nil || pinLock.locked {
+		return
+	}
+	for _, req := range payReqWidgets.reqs {
+		if req.Notified || !req.Expired() || payReqAlerts[req.Address] {
+			continue
+		}
+		showPayReqExpiredAlert(req)
+	}
+}
+
+// showPayReqExpiredAlert shows that payment request req expired without
+// being paid, offering to resend the request with a new expiry time or
+// to regenerate it for a new address.  The request is not alerted for
+// again once the alert is answered.
+//
+// This must be run from the GTK main event loop.
+func showPayReqExpiredAlert(req *paymentRequest) {
+	payReqAlerts[req.Address] = true
+
+	what := "any amount"
+	if req.Amount > 0 {
+		what = formatAmount(req.Amount)
+	}
+	if req.Memo != "" {
+		what += fmt.Sprintf(" (%s)", req.Memo)
+	}
+	msg := fmt.Sprintf("The payment request for %s to %s expired "+
+		"without being paid.", what, req.Address)
+	if req.Received > 0 {
+		msg += fmt.Sprintf("\n\nOnly %s of the expected amount was "+
+			"received.", formatAmount(req.Received))
+	}
+	msg += "\n\nResending renews the request for the same address and " +
+		"copies its payment link.  Regenerating creates a new request " +
+		"for a new address."
+
+	dialog := gtk.MessageDialogNew(mainWindow, 0, gtk.MESSAGE_INFO,
+		gtk.BUTTONS_NONE, msg)
+	dialog.SetTitle("Payment request expired")
+	dialog.AddButton("_Dismiss", payReqDismiss)
+	dialog.AddButton("_Resend", payReqResend)
+	dialog.AddButton("Re_generate", payReqRegenerate)
+	dialog.SetDefaultResponse(payReqDismiss)
+	dialog.Connect("response", func(_ *gtk.MessageDialog, rt gtk.ResponseType) {
+		dialog.Destroy()
+		delete(payReqAlerts, req.Address)
+		req.Notified = true
+		savePayReqs()
+		switch rt {
+		case payReqResend:
+			resendPayReq(req)
+		case payReqRegenerate:
+			regeneratePayReq(req)
+		}
+	})
+	showMainWindow()
+	dialog.Show()
+}
+
+// resendPayReq renews an expired payment request for as long as it was
+// first valid and copies its payment link to the clipboard so it can be
+// sent to the payer again.
+//
+// This must be run from the GTK main event loop.
+func resendPayReq(req *paymentRequest) {
+	if req.Expired() {
+		req.Expires = time.Now().Add(req.lifetime())
+		req.Notified = false
+	}
+	copyToClipboard(req.uri())
+	refreshPayReqs()
+	savePayReqs()
+	setStatus("Payment link copied to clipboard")
+}
+
+// regeneratePayReq creates a new payment request for a new address with
+// the amount and memo of req, valid for as long as req was first valid.
+// The old request is kept so late payments to it are still recorded.
+//
+// This must be run from the GTK main event loop.
+func regeneratePayReq(req *paymentRequest) {
+	now := time.Now()
+	newReq := &paymentRequest{
+		Amount:  req.Amount,
+		Memo:    req.Memo,
+		Created: now,
+	}
+	if !req.Expires.IsZero() {
+		newReq.Expires = now.Add(req.lifetime())
+	}
+	go func() {
+		addr, err := newAddress()
+		glib.IdleAdd(func() {
+			if err != nil {
+				d := errorDialog("New address generation failed",
+					err.Error())
+				d.Run()
+				d.Destroy()
+			} else {
+				newReq.Address = addr
+				addPayReq(newReq)
+				showNewAddr(addr, newReq.Memo)
+				copyToClipboard(newReq.uri())
+				setStatus("Payment link for regenerated request " +
+					"copied to clipboard")
+			}
+		})
+	}()
+}
@@ -0,0 +1,313 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pinFilename is the name of the file in the data directory holding
+// pinCheck encrypted with the GUI PIN.  No PIN is required if it does not
+// exist.
+const pinFilename = "pin"
+
+// pinCheck is encrypted with the PIN, so an entered PIN is verified by
+// decrypting it, without the PIN itself ever being stored.
+var pinCheck = []byte("btcgui pin")
+
+// pinLock holds the encrypted pinCheck, or nil if no PIN is set, and
+// whether the PIN must be entered before the main window is shown.  This
+// must only be accessed from the GTK main event loop after the main
+// window is created.
+var pinLock struct {
+	data   []byte
+	locked bool
+}
+
+// loadPIN reads the encrypted pinCheck from the data directory.  The GUI
+// starts locked if a PIN is set.  Automated runs never ask for a PIN.
+func loadPIN() error {
+	if cfg.Harness {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(cfg.DataDir, pinFilename))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	pinLock.data = data
+	pinLock.locked = true
+	return nil
+}
+
+// savePIN sets the GUI PIN, or removes it if pin is empty.
+//
+// This must be run from the GTK main event loop.
+func savePIN(pin string) error {
+	filename := filepath.Join(cfg.DataDir, pinFilename)
+	if pin == "" {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		pinLock.data = nil
+		return nil
+	}
+	data, err := encryptData([]byte(pin), pinCheck)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+		return err
+	}
+	pinLock.data = data
+	return nil
+}
+
+// checkPIN returns whether pin is the GUI PIN.
+//
+// This must be run from the GTK main event loop.
+func checkPIN(pin string) bool {
+	_, _, err := decryptData([]byte(pin), pinLock.data)
+	return err == nil
+}
+
+// lockGUI hides the main window until the PIN is entered, if a PIN is
+// set.  Without a tray icon to show the window again, the PIN is asked
+// for immediately.
+//
+// This must be run from the GTK main event loop.
+func lockGUI() {
+	mainWindow.Hide()
+	if pinLock.data == nil {
+		return
+	}
+	pinLock.locked = true
+	if statusIcon == nil {
+		showMainWindow()
+	}
+}
+
+// unlockGUI asks for the PIN if the GUI is locked, and returns whether
+// the main window may be shown.  Without a tray icon to show the window
+// again later, btcgui quits if the PIN is not entered.
+//
+// This must be run from the GTK main event loop.
+func unlockGUI() bool {
+	if !pinLock.locked {
+		return true
+	}
+
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	defer dialog.Destroy()
+	dialog.SetTitle("btcgui locked")
+	dialog.AddButton("_Unlock", gtk.RESPONSE_OK)
+	if statusIcon == nil {
+		dialog.AddButton("_Quit", gtk.RESPONSE_CANCEL)
+	} else {
+		dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	}
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+	dialog.SetPosition(gtk.WIN_POS_CENTER)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	b.Add(grid)
+
+	msg, err := gtk.LabelNew("Enter the PIN to show btcgui.")
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	grid.Attach(msg, 0, 0, 1, 1)
+
+	pin, err := gtk.EntryNew()
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	pin.SetVisibility(false)
+	pin.Connect("activate", func() {
+		dialog.Response(gtk.RESPONSE_OK)
+	})
+	grid.Attach(pin, 0, 1, 1, 1)
+	dialog.ShowAll()
+
+	for gtk.ResponseType(dialog.Run()) == gtk.RESPONSE_OK {
+		s, _ := pin.GetText()
+		pin.SetText("")
+		if checkPIN(s) {
+			pinLock.locked = false
+			noteUserActivity()
+			return true
+		}
+		msg.SetText("Incorrect PIN.  Enter the PIN to show btcgui.")
+	}
+	if statusIcon == nil {
+		gtk.MainQuit()
+	}
+	return false
+}
+
+// pinIdleLocker periodically checks whether the user has been idle for
+// longer than the pinidle option allows, and if so, locks the GUI until
+// the PIN is entered.  This must be run as a goroutine, and only returns
+// if locking the idle GUI is disabled.
+func pinIdleLocker() {
+	if cfg.PINIdle <= 0 {
+		return
+	}
+	limit := time.Duration(cfg.PINIdle) * time.Minute
+	for _ = range time.Tick(30 * time.Second) {
+		if idleDuration() > limit {
+			glib.IdleAdd(func() {
+				if pinLock.data != nil && !pinLock.locked &&
+					mainWindow.GetVisible() {
+					lockGUI()
+				}
+			})
+		}
+	}
+}
+
+// createPINDialog creates a dialog to set, change or remove the GUI PIN.
+// The current PIN must be entered to change or remove it.
+func createPINDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Set GUI PIN")
+
+	dialog.AddButton("_OK", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetColumnSpacing(6)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	l, err := gtk.LabelNew("The PIN must be entered to show the window " +
+		"after it is hidden in the tray or btcgui is left idle.  It " +
+		"protects the privacy of balances on a shared desktop, and is " +
+		"unrelated to the wallet passphrase.  Leave the new PIN empty " +
+		"to remove it.")
+	if err != nil {
+		return nil, err
+	}
+	l.SetLineWrap(true)
+	l.SetWidthChars(50)
+	grid.Attach(l, 0, 0, 2, 1)
+
+	row := 1
+	addEntry := func(text string) (*gtk.Entry, error) {
+		l, err := gtk.LabelNew(text)
+		if err != nil {
+			return nil, err
+		}
+		l.SetHAlign(gtk.ALIGN_START)
+		grid.Attach(l, 0, row, 1, 1)
+		e, err := gtk.EntryNew()
+		if err != nil {
+			return nil, err
+		}
+		e.SetVisibility(false)
+		e.SetHExpand(true)
+		grid.Attach(e, 1, row, 1, 1)
+		row++
+		return e, nil
+	}
+	var current *gtk.Entry
+	if pinLock.data != nil {
+		if current, err = addEntry("Current PIN:"); err != nil {
+			return nil, err
+		}
+	}
+	newPIN, err := addEntry("New PIN:")
+	if err != nil {
+		return nil, err
+	}
+	repeated, err := addEntry("Confirm new PIN:")
+	if err != nil {
+		return nil, err
+	}
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		if rt != gtk.RESPONSE_OK {
+			dialog.Destroy()
+			return
+		}
+
+		var msg string
+		p, _ := newPIN.GetText()
+		r, _ := repeated.GetText()
+		if current != nil {
+			c, _ := current.GetText()
+			if !checkPIN(c) {
+				msg = "The current PIN is incorrect."
+			}
+		}
+		if msg == "" && p != r {
+			msg = "The new PINs do not match."
+		}
+		if msg == "" {
+			if err := savePIN(p); err != nil {
+				msg = err.Error()
+			}
+		}
+		if msg != "" {
+			d := errorDialog("Cannot set PIN", msg)
+			d.Run()
+			d.Destroy()
+			return
+		}
+		dialog.Destroy()
+	})
+
+	return dialog, nil
+}
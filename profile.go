@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// profilesDirname is the directory under the btcgui home directory
+// holding one directory per named profile.  Each profile directory has
+// its own configuration file, CA certificate copy, and data directory,
+// so separate wallets or networks do not share connection settings or
+// metadata.
+const profilesDirname = "profiles"
+
+// errNoProfile describes the error where the profile chooser shown at
+// startup was closed without choosing a profile.
+var errNoProfile = errors.New("no profile chosen")
+
+// profilesDir is the directory holding all named profiles.
+var profilesDir = filepath.Join(btcguiHomeDir, profilesDirname)
+
+// profileDir returns the directory of the profile name.  The empty name
+// is the default profile, which uses the btcgui home directory itself.
+func profileDir(name string) string {
+	if name == "" {
+		return btcguiHomeDir
+	}
+	return filepath.Join(profilesDir, name)
+}
+
+// validateProfileName returns an error if name can not be used as the
+// name of a profile directory.
+func validateProfileName(name string) error {
+	switch {
+	case name == "", name == ".", name == "..":
+		return fmt.Errorf("'%s' is not a valid profile name", name)
+	case strings.ContainsAny(name, "/\\:"):
+		return fmt.Errorf("Profile name '%s' may not contain path "+
+			"separators", name)
+	}
+	return nil
+}
+
+// listProfiles returns the sorted names of all existing named profiles.
+// Errors reading the profiles directory, such as when no profile has
+// ever been created, result in no profiles.
+func listProfiles() []string {
+	fis, err := ioutil.ReadDir(profilesDir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, fi := range fis {
+		if fi.IsDir() && validateProfileName(fi.Name()) == nil {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
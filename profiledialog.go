@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/gtk"
+)
+
+// defaultProfileLabel is shown in the profile chooser for the default
+// profile, which has no name.
+const defaultProfileLabel = "Default"
+
+// chooseProfile runs a dialog asking which profile to start with, out of
+// the default profile and the named profiles in names.  The empty string
+// is returned for the default profile, and errNoProfile if the dialog is
+// closed without choosing.
+//
+// This must be run after GTK is initialized, but may be run before the
+// main event loop is started.
+func chooseProfile(names []string) (string, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return "", err
+	}
+	defer dialog.Destroy()
+	dialog.SetTitle("Choose profile")
+	dialog.SetPosition(gtk.WIN_POS_CENTER)
+
+	dialog.AddButton("_Open", gtk.RESPONSE_OK)
+	dialog.AddButton("_Quit", gtk.RESPONSE_CANCEL)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return "", err
+	}
+	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return "", err
+	}
+	b.Add(grid)
+
+	l, err := gtk.LabelNew("Each profile has its own connection settings " +
+		"and data.  Choose the profile to open:")
+	if err != nil {
+		return "", err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Add(l)
+
+	combo, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		return "", err
+	}
+	combo.AppendText(defaultProfileLabel)
+	for _, name := range names {
+		combo.AppendText(name)
+	}
+	combo.SetActive(0)
+	grid.Add(combo)
+
+	dialog.ShowAll()
+	if gtk.ResponseType(dialog.Run()) != gtk.RESPONSE_OK {
+		return "", errNoProfile
+	}
+	if i := combo.GetActive(); i > 0 {
+		return names[i-1], nil
+	}
+	return "", nil
+}
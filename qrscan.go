@@ -0,0 +1,230 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// External programs used to decode QR codes from images and to capture
+// a region of the screen.  They are provided by the zbar-tools and
+// ImageMagick packages of most distributions.
+const (
+	qrDecoderProgram  = "zbarimg"
+	screenshotProgram = "import"
+)
+
+// ErrNoPaymentQR describes the error returned when an image holds no QR
+// code with a bitcoin: URI or address.
+var ErrNoPaymentQR = errors.New("no bitcoin payment QR code was found " +
+	"in the image")
+
+// programError describes an external program which could not be run,
+// suggesting how to install it when it is missing.
+func programError(program, pkg string, err error) error {
+	if e, ok := err.(*exec.Error); ok && e.Err == exec.ErrNotFound {
+		return fmt.Errorf("%s was not found.  Install %s to scan "+
+			"QR codes.", program, pkg)
+	}
+	return fmt.Errorf("%s failed: %v", program, err)
+}
+
+// decodePaymentQR decodes the QR codes of the image file filename,
+// returning the first bitcoin: URI found.  QR codes holding only an
+// address are returned as a URI for that address.
+func decodePaymentQR(filename string) (string, error) {
+	out, err := exec.Command(qrDecoderProgram, "--quiet", "--raw",
+		filename).Output()
+	if err != nil {
+		// zbarimg exits with status 4 if no code was found.
+		if e, ok := err.(*exec.ExitError); ok && len(out) == 0 &&
+			len(e.Stderr) == 0 {
+			return "", ErrNoPaymentQR
+		}
+		return "", programError(qrDecoderProgram, "zbar-tools", err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if isBitcoinURI(line) {
+			return line, nil
+		}
+		if _, err := btcutil.DecodeAddress(line, activeNet.Params); err == nil {
+			return bitcoinURIScheme + ":" + line, nil
+		}
+	}
+	return "", ErrNoPaymentQR
+}
+
+// captureScreenRegion lets the user select a region of the screen with
+// the mouse, saving it to a temporary image file.  The caller must
+// remove the file.
+func captureScreenRegion() (string, error) {
+	f, err := ioutil.TempFile("", "btcgui-qr")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	filename := f.Name() + ".png"
+	os.Remove(f.Name())
+	if err := exec.Command(screenshotProgram, filename).Run(); err != nil {
+		os.Remove(filename)
+		return "", programError(screenshotProgram, "ImageMagick", err)
+	}
+	return filename, nil
+}
+
+// fillRecipientFromURI fills in the first empty recipient of the send
+// coins tab, or a new recipient if none is empty, with the payment of
+// the bitcoin: URI s.
+//
+// This must be run from the GTK main event loop.
+func fillRecipientFromURI(s string) error {
+	uri, err := parseBitcoinURI(s)
+	if err != nil {
+		return err
+	}
+	var r *recipient
+	for e := recipients.Front(); e != nil; e = e.Next() {
+		rr := e.Value.(*recipient)
+		if addr, _ := rr.payTo.GetText(); addr == "" {
+			r = rr
+			break
+		}
+	}
+	if r == nil {
+		insertSendEntries(SendCoins.EntryGrid)
+		r = recipients.Back().Value.(*recipient)
+	}
+	r.payTo.SetText(uri.address)
+	r.amount.SetValue(uri.amount)
+	r.label.SetText(uri.label)
+	if c, _ := SendCoins.Comment.GetText(); c == "" {
+		SendCoins.Comment.SetText(uri.message)
+	}
+	return nil
+}
+
+// scanPaymentQR decodes the payment QR code of the image returned by
+// image, filling in a recipient of the send coins tab with it.  remove
+// is set when the image is a temporary file to be removed afterwards.
+//
+// This is written to be run as a goroutine executing outside of the GTK
+// main event loop.
+func scanPaymentQR(image func() (string, error), remove bool) {
+	showErr := func(err error) {
+		glib.IdleAdd(func() {
+			d := errorDialog("Cannot scan QR code", err.Error())
+			d.Run()
+			d.Destroy()
+		})
+	}
+	filename, err := image()
+	if err != nil {
+		showErr(err)
+		return
+	}
+	if filename == "" {
+		return
+	}
+	uri, err := decodePaymentQR(filename)
+	if remove {
+		os.Remove(filename)
+	}
+	if err != nil {
+		showErr(err)
+		return
+	}
+	glib.IdleAdd(func() {
+		if err := fillRecipientFromURI(uri); err != nil {
+			d := errorDialog("Invalid payment QR code", err.Error())
+			d.Run()
+			d.Destroy()
+		}
+	})
+}
+
+// chooseQRImage asks for an image file holding a payment QR code,
+// returning the empty string if none was chosen.
+//
+// This must be run from the GTK main event loop.
+func chooseQRImage() string {
+	fc, err := gtk.FileChooserDialogNewWith2Buttons("Open QR Image",
+		mainWindow, gtk.FILE_CHOOSER_ACTION_OPEN,
+		"_Cancel", gtk.RESPONSE_CANCEL,
+		"_Open", gtk.RESPONSE_ACCEPT)
+	if err != nil {
+		log.Print(err)
+		return ""
+	}
+	defer fc.Destroy()
+	if gtk.ResponseType(fc.Run()) != gtk.RESPONSE_ACCEPT {
+		return ""
+	}
+	return fc.GetFilename()
+}
+
+// showQRScanMenu shows a menu to scan a payment QR code from an image
+// file or from a region of the screen, such as an invoice shown by
+// another application.
+//
+// This must be run from the GTK main event loop.
+func showQRScanMenu() {
+	menu, err := gtk.MenuNew()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	mitem, err := gtk.MenuItemNewWithLabel("Open QR Image...")
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	mitem.Connect("activate", func() {
+		filename := chooseQRImage()
+		if filename == "" {
+			return
+		}
+		go scanPaymentQR(func() (string, error) {
+			return filename, nil
+		}, false)
+	})
+	menu.Append(mitem)
+
+	mitem, err = gtk.MenuItemNewWithLabel("Scan Screen Region...")
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	mitem.SetTooltipText("Drag over the QR code with the mouse to scan it")
+	mitem.Connect("activate", func() {
+		go scanPaymentQR(captureScreenRegion, true)
+	})
+	menu.Append(mitem)
+
+	menu.ShowAll()
+	menu.PopupAtMouseCursor(nil, nil, 1, 0)
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/gdk"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+)
+
+// keyEscape is the key value of the Escape key.
+const keyEscape = 0xff1b
+
+// quickUnlockTimeout is the timeout in seconds first chosen for a quick
+// unlock from the statusbar.
+const quickUnlockTimeout = 300
+
+// quickUnlock holds the widgets of the statusbar form unlocking the
+// wallet without opening the unlock dialog.  The form is shown by
+// clicking the lock button, which is only shown while the wallet is
+// locked.
+var quickUnlock struct {
+	button     *gtk.Button
+	form       *gtk.Box
+	passphrase *gtk.Entry
+	timeout    *gtk.SpinButton
+
+	// busy is set while an unlock request is waiting for its reply.
+	// This must only be accessed from the GTK main event loop.
+	busy bool
+}
+
+// createQuickUnlock creates the lock button of the statusbar and the form
+// shown next to it to enter a passphrase and timeout.
+func createQuickUnlock() *gtk.Widget {
+	box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 3)
+	if err != nil {
+		log.Fatal("Unable to create box:", err)
+	}
+
+	b, err := gtk.ButtonNew()
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	img, err := gtk.ImageNewFromIconName("changes-prevent",
+		gtk.ICON_SIZE_MENU)
+	if err != nil {
+		log.Fatal("Unable to create image:", err)
+	}
+	b.SetImage(img)
+	b.SetRelief(gtk.RELIEF_NONE)
+	b.SetTooltipText("The wallet is locked.  Click to unlock it.")
+	b.SetNoShowAll(true)
+	b.Connect("clicked", func() {
+		if quickUnlock.form.GetVisible() {
+			hideQuickUnlock()
+		} else {
+			showQuickUnlock()
+		}
+	})
+	box.PackStart(b, false, false, 0)
+	quickUnlock.button = b
+
+	form, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 3)
+	if err != nil {
+		log.Fatal("Unable to create box:", err)
+	}
+	box.PackStart(form, false, false, 0)
+	quickUnlock.form = form
+
+	e, err := gtk.EntryNew()
+	if err != nil {
+		log.Fatal("Unable to create entry:", err)
+	}
+	e.SetVisibility(false)
+	e.SetWidthChars(16)
+	e.SetPlaceholderText("Passphrase")
+	e.Connect("activate", func() {
+		submitQuickUnlock()
+	})
+	e.Connect("key-press-event", func(_ *gtk.Entry, ev *gdk.Event) bool {
+		ek := &gdk.EventKey{Event: ev}
+		if ek.KeyVal() == keyEscape {
+			hideQuickUnlock()
+			return true
+		}
+		return false
+	})
+	form.PackStart(e, false, false, 0)
+	quickUnlock.passphrase = e
+
+	spin, err := gtk.SpinButtonNewWithRange(1, sessionUnlockTimeout, 60)
+	if err != nil {
+		log.Fatal("Unable to create spin button:", err)
+	}
+	spin.SetValue(quickUnlockTimeout)
+	spin.SetTooltipText("Seconds until the wallet is locked again")
+	spin.Connect("activate", func() {
+		submitQuickUnlock()
+	})
+	form.PackStart(spin, false, false, 0)
+	quickUnlock.timeout = spin
+
+	l, err := gtk.LabelNew("s")
+	if err != nil {
+		log.Fatal("Unable to create label:", err)
+	}
+	form.PackStart(l, false, false, 0)
+
+	ub, err := gtk.ButtonNewWithLabel("Unlock")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	ub.Connect("clicked", func() {
+		submitQuickUnlock()
+	})
+	form.PackStart(ub, false, false, 0)
+
+	// The form is hidden until the lock button is clicked, while its
+	// children are always shown with it.
+	form.ShowAll()
+	form.Hide()
+	form.SetNoShowAll(true)
+
+	return &box.Container.Widget
+}
+
+// setQuickUnlockShown shows the lock button of the statusbar if show is
+// set, which it should be while connected to a locked wallet.  The form
+// is hidden along with the button.
+//
+// This must be run from the GTK main event loop.
+func setQuickUnlockShown(show bool) {
+	if show && !cfg.WatchOnly {
+		quickUnlock.button.Show()
+		return
+	}
+	hideQuickUnlock()
+	quickUnlock.button.Hide()
+}
+
+// showQuickUnlock shows the quick unlock form with the passphrase entry
+// focused.
+//
+// This must be run from the GTK main event loop.
+func showQuickUnlock() {
+	quickUnlock.form.Show()
+	quickUnlock.passphrase.GrabFocus()
+}
+
+// hideQuickUnlock hides the quick unlock form and clears the passphrase.
+//
+// This must be run from the GTK main event loop.
+func hideQuickUnlock() {
+	quickUnlock.passphrase.SetText("")
+	quickUnlock.form.Hide()
+}
+
+// submitQuickUnlock unlocks the wallet with the passphrase and timeout
+// entered in the quick unlock form.  The form is hidden once the wallet
+// is unlocked, or the passphrase is selected to be typed again if
+// unlocking failed.
+//
+// This must be run from the GTK main event loop.
+func submitQuickUnlock() {
+	if quickUnlock.busy {
+		return
+	}
+	pass, err := quickUnlock.passphrase.GetText()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if pass == "" {
+		return
+	}
+	params := &UnlockParams{
+		passphrase: pass,
+		timeout:    int64(quickUnlock.timeout.GetValueAsInt()),
+	}
+
+	quickUnlock.busy = true
+	quickUnlock.form.SetSensitive(false)
+	go func() {
+		err := unlockWallet(params)
+		glib.IdleAdd(func() {
+			quickUnlock.busy = false
+			quickUnlock.form.SetSensitive(true)
+			if err != nil {
+				setStatus("Cannot unlock wallet: " +
+					walletErrorMessage(err))
+				quickUnlock.passphrase.GrabFocus()
+				quickUnlock.passphrase.SelectRegion(0, -1)
+				return
+			}
+			hideQuickUnlock()
+			setStatus("Wallet unlocked")
+		})
+	}()
+}
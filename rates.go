@@ -0,0 +1,323 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/conformal/go-socks"
+	"github.com/conformal/gotk3/glib"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// rateCacheFilename is the name of the file in the data directory
+	// holding the last received exchange rate, so fiat amounts can be
+	// shown before any rate source answers, or while offline.
+	rateCacheFilename = "rate.json"
+
+	// rateRefreshInterval is how often the exchange rate is requested.
+	rateRefreshInterval = 5 * time.Minute
+
+	// rateStaleAge is the age after which an exchange rate is shown as
+	// outdated.
+	rateStaleAge = 30 * time.Minute
+
+	// rateTimeout is the longest time waited for a rate source to
+	// answer.
+	rateTimeout = 30 * time.Second
+)
+
+// defaultRateSources are the exchange rate sources tried in order, each
+// given as a URL template and the dot separated path to the rate in the
+// JSON response.  {currency} is replaced with the fiat currency code.
+var defaultRateSources = []string{
+	"https://api.coindesk.com/v1/bpi/currentprice/{currency}.json bpi.{currency}.rate_float",
+	"https://api.coinbase.com/v2/prices/BTC-{currency}/spot data.amount",
+	"https://blockchain.info/ticker {currency}.last",
+}
+
+// ErrNoRate describes the error where a rate source response does not
+// hold a usable exchange rate.
+var ErrNoRate = errors.New("response does not include an exchange rate")
+
+// rateProvider is a source of the price of one bitcoin in the fiat
+// currency.
+type rateProvider struct {
+	url  string
+	path string
+}
+
+// parseRateSource parses a rate source option, a URL template followed
+// by whitespace and the path to the rate in the JSON response.
+func parseRateSource(s string) (rateProvider, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return rateProvider{}, fmt.Errorf("The ratesource option "+
+			"must be a URL and a JSON path -- got '%s'", s)
+	}
+	u, err := url.Parse(fields[0])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+		u.Host == "" {
+		return rateProvider{}, fmt.Errorf("The ratesource option "+
+			"must start with an http or https URL -- got '%s'", s)
+	}
+	return rateProvider{url: fields[0], path: fields[1]}, nil
+}
+
+// String returns the host name of the rate source, which is shown as
+// the source of the rate.
+func (p rateProvider) String() string {
+	if u, err := url.Parse(p.url); err == nil {
+		return u.Host
+	}
+	return p.url
+}
+
+// exchangeRate holds the most recently received price of one bitcoin in
+// the fiat currency.
+var exchangeRate struct {
+	sync.Mutex
+	rate    float64
+	known   bool
+	source  string
+	updated time.Time
+}
+
+// rateCache is the exchange rate saved in the data directory.
+type rateCache struct {
+	Currency string    `json:"currency"`
+	Rate     float64   `json:"rate"`
+	Source   string    `json:"source"`
+	Updated  time.Time `json:"updated"`
+}
+
+// fiatEnabled returns whether amounts may be shown and entered in a
+// fiat currency.
+func fiatEnabled() bool {
+	return cfg.Fiat != ""
+}
+
+// currentRate returns the price of one bitcoin in the fiat currency,
+// and whether any rate has been received yet.
+func currentRate() (float64, bool) {
+	exchangeRate.Lock()
+	defer exchangeRate.Unlock()
+	return exchangeRate.rate, exchangeRate.known
+}
+
+// rateText describes the current exchange rate, noting when it is
+// outdated because no rate source could be reached recently.
+func rateText() string {
+	exchangeRate.Lock()
+	defer exchangeRate.Unlock()
+	text := fmt.Sprintf("%.2f %s/BTC", exchangeRate.rate, cfg.Fiat)
+	if time.Since(exchangeRate.updated) > rateStaleAge {
+		text += fmt.Sprintf(" (outdated, from %v %v)",
+			exchangeRate.source,
+			exchangeRate.updated.Format("Jan 2 15:04"))
+	}
+	return text
+}
+
+// setRate records an exchange rate received from source at time
+// updated.
+func setRate(rate float64, source string, updated time.Time) {
+	exchangeRate.Lock()
+	exchangeRate.rate = rate
+	exchangeRate.known = true
+	exchangeRate.source = source
+	exchangeRate.updated = updated
+	exchangeRate.Unlock()
+}
+
+// loadRateCache sets the exchange rate to the one saved in the data
+// directory, unless it was saved for another fiat currency.
+func loadRateCache() error {
+	buf, err := ioutil.ReadFile(filepath.Join(cfg.DataDir, rateCacheFilename))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var c rateCache
+	if err := json.Unmarshal(buf, &c); err != nil {
+		return err
+	}
+	if c.Currency == cfg.Fiat && c.Rate > 0 {
+		setRate(c.Rate, c.Source, c.Updated)
+	}
+	return nil
+}
+
+// saveRateCache saves the current exchange rate in the data directory.
+func saveRateCache() error {
+	exchangeRate.Lock()
+	c := rateCache{
+		Currency: cfg.Fiat,
+		Rate:     exchangeRate.rate,
+		Source:   exchangeRate.source,
+		Updated:  exchangeRate.updated,
+	}
+	exchangeRate.Unlock()
+	buf, err := json.Marshal(&c)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(cfg.DataDir, rateCacheFilename),
+		buf, 0600)
+}
+
+// rateHTTPClient returns the client used to request exchange rates.
+// When a proxy is configured, rates are requested through it as well,
+// so the rate source never sees the user's address.
+func rateHTTPClient() *http.Client {
+	client := &http.Client{Timeout: rateTimeout}
+	if cfg.Proxy != "" {
+		proxy := &socks.Proxy{
+			Addr:     cfg.Proxy,
+			Username: cfg.ProxyUser,
+			Password: cfg.ProxyPass,
+		}
+		client.Transport = &http.Transport{Dial: proxy.Dial}
+	}
+	return client
+}
+
+// expandRateTemplate replaces {currency} in a rate source URL or path
+// template with the configured fiat currency code.
+func expandRateTemplate(template string) string {
+	return strings.Replace(template, "{currency}", cfg.Fiat, -1)
+}
+
+// fetch requests the price of one bitcoin from the rate source.
+func (p rateProvider) fetch(client *http.Client) (float64, error) {
+	u := expandRateTemplate(p.url)
+	resp, err := client.Get(u)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%v: %v", u, resp.Status)
+	}
+
+	var v interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return 0, err
+	}
+	return jsonPathFloat(v, expandRateTemplate(p.path))
+}
+
+// jsonPathFloat returns the number found by following the dot separated
+// object keys or array indexes of path in a decoded JSON value.  Rates
+// given as strings are parsed as well.
+func jsonPathFloat(v interface{}, path string) (float64, error) {
+	for _, key := range strings.Split(path, ".") {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			v = t[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(t) {
+				return 0, ErrNoRate
+			}
+			v = t[i]
+		default:
+			return 0, ErrNoRate
+		}
+	}
+
+	var rate float64
+	switch t := v.(type) {
+	case float64:
+		rate = t
+	case string:
+		var err error
+		rate, err = strconv.ParseFloat(strings.Replace(t, ",", "", -1), 64)
+		if err != nil {
+			return 0, ErrNoRate
+		}
+	default:
+		return 0, ErrNoRate
+	}
+	if rate <= 0 {
+		return 0, ErrNoRate
+	}
+	return rate, nil
+}
+
+// fetchRate requests the exchange rate from each rate source in turn
+// until one answers, recording and caching the rate.
+func fetchRate(client *http.Client, providers []rateProvider) error {
+	var err error
+	for _, p := range providers {
+		var rate float64
+		rate, err = p.fetch(client)
+		if err != nil {
+			log.Printf("[ERR] cannot get exchange rate from %v: %v",
+				p, err)
+			continue
+		}
+		setRate(rate, p.String(), time.Now())
+		if err := saveRateCache(); err != nil {
+			log.Printf("[ERR] cannot save exchange rate: %v", err)
+		}
+		return nil
+	}
+	return err
+}
+
+// rateUpdater requests the exchange rate every rateRefreshInterval,
+// trying the configured rate sources in order, and converts the fiat
+// amounts entered in the send coins tab again.  Until a source answers,
+// the last saved rate is used and shown as outdated once it is old.
+// This is run as a goroutine.
+func rateUpdater() {
+	if err := loadRateCache(); err != nil {
+		log.Printf("[ERR] cannot read saved exchange rate: %v", err)
+	} else {
+		glib.IdleAdd(updateFiatAmounts)
+	}
+
+	var providers []rateProvider
+	for _, s := range cfg.RateSources {
+		// Rate sources were checked when loading the configuration.
+		p, _ := parseRateSource(s)
+		providers = append(providers, p)
+	}
+
+	client := rateHTTPClient()
+	for {
+		if fetchRate(client, providers) != nil {
+			log.Print("[ERR] no exchange rate source could be reached")
+		}
+		glib.IdleAdd(updateFiatAmounts)
+		time.Sleep(rateRefreshInterval)
+	}
+}
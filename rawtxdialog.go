@@ -0,0 +1,253 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// Responses of the raw transaction dialog buttons which do not close the
+// dialog.
+const (
+	rawTxSignResponse gtk.ResponseType = iota + 1
+	rawTxSaveResponse
+	rawTxBroadcastResponse
+)
+
+// readRawTxFile reads a hex encoded raw transaction from filename,
+// ignoring surrounding whitespace.
+func readRawTxFile(filename string) (string, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	tx := strings.TrimSpace(string(b))
+	if tx == "" {
+		return "", fmt.Errorf("%s is empty", filename)
+	}
+	if _, err := hex.DecodeString(tx); err != nil {
+		return "", fmt.Errorf("%s does not hold a hex encoded "+
+			"transaction", filename)
+	}
+	return tx, nil
+}
+
+// signRawTx requests btcwallet to sign the inputs of the raw transaction
+// tx it holds keys for, returning the signed transaction and whether
+// every input is now signed.  A locked wallet is asked to be unlocked
+// before signing.
+func signRawTx(tx string) (string, bool, error) {
+	b := activeBackend()
+	if b == nil {
+		return "", false, ErrConnectionLost
+	}
+	return b.SignRawTransaction(tx)
+}
+
+// broadcastRawTx requests the signed raw transaction tx to be sent to
+// the network, returning its transaction ID.
+func broadcastRawTx(tx string) (string, error) {
+	b := activeBackend()
+	if b == nil {
+		return "", ErrConnectionLost
+	}
+	return b.SendRawTransaction(tx)
+}
+
+// signRawTxFromFile asks for a file holding an unsigned raw transaction,
+// such as one created on another computer, and opens the raw
+// transaction dialog to sign it.
+//
+// This must be run from the GTK main event loop.
+func signRawTxFromFile() {
+	fc, err := gtk.FileChooserDialogNewWith2Buttons("Open Raw Transaction",
+		mainWindow, gtk.FILE_CHOOSER_ACTION_OPEN,
+		"_Cancel", gtk.RESPONSE_CANCEL,
+		"_Open", gtk.RESPONSE_ACCEPT)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if gtk.ResponseType(fc.Run()) != gtk.RESPONSE_ACCEPT {
+		fc.Destroy()
+		return
+	}
+	filename := fc.GetFilename()
+	fc.Destroy()
+
+	tx, err := readRawTxFile(filename)
+	if err == nil {
+		_, err = createRawTxDialog(filename, tx)
+	}
+	if err != nil {
+		d := errorDialog("Cannot open raw transaction", err.Error())
+		d.Run()
+		d.Destroy()
+	}
+}
+
+// createRawTxDialog creates a dialog to sign the raw transaction tx read
+// from filename with the keys of the wallet.  Once signed, the signed
+// transaction may be saved to a file, such as to be broadcast from
+// another computer, or broadcast to the network when completely signed.
+func createRawTxDialog(filename, tx string) (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Sign raw transaction")
+	dialog.SetDefaultSize(450, -1)
+
+	dialog.AddButton("_Sign", rawTxSignResponse)
+	dialog.AddButton("Sa_ve...", rawTxSaveResponse)
+	dialog.AddButton("_Broadcast", rawTxBroadcastResponse)
+	dialog.AddButton("_Close", gtk.RESPONSE_CLOSE)
+	dialog.SetResponseSensitive(rawTxSaveResponse, false)
+	dialog.SetResponseSensitive(rawTxBroadcastResponse, false)
+	dialog.SetDefaultResponse(rawTxSignResponse)
+
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	status, err := gtk.LabelNew(fmt.Sprintf("Loaded an unsigned "+
+		"transaction of %d bytes from %s.", len(tx)/2,
+		filepath.Base(filename)))
+	if err != nil {
+		return nil, err
+	}
+	status.SetHAlign(gtk.ALIGN_START)
+	status.SetLineWrap(true)
+	b.Add(status)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	var signed string
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		switch rt {
+		case rawTxSignResponse:
+			dialog.SetResponseSensitive(rawTxSignResponse, false)
+			status.SetText("Signing...")
+			go func() {
+				stx, complete, err := signRawTx(tx)
+				glib.IdleAdd(func() {
+					if err != nil {
+						dialog.SetResponseSensitive(rawTxSignResponse, true)
+						status.SetText("Cannot sign transaction: " +
+							walletErrorMessage(err))
+						return
+					}
+					signed = stx
+					dialog.SetResponseSensitive(rawTxSaveResponse, true)
+					if !complete {
+						status.SetText("Signed the inputs this " +
+							"wallet holds keys for.  Other inputs " +
+							"must still be signed elsewhere " +
+							"before it can be broadcast.")
+						return
+					}
+					status.SetText("The transaction is completely " +
+						"signed and may be saved or broadcast.")
+					dialog.SetResponseSensitive(rawTxBroadcastResponse, true)
+				})
+			}()
+
+		case rawTxSaveResponse:
+			saveSignedRawTx(&dialog.Window, filename, signed)
+
+		case rawTxBroadcastResponse:
+			if !confirmBroadcast() {
+				return
+			}
+			dialog.SetResponseSensitive(rawTxBroadcastResponse, false)
+			status.SetText("Broadcasting...")
+			go func() {
+				txid, err := broadcastRawTx(signed)
+				glib.IdleAdd(func() {
+					if err != nil {
+						dialog.SetResponseSensitive(rawTxBroadcastResponse, true)
+						status.SetText("Cannot broadcast transaction: " +
+							walletErrorMessage(err))
+						return
+					}
+					logEvent(eventSent, "%v: signed raw transaction "+
+						"from %s", txid, filepath.Base(filename))
+					status.SetText("Broadcast transaction " + txid + ".")
+				})
+			}()
+
+		default:
+			dialog.Destroy()
+		}
+	})
+
+	return dialog, nil
+}
+
+// confirmBroadcast asks whether a signed raw transaction should be sent
+// to the network, as it can not be taken back once sent.
+//
+// This must be run from the GTK main event loop.
+func confirmBroadcast() bool {
+	d := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL,
+		gtk.MESSAGE_QUESTION, gtk.BUTTONS_YES_NO, "Broadcast the "+
+			"signed transaction to the network?  It can not be "+
+			"taken back once sent.")
+	d.SetTitle("Broadcast transaction")
+	defer d.Destroy()
+	return gtk.ResponseType(d.Run()) == gtk.RESPONSE_YES
+}
+
+// saveSignedRawTx asks for a file to save the signed raw transaction tx
+// to, suggesting a name beside the unsigned transaction read from
+// filename, and writes it hex encoded.
+//
+// This must be run from the GTK main event loop.
+func saveSignedRawTx(parent *gtk.Window, filename, tx string) {
+	fc, err := gtk.FileChooserDialogNewWith2Buttons("Save Signed Transaction",
+		parent, gtk.FILE_CHOOSER_ACTION_SAVE,
+		"_Cancel", gtk.RESPONSE_CANCEL,
+		"_Save", gtk.RESPONSE_ACCEPT)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	defer fc.Destroy()
+	fc.SetDoOverwriteConfirmation(true)
+	name := strings.TrimSuffix(filepath.Base(filename),
+		filepath.Ext(filename))
+	fc.SetCurrentName(name + "-signed.txt")
+
+	if gtk.ResponseType(fc.Run()) != gtk.RESPONSE_ACCEPT {
+		return
+	}
+	err = ioutil.WriteFile(fc.GetFilename(), []byte(tx+"\n"), 0600)
+	if err != nil {
+		d := errorDialog("Cannot save signed transaction", err.Error())
+		d.Run()
+		d.Destroy()
+	}
+}
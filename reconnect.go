@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/gotk3/glib"
+	"math/rand"
+	"time"
+)
+
+// defaultReconnectMax is the default longest time, in seconds, waited
+// between attempts to reconnect to btcwallet.
+const defaultReconnectMax = 300
+
+// reconnectRand randomizes reconnect delays.
+var reconnectRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// retryNow is signaled to stop waiting before the next attempt to
+// connect to btcwallet.  It is buffered so a request to retry is never
+// lost, and never blocks the GTK main event loop.
+var retryNow = make(chan struct{}, 1)
+
+// reconnectDeadline is the time of the next attempt to connect to
+// btcwallet, or the zero time if not waiting to reconnect.  This must
+// only be accessed from the GTK main event loop.
+var reconnectDeadline time.Time
+
+// reconnectBackoff computes the delays between failed attempts to
+// connect to btcwallet.  Each failure doubles the delay, starting from
+// reconnectDelay, up to reconnectMaxDelay.
+type reconnectBackoff struct {
+	failures uint
+}
+
+// next returns the time to wait before the next attempt to connect.  A
+// random jitter of up to half the delay is subtracted, so GUIs which lost
+// their connection together, such as when btcwallet restarts, do not
+// reconnect all at once.
+func (b *reconnectBackoff) next() time.Duration {
+	d := reconnectMaxDelay
+	if b.failures < 32 && reconnectDelay<<b.failures < reconnectMaxDelay {
+		d = reconnectDelay << b.failures
+		b.failures++
+	}
+	if d/2 <= 0 {
+		return d
+	}
+	return d - time.Duration(reconnectRand.Int63n(int64(d/2)))
+}
+
+// reset starts again from the shortest delay after connecting.
+func (b *reconnectBackoff) reset() {
+	b.failures = 0
+}
+
+// waitReconnect waits d before the next attempt to connect to btcwallet,
+// showing the time left in the statusbar, unless retrying now is chosen
+// first.
+//
+// This is written to be run outside of the GTK main event loop.
+func waitReconnect(d time.Duration) {
+	// Only a retry chosen while waiting ends the wait early.
+	select {
+	case <-retryNow:
+	default:
+	}
+
+	deadline := time.Now().Add(d)
+	glib.IdleAdd(func() {
+		reconnectDeadline = deadline
+		showReconnectCountdown()
+		StatusElems.Retry.Show()
+	})
+
+	timer := time.NewTimer(d)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+wait:
+	for {
+		select {
+		case <-ticker.C:
+			glib.IdleAdd(showReconnectCountdown)
+		case <-timer.C:
+			break wait
+		case <-retryNow:
+			timer.Stop()
+			break wait
+		}
+	}
+
+	glib.IdleAdd(func() {
+		reconnectDeadline = time.Time{}
+		StatusElems.Retry.Hide()
+		StatusElems.Lab.SetText("Reconnecting to btcwallet...")
+	})
+}
+
+// showReconnectCountdown shows the time left until the next attempt to
+// connect to btcwallet in the statusbar.  The countdown is not recorded
+// in the status history, as it changes every second.
+//
+// This must be run from the GTK main event loop.
+func showReconnectCountdown() {
+	if reconnectDeadline.IsZero() {
+		return
+	}
+	left := reconnectDeadline.Sub(time.Now())
+	if left < 0 {
+		left = 0
+	}
+	secs := (left + time.Second - 1) / time.Second
+	StatusElems.Lab.SetText(fmt.Sprintf("Disconnected from btcwallet.  "+
+		"Reconnecting in %v...", secs*time.Second))
+}
+
+// retryConnect ends the wait for the next attempt to connect to
+// btcwallet.
+//
+// This must be run from the GTK main event loop.
+func retryConnect() {
+	select {
+	case retryNow <- struct{}{}:
+	default:
+	}
+}
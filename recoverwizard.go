@@ -0,0 +1,426 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"os"
+	"time"
+)
+
+// ErrRecoveryUnsupported describes an error where a wallet could not be
+// recovered from a seed because btcwallet does not support it.
+var ErrRecoveryUnsupported = errors.New("the connected btcwallet can not " +
+	"recover a wallet from a seed; recover from a backup file of " +
+	"private keys instead")
+
+// Responses of the first-run dialog.
+const (
+	firstRunCreate gtk.ResponseType = iota + 1
+	firstRunRecover
+)
+
+// RecoverParams holds a wallet seed, or the private keys and addresses
+// of a backup file, to recover a wallet from.  The progress of each step
+// is sent to progress, which is closed once the recovered balance appears,
+// recovery fails, or quit is closed.
+type RecoverParams struct {
+	seed       string
+	entries    []importEntry
+	passphrase string
+	progress   chan recoverProgress
+	quit       chan struct{}
+}
+
+// recoverProgress describes a step of a wallet recovery.  fraction is the
+// fraction of the step completed, or negative if unknown.  err is set if
+// recovery failed, and done once the recovered balance appears.
+type recoverProgress struct {
+	text     string
+	fraction float64
+	err      error
+	done     bool
+}
+
+// recoverWallet recovers the wallet from the seed or backup file of
+// params.  A backup file is recovered by creating a new wallet and
+// importing every entry, rescanning once for the last entry.  The
+// balance is then requested every recoverPollInterval until funds are
+// found by the rescan.
+//
+// This is written to be run as a goroutine executing outside of the GTK
+// main event loop.
+func recoverWallet(params *RecoverParams) {
+	defer close(params.progress)
+	fail := func(err error) {
+		params.progress <- recoverProgress{text: err.Error(), err: err}
+	}
+
+	b := activeBackend()
+	if b == nil {
+		fail(ErrConnectionLost)
+		return
+	}
+
+	if params.seed != "" {
+		params.progress <- recoverProgress{
+			text:     "Restoring wallet from seed...",
+			fraction: -1,
+		}
+		err := b.RecoverWallet(params.seed, params.passphrase)
+		if jsonErr, ok := err.(*btcjson.Error); ok &&
+			jsonErr.Code == btcjson.ErrMethodNotFound.Code {
+			err = ErrRecoveryUnsupported
+		}
+		if err != nil {
+			fail(walletError(err))
+			return
+		}
+	} else {
+		params.progress <- recoverProgress{
+			text:     "Creating wallet...",
+			fraction: -1,
+		}
+		if err := b.CreateEncryptedWallet(params.passphrase); err != nil {
+			fail(walletError(err))
+			return
+		}
+
+		imp := &ImportParams{
+			entries: params.entries,
+			results: make(chan error, len(params.entries)),
+		}
+		go importKeys(b, imp)
+		i, failed := 0, 0
+		for err := range imp.results {
+			i++
+			if err != nil {
+				failed++
+			}
+			params.progress <- recoverProgress{
+				text: fmt.Sprintf("Imported %d of %d keys and addresses...",
+					i, len(params.entries)),
+				fraction: float64(i) / float64(len(params.entries)),
+			}
+		}
+		if failed == len(params.entries) {
+			fail(errors.New("no keys or addresses of the backup " +
+				"file could be imported"))
+			return
+		}
+	}
+	requestWalletState(b)
+
+	ticker := time.NewTicker(recoverPollInterval)
+	defer ticker.Stop()
+	for {
+		params.progress <- recoverProgress{
+			text:     "Rescanning the blockchain for wallet transactions...",
+			fraction: -1,
+		}
+		select {
+		case <-ticker.C:
+		case <-params.quit:
+			return
+		case <-b.Done():
+			fail(ErrConnectionLost)
+			return
+		}
+
+		bal, err := b.GetBalance(currentAccount())
+		if err != nil {
+			continue
+		}
+		unconfirmed, err := b.GetUnconfirmedBalance(currentAccount())
+		if err != nil {
+			continue
+		}
+		if bal+unconfirmed != 0 {
+			fetchBalance(b)
+			fetchUnconfirmedBalance(b)
+			params.progress <- recoverProgress{
+				text: fmt.Sprintf("Recovered %s.", formatAmount(bal+unconfirmed)),
+				done: true,
+			}
+			return
+		}
+	}
+}
+
+// showFirstRunDialog asks whether to create a new wallet or recover an
+// existing wallet, when btcwallet has no wallet open.
+//
+// This must be run from the GTK main event loop.
+func showFirstRunDialog() {
+	dialog := gtk.MessageDialogNew(mainWindow, 0, gtk.MESSAGE_QUESTION,
+		gtk.BUTTONS_NONE, "btcwallet has no wallet.  Create a new "+
+			"wallet, or recover an existing wallet from its seed or "+
+			"a backup file of its private keys?")
+	dialog.SetTitle("No wallet")
+	dialog.AddButton("_Recover Wallet...", firstRunRecover)
+	dialog.AddButton("_Create New Wallet...", firstRunCreate)
+	rt := gtk.ResponseType(dialog.Run())
+	dialog.Destroy()
+
+	var d *gtk.Dialog
+	var err error
+	switch rt {
+	case firstRunCreate:
+		d, err = createNewWalletDialog()
+	case firstRunRecover:
+		d, err = createRecoverDialog()
+	default:
+		return
+	}
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	d.Run()
+}
+
+// createRecoverDialog creates a wizard to recover a wallet from its seed
+// or a backup file of private keys and addresses, as read by
+// parseImportFile.  Once started, the progress of recovery is shown until
+// the recovered balance appears.
+func createRecoverDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Recover wallet")
+
+	dialog.AddButton("_Recover", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetHExpand(true)
+	grid.SetVExpand(true)
+	grid.SetColumnSpacing(6)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	fromSeed, err := gtk.RadioButtonNewWithLabel(nil, "From the wallet seed:")
+	if err != nil {
+		return nil, err
+	}
+	grid.Attach(fromSeed, 0, 0, 1, 1)
+
+	seed, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	seed.SetHExpand(true)
+	grid.Attach(seed, 1, 0, 1, 1)
+
+	fromFile, err := gtk.RadioButtonNewWithLabelFromWidget(fromSeed,
+		"From a backup file:")
+	if err != nil {
+		return nil, err
+	}
+	grid.Attach(fromFile, 0, 1, 1, 1)
+
+	chooseFile, err := gtk.ButtonNewWithLabel("Choose File...")
+	if err != nil {
+		return nil, err
+	}
+	chooseFile.SetSensitive(false)
+	grid.Attach(chooseFile, 1, 1, 1, 1)
+
+	var entries []importEntry
+	chooseFile.Connect("clicked", func() {
+		fc, err := gtk.FileChooserDialogNewWith2Buttons("Choose Backup File",
+			&dialog.Window, gtk.FILE_CHOOSER_ACTION_OPEN,
+			"_Cancel", gtk.RESPONSE_CANCEL,
+			"_Open", gtk.RESPONSE_ACCEPT)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		if gtk.ResponseType(fc.Run()) != gtk.RESPONSE_ACCEPT {
+			fc.Destroy()
+			return
+		}
+		filename := fc.GetFilename()
+		fc.Destroy()
+
+		f, err := os.Open(filename)
+		if err == nil {
+			entries, err = parseImportFile(f)
+			f.Close()
+		}
+		if err == nil && len(entries) == 0 {
+			err = fmt.Errorf("%s has no keys or addresses", filename)
+		}
+		if err != nil {
+			entries = nil
+			chooseFile.SetLabel("Choose File...")
+			d := errorDialog("Cannot read backup file", err.Error())
+			d.Run()
+			d.Destroy()
+			return
+		}
+		chooseFile.SetLabel(fmt.Sprintf("%s (%d entries)", filename,
+			len(entries)))
+	})
+	fromFile.Connect("toggled", func() {
+		chooseFile.SetSensitive(fromFile.GetActive())
+		seed.SetSensitive(!fromFile.GetActive())
+	})
+
+	l, err := gtk.LabelNew("New passphrase:")
+	if err != nil {
+		return nil, err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(l, 0, 2, 1, 1)
+
+	passphrase, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	passphrase.SetVisibility(false)
+	grid.Attach(passphrase, 1, 2, 1, 1)
+
+	l, err = gtk.LabelNew("Confirm passphrase:")
+	if err != nil {
+		return nil, err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(l, 0, 3, 1, 1)
+
+	repeated, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	repeated.SetVisibility(false)
+	grid.Attach(repeated, 1, 3, 1, 1)
+
+	progress, err := gtk.ProgressBarNew()
+	if err != nil {
+		return nil, err
+	}
+	progress.SetShowText(true)
+	progress.SetNoShowAll(true)
+	grid.Attach(progress, 0, 4, 2, 1)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	// quit is closed when the dialog is closed during recovery, to stop
+	// waiting for the recovered balance.  The rescan continues in
+	// btcwallet.
+	var quit chan struct{}
+	closed := false
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		if rt != gtk.RESPONSE_OK {
+			if quit != nil {
+				close(quit)
+			}
+			closed = true
+			dialog.Destroy()
+			return
+		}
+
+		params := &RecoverParams{
+			progress: make(chan recoverProgress),
+		}
+		if fromFile.GetActive() {
+			if len(entries) == 0 {
+				d := errorDialog("Cannot recover wallet",
+					"Choose a backup file to recover from.")
+				d.Run()
+				d.Destroy()
+				return
+			}
+			params.entries = entries
+		} else {
+			params.seed, _ = seed.GetText()
+			if params.seed == "" {
+				d := errorDialog("Cannot recover wallet",
+					"Enter the wallet seed to recover from.")
+				d.Run()
+				d.Destroy()
+				return
+			}
+		}
+		params.passphrase, _ = passphrase.GetText()
+		rStr, _ := repeated.GetText()
+		switch {
+		case params.passphrase == "":
+			d := errorDialog("Cannot recover wallet",
+				"A passphrase must be entered to encrypt the wallet.")
+			d.Run()
+			d.Destroy()
+			return
+		case params.passphrase != rStr:
+			d := errorDialog("Cannot recover wallet",
+				"The passphrases do not match.")
+			d.Run()
+			d.Destroy()
+			return
+		}
+
+		quit = make(chan struct{})
+		params.quit = quit
+		grid.SetSensitive(false)
+		progress.SetSensitive(true)
+		progress.Show()
+		dialog.SetResponseSensitive(gtk.RESPONSE_OK, false)
+
+		go func() {
+			go recoverWallet(params)
+			for p := range params.progress {
+				p := p
+				glib.IdleAdd(func() {
+					if closed {
+						return
+					}
+					progress.SetText(p.text)
+					if p.fraction < 0 {
+						progress.Pulse()
+					} else {
+						progress.SetFraction(p.fraction)
+					}
+					switch {
+					case p.err != nil:
+						grid.SetSensitive(true)
+						dialog.SetResponseSensitive(gtk.RESPONSE_OK, true)
+						quit = nil
+					case p.done:
+						progress.SetFraction(1)
+					}
+				})
+			}
+		}()
+	})
+
+	return dialog, nil
+}
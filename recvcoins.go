@@ -17,21 +17,209 @@
 package main
 
 import (
-	"github.com/conformal/gotk3/gdk"
 	"github.com/conformal/gotk3/glib"
 	"github.com/conformal/gotk3/gtk"
 	"log"
+	"strconv"
+	"time"
 )
 
+// Columns of the receive coins list store.
+const (
+	recvColLabel = iota
+	recvColAddress
+	recvColChange
+	recvColFirstUsed
+	recvColLastUsed
+	recvColUses
+)
+
+// addrUsage holds statistics of the payments received by an address.
+type addrUsage struct {
+	first, last time.Time
+	txids       map[string]struct{}
+}
+
+// recvAddrUsage maps receive addresses to statistics of their payments,
+// derived from the transaction history.  This must only be accessed from
+// the GTK main event loop.
+var recvAddrUsage = make(map[string]*addrUsage)
+
+// ownAddrs holds every address of the wallet added to the receive coins
+// list, including change addresses once shown.  This must only be
+// accessed from the GTK main event loop.
+var ownAddrs = make(map[string]struct{})
+
+// isOwnAddr returns whether addr is known to belong to the wallet.
+//
+// This must be run from the GTK main event loop.
+func isOwnAddr(addr string) bool {
+	_, ok := ownAddrs[addr]
+	return ok
+}
+
 // RecvCoins holds pointers to widgets in the receive coins tab.
 var RecvCoins struct {
 	Store      *gtk.ListStore
 	Treeview   *gtk.TreeView
 	NewAddrBtn *gtk.Button
+	PregenBtn  *gtk.Button
+	ShowChange *gtk.CheckButton
+	Unused     *gtk.Label
+}
+
+// noteAddrUsage adds a received transaction to the usage statistics of
+// its address, updating the receive coins row of the address if it is
+// shown.
+//
+// This must be run from the GTK main event loop.
+func noteAddrUsage(attr *TxAttributes) {
+	if attr.Direction != Recv || attr.Address == "" {
+		return
+	}
+	u, ok := recvAddrUsage[attr.Address]
+	if !ok {
+		u = &addrUsage{
+			first: attr.Date,
+			last:  attr.Date,
+			txids: make(map[string]struct{}),
+		}
+		recvAddrUsage[attr.Address] = u
+	}
+	if attr.Date.Before(u.first) {
+		u.first = attr.Date
+	}
+	if attr.Date.After(u.last) {
+		u.last = attr.Date
+	}
+	u.txids[attr.TxID] = struct{}{}
+	noteAddrUsed(attr.Address)
+
+	store := RecvCoins.Store
+	iter, ok := store.GetIterFirst()
+	for ok {
+		val, err := store.GetValue(iter, recvColAddress)
+		if err == nil {
+			if addr, _ := val.GetString(); addr == attr.Address {
+				setRecvUsage(iter, addr)
+			}
+		}
+		ok = store.IterNext(iter)
+	}
+}
+
+// setRecvUsage sets the usage columns of the receive coins row
+// referenced by iter to the statistics of addr.
+//
+// This must be run from the GTK main event loop.
+func setRecvUsage(iter *gtk.TreeIter, addr string) {
+	const layout = "01/02/2006"
+	first, last, uses := "", "", "0"
+	if u, ok := recvAddrUsage[addr]; ok {
+		first = u.first.Format(layout)
+		last = u.last.Format(layout)
+		uses = strconv.Itoa(len(u.txids))
+	}
+	RecvCoins.Store.Set(iter,
+		[]int{recvColFirstUsed, recvColLastUsed, recvColUses},
+		[]interface{}{first, last, uses})
+}
+
+// appendRecvAddr adds a row for addr to the receive coins list, labeled
+// with the address label saved in the metadata store.  change is shown
+// beside the address to mark change addresses, and is empty for payment
+// addresses.  The address is remembered as belonging to the wallet.
+//
+// This must be run from the GTK main event loop.
+func appendRecvAddr(addr, change string) *gtk.TreeIter {
+	iter := RecvCoins.Store.Append()
+	RecvCoins.Store.Set(iter,
+		[]int{recvColLabel, recvColAddress, recvColChange},
+		[]interface{}{metadata.AddrLabel(addr), addr, change})
+	setRecvUsage(iter, addr)
+	if !isOwnAddr(addr) {
+		ownAddrs[addr] = struct{}{}
+		checkSelfTransfers()
+	}
+	return iter
+}
+
+// isChangeRow returns whether the receive coins row referenced by iter
+// is a change address.
+func isChangeRow(iter *gtk.TreeIter) bool {
+	val, err := RecvCoins.Store.GetValue(iter, recvColChange)
+	if err != nil {
+		return false
+	}
+	s, _ := val.GetString()
+	return s != ""
+}
+
+// removeChangeAddrs removes all change addresses from the receive coins
+// list.
+//
+// This must be run from the GTK main event loop.
+func removeChangeAddrs() {
+	store := RecvCoins.Store
+	iter, ok := store.GetIterFirst()
+	for ok {
+		if isChangeRow(iter) {
+			ok = store.Remove(iter)
+		} else {
+			ok = store.IterNext(iter)
+		}
+	}
+}
+
+// showChangeAddrs adds the wallet's change addresses to the receive
+// coins list, marked as change.  btcwallet does not list change
+// addresses with the payment addresses of an account, but they are
+// included in the address groupings of all addresses with on-chain
+// activity.  Any grouped address which is not a payment address is
+// therefore shown as a change address.
+//
+// This is written to be run as a goroutine executing outside of the GTK
+// main event loop.
+func showChangeAddrs() {
+	groups, err := addrGroupings()
+	if err != nil {
+		log.Printf("[ERR] cannot list change addresses: %v", err)
+		return
+	}
+
+	glib.IdleAdd(func() {
+		if !RecvCoins.ShowChange.GetActive() {
+			return
+		}
+		removeChangeAddrs()
+
+		store := RecvCoins.Store
+		payAddrs := make(map[string]struct{})
+		iter, ok := store.GetIterFirst()
+		for ok {
+			val, err := store.GetValue(iter, recvColAddress)
+			if err == nil {
+				addr, _ := val.GetString()
+				payAddrs[addr] = struct{}{}
+			}
+			ok = store.IterNext(iter)
+		}
+
+		for _, group := range groups {
+			for _, e := range group {
+				if _, ok := payAddrs[e.Address]; ok {
+					continue
+				}
+				appendRecvAddr(e.Address, "Change")
+			}
+		}
+	})
 }
 
 func createRecvCoins() *gtk.Widget {
-	store, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING)
+	store, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_STRING)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -52,12 +240,21 @@ func createRecvCoins() *gtk.Widget {
 	renderer.Connect("edited", func(_ *gtk.CellRendererText, path, text string) {
 		iter, err := store.GetIterFromString(path)
 		if err == nil {
-			store.Set(iter, []int{0}, []interface{}{text})
+			store.Set(iter, []int{recvColLabel}, []interface{}{text})
+			val, err := store.GetValue(iter, recvColAddress)
+			if err != nil {
+				log.Print(err)
+				return
+			}
+			addr, _ := val.GetString()
+			if err := metadata.SetAddrLabel(addr, text); err != nil {
+				log.Printf("[ERR] cannot save address label: %v", err)
+			}
 		}
 	})
 
 	col, err := gtk.TreeViewColumnNewWithAttribute("Label", renderer,
-		"text", 0)
+		"text", recvColLabel)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -67,12 +264,45 @@ func createRecvCoins() *gtk.Widget {
 	if err != nil {
 		log.Fatal(err)
 	}
-	col, err = gtk.TreeViewColumnNewWithAttribute("Address", cr, "text", 1)
+	col, err = gtk.TreeViewColumnNewWithAttribute("Address", cr, "text", recvColAddress)
 	if err != nil {
 		log.Fatal(err)
 	}
 	col.SetMinWidth(350)
 	tv.AppendColumn(col)
+	cr, err = gtk.CellRendererTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cr.Set("foreground", "gray")
+	col, err = gtk.TreeViewColumnNewWithAttribute("", cr, "text", recvColChange)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tv.AppendColumn(col)
+
+	// Usage statistics help decide whether an address may be handed
+	// out again without linking payments.
+	usageCols := []struct {
+		title string
+		col   int
+	}{
+		{"First used", recvColFirstUsed},
+		{"Last used", recvColLastUsed},
+		{"Payments", recvColUses},
+	}
+	for _, c := range usageCols {
+		cr, err = gtk.CellRendererTextNew()
+		if err != nil {
+			log.Fatal(err)
+		}
+		col, err = gtk.TreeViewColumnNewWithAttribute(c.title, cr,
+			"text", c.col)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tv.AppendColumn(col)
+	}
 
 	newAddr, err := gtk.ButtonNewWithLabel("New Address")
 	if err != nil {
@@ -80,25 +310,11 @@ func createRecvCoins() *gtk.Widget {
 	}
 	newAddr.SetSizeRequest(150, -1)
 	newAddr.Connect("clicked", func() {
-		go func() {
-			triggers.newAddr <- 1
-			reply := <-triggerReplies.newAddr
-			if err, ok := reply.(error); ok {
-				glib.IdleAdd(func() {
-					mDialog := errorDialog("New address generation failed",
-						err.Error())
-					mDialog.Run()
-					mDialog.Destroy()
-
-				})
-			} else if addr, ok := reply.(string); ok {
-				glib.IdleAdd(func() {
-					iter := RecvCoins.Store.Append()
-					RecvCoins.Store.Set(iter, []int{0, 1},
-						[]interface{}{"", addr})
-				})
-			}
-		}()
+		if dialog, err := createNewAddrDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
 	})
 	newAddr.SetSensitive(false)
 	RecvCoins.NewAddrBtn = newAddr
@@ -121,36 +337,108 @@ func createRecvCoins() *gtk.Widget {
 		}
 		var iter gtk.TreeIter
 		if sel.GetSelected(nil, &iter) {
-			val, err := store.GetValue(&iter, 1)
+			val, err := store.GetValue(&iter, recvColAddress)
 			if err != nil {
 				log.Fatal(err)
 			}
+			s, _ := val.GetString()
+			copyToClipboard(s)
+		}
+	})
+	buttons.Add(cpyAddr)
 
-			display, err := gdk.DisplayGetDefault()
+	cpyURI, err := gtk.ButtonNewWithLabel("Copy URI")
+	if err != nil {
+		log.Fatal(err)
+	}
+	cpyURI.SetSizeRequest(150, -1)
+	cpyURI.SetTooltipText("Copy a bitcoin: payment link including the " +
+		"address label")
+	cpyURI.Connect("clicked", func() {
+		sel, err := tv.GetSelection()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var iter gtk.TreeIter
+		if sel.GetSelected(nil, &iter) {
+			val, err := store.GetValue(&iter, recvColAddress)
 			if err != nil {
 				log.Fatal(err)
 			}
-
-			clipboard, err := gtk.ClipboardGetForDisplay(
-				display,
-				gdk.SELECTION_CLIPBOARD)
-			if err != nil {
-				log.Fatal(err)
+			addr, _ := val.GetString()
+			uri := &bitcoinURI{
+				address: addr,
+				label:   metadata.AddrLabel(addr),
 			}
+			copyToClipboard(uri.String())
+		}
+	})
+	buttons.Add(cpyURI)
 
-			primary, err := gtk.ClipboardGetForDisplay(
-				display,
-				gdk.SELECTION_PRIMARY)
+	showTxs, err := gtk.ButtonNewWithLabel("Show Transactions")
+	if err != nil {
+		log.Fatal(err)
+	}
+	showTxs.SetSizeRequest(150, -1)
+	showTxs.Connect("clicked", func() {
+		sel, err := tv.GetSelection()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var iter gtk.TreeIter
+		if sel.GetSelected(nil, &iter) {
+			val, err := store.GetValue(&iter, recvColAddress)
 			if err != nil {
 				log.Fatal(err)
 			}
-
 			s, _ := val.GetString()
-			clipboard.SetText(s)
-			primary.SetText(s)
+			showAddressTxs(s)
 		}
 	})
-	buttons.Add(cpyAddr)
+	buttons.Add(showTxs)
+
+	showChange, err := gtk.CheckButtonNewWithLabel("Show change addresses")
+	if err != nil {
+		log.Fatal(err)
+	}
+	showChange.SetTooltipText("Include addresses which received change " +
+		"from sent transactions")
+	showChange.Connect("toggled", func() {
+		if showChange.GetActive() {
+			go showChangeAddrs()
+		} else {
+			removeChangeAddrs()
+		}
+	})
+	RecvCoins.ShowChange = showChange
+	buttons.Add(showChange)
+
+	// The number of never used addresses shows when more should be
+	// generated ahead of handing them out.
+	unused, err := gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	unused.SetMarginLeft(12)
+	RecvCoins.Unused = unused
+	buttons.Add(unused)
+
+	pregen, err := gtk.ButtonNewWithLabel("Pre-generate...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	pregen.SetTooltipText("Generate a batch of new addresses ahead of " +
+		"time, to hand out without waiting for btcwallet")
+	pregen.Connect("clicked", func() {
+		if dialog, err := createPregenDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	pregen.SetSensitive(false)
+	RecvCoins.PregenBtn = pregen
+	buttons.Add(pregen)
 
 	sw, err := gtk.ScrolledWindowNew(nil, nil)
 	if err != nil {
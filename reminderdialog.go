@@ -0,0 +1,300 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"strings"
+	"time"
+)
+
+// Responses of the payment reminders dialog buttons which do not close
+// the dialog.
+const (
+	reminderAddResponse gtk.ResponseType = iota + 1
+	reminderRemoveResponse
+)
+
+// Columns of the payment reminders list store.
+const (
+	remColID = iota
+	remColPayee
+	remColAmount
+	remColInterval
+	remColNext
+)
+
+// createRemindersDialog creates a dialog listing the reminders of
+// recurring payments, to add new reminders or remove them.
+func createRemindersDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Payment reminders")
+	dialog.SetDefaultSize(500, 300)
+
+	dialog.AddButton("_Add...", reminderAddResponse)
+	dialog.AddButton("_Remove", reminderRemoveResponse)
+	dialog.AddButton("_Close", gtk.RESPONSE_CLOSE)
+
+	store, err := gtk.ListStoreNew(glib.TYPE_INT64, glib.TYPE_STRING,
+		glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING)
+	if err != nil {
+		return nil, err
+	}
+	fill := func() {
+		store.Clear()
+		for _, r := range metadata.PaymentReminders() {
+			store.Set(store.Append(),
+				[]int{remColID, remColPayee, remColAmount,
+					remColInterval, remColNext},
+				[]interface{}{r.ID, r.Payee,
+					r.amountText(),
+					r.intervalName(),
+					r.Next.Format("01/02/2006")})
+		}
+	}
+	fill()
+
+	tv, err := gtk.TreeViewNewWithModel(store)
+	if err != nil {
+		return nil, err
+	}
+	cols := []struct {
+		title string
+		col   int
+	}{
+		{"Payee", remColPayee},
+		{"Amount", remColAmount},
+		{"Interval", remColInterval},
+		{"Next due", remColNext},
+	}
+	for _, c := range cols {
+		cr, err := gtk.CellRendererTextNew()
+		if err != nil {
+			return nil, err
+		}
+		col, err := gtk.TreeViewColumnNewWithAttribute(c.title, cr,
+			"text", c.col)
+		if err != nil {
+			return nil, err
+		}
+		tv.AppendColumn(col)
+	}
+
+	sw, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	sw.Add(tv)
+	sw.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	sw.SetHExpand(true)
+	sw.SetVExpand(true)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(sw)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		switch rt {
+		case reminderAddResponse:
+			d, err := createAddReminderDialog(fill)
+			if err != nil {
+				log.Print(err)
+				return
+			}
+			d.Run()
+
+		case reminderRemoveResponse:
+			sel, err := tv.GetSelection()
+			if err != nil {
+				log.Print(err)
+				return
+			}
+			var iter gtk.TreeIter
+			if !sel.GetSelected(nil, &iter) {
+				return
+			}
+			val, err := store.GetValue(&iter, remColID)
+			if err != nil {
+				log.Print(err)
+				return
+			}
+			id, _ := val.GoValue()
+			removeReminder(id.(int64))
+			fill()
+
+		default:
+			dialog.Destroy()
+		}
+	})
+
+	return dialog, nil
+}
+
+// removeReminder removes the payment reminder with the given ID.
+//
+// This must be run from the GTK main event loop.
+func removeReminder(id int64) {
+	reminders := metadata.PaymentReminders()
+	for i := range reminders {
+		if reminders[i].ID == id {
+			reminders = append(reminders[:i], reminders[i+1:]...)
+			break
+		}
+	}
+	if err := metadata.SetPaymentReminders(reminders); err != nil {
+		d := errorDialog("Cannot remove reminder", err.Error())
+		d.Run()
+		d.Destroy()
+	}
+}
+
+// createAddReminderDialog creates a dialog asking for the payee, address,
+// amount and interval of a new recurring payment reminder.  The first
+// payment is due after one interval.  added is called once the reminder
+// is saved.
+func createAddReminderDialog(added func()) (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Add payment reminder")
+
+	dialog.AddButton("_OK", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetColumnSpacing(6)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	labels := []string{"Payee:", "Pay To:", "Amount:", "Interval:"}
+	for i, s := range labels {
+		l, err := gtk.LabelNew(s)
+		if err != nil {
+			return nil, err
+		}
+		l.SetHAlign(gtk.ALIGN_START)
+		grid.Attach(l, 0, i, 1, 1)
+	}
+
+	payee, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	payee.SetHExpand(true)
+	payee.SetActivatesDefault(true)
+	grid.Attach(payee, 1, 0, 1, 1)
+
+	address, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	address.SetWidthChars(36)
+	address.SetActivatesDefault(true)
+	grid.Attach(address, 1, 1, 1, 1)
+
+	amount, err := gtk.SpinButtonNewWithRange(0, 21000000, 0.00000001)
+	if err != nil {
+		return nil, err
+	}
+	grid.Attach(amount, 1, 2, 1, 1)
+
+	interval, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range reminderIntervals {
+		interval.AppendText(i.name)
+	}
+	interval.SetActive(2)
+	grid.Attach(interval, 1, 3, 1, 1)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		if rt != gtk.RESPONSE_OK {
+			dialog.Destroy()
+			return
+		}
+		payeeStr, _ := payee.GetText()
+		payeeStr = strings.TrimSpace(payeeStr)
+		addrStr, _ := address.GetText()
+		addrStr = strings.TrimSpace(addrStr)
+		var msg string
+		if addr, err := btcutil.DecodeAddress(addrStr, activeNet.Params); err != nil ||
+			!addr.IsForNet(activeNet.Params) {
+			msg = fmt.Sprintf("'%v' is not a valid payment address",
+				addrStr)
+		} else if amount.GetValue() <= 0 {
+			msg = "Enter the amount of the payment."
+		}
+		if msg != "" {
+			d := errorDialog("Cannot add reminder", msg)
+			d.Run()
+			d.Destroy()
+			return
+		}
+		if payeeStr == "" {
+			payeeStr = addrStr
+		}
+
+		ival := reminderIntervals[interval.GetActive()]
+		now := time.Now()
+		r := paymentReminder{
+			ID:             now.UnixNano(),
+			Payee:          payeeStr,
+			Address:        addrStr,
+			Amount:         amount.GetValue(),
+			IntervalMonths: ival.months,
+			IntervalDays:   ival.days,
+			Next:           now.AddDate(0, ival.months, ival.days),
+		}
+		reminders := append(metadata.PaymentReminders(), r)
+		if err := metadata.SetPaymentReminders(reminders); err != nil {
+			d := errorDialog("Cannot add reminder", err.Error())
+			d.Run()
+			d.Destroy()
+			return
+		}
+		dialog.Destroy()
+		added()
+	})
+
+	return dialog, nil
+}
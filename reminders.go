@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"time"
+)
+
+// How long a reminder is snoozed when asked to remind later, and how
+// often reminders are checked for being due.
+const (
+	reminderSnooze        = time.Hour
+	reminderCheckInterval = time.Minute
+)
+
+// Responses of the buttons of a payment reminder alert.
+const (
+	reminderPay gtk.ResponseType = iota + 1
+	reminderSkip
+	reminderLater
+)
+
+// reminderInterval is a choice of time between two payments of a
+// recurring payment.
+type reminderInterval struct {
+	name   string
+	months int
+	days   int
+}
+
+// reminderIntervals are the intervals a recurring payment may be made
+// at.
+var reminderIntervals = []reminderInterval{
+	{"Weekly", 0, 7},
+	{"Every 2 weeks", 0, 14},
+	{"Monthly", 1, 0},
+	{"Quarterly", 3, 0},
+	{"Yearly", 12, 0},
+}
+
+// paymentReminder reminds of a recurring payment of Amount to Address,
+// paid every IntervalMonths months and IntervalDays days.  Next is when
+// the next payment is due.  Reminders never send anything themselves.
+type paymentReminder struct {
+	ID             int64     `json:"id"`
+	Payee          string    `json:"payee"`
+	Address        string    `json:"address"`
+	Amount         float64   `json:"amount"`
+	IntervalMonths int       `json:"intervalmonths"`
+	IntervalDays   int       `json:"intervaldays"`
+	Next           time.Time `json:"next"`
+}
+
+// intervalName returns the name of the interval of r.
+func (r *paymentReminder) intervalName() string {
+	for _, i := range reminderIntervals {
+		if i.months == r.IntervalMonths && i.days == r.IntervalDays {
+			return i.name
+		}
+	}
+	return fmt.Sprintf("Every %d months and %d days", r.IntervalMonths,
+		r.IntervalDays)
+}
+
+// amountText returns the amount of the payment of r as shown to users.
+func (r *paymentReminder) amountText() string {
+	amt, _ := btcutil.NewAmount(r.Amount)
+	return formatAmount(amt)
+}
+
+// advance moves the due date of r to the first payment after now.
+func (r *paymentReminder) advance(now time.Time) {
+	if r.IntervalMonths <= 0 && r.IntervalDays <= 0 {
+		return
+	}
+	for !r.Next.After(now) {
+		r.Next = r.Next.AddDate(0, r.IntervalMonths, r.IntervalDays)
+	}
+}
+
+// reminderAlerts maps the IDs of reminders currently shown or snoozed
+// to when they may be shown again, or the zero time while shown.  It
+// must only be accessed from the GTK main event loop.
+var reminderAlerts = make(map[int64]time.Time)
+
+// reminderChecker shows the payment reminders which are due.  This must
+// be run as a goroutine.
+func reminderChecker() {
+	glib.IdleAdd(checkReminders)
+	for _ = range time.Tick(reminderCheckInterval) {
+		glib.IdleAdd(checkReminders)
+	}
+}
+
+// checkReminders shows an alert for every payment reminder which is due,
+// unless it is already shown or was snoozed.  Nothing is shown while the
+// GUI is locked until the PIN is entered.
+//
+// This must be run from the GTK main event loop.
+func checkReminders() {
+	if mainWindow == nil || pinLock.locked {
+		return
+	}
+	now := time.Now()
+	for _, r := range metadata.PaymentReminders() {
+		if r.Next.After(now) {
+			continue
+		}
+		until, ok := reminderAlerts[r.ID]
+		if ok && (until.IsZero() || now.Before(until)) {
+			continue
+		}
+		r := r
+		showReminderAlert(&r)
+	}
+}
+
+// showReminderAlert shows that the payment of reminder r is due, with a
+// button to fill in the send coins tab with the payment.  Paying or
+// skipping the payment moves the reminder to the next payment.
+//
+// This must be run from the GTK main event loop.
+func showReminderAlert(r *paymentReminder) {
+	// Shown alerts are not shown again until answered.
+	reminderAlerts[r.ID] = time.Time{}
+
+	dialog := gtk.MessageDialogNew(mainWindow, 0, gtk.MESSAGE_INFO,
+		gtk.BUTTONS_NONE, fmt.Sprintf("A payment of %s to %s is "+
+			"due.\n\nThe payment is not sent until you review and send "+
+			"it from the Send tab.", r.amountText(), r.Payee))
+	dialog.SetTitle("Payment due")
+	dialog.AddButton("Remind _Later", reminderLater)
+	dialog.AddButton("_Skip Payment", reminderSkip)
+	dialog.AddButton("_Pay...", reminderPay)
+	dialog.SetDefaultResponse(reminderPay)
+	dialog.Connect("response", func(_ *gtk.MessageDialog, rt gtk.ResponseType) {
+		dialog.Destroy()
+		switch rt {
+		case reminderPay:
+			delete(reminderAlerts, r.ID)
+			advanceReminder(r.ID)
+			payReminder(r)
+		case reminderSkip:
+			delete(reminderAlerts, r.ID)
+			advanceReminder(r.ID)
+		default:
+			reminderAlerts[r.ID] = time.Now().Add(reminderSnooze)
+		}
+	})
+	showMainWindow()
+	dialog.Show()
+}
+
+// advanceReminder moves the reminder with the given ID to its next
+// payment.
+//
+// This must be run from the GTK main event loop.
+func advanceReminder(id int64) {
+	reminders := metadata.PaymentReminders()
+	for i := range reminders {
+		if reminders[i].ID == id {
+			reminders[i].advance(time.Now())
+		}
+	}
+	if err := metadata.SetPaymentReminders(reminders); err != nil {
+		log.Printf("[ERR] cannot save payment reminders: %v", err)
+	}
+}
+
+// payReminder fills in the send coins tab with the payment of reminder
+// r, asking first if recipients were already entered.  The payment is
+// only sent once the user reviews and sends it.
+//
+// This must be run from the GTK main event loop.
+func payReminder(r *paymentReminder) {
+	loadPayeeTemplate(&payeeTemplate{
+		Name: r.Payee,
+		Recipients: []draftRecipient{{
+			Address: r.Address,
+			Label:   r.Payee,
+			Amount:  r.Amount,
+		}},
+	})
+	mainNotebook.SetCurrentPage(sendCoinsPage)
+}
@@ -0,0 +1,192 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/conformal/btcutil"
+	"html/template"
+	"io"
+	"time"
+)
+
+type reportFormat int
+
+// Supported formats of generated reports.
+const (
+	reportHTML reportFormat = iota
+	reportCSV
+)
+
+// reportFormats holds the name and default file extension of each report
+// format, indexed by the format.
+var reportFormats = []struct {
+	name string
+	ext  string
+}{
+	reportHTML: {"HTML", "html"},
+	reportCSV:  {"CSV (spreadsheet)", "csv"},
+}
+
+// reportMonth summarizes the transactions of one month.  The ending
+// balance is the total of every transaction up to the end of the month,
+// less fees.
+type reportMonth struct {
+	Start      time.Time
+	In         btcutil.Amount
+	Out        btcutil.Amount
+	Fees       btcutil.Amount
+	EndBalance btcutil.Amount
+}
+
+// monthStart returns the local midnight starting the month of t.
+func monthStart(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+}
+
+// monthlyReport summarizes attrs for each month from the month of from
+// through the month of to.  Transactions before from still count toward
+// the ending balances.
+func monthlyReport(attrs []*TxAttributes, from, to time.Time) []reportMonth {
+	first := monthStart(from)
+	var months []reportMonth
+	for m := first; !m.After(to); m = m.AddDate(0, 1, 0) {
+		months = append(months, reportMonth{Start: m})
+	}
+	if len(months) == 0 {
+		return nil
+	}
+
+	var opening btcutil.Amount
+	for _, attr := range attrs {
+		net := attr.Amount - attr.Fee
+		if attr.Date.Before(first) {
+			opening += net
+			continue
+		}
+		i := 0
+		for i+1 < len(months) && !attr.Date.Before(months[i+1].Start) {
+			i++
+		}
+		if !attr.Date.Before(months[i].Start.AddDate(0, 1, 0)) {
+			continue
+		}
+		rm := &months[i]
+		switch {
+		case attr.Amount < 0:
+			rm.Out -= attr.Amount
+		default:
+			rm.In += attr.Amount
+		}
+		rm.Fees += attr.Fee
+		rm.EndBalance += net
+	}
+
+	// Each month's ending balance so far only holds the change during
+	// the month.
+	bal := opening
+	for i := range months {
+		bal += months[i].EndBalance
+		months[i].EndBalance = bal
+	}
+	return months
+}
+
+// writeReport writes months to w in the given format.
+func writeReport(w io.Writer, format reportFormat, months []reportMonth) error {
+	switch format {
+	case reportHTML:
+		return writeReportHTML(w, months)
+	case reportCSV:
+		return writeReportCSV(w, months)
+	default:
+		return fmt.Errorf("unknown report format %d", format)
+	}
+}
+
+func writeReportCSV(w io.Writer, months []reportMonth) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"Month", "Received", "Sent", "Fees",
+		"Ending balance"})
+	if err != nil {
+		return err
+	}
+	for _, m := range months {
+		err := cw.Write([]string{
+			m.Start.Format("2006-01"),
+			btcString(m.In),
+			btcString(m.Out),
+			btcString(m.Fees),
+			btcString(m.EndBalance),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// reportTemplate is the HTML report of a period.  Amounts are formatted
+// with btcString.
+var reportTemplate = template.Must(template.New("report").Funcs(
+	template.FuncMap{"btc": btcString}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Wallet report {{.From}} to {{.To}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { padding: 4px 12px; border-bottom: 1px solid #ccc; }
+td.amount { text-align: right; font-family: monospace; }
+</style>
+</head>
+<body>
+<h1>Wallet report</h1>
+<p>{{.From}} to {{.To}}, generated {{.Generated}}.  Amounts are in BTC.</p>
+<table>
+<tr><th>Month</th><th>Received</th><th>Sent</th><th>Fees</th><th>Ending balance</th></tr>
+{{range .Months}}<tr><td>{{.Start.Format "January 2006"}}</td><td class="amount">{{btc .In}}</td><td class="amount">{{btc .Out}}</td><td class="amount">{{btc .Fees}}</td><td class="amount">{{btc .EndBalance}}</td></tr>
+{{end}}<tr><th>Total</th><th class="amount">{{btc .In}}</th><th class="amount">{{btc .Out}}</th><th class="amount">{{btc .Fees}}</th><th></th></tr>
+</table>
+</body>
+</html>
+`))
+
+func writeReportHTML(w io.Writer, months []reportMonth) error {
+	data := struct {
+		From, To, Generated string
+		Months              []reportMonth
+		In, Out, Fees       btcutil.Amount
+	}{
+		Generated: time.Now().Format("Jan 2, 2006 at 3:04 PM"),
+		Months:    months,
+	}
+	if len(months) != 0 {
+		data.From = months[0].Start.Format("January 2006")
+		data.To = months[len(months)-1].Start.Format("January 2006")
+	}
+	for _, m := range months {
+		data.In += m.In
+		data.Out += m.Out
+		data.Fees += m.Fees
+	}
+	return reportTemplate.Execute(w, data)
+}
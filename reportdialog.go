@@ -0,0 +1,197 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/conformal/gotk3/gtk"
+	"io/ioutil"
+	"log"
+	"time"
+)
+
+// reportMonths is the number of months, ending with the current month,
+// selected by default for a report.
+const reportMonths = 12
+
+// monthChooser is a month and year chosen in the report dialog.
+type monthChooser struct {
+	month *gtk.ComboBoxText
+	year  *gtk.SpinButton
+}
+
+// newMonthChooser creates a month chooser attached to row of grid,
+// labeled with text and showing the month of t.
+func newMonthChooser(grid *gtk.Grid, row int, text string, t time.Time) (*monthChooser, error) {
+	l, err := gtk.LabelNew(text)
+	if err != nil {
+		return nil, err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(l, 0, row, 1, 1)
+
+	month, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		return nil, err
+	}
+	for m := time.January; m <= time.December; m++ {
+		month.AppendText(m.String())
+	}
+	month.SetActive(int(t.Month() - time.January))
+	grid.Attach(month, 1, row, 1, 1)
+
+	year, err := gtk.SpinButtonNewWithRange(2009, 9999, 1)
+	if err != nil {
+		return nil, err
+	}
+	year.SetValue(float64(t.Year()))
+	grid.Attach(year, 2, row, 1, 1)
+
+	return &monthChooser{month: month, year: year}, nil
+}
+
+// Time returns the start of the chosen month in local time.
+func (c *monthChooser) Time() time.Time {
+	return time.Date(c.year.GetValueAsInt(),
+		time.Month(c.month.GetActive())+time.January, 1, 0, 0, 0, 0,
+		time.Local)
+}
+
+// createReportDialog creates a dialog to choose the months and format of
+// a report summarizing each month of the transaction history, and then a
+// file to save it to.
+func createReportDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Generate Report")
+
+	dialog.AddButton("_Save...", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetColumnSpacing(6)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	now := time.Now()
+	from, err := newMonthChooser(grid, 0, "From:",
+		now.AddDate(0, -(reportMonths-1), 0))
+	if err != nil {
+		return nil, err
+	}
+	to, err := newMonthChooser(grid, 1, "To:", now)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := gtk.LabelNew("Format:")
+	if err != nil {
+		return nil, err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(l, 0, 2, 1, 1)
+
+	formats, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range reportFormats {
+		formats.AppendText(f.name)
+	}
+	formats.SetActive(int(reportHTML))
+	grid.Attach(formats, 1, 2, 2, 1)
+
+	l, err = gtk.LabelNew("Each month lists the amounts received and " +
+		"sent, fees paid, and the balance at the end of the month.")
+	if err != nil {
+		return nil, err
+	}
+	l.SetLineWrap(true)
+	l.SetWidthChars(40)
+	grid.Attach(l, 0, 3, 3, 1)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		if rt != gtk.RESPONSE_OK {
+			dialog.Destroy()
+			return
+		}
+		if to.Time().Before(from.Time()) {
+			d := errorDialog("Invalid period",
+				"The first month must not be after the last month.")
+			d.Run()
+			d.Destroy()
+			return
+		}
+		months := monthlyReport(allTxAttrs(), from.Time(), to.Time())
+		format := reportFormat(formats.GetActive())
+		if saveReport(&dialog.Window, format, months) {
+			dialog.Destroy()
+		}
+	})
+
+	return dialog, nil
+}
+
+// saveReport asks for a file to save the report of months to, and writes
+// it in the chosen format.  Returns false if no file was chosen or
+// writing the report failed.
+//
+// This must be run from the GTK main event loop.
+func saveReport(parent *gtk.Window, format reportFormat, months []reportMonth) bool {
+	fc, err := gtk.FileChooserDialogNewWith2Buttons("Save Report",
+		parent, gtk.FILE_CHOOSER_ACTION_SAVE,
+		"_Cancel", gtk.RESPONSE_CANCEL,
+		"_Save", gtk.RESPONSE_ACCEPT)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	defer fc.Destroy()
+	fc.SetDoOverwriteConfirmation(true)
+	fc.SetCurrentName("report." + reportFormats[format].ext)
+
+	if gtk.ResponseType(fc.Run()) != gtk.RESPONSE_ACCEPT {
+		return false
+	}
+	filename := fc.GetFilename()
+
+	var buf bytes.Buffer
+	err = writeReport(&buf, format, months)
+	if err == nil {
+		err = ioutil.WriteFile(filename, buf.Bytes(), 0600)
+	}
+	if err != nil {
+		d := errorDialog("Cannot save report", err.Error())
+		d.Run()
+		d.Destroy()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/glib"
+	"log"
+	"sync"
+)
+
+// walletIdentity describes the btcwallet process answering a connection,
+// to tell whether btcwallet was restarted since the previous connection.
+type walletIdentity struct {
+	version       float64
+	walletVersion float64
+	locked        bool
+	height        int32
+}
+
+// lastIdentity is the btcwallet process of the previous connection, or
+// nil before the first connection, and the address it was connected at.
+var lastIdentity struct {
+	sync.Mutex
+	id   *walletIdentity
+	addr string
+}
+
+// requestWalletIdentity requests the version, lock state and best block
+// height of the btcwallet process answering b.
+func requestWalletIdentity(b WalletBackend) (*walletIdentity, error) {
+	info, err := b.GetInfo()
+	if err != nil {
+		return nil, err
+	}
+	locked, err := b.WalletIsLocked()
+	if err != nil {
+		return nil, err
+	}
+	height, err := b.GetBlockCount()
+	if err != nil {
+		return nil, err
+	}
+
+	id := &walletIdentity{locked: locked, height: height}
+	id.version, _ = info["version"].(float64)
+	id.walletVersion, _ = info["walletversion"].(float64)
+	return id, nil
+}
+
+// detectWalletRestart returns whether the btcwallet process answering b
+// was started since the previous connection, rather than the connection
+// having only dropped.  btcwallet was restarted if it reports a
+// different version, if it is locked although btcgui unlocked it and
+// the unlock has not yet expired, as only a new process forgets the
+// passphrase early, or if its best block is below the last block it
+// notified, as a new process catches up again from the block its wallet
+// was last synced to.
+//
+// A restart of the same btcwallet version with a wallet which was
+// already locked, once it caught up to the last notified block again,
+// can not be told apart from a dropped connection.  No state kept about
+// the previous process is stale then, as btcgui held no unlock and every
+// block it notifies again is above the last notified block.
+func detectWalletRestart(b WalletBackend) bool {
+	id, err := requestWalletIdentity(b)
+	if err != nil {
+		log.Printf("[ERR] cannot identify btcwallet: %v", err)
+		return false
+	}
+
+	lastIdentity.Lock()
+	last := lastIdentity.id
+	lastIdentity.id = id
+	lastIdentity.Unlock()
+	if last == nil {
+		return false
+	}
+	return id.version != last.version ||
+		id.walletVersion != last.walletVersion ||
+		(id.locked && unlockActive()) ||
+		id.height < ntfnHeight()
+}
+
+// noteWalletAddr records that btcwallet is connected at addr, and
+// forgets the state kept about the btcwallet of the previous connection
+// if it was connected at another address, as another wallet was
+// selected.  It must be called before the session of the connection is
+// created, once the session of the previous connection was shut down, so
+// no notification of the previous wallet is applied afterwards.
+func noteWalletAddr(addr string) {
+	lastIdentity.Lock()
+	prev := lastIdentity.addr
+	lastIdentity.addr = addr
+	if prev != "" && prev != addr {
+		lastIdentity.id = nil
+	}
+	lastIdentity.Unlock()
+
+	if prev != "" && prev != addr {
+		log.Printf("[INF] Connected to another btcwallet at %v, "+
+			"forgetting the state of %v", addr, prev)
+		setUnlockTimeout(0, false)
+		resetNtfnHeight()
+		resetSyncBlock()
+	}
+}
+
+// resetSessionState forgets the state kept by btcgui about a btcwallet
+// process which has since been restarted: the passphrase it no longer
+// holds, and the height of the blocks it notified, which a new process
+// notifies again while catching up.
+func resetSessionState() {
+	log.Print("[WRN] btcwallet was restarted, reloading all wallet state")
+	setUnlockTimeout(0, false)
+	resetNtfnHeight()
+	glib.IdleAdd(func() {
+		setStatus("btcwallet was restarted.  Reloading wallet...")
+	})
+}
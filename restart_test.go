@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"testing"
+)
+
+func TestDetectWalletRestart(t *testing.T) {
+	setUnlockTimeout(0, false)
+	resetNtfnHeight()
+	noteWalletAddr("first")
+
+	b := newMockBackend()
+	b.height = 100
+	if detectWalletRestart(b) {
+		t.Error("first connection detected as a restart")
+	}
+	if detectWalletRestart(b) {
+		t.Error("reconnect to the same process detected as a restart")
+	}
+
+	// A new process catching up again is behind the last notified block.
+	ntfnOrder.Lock()
+	ntfnOrder.height = 120
+	ntfnOrder.Unlock()
+	if !detectWalletRestart(b) {
+		t.Error("restart behind the last notified block not detected")
+	}
+
+	// A restart which forgets an unlock by btcgui.
+	b.height = 120
+	setUnlockTimeout(60, false)
+	b.locked = true
+	if !detectWalletRestart(b) {
+		t.Error("restart forgetting the unlock not detected")
+	}
+	setUnlockTimeout(0, false)
+
+	// The state of another wallet is not compared.
+	noteWalletAddr("second")
+	b.height = 10
+	if detectWalletRestart(b) {
+		t.Error("connection to another wallet detected as a restart")
+	}
+}
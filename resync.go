@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/gotk3/glib"
+	"log"
+	"sync"
+)
+
+// syncBlock is the hash of the best block when the wallet state shown by
+// the GUI was last known to be complete, or empty before the first
+// connection.  It is kept across connections, so the transactions made
+// while disconnected can be requested after reconnecting.
+var syncBlock struct {
+	sync.Mutex
+	hash string
+}
+
+// noteSyncBlock records hash as the best block the wallet state shown by
+// the GUI is complete up to.
+func noteSyncBlock(hash string) {
+	if hash == "" {
+		return
+	}
+	syncBlock.Lock()
+	syncBlock.hash = hash
+	syncBlock.Unlock()
+}
+
+// resetSyncBlock forgets the synchronized block after another wallet was
+// selected, as the transactions of the new wallet since it are unknown.
+func resetSyncBlock() {
+	syncBlock.Lock()
+	syncBlock.hash = ""
+	syncBlock.Unlock()
+}
+
+// resyncWalletState resynchronizes the wallet state shown by the GUI
+// with the wallet of b after connecting.  Any state shown
+// from a previous connection is cleared, all wallet state is requested
+// again, and the transactions made since the last synchronized block are
+// then reconciled with the transactions shown.  Notifications must be
+// held until it returns.
+//
+// This is written to be run outside of the GTK main event loop.
+func resyncWalletState(b WalletBackend) {
+	resetWalletState()
+	requestWalletState(b)
+	reconcileSinceBlock(b)
+}
+
+// reconcileSinceBlock requests the transactions of the wallet since the
+// last synchronized block from b, shows any of the selected account
+// which are missing from the transaction history as new transactions,
+// as they were made after the listalltransactions reply, updates those
+// since mined, and records the best block as the new synchronized block.  Nothing is
+// requested on the first connection, when there is nothing to reconcile,
+// except the best block.
+func reconcileSinceBlock(b WalletBackend) {
+	syncBlock.Lock()
+	since := syncBlock.hash
+	syncBlock.Unlock()
+
+	if since == "" {
+		hash, err := b.GetBestBlockHash()
+		if err != nil {
+			log.Printf("[ERR] getbestblockhash: %v", err)
+			return
+		}
+		noteSyncBlock(hash)
+		return
+	}
+
+	account := currentAccount()
+	txs, lastBlock, err := b.ListSinceBlock(since)
+	if err != nil {
+		log.Printf("[ERR] listsinceblock: %v", err)
+		return
+	}
+	noteSyncBlock(lastBlock)
+	if account != currentAccount() {
+		return
+	}
+
+	changed, missing := 0, 0
+	for _, m := range txs {
+		if a, _ := m["account"].(string); a != account {
+			continue
+		}
+		if category, _ := m["category"].(string); category == "immature" {
+			continue
+		}
+		changed++
+
+		txAttr, err := NewTxAttributesFromMap(m)
+		if err != nil {
+			log.Printf("[ERR] listsinceblock: %v", err)
+			continue
+		}
+		switch markTxSeen(txAttr) {
+		case txSeenDuplicate:
+			continue
+		case txSeenMined:
+			walletEvents.Publish(TxMined{txAttr})
+			continue
+		}
+		missing++
+		walletEvents.Publish(TxAppended{
+			Tx:       txAttr,
+			New:      true,
+			Overview: true,
+		})
+	}
+
+	if missing != 0 {
+		log.Printf("[WRN] %d transactions were missing after "+
+			"reconnecting and have been added", missing)
+	}
+	if changed == 0 {
+		return
+	}
+	log.Printf("[INF] %d transactions since block %s while "+
+		"disconnected", changed, since)
+	msg := fmt.Sprintf("Resynchronized %d transactions made while "+
+		"disconnected.", changed)
+	glib.IdleAdd(func() {
+		recordStatus(msg)
+	})
+}
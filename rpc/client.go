@@ -0,0 +1,516 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+// Package rpc implements a client for the websocket JSON-RPC API of
+// btcwallet.  Connect opens a Transport to btcwallet, falling back to
+// HTTP POST requests where websockets can not be used.  A Client sends
+// requests over a Transport, dispatches each reply to the request
+// waiting for it, and queues notifications to be handled in the order
+// they were received.
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/websocket"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrConnectionLost describes an error where a connection to btcwallet
+// was lost.
+var ErrConnectionLost = errors.New("connection lost")
+
+// ErrRequestTimeout describes an error where btcwallet did not reply to
+// a request within its timeout.  The request may still have been carried
+// out by btcwallet.
+var ErrRequestTimeout = errors.New("no reply from btcwallet in time")
+
+// RequestTimeout is the longest time waited for the reply to a request
+// for any method not in MethodTimeouts, including the time waiting to be
+// written.  btcwallet may silently drop requests, so without a deadline a
+// request could be waited on forever.
+var RequestTimeout = 2 * time.Minute
+
+// MethodTimeouts are the timeouts of the methods which may take much
+// longer than RequestTimeout to reply, such as those which rescan the
+// blockchain before replying.
+var MethodTimeouts = map[string]time.Duration{
+	"createencryptedwallet": 10 * time.Minute,
+	"importaddress":         time.Hour,
+	"importprivkey":         time.Hour,
+	"recoverwallet":         time.Hour,
+	"walletpassphrase":      10 * time.Minute,
+}
+
+// methodTimeout returns the timeout of requests for method.
+func methodTimeout(method string) time.Duration {
+	if d, ok := MethodTimeouts[method]; ok {
+		return d
+	}
+	return RequestTimeout
+}
+
+// requestTimeout returns the timeout of the request msg, or
+// RequestTimeout if its method can not be parsed.
+func requestTimeout(msg []byte) time.Duration {
+	var req struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return RequestTimeout
+	}
+	return methodTimeout(req.Method)
+}
+
+// NtfnQueueSize is the number of notifications which may be buffered
+// before reading further messages from btcwallet blocks.
+const NtfnQueueSize = 100
+
+// Limits of the outgoing request queue.  Up to writeBurst requests may be
+// written at once, after which requests are written at most once every
+// writeInterval.  Once writeQueueSize requests are waiting, callers
+// block until there is room in the queue.
+const (
+	writeQueueSize = 64
+	writeBurst     = 10
+	writeInterval  = 50 * time.Millisecond
+)
+
+// nextID is the JSON ID of the next request.  It must only be accessed
+// atomically.
+var nextID uint64
+
+// NextID returns a unique value for the JSON ID field of btcwallet
+// requests, starting from zero and incrementing by one after each call.
+// It is safe for concurrent use.
+func NextID() uint64 {
+	return atomic.AddUint64(&nextID, 1) - 1
+}
+
+// Transport carries requests to btcwallet, and replies and notifications
+// back.  It is implemented by a websocket connection, or by any other
+// transport reading replies as if received over a websocket.
+type Transport interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// ReplyHandler handles the reply to a request, which is either the
+// result or an error.  The error is a *btcjson.Error if replied by
+// btcwallet, or ErrConnectionLost or ErrRequestTimeout if no reply was
+// received.
+type ReplyHandler func(result interface{}, err error)
+
+// Hooks are called by a Client as requests are sent and replies and
+// notifications are received.  Any hook may be nil.
+type Hooks struct {
+	// CheckRequest is called with each request before it is written,
+	// and refuses to send it by returning an error.
+	CheckRequest func(msg []byte) error
+
+	// Sent is called after the request with id was written.
+	Sent func(id uint64)
+
+	// Replied is called for each reply with the ID of the request it
+	// answers, and whether btcwallet replied with an error.
+	Replied func(id uint64, failed bool)
+
+	// Notified is called for each notification received.
+	Notified func()
+
+	// TimedOut is called when the request with id was not answered
+	// within the timeout of its method.  Its reply handler is called
+	// with ErrRequestTimeout, and a later reply is ignored.
+	TimedOut func(id uint64)
+
+	// Refused is called when btcwallet replies to the request msg with
+	// id with an error.  If it returns true, handler is not called
+	// with the reply, and Refused is responsible for calling it later,
+	// such as after sending the request again with Resend.
+	Refused func(id uint64, msg []byte, handler ReplyHandler,
+		result interface{}, err *btcjson.Error) bool
+}
+
+// writeRequest is a message waiting in the outgoing request queue.  The
+// result of writing the message is sent to err.
+type writeRequest struct {
+	msg []byte
+	err chan error
+}
+
+// pendingRequest is a request waiting for its reply.  timer expires the
+// request once timeout passes.
+type pendingRequest struct {
+	msg     []byte
+	handler ReplyHandler
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+// Client is a single session with btcwallet over a Transport.  Every
+// goroutine started for the session is tracked so that a session can be
+// completely shut down before a new one is created.
+type Client struct {
+	t         Transport
+	hooks     Hooks
+	writes    chan *writeRequest
+	ntfns     chan btcjson.Cmd
+	quit      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+
+	pendingMu sync.Mutex
+	pending   map[uint64]*pendingRequest
+}
+
+// New creates a session for the established connection t and starts its
+// writer.  Replies and notifications are only read once Serve is called.
+func New(t Transport, hooks Hooks) *Client {
+	c := &Client{
+		t:       t,
+		hooks:   hooks,
+		writes:  make(chan *writeRequest, writeQueueSize),
+		ntfns:   make(chan btcjson.Cmd, NtfnQueueSize),
+		quit:    make(chan struct{}),
+		pending: make(map[uint64]*pendingRequest),
+	}
+	c.Go(c.writer)
+	return c
+}
+
+// writer is the only goroutine writing to the connection, so concurrent
+// requests are never interleaved.  Writes are rate limited so a burst of
+// requests, such as after reconnecting, can not flood btcwallet.
+func (c *Client) writer() {
+	tokens := writeBurst
+	ticker := time.NewTicker(writeInterval)
+	defer ticker.Stop()
+	for {
+		if tokens == 0 {
+			select {
+			case <-ticker.C:
+				tokens++
+			case <-c.quit:
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ticker.C:
+			if tokens < writeBurst {
+				tokens++
+			}
+		case req := <-c.writes:
+			tokens--
+			req.err <- c.t.WriteMessage(websocket.TextMessage, req.msg)
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// write queues the request msg with id to be written by the writer and
+// waits for the result.  ErrConnectionLost is returned if the session is
+// closed first, and the error of the CheckRequest hook if msg may not be
+// sent.
+func (c *Client) write(id uint64, msg []byte) error {
+	if c.hooks.CheckRequest != nil {
+		if err := c.hooks.CheckRequest(msg); err != nil {
+			return err
+		}
+	}
+	req := &writeRequest{msg: msg, err: make(chan error, 1)}
+	select {
+	case c.writes <- req:
+	case <-c.quit:
+		return ErrConnectionLost
+	}
+	select {
+	case err := <-req.err:
+		if err == nil && c.hooks.Sent != nil {
+			c.hooks.Sent(id)
+		}
+		return err
+	case <-c.quit:
+		return ErrConnectionLost
+	}
+}
+
+// Go runs f in a goroutine belonging to the session.
+func (c *Client) Go(f func()) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		f()
+	}()
+}
+
+// Done returns a channel which is closed when the session is closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.quit
+}
+
+// Close closes the connection, causing Serve to return, and signals any
+// other goroutines of the session to quit.  It is safe to call Close
+// multiple times.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.quit)
+		c.t.Close()
+	})
+}
+
+// Shutdown closes the session, fails every request still waiting for a
+// reply, and waits for all goroutines of the session to exit.
+func (c *Client) Shutdown() {
+	c.Close()
+
+	c.pendingMu.Lock()
+	pending := c.pending
+	c.pending = make(map[uint64]*pendingRequest)
+	c.pendingMu.Unlock()
+	for _, p := range pending {
+		p.timer.Stop()
+		f := p.handler
+		c.Go(func() {
+			f(nil, ErrConnectionLost)
+		})
+	}
+
+	c.wg.Wait()
+}
+
+// Pending returns the number of requests waiting for a reply.
+func (c *Client) Pending() int {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	return len(c.pending)
+}
+
+// QueueLen returns the number of requests waiting to be written and the
+// number of notifications waiting to be handled.
+func (c *Client) QueueLen() (writes, ntfns int) {
+	return len(c.writes), len(c.ntfns)
+}
+
+// Notifications returns the channel notifications are received from, in
+// the order they were sent.
+func (c *Client) Notifications() <-chan btcjson.Cmd {
+	return c.ntfns
+}
+
+// Serve reads replies and notifications from the connection until
+// reading fails, such as after the session is closed, and returns the
+// read error.  Notifications are queued to be read from Notifications,
+// blocking further reads while the queue is full, while replies are
+// handled concurrently.
+func (c *Client) Serve() error {
+	for {
+		_, msg, err := c.t.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		// Check for notifications first.
+		n, err := parseNotification(msg)
+		switch err {
+		case nil:
+			if c.hooks.Notified != nil {
+				c.hooks.Notified()
+			}
+			select {
+			case c.ntfns <- n:
+			case <-c.quit:
+				return ErrConnectionLost
+			}
+
+		case errNotNotification:
+			c.Go(func() {
+				c.handleReply(msg)
+			})
+
+		default:
+			log.Printf("[WRN] %v", err)
+		}
+	}
+}
+
+// handleReply handles a btcwallet reply by calling the handler
+// registered for the reply ID.
+func (c *Client) handleReply(b []byte) {
+	// b is not a Request notification, so it must be a Response.
+	// Attempt to parse it as one and handle.
+	var r btcjson.Reply
+	if err := json.Unmarshal(b, &r); err != nil {
+		log.Print("[WRN] Unable to unmarshal btcwallet message as notification or response")
+		return
+	}
+
+	// Check for a valid ID.  btcgui only sends numbers as IDs, so
+	// perform an appropiate type check.
+	if r.Id == nil {
+		// Responses with no IDs cannot be handled.
+		log.Print("[WRN] Unable to process btcwallet response without ID")
+		return
+	}
+	fid, ok := (*r.Id).(float64)
+	if !ok {
+		log.Printf("[WRN] Unable to process btcwallet response with non-number ID %v",
+			*r.Id)
+		return
+	}
+	id := uint64(fid)
+
+	if c.hooks.Replied != nil {
+		c.hooks.Replied(id, r.Error != nil)
+	}
+	p, ok := c.take(id)
+	if !ok {
+		log.Print("[WRN] No handler for btcwallet response " +
+			"(the request may have timed out)")
+		return
+	}
+	if r.Error == nil {
+		p.handler(r.Result, nil)
+		return
+	}
+	if c.hooks.Refused != nil &&
+		c.hooks.Refused(id, p.msg, p.handler, r.Result, r.Error) {
+		return
+	}
+	p.handler(r.Result, r.Error)
+}
+
+// Resend sends the request msg with id again, calling handler with its
+// reply.  The request may have first been sent over another session,
+// such as one lost while the request was waiting to be retried.
+func (c *Client) Resend(id uint64, msg []byte, handler ReplyHandler) error {
+	return c.send(id, msg, handler)
+}
+
+// take removes the request with id from the requests waiting for a
+// reply and stops its deadline, returning the request and whether it was
+// still waiting.
+func (c *Client) take(id uint64) (*pendingRequest, bool) {
+	c.pendingMu.Lock()
+	p, ok := c.pending[id]
+	delete(c.pending, id)
+	c.pendingMu.Unlock()
+	if ok {
+		p.timer.Stop()
+	}
+	return p, ok
+}
+
+// expire removes the request p with id once its deadline has passed, if
+// it is still waiting for a reply, and calls its handler with
+// ErrRequestTimeout.
+func (c *Client) expire(id uint64, p *pendingRequest) {
+	c.pendingMu.Lock()
+	waiting := c.pending[id] == p
+	if waiting {
+		delete(c.pending, id)
+	}
+	c.pendingMu.Unlock()
+	if !waiting {
+		return
+	}
+
+	log.Printf("[WRN] No reply from btcwallet to request %d after %v",
+		id, p.timeout)
+	if c.hooks.TimedOut != nil {
+		c.hooks.TimedOut(id)
+	}
+	p.handler(nil, ErrRequestTimeout)
+}
+
+// send writes the request msg with id, registering handler to be called
+// with its reply, or with ErrRequestTimeout if no reply is received
+// within the timeout of its method.
+func (c *Client) send(id uint64, msg []byte, handler ReplyHandler) error {
+	p := &pendingRequest{
+		msg:     msg,
+		handler: handler,
+		timeout: requestTimeout(msg),
+	}
+	c.pendingMu.Lock()
+	p.timer = time.AfterFunc(p.timeout, func() {
+		c.expire(id, p)
+	})
+	c.pending[id] = p
+	c.pendingMu.Unlock()
+
+	if err := c.write(id, msg); err != nil {
+		c.pendingMu.Lock()
+		if c.pending[id] == p {
+			delete(c.pending, id)
+		}
+		c.pendingMu.Unlock()
+		p.timer.Stop()
+		return err
+	}
+	return nil
+}
+
+// Request sends a request for method with params to btcwallet and waits
+// for the reply, returning its result.  Errors replied by btcwallet are
+// returned as a *btcjson.Error, so callers may check the error code.
+// ErrConnectionLost is returned if the session is closed first, and
+// ErrRequestTimeout if btcwallet does not reply within the timeout of
+// method.
+func (c *Client) Request(method string, params ...interface{}) (interface{}, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	id := NextID()
+	m := btcjson.Message{
+		Jsonrpc: "1.0",
+		Id:      id,
+		Method:  method,
+		Params:  params,
+	}
+	msg, err := json.Marshal(&m)
+	if err != nil {
+		return nil, err
+	}
+
+	type reply struct {
+		result interface{}
+		err    error
+	}
+	replies := make(chan reply, 1)
+	err = c.send(id, msg, func(result interface{}, err error) {
+		replies <- reply{result, err}
+	})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case r := <-replies:
+		if r.err != nil {
+			return nil, r.err
+		}
+		return r.result, nil
+	case <-c.quit:
+		return nil, ErrConnectionLost
+	}
+}
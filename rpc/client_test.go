@@ -0,0 +1,198 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcws"
+	"github.com/conformal/websocket"
+	"testing"
+	"time"
+)
+
+// fakeTransport is a Transport passing each written request to requests
+// and reading messages queued to msgs.
+type fakeTransport struct {
+	requests chan []byte
+	msgs     chan []byte
+	closed   chan struct{}
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{
+		requests: make(chan []byte, 10),
+		msgs:     make(chan []byte, 10),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (t *fakeTransport) WriteMessage(_ int, data []byte) error {
+	t.requests <- data
+	return nil
+}
+
+func (t *fakeTransport) ReadMessage() (int, []byte, error) {
+	select {
+	case msg := <-t.msgs:
+		return websocket.TextMessage, msg, nil
+	case <-t.closed:
+		return 0, nil, ErrConnectionLost
+	}
+}
+
+func (t *fakeTransport) Close() error {
+	close(t.closed)
+	return nil
+}
+
+// reply queues the reply to the next written request, replying with
+// result, or with err if not nil, and returns the method of the request.
+func (t *fakeTransport) reply(result interface{}, err *btcjson.Error) (string, error) {
+	var req btcjson.Message
+	if err := json.Unmarshal(<-t.requests, &req); err != nil {
+		return "", err
+	}
+	msg, merr := json.Marshal(&struct {
+		Result interface{}    `json:"result"`
+		Error  *btcjson.Error `json:"error"`
+		Id     interface{}    `json:"id"`
+	}{result, err, req.Id})
+	if merr != nil {
+		return "", merr
+	}
+	t.msgs <- msg
+	return req.Method, nil
+}
+
+// newTestClient returns a session over a new fakeTransport which is
+// served until it is shut down.
+func newTestClient() (*Client, *fakeTransport) {
+	t := newFakeTransport()
+	c := New(t, Hooks{})
+	go c.Serve()
+	return c, t
+}
+
+func TestRequest(t *testing.T) {
+	c, ft := newTestClient()
+	defer c.Shutdown()
+
+	methods := make(chan string, 1)
+	go func() {
+		method, err := ft.reply(float64(12), nil)
+		if err != nil {
+			method = err.Error()
+		}
+		methods <- method
+	}()
+	height, err := c.GetBlockCount()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if height != 12 {
+		t.Errorf("got height %d, want 12", height)
+	}
+	if method := <-methods; method != "getblockcount" {
+		t.Errorf("got method %q, want getblockcount", method)
+	}
+}
+
+func TestRequestError(t *testing.T) {
+	c, ft := newTestClient()
+	defer c.Shutdown()
+
+	go ft.reply(nil, &btcjson.ErrWalletUnlockNeeded)
+	_, err := c.WalletIsLocked()
+	jsonErr, ok := err.(*btcjson.Error)
+	if !ok || jsonErr.Code != btcjson.ErrWalletUnlockNeeded.Code {
+		t.Errorf("got error %v, want %v", err,
+			btcjson.ErrWalletUnlockNeeded)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	MethodTimeouts["testtimeout"] = 10 * time.Millisecond
+	defer delete(MethodTimeouts, "testtimeout")
+
+	c, _ := newTestClient()
+	defer c.Shutdown()
+
+	if _, err := c.Request("testtimeout"); err != ErrRequestTimeout {
+		t.Errorf("got error %v, want %v", err, ErrRequestTimeout)
+	}
+	if n := c.Pending(); n != 0 {
+		t.Errorf("%d requests still pending after timeout", n)
+	}
+}
+
+func TestShutdownFailsPending(t *testing.T) {
+	c, ft := newTestClient()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := c.Request("getinfo")
+		errs <- err
+	}()
+	<-ft.requests
+	c.Shutdown()
+	if err := <-errs; err != ErrConnectionLost {
+		t.Errorf("got error %v, want %v", err, ErrConnectionLost)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	c, ft := newTestClient()
+	defer c.Shutdown()
+
+	for i := int32(1); i <= 3; i++ {
+		ft.msgs <- []byte(fmt.Sprintf(`{"jsonrpc":"1.0",`+
+			`"method":"blockconnected","params":["hash%d",%d],`+
+			`"id":null}`, i, i))
+	}
+	for i := int32(1); i <= 3; i++ {
+		select {
+		case n := <-c.Notifications():
+			b, ok := n.(*btcws.BlockConnectedNtfn)
+			if !ok || b.Height != i {
+				t.Fatalf("got notification %#v, want height %d",
+					n, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("notification not received")
+		}
+	}
+}
+
+func TestMethodTimeout(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want time.Duration
+	}{
+		{`{"method":"getinfo"}`, RequestTimeout},
+		{`{"method":"importprivkey"}`, MethodTimeouts["importprivkey"]},
+		{`{"method":"walletpassphrase"}`, MethodTimeouts["walletpassphrase"]},
+		{`not json`, RequestTimeout},
+	}
+	for _, test := range tests {
+		if d := requestTimeout([]byte(test.msg)); d != test.want {
+			t.Errorf("%s: got timeout %v, want %v", test.msg, d,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package rpc
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/conformal/websocket"
+	"log"
+	"net"
+	"net/http"
+)
+
+// Endpoint describes how to connect to btcwallet.
+type Endpoint struct {
+	// Host is the host and port of btcwallet, which its certificate
+	// must be valid for.
+	Host string
+
+	// TLSConfig verifies the certificate of btcwallet.
+	TLSConfig *tls.Config
+
+	// Dial makes the connections to btcwallet, or is nil to connect
+	// directly.
+	Dial func(network, addr string) (net.Conn, error)
+
+	// Auth is the Authorization header of each request.
+	Auth string
+
+	// CheckRequest, if not nil, is passed each request sent with HTTP
+	// POST requests, and refuses to send it by returning an error.
+	CheckRequest func(msg []byte) error
+}
+
+// Connection is an open connection to btcwallet.
+type Connection struct {
+	// Transport carries requests and replies to be served by a Client.
+	Transport Transport
+
+	// Polling is set if the connection uses HTTP POST requests, over
+	// which no notifications are received, so the wallet state must be
+	// requested periodically instead.
+	Polling bool
+
+	// Kind describes the type of the connection to users.
+	Kind string
+
+	// State is the TLS connection state, or nil if unknown.
+	State *tls.ConnectionState
+}
+
+// Connect opens a websocket connection to btcwallet at e, or falls back
+// to HTTP POST requests if the websocket can not be opened.  The error
+// opening the websocket is returned if neither can be used.
+func Connect(e *Endpoint) (*Connection, error) {
+	header := make(http.Header)
+	header.Add("Authorization", e.Auth)
+	dialer := websocket.Dialer{
+		TLSClientConfig: e.TLSConfig,
+		NetDial:         e.Dial,
+	}
+	ws, _, err := dialer.Dial(fmt.Sprintf("wss://%s/ws", e.Host), header)
+	if err == nil {
+		var state *tls.ConnectionState
+		if tc, ok := ws.UnderlyingConn().(*tls.Conn); ok {
+			cs := tc.ConnectionState()
+			state = &cs
+		}
+		return &Connection{
+			Transport: ws,
+			Kind:      "Websocket",
+			State:     state,
+		}, nil
+	}
+	log.Printf("[ERR] cannot create websocket config: %v", err)
+
+	// Restrictive proxies may refuse the websocket upgrade while still
+	// allowing plain HTTPS requests, so fall back to HTTP POST requests,
+	// polling for changes as no notifications are received.
+	t, state, herr := DialHTTP(e.Host, e.TLSConfig, e.Dial, e.Auth,
+		e.CheckRequest)
+	if herr != nil {
+		log.Printf("[ERR] cannot connect with HTTP POST: %v", herr)
+		return nil, err
+	}
+	log.Print("[WRN] Websocket connection failed, using HTTP POST " +
+		"requests and polling for changes")
+	return &Connection{
+		Transport: t,
+		Polling:   true,
+		Kind:      "HTTP POST (polling)",
+		State:     state,
+	}, nil
+}
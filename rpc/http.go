@@ -0,0 +1,203 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package rpc
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/websocket"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// httpProbe is the request sent to check that btcwallet accepts HTTP POST
+// requests before using them.  Its reply is not handled.
+const httpProbe = `{"jsonrpc":"1.0","id":"probe","method":"getblockcount","params":[]}`
+
+// ErrHTTPUnauthorized describes the error where btcwallet refuses the
+// username and password of an HTTP POST request.
+var ErrHTTPUnauthorized = errors.New("btcwallet refused the username and password")
+
+// HTTPTransport sends each request to btcwallet as an HTTP POST request,
+// for when a websocket connection can not be established, such as through
+// a proxy not supporting websockets.  The reply to each request is read
+// with ReadMessage as if received over a websocket.  No notifications
+// are ever received.
+type HTTPTransport struct {
+	client    *http.Client
+	url       string
+	auth      string
+	check     func(msg []byte) error
+	replies   chan []byte
+	quit      chan struct{}
+	closeOnce sync.Once
+
+	// err is the error which closed the transport, returned by reads
+	// after closing.
+	errMu sync.Mutex
+	err   error
+}
+
+// DialHTTP checks that btcwallet at host accepts HTTP POST requests with
+// the Authorization header auth, and returns a transport sending requests
+// there and the TLS connection state.  Connections are made with dial,
+// or directly if dial is nil.  Each request is first passed to check, if
+// not nil, which refuses to send it by returning an error.
+func DialHTTP(host string, tlsConfig *tls.Config,
+	dial func(network, addr string) (net.Conn, error), auth string,
+	check func(msg []byte) error) (*HTTPTransport, *tls.ConnectionState, error) {
+
+	t := &HTTPTransport{
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: tlsConfig,
+				Dial:            dial,
+			},
+		},
+		url:     fmt.Sprintf("https://%s/", host),
+		auth:    auth,
+		check:   check,
+		replies: make(chan []byte, 100),
+		quit:    make(chan struct{}),
+	}
+	resp, _, err := t.post([]byte(httpProbe))
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, resp.TLS, nil
+}
+
+// post sends msg as an HTTP POST request and returns the response and its
+// body.  Requests refused by the check function of the transport are
+// not sent.
+func (t *HTTPTransport) post(msg []byte) (*http.Response, []byte, error) {
+	if t.check != nil {
+		if err := t.check(msg); err != nil {
+			return nil, nil, err
+		}
+	}
+	req, err := http.NewRequest("POST", t.url, bytes.NewReader(msg))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", t.auth)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, body, nil
+	case http.StatusUnauthorized:
+		return nil, nil, ErrHTTPUnauthorized
+	default:
+		return nil, nil, fmt.Errorf("HTTP POST request failed: %s",
+			resp.Status)
+	}
+}
+
+// RequestAmount sends a request for method directly, outside of any
+// session, and parses the reply as an amount in bitcoin.  The method is
+// used as the request ID, as replies are never read with ReadMessage.
+func (t *HTTPTransport) RequestAmount(method string) (btcutil.Amount, error) {
+	msg, err := json.Marshal(&btcjson.Message{
+		Jsonrpc: "1.0",
+		Id:      method,
+		Method:  method,
+		Params:  []interface{}{},
+	})
+	if err != nil {
+		return 0, err
+	}
+	_, body, err := t.post(msg)
+	if err != nil {
+		return 0, err
+	}
+	var r btcjson.Reply
+	if err := json.Unmarshal(body, &r); err != nil {
+		return 0, err
+	}
+	if r.Error != nil {
+		return 0, r.Error
+	}
+	f, ok := r.Result.(float64)
+	if !ok {
+		return 0, errors.New(method + " reply is not a number")
+	}
+	return btcutil.NewAmount(f)
+}
+
+// WriteMessage sends data as an HTTP POST request, queuing the reply to
+// be read with ReadMessage.  Any error closes the transport, ending the
+// session so a new connection is made.
+func (t *HTTPTransport) WriteMessage(_ int, data []byte) error {
+	_, body, err := t.post(data)
+	if err != nil {
+		t.errMu.Lock()
+		t.err = err
+		t.errMu.Unlock()
+		t.Close()
+		return err
+	}
+	select {
+	case t.replies <- body:
+		return nil
+	case <-t.quit:
+		return ErrConnectionLost
+	}
+}
+
+// ReadMessage returns the next reply to a request, waiting until one is
+// received or the transport is closed.
+func (t *HTTPTransport) ReadMessage() (int, []byte, error) {
+	select {
+	case b := <-t.replies:
+		return websocket.TextMessage, b, nil
+	case <-t.quit:
+		t.errMu.Lock()
+		defer t.errMu.Unlock()
+		if t.err != nil {
+			return 0, nil, t.err
+		}
+		return 0, nil, ErrConnectionLost
+	}
+}
+
+// Close stops all reads and writes.  It is safe to call Close multiple
+// times.
+func (t *HTTPTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.quit)
+		if tr, ok := t.client.Transport.(*http.Transport); ok {
+			tr.CloseIdleConnections()
+		}
+	})
+	return nil
+}
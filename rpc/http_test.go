@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package rpc
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testAuth is the Authorization header accepted by newTestWallet.
+const testAuth = "Basic dXNlcjpwYXNz"
+
+// newTestWallet starts an HTTPS server replying to every request with
+// result, refusing requests without testAuth, and returns the server
+// and its host.
+func newTestWallet(result interface{}) (*httptest.Server, string) {
+	s := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != testAuth {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var req struct {
+				Id interface{} `json:"id"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"result": result,
+				"error":  nil,
+				"id":     req.Id,
+			})
+		}))
+	return s, strings.TrimPrefix(s.URL, "https://")
+}
+
+// testTLSConfig accepts the self-signed certificate of httptest servers.
+var testTLSConfig = &tls.Config{InsecureSkipVerify: true}
+
+func TestDialHTTP(t *testing.T) {
+	s, host := newTestWallet(12)
+	defer s.Close()
+
+	ht, state, err := DialHTTP(host, testTLSConfig, nil, testAuth, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer ht.Close()
+	if state == nil {
+		t.Error("no TLS connection state")
+	}
+}
+
+func TestDialHTTPUnauthorized(t *testing.T) {
+	s, host := newTestWallet(12)
+	defer s.Close()
+
+	_, _, err := DialHTTP(host, testTLSConfig, nil, "Basic bad", nil)
+	if err != ErrHTTPUnauthorized {
+		t.Errorf("got error %v, want %v", err, ErrHTTPUnauthorized)
+	}
+}
+
+func TestHTTPTransportCheck(t *testing.T) {
+	s, host := newTestWallet(12)
+	defer s.Close()
+
+	errRefused := errors.New("refused")
+	check := func(msg []byte) error {
+		if strings.Contains(string(msg), "sendmany") {
+			return errRefused
+		}
+		return nil
+	}
+	ht, _, err := DialHTTP(host, testTLSConfig, nil, testAuth, check)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A refused request closes the transport, failing every later
+	// read with the same error.
+	req := []byte(`{"jsonrpc":"1.0","id":1,"method":"sendmany","params":[]}`)
+	if err := ht.WriteMessage(0, req); err != errRefused {
+		t.Errorf("got error %v, want %v", err, errRefused)
+	}
+	if _, _, err := ht.ReadMessage(); err != errRefused {
+		t.Errorf("got read error %v, want %v", err, errRefused)
+	}
+}
+
+func TestHTTPTransportReply(t *testing.T) {
+	s, host := newTestWallet(12)
+	defer s.Close()
+
+	ht, _, err := DialHTTP(host, testTLSConfig, nil, testAuth, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer ht.Close()
+
+	req := []byte(`{"jsonrpc":"1.0","id":7,"method":"getblockcount","params":[]}`)
+	if err := ht.WriteMessage(0, req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, msg, err := ht.ReadMessage()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var reply struct {
+		Result float64 `json:"result"`
+		Id     float64 `json:"id"`
+	}
+	if err := json.Unmarshal(msg, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Result != 12 || reply.Id != 7 {
+		t.Errorf("got reply %s, want result 12 for ID 7", msg)
+	}
+}
+
+func TestHTTPTransportClose(t *testing.T) {
+	s, host := newTestWallet(12)
+	defer s.Close()
+
+	ht, _, err := DialHTTP(host, testTLSConfig, nil, testAuth, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ht.Close()
+	if _, _, err := ht.ReadMessage(); err != ErrConnectionLost {
+		t.Errorf("got read error %v, want %v", err, ErrConnectionLost)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcws"
+)
+
+// errNotNotification describes the error returned when parsing a
+// btcwallet message which is not a notification.
+var errNotNotification = errors.New("message is not a notification")
+
+// rawNtfn is a JSON-RPC notification with unparsed parameters.
+type rawNtfn struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Id     interface{}       `json:"id"`
+}
+
+// ntfnAdapter translates the parameters of a notification, as sent by
+// some btcwallet release, to the btcws type handled by btcgui.
+type ntfnAdapter func(params []json.RawMessage) (btcjson.Cmd, error)
+
+// ntfnAdapters maps notification methods to an adapter for each known
+// version of the notification parameters, newest first.  Adapters only
+// read the parameters they know about, so parameters added by newer
+// btcwallet releases are ignored rather than causing an error.
+var ntfnAdapters = map[string][]ntfnAdapter{
+	btcws.AccountBalanceNtfnMethod: {
+		func(p []json.RawMessage) (btcjson.Cmd, error) {
+			var account string
+			var balance float64
+			var confirmed bool
+			err := unmarshalParams(p, &account, &balance, &confirmed)
+			if err != nil {
+				return nil, err
+			}
+			return btcws.NewAccountBalanceNtfn(account, balance,
+				confirmed), nil
+		},
+	},
+	btcws.BlockConnectedNtfnMethod: {
+		func(p []json.RawMessage) (btcjson.Cmd, error) {
+			var hash string
+			var height int32
+			if err := unmarshalParams(p, &hash, &height); err != nil {
+				return nil, err
+			}
+			return btcws.NewBlockConnectedNtfn(hash, height), nil
+		},
+		// Early releases sent the block as a single object.
+		func(p []json.RawMessage) (btcjson.Cmd, error) {
+			var block struct {
+				Hash   string `json:"hash"`
+				Height int32  `json:"height"`
+			}
+			if err := unmarshalParams(p, &block); err != nil {
+				return nil, err
+			}
+			return btcws.NewBlockConnectedNtfn(block.Hash,
+				block.Height), nil
+		},
+	},
+	btcws.BlockDisconnectedNtfnMethod: {
+		func(p []json.RawMessage) (btcjson.Cmd, error) {
+			var hash string
+			var height int32
+			if err := unmarshalParams(p, &hash, &height); err != nil {
+				return nil, err
+			}
+			return btcws.NewBlockDisconnectedNtfn(hash, height), nil
+		},
+	},
+	btcws.BtcdConnectedNtfnMethod: {
+		func(p []json.RawMessage) (btcjson.Cmd, error) {
+			var connected bool
+			if err := unmarshalParams(p, &connected); err != nil {
+				return nil, err
+			}
+			return btcws.NewBtcdConnectedNtfn(connected), nil
+		},
+	},
+	btcws.TxNtfnMethod: {
+		func(p []json.RawMessage) (btcjson.Cmd, error) {
+			var account string
+			var details btcjson.ListTransactionsResult
+			err := unmarshalParams(p, &account, &details)
+			if err != nil {
+				return nil, err
+			}
+			return btcws.NewTxNtfn(account, &details), nil
+		},
+		// Early releases only sent the details, which include the
+		// account.
+		func(p []json.RawMessage) (btcjson.Cmd, error) {
+			var details btcjson.ListTransactionsResult
+			if err := unmarshalParams(p, &details); err != nil {
+				return nil, err
+			}
+			return btcws.NewTxNtfn(details.Account, &details), nil
+		},
+	},
+	btcws.WalletLockStateNtfnMethod: {
+		func(p []json.RawMessage) (btcjson.Cmd, error) {
+			var account string
+			var locked bool
+			if err := unmarshalParams(p, &account, &locked); err != nil {
+				return nil, err
+			}
+			return btcws.NewWalletLockStateNtfn(account, locked), nil
+		},
+		// Early releases did not include the account.
+		func(p []json.RawMessage) (btcjson.Cmd, error) {
+			var locked bool
+			if err := unmarshalParams(p, &locked); err != nil {
+				return nil, err
+			}
+			return btcws.NewWalletLockStateNtfn("", locked), nil
+		},
+	},
+}
+
+// unmarshalParams unmarshals the leading notification parameters into
+// each of dst, in order.  Any additional parameters are ignored.
+func unmarshalParams(params []json.RawMessage, dst ...interface{}) error {
+	if len(params) < len(dst) {
+		return fmt.Errorf("expected %d parameters, got %d", len(dst),
+			len(params))
+	}
+	for i, d := range dst {
+		if err := json.Unmarshal(params[i], d); err != nil {
+			return fmt.Errorf("parameter %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// parseNotification parses a btcwallet message as a notification.  Known
+// notifications are translated by the first adapter accepting their
+// parameters, and any others are parsed by btcjson.  errNotNotification
+// is returned if the message is a response.
+func parseNotification(b []byte) (btcjson.Cmd, error) {
+	var raw rawNtfn
+	if err := json.Unmarshal(b, &raw); err != nil || raw.Method == "" {
+		return nil, errNotNotification
+	}
+	if raw.Id != nil {
+		return nil, fmt.Errorf("btcwallet sent a non-notification "+
+			"JSON-RPC Request (Id: %v)", raw.Id)
+	}
+
+	adapters, ok := ntfnAdapters[raw.Method]
+	if !ok {
+		return btcjson.ParseMarshaledCmd(b)
+	}
+	var err error
+	for _, adapt := range adapters {
+		var n btcjson.Cmd
+		if n, err = adapt(raw.Params); err == nil {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot parse %v notification: %v", raw.Method,
+		err)
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package rpc
+
+import (
+	"github.com/conformal/btcws"
+	"testing"
+)
+
+func TestParseNotification(t *testing.T) {
+	tests := []struct {
+		name  string
+		msg   string
+		check func(n interface{}) bool
+	}{
+		{
+			name: "blockconnected",
+			msg:  `{"jsonrpc":"1.0","method":"blockconnected","params":["abc",12],"id":null}`,
+			check: func(n interface{}) bool {
+				b, ok := n.(*btcws.BlockConnectedNtfn)
+				return ok && b.Hash == "abc" && b.Height == 12
+			},
+		},
+		{
+			name: "early blockconnected",
+			msg:  `{"jsonrpc":"1.0","method":"blockconnected","params":[{"hash":"abc","height":12}],"id":null}`,
+			check: func(n interface{}) bool {
+				b, ok := n.(*btcws.BlockConnectedNtfn)
+				return ok && b.Hash == "abc" && b.Height == 12
+			},
+		},
+		{
+			name: "newtx",
+			msg:  `{"jsonrpc":"1.0","method":"newtx","params":["acct",{"account":"acct","category":"receive","amount":1.5}],"id":null}`,
+			check: func(n interface{}) bool {
+				tx, ok := n.(*btcws.TxNtfn)
+				return ok && tx.Account == "acct" &&
+					tx.Details.Amount == 1.5
+			},
+		},
+		{
+			name: "early newtx",
+			msg:  `{"jsonrpc":"1.0","method":"newtx","params":[{"account":"acct","category":"receive","amount":1.5}],"id":null}`,
+			check: func(n interface{}) bool {
+				tx, ok := n.(*btcws.TxNtfn)
+				return ok && tx.Account == "acct" &&
+					tx.Details.Amount == 1.5
+			},
+		},
+		{
+			name: "walletlockstate",
+			msg:  `{"jsonrpc":"1.0","method":"walletlockstate","params":["acct",true],"id":null}`,
+			check: func(n interface{}) bool {
+				l, ok := n.(*btcws.WalletLockStateNtfn)
+				return ok && l.Account == "acct" && l.Locked
+			},
+		},
+		{
+			name: "early walletlockstate",
+			msg:  `{"jsonrpc":"1.0","method":"walletlockstate","params":[true],"id":null}`,
+			check: func(n interface{}) bool {
+				l, ok := n.(*btcws.WalletLockStateNtfn)
+				return ok && l.Account == "" && l.Locked
+			},
+		},
+		{
+			name: "accountbalance with added params",
+			msg:  `{"jsonrpc":"1.0","method":"accountbalance","params":["acct",2.5,true,"added"],"id":null}`,
+			check: func(n interface{}) bool {
+				b, ok := n.(*btcws.AccountBalanceNtfn)
+				return ok && b.Account == "acct" &&
+					b.Balance == 2.5 && b.Confirmed
+			},
+		},
+	}
+
+	for _, test := range tests {
+		n, err := parseNotification([]byte(test.msg))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !test.check(n) {
+			t.Errorf("%s: unexpected notification %#v", test.name, n)
+		}
+	}
+}
+
+func TestParseNotificationErrors(t *testing.T) {
+	reply := `{"result":12,"error":null,"id":3}`
+	if _, err := parseNotification([]byte(reply)); err != errNotNotification {
+		t.Errorf("reply: got error %v, want %v", err, errNotNotification)
+	}
+
+	tests := []struct {
+		name string
+		msg  string
+	}{
+		{
+			name: "request with ID",
+			msg:  `{"jsonrpc":"1.0","method":"blockconnected","params":["abc",12],"id":1}`,
+		},
+		{
+			name: "missing params",
+			msg:  `{"jsonrpc":"1.0","method":"blockconnected","params":["abc"],"id":null}`,
+		},
+		{
+			name: "wrong param types",
+			msg:  `{"jsonrpc":"1.0","method":"btcdconnected","params":["yes"],"id":null}`,
+		},
+	}
+	for _, test := range tests {
+		_, err := parseNotification([]byte(test.msg))
+		if err == nil || err == errNotNotification {
+			t.Errorf("%s: got error %v, want a parse error", test.name,
+				err)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package rpc
+
+import (
+	"errors"
+	"github.com/conformal/btcutil"
+)
+
+// OutPoint identifies a single transaction output.
+type OutPoint struct {
+	TxID string
+	Vout uint32
+}
+
+// AddressGroupEntry is a single address of an address grouping, along
+// with its balance and the account it belongs to.
+type AddressGroupEntry struct {
+	Address string
+	Amount  btcutil.Amount
+	Account string
+}
+
+// requestAmount requests method with params and parses the reply as an
+// amount in bitcoin.
+func (c *Client) requestAmount(method string, params ...interface{}) (btcutil.Amount, error) {
+	result, err := c.Request(method, params...)
+	if err != nil {
+		return 0, err
+	}
+	f, ok := result.(float64)
+	if !ok {
+		return 0, errors.New(method + " reply is not a number")
+	}
+	return btcutil.NewAmount(f)
+}
+
+// requestObject requests method with params and returns the reply, which
+// must be a JSON object.
+func (c *Client) requestObject(method string, params ...interface{}) (map[string]interface{}, error) {
+	result, err := c.Request(method, params...)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(method + " reply is not a JSON object")
+	}
+	return m, nil
+}
+
+// GetBalance returns the balance of account with at least one
+// confirmation.
+func (c *Client) GetBalance(account string) (btcutil.Amount, error) {
+	return c.requestAmount("getbalance", account)
+}
+
+// GetUnconfirmedBalance returns the balance of unconfirmed transactions
+// of account.
+func (c *Client) GetUnconfirmedBalance(account string) (btcutil.Amount, error) {
+	return c.requestAmount("getunconfirmedbalance", account)
+}
+
+// GetBlockCount returns the height of the best chain.
+func (c *Client) GetBlockCount() (int32, error) {
+	result, err := c.Request("getblockcount")
+	if err != nil {
+		return 0, err
+	}
+	f, ok := result.(float64)
+	if !ok {
+		return 0, errors.New("getblockcount reply is not a number")
+	}
+	return int32(f), nil
+}
+
+// GetInfo returns the state of btcwallet and the chain as the JSON object
+// of a getinfo reply.
+func (c *Client) GetInfo() (map[string]interface{}, error) {
+	return c.requestObject("getinfo")
+}
+
+// GetWalletInfo returns the state of the wallet as the JSON object of a
+// getwalletinfo reply.  btcwallet versions without the method reply that
+// it was not found.
+func (c *Client) GetWalletInfo() (map[string]interface{}, error) {
+	return c.requestObject("getwalletinfo")
+}
+
+// ListAccounts returns the balance of every account of the wallet, keyed
+// by account name.
+func (c *Client) ListAccounts() (map[string]btcutil.Amount, error) {
+	m, err := c.requestObject("listaccounts")
+	if err != nil {
+		return nil, err
+	}
+	accounts := make(map[string]btcutil.Amount, len(m))
+	for name, v := range m {
+		f, _ := v.(float64)
+		amt, err := btcutil.NewAmount(f)
+		if err != nil {
+			return nil, err
+		}
+		accounts[name] = amt
+	}
+	return accounts, nil
+}
+
+// ListTransactions returns every transaction of account as the JSON
+// objects of a listalltransactions reply.
+func (c *Client) ListTransactions(account string) ([]map[string]interface{}, error) {
+	result, err := c.Request("listalltransactions", account)
+	if err != nil {
+		return nil, err
+	}
+	vr, ok := result.([]interface{})
+	if !ok {
+		return nil, errors.New("listalltransactions reply is not an array")
+	}
+	txs := make([]map[string]interface{}, len(vr))
+	for i, r := range vr {
+		if txs[i], ok = r.(map[string]interface{}); !ok {
+			return nil, errors.New("listalltransactions reply is " +
+				"not an array of JSON objects")
+		}
+	}
+	return txs, nil
+}
+
+// ListSinceBlock returns every transaction of the wallet in blocks after
+// the block with hash, or unconfirmed, as the JSON objects of a
+// listsinceblock reply, along with the hash of the best block.
+func (c *Client) ListSinceBlock(hash string) ([]map[string]interface{}, string, error) {
+	m, err := c.requestObject("listsinceblock", hash)
+	if err != nil {
+		return nil, "", err
+	}
+	lastBlock, _ := m["lastblock"].(string)
+	vr, ok := m["transactions"].([]interface{})
+	if !ok {
+		return nil, "", errors.New("listsinceblock reply has no " +
+			"transactions array")
+	}
+	txs := make([]map[string]interface{}, len(vr))
+	for i, r := range vr {
+		if txs[i], ok = r.(map[string]interface{}); !ok {
+			return nil, "", errors.New("listsinceblock reply is " +
+				"not an array of JSON objects")
+		}
+	}
+	return txs, lastBlock, nil
+}
+
+// GetBestBlockHash returns the hash of the best block, passed through
+// by btcwallet from btcd.
+func (c *Client) GetBestBlockHash() (string, error) {
+	result, err := c.Request("getbestblockhash")
+	if err != nil {
+		return "", err
+	}
+	hash, ok := result.(string)
+	if !ok {
+		return "", errors.New("getbestblockhash reply is not a string")
+	}
+	return hash, nil
+}
+
+// SendMany creates and sends a transaction from account paying each
+// address of pairs the amount in bitcoin it maps to, and returns its ID.
+func (c *Client) SendMany(account string, pairs map[string]float64, comment string) (string, error) {
+	params := []interface{}{account, pairs}
+	if comment != "" {
+		// The comment follows the optional minconf parameter, so it
+		// must be set to the default of 1 confirmation.
+		params = append(params, 1, comment)
+	}
+	result, err := c.Request("sendmany", params...)
+	if err != nil {
+		return "", err
+	}
+	txid, ok := result.(string)
+	if !ok {
+		return "", errors.New("sendmany reply is not a string")
+	}
+	return txid, nil
+}
+
+// GetNewAddress returns a new payment address of account.
+func (c *Client) GetNewAddress(account string) (string, error) {
+	result, err := c.Request("getnewaddress", account)
+	if err != nil {
+		return "", err
+	}
+	addr, ok := result.(string)
+	if !ok {
+		return "", errors.New("getnewaddress reply is not a string")
+	}
+	return addr, nil
+}
+
+// GetAddressesByAccount returns every payment address of account.
+func (c *Client) GetAddressesByAccount(account string) ([]string, error) {
+	result, err := c.Request("getaddressesbyaccount", account)
+	if err != nil {
+		return nil, err
+	}
+	vr, ok := result.([]interface{})
+	if !ok {
+		return nil, errors.New("getaddressesbyaccount reply is not " +
+			"an array")
+	}
+	addrs := make([]string, 0, len(vr))
+	for _, v := range vr {
+		if addr, ok := v.(string); ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
+
+// CreateEncryptedWallet creates a new wallet encrypted with passphrase.
+func (c *Client) CreateEncryptedWallet(passphrase string) error {
+	_, err := c.Request("createencryptedwallet", passphrase)
+	return err
+}
+
+// ListLockUnspent returns every unspent output which has been locked
+// against spending.
+func (c *Client) ListLockUnspent() ([]OutPoint, error) {
+	result, err := c.Request("listlockunspent")
+	if err != nil {
+		return nil, err
+	}
+	vr, ok := result.([]interface{})
+	if !ok {
+		return nil, errors.New("listlockunspent reply is not an array")
+	}
+	outpoints := make([]OutPoint, 0, len(vr))
+	for _, r := range vr {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("listlockunspent reply is not " +
+				"an array of JSON objects")
+		}
+		txid, _ := m["txid"].(string)
+		vout, _ := m["vout"].(float64)
+		outpoints = append(outpoints, OutPoint{txid, uint32(vout)})
+	}
+	return outpoints, nil
+}
+
+// GetTxOutValue returns the value of the unspent transaction output vout
+// of txid, including outputs of unconfirmed transactions.  Zero is
+// returned for spent outputs.
+func (c *Client) GetTxOutValue(txid string, vout uint32) (btcutil.Amount, error) {
+	result, err := c.Request("gettxout", txid, vout, true)
+	if err != nil {
+		return 0, err
+	}
+
+	// A nil result is returned for spent outputs.
+	m, ok := result.(map[string]interface{})
+	if !ok {
+		return 0, nil
+	}
+	fvalue, _ := m["value"].(float64)
+	return btcutil.NewAmount(fvalue)
+}
+
+// WalletIsLocked returns whether the wallet is locked.
+func (c *Client) WalletIsLocked() (bool, error) {
+	result, err := c.Request("walletislocked")
+	if err != nil {
+		return false, err
+	}
+	locked, ok := result.(bool)
+	if !ok {
+		return false, errors.New("walletislocked reply is not a boolean")
+	}
+	return locked, nil
+}
+
+// WalletLock locks the wallet.
+func (c *Client) WalletLock() error {
+	_, err := c.Request("walletlock")
+	return err
+}
+
+// WalletPassphrase unlocks the wallet with passphrase for timeout
+// seconds.
+func (c *Client) WalletPassphrase(passphrase string, timeout int64) error {
+	_, err := c.Request("walletpassphrase", passphrase, timeout)
+	return err
+}
+
+// SetTxFee sets the fee per kilobyte added to newly-created transactions.
+func (c *Client) SetTxFee(fee btcutil.Amount) error {
+	_, err := c.Request("settxfee", fee.ToUnit(btcutil.AmountBitcoin))
+	return err
+}
+
+// ValidateAddress returns the details of addr as the JSON object of a
+// validateaddress reply, including the redeem script of
+// pay-to-script-hash addresses known by the wallet.
+func (c *Client) ValidateAddress(addr string) (map[string]interface{}, error) {
+	return c.requestObject("validateaddress", addr)
+}
+
+// ListAddressGroupings returns the groups of wallet addresses whose
+// common ownership has been made public by spending from several of
+// them in a single transaction.
+func (c *Client) ListAddressGroupings() ([][]AddressGroupEntry, error) {
+	result, err := c.Request("listaddressgroupings")
+	if err != nil {
+		return nil, err
+	}
+	return parseAddressGroupings(result)
+}
+
+// parseAddressGroupings parses the result of a listaddressgroupings
+// request.
+func parseAddressGroupings(result interface{}) ([][]AddressGroupEntry, error) {
+	errBadReply := errors.New("listaddressgroupings reply is malformed")
+
+	groups, ok := result.([]interface{})
+	if !ok {
+		return nil, errBadReply
+	}
+	parsed := make([][]AddressGroupEntry, 0, len(groups))
+	for _, g := range groups {
+		entries, ok := g.([]interface{})
+		if !ok {
+			return nil, errBadReply
+		}
+		group := make([]AddressGroupEntry, 0, len(entries))
+		for _, e := range entries {
+			fields, ok := e.([]interface{})
+			if !ok || len(fields) < 2 {
+				return nil, errBadReply
+			}
+			var entry AddressGroupEntry
+			entry.Address, ok = fields[0].(string)
+			if !ok {
+				return nil, errBadReply
+			}
+			famount, ok := fields[1].(float64)
+			if !ok {
+				return nil, errBadReply
+			}
+			amount, err := btcutil.NewAmount(famount)
+			if err != nil {
+				return nil, errBadReply
+			}
+			entry.Amount = amount
+			if len(fields) > 2 {
+				entry.Account, _ = fields[2].(string)
+			}
+			group = append(group, entry)
+		}
+		parsed = append(parsed, group)
+	}
+	return parsed, nil
+}
+
+// ImportPrivKey imports the WIF-encoded private key into the wallet with
+// label, rescanning the blockchain for its transactions if rescan is set.
+func (c *Client) ImportPrivKey(key, label string, rescan bool) error {
+	_, err := c.Request("importprivkey", key, label, rescan)
+	return err
+}
+
+// ImportAddress imports addr into the wallet to be watched without its
+// private key, rescanning the blockchain for its transactions if rescan
+// is set.
+func (c *Client) ImportAddress(addr, label string, rescan bool) error {
+	_, err := c.Request("importaddress", addr, label, rescan)
+	return err
+}
+
+// GetTransaction returns the details of the wallet transaction txid as
+// the JSON object of a gettransaction reply.
+func (c *Client) GetTransaction(txid string) (map[string]interface{}, error) {
+	return c.requestObject("gettransaction", txid)
+}
+
+// ListReceivedByAddress returns the amount received by each address of
+// the wallet in transactions with at least minConf confirmations, as the
+// JSON objects of a listreceivedbyaddress reply.  Addresses which never
+// received any payment are only included if includeEmpty is set.
+func (c *Client) ListReceivedByAddress(minConf int, includeEmpty bool) ([]map[string]interface{}, error) {
+	result, err := c.Request("listreceivedbyaddress", minConf, includeEmpty)
+	if err != nil {
+		return nil, err
+	}
+	vr, ok := result.([]interface{})
+	if !ok {
+		return nil, errors.New("listreceivedbyaddress reply is not " +
+			"an array")
+	}
+	entries := make([]map[string]interface{}, 0, len(vr))
+	for _, v := range vr {
+		if m, ok := v.(map[string]interface{}); ok {
+			entries = append(entries, m)
+		}
+	}
+	return entries, nil
+}
+
+// GetRawMempool returns every transaction in the mempool of btcd, passed
+// through by btcwallet, as the JSON object of a verbose getrawmempool
+// reply keyed by transaction ID.
+func (c *Client) GetRawMempool() (map[string]interface{}, error) {
+	return c.requestObject("getrawmempool", true)
+}
+
+// GetBlock returns the block with hash from btcd, passed through by
+// btcwallet, as the JSON object of a getblock reply.
+func (c *Client) GetBlock(hash string) (map[string]interface{}, error) {
+	return c.requestObject("getblock", hash)
+}
+
+// SignRawTransaction signs each input of the hex-encoded raw transaction
+// tx which the wallet holds the key for, and returns the signed
+// transaction and whether every input is now signed.
+func (c *Client) SignRawTransaction(tx string) (string, bool, error) {
+	m, err := c.requestObject("signrawtransaction", tx)
+	if err != nil {
+		return "", false, err
+	}
+	signed, ok := m["hex"].(string)
+	if !ok {
+		return "", false, errors.New("signrawtransaction reply is " +
+			"missing the signed transaction")
+	}
+	complete, _ := m["complete"].(bool)
+	return signed, complete, nil
+}
+
+// SendRawTransaction sends the signed hex-encoded raw transaction tx to
+// the network and returns its ID.
+func (c *Client) SendRawTransaction(tx string) (string, error) {
+	result, err := c.Request("sendrawtransaction", tx)
+	if err != nil {
+		return "", err
+	}
+	txid, ok := result.(string)
+	if !ok {
+		return "", errors.New("sendrawtransaction reply is not a " +
+			"transaction ID")
+	}
+	return txid, nil
+}
+
+// ExportWalletSeed returns the seed of the opened wallet, which must be
+// unlocked.  btcwallet versions which can not export a seed reply that
+// the method was not found.
+func (c *Client) ExportWalletSeed() (string, error) {
+	result, err := c.Request("exportwalletseed")
+	if err != nil {
+		return "", err
+	}
+	seed, ok := result.(string)
+	if !ok || seed == "" {
+		return "", errors.New("exportwalletseed reply is not a seed")
+	}
+	return seed, nil
+}
+
+// RecoverWallet creates the wallet from seed, encrypted with passphrase.
+// btcwallet versions which can not recover from a seed reply that the
+// method was not found.
+func (c *Client) RecoverWallet(seed, passphrase string) error {
+	_, err := c.Request("recoverwallet", seed, passphrase)
+	return err
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package rpc
+
+import (
+	"encoding/json"
+	"github.com/conformal/btcutil"
+	"reflect"
+	"testing"
+)
+
+func TestParseAddressGroupings(t *testing.T) {
+	var result interface{}
+	reply := `[[["addr1",1.5,"acct"],["addr2",0]],[["addr3",0.25]]]`
+	if err := json.Unmarshal([]byte(reply), &result); err != nil {
+		t.Fatal(err)
+	}
+	groups, err := parseAddressGroupings(result)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := [][]AddressGroupEntry{
+		{
+			{Address: "addr1", Amount: 150000000, Account: "acct"},
+			{Address: "addr2", Amount: 0},
+		},
+		{
+			{Address: "addr3", Amount: btcutil.Amount(25000000)},
+		},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("got %v, want %v", groups, want)
+	}
+}
+
+func TestParseAddressGroupingsMalformed(t *testing.T) {
+	tests := []string{
+		`{}`,
+		`[{}]`,
+		`[[["addr1"]]]`,
+		`[[[1.5,"addr1"]]]`,
+		`[[["addr1","1.5"]]]`,
+	}
+	for _, reply := range tests {
+		var result interface{}
+		if err := json.Unmarshal([]byte(reply), &result); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseAddressGroupings(result); err == nil {
+			t.Errorf("%s: expected an error", reply)
+		}
+	}
+}
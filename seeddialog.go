@@ -0,0 +1,225 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"html"
+	"log"
+	"time"
+)
+
+// seedExportMethod is the btcwallet method replying with the seed of the
+// opened wallet.  btcwallet versions which can not export a seed reply
+// that the method was not found.
+const seedExportMethod = "exportwalletseed"
+
+// seedUnlockTimeout is how long the wallet is unlocked to export the seed,
+// unless it was already unlocked by btcgui.
+const seedUnlockTimeout = 60 * time.Second
+
+// seedConfirmPhrase must be typed before the seed is shown.
+const seedConfirmPhrase = "I understand"
+
+// seedWarningMarkup is shown before and with the wallet seed.
+const seedWarningMarkup = "<b>Anyone who learns your wallet seed can " +
+	"spend all of your funds.</b>\n\n" +
+	"Only show the seed when no one can see your screen and no screen " +
+	"recording or remote desktop software is running.  Write it down on " +
+	"paper and keep it somewhere safe and offline.  Never type it into a " +
+	"website, email or chat, and never store a photo of it."
+
+// createSeedDialog creates a dialog warning about the risks of showing
+// the wallet seed.  The seed is only requested once the wallet passphrase
+// is entered and seedConfirmPhrase is typed, and is then shown by a
+// separate dialog.
+func createSeedDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Show Wallet Seed")
+
+	dialog.AddButton("_Show Seed", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	dialog.SetResponseSensitive(gtk.RESPONSE_OK, false)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetHExpand(true)
+	grid.SetVExpand(true)
+	grid.SetColumnSpacing(6)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	warning, err := gtk.LabelNew("")
+	if err != nil {
+		return nil, err
+	}
+	warning.SetMarkup(seedWarningMarkup)
+	warning.SetLineWrap(true)
+	warning.SetWidthChars(50)
+	grid.Attach(warning, 0, 0, 2, 1)
+
+	l, err := gtk.LabelNew("Passphrase:")
+	if err != nil {
+		return nil, err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(l, 0, 1, 1, 1)
+
+	passphrase, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	passphrase.SetVisibility(false)
+	passphrase.SetHExpand(true)
+	grid.Attach(passphrase, 1, 1, 1, 1)
+
+	l, err = gtk.LabelNew(fmt.Sprintf("Type \"%s\" to continue:",
+		seedConfirmPhrase))
+	if err != nil {
+		return nil, err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(l, 0, 2, 1, 1)
+
+	confirm, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	confirm.SetHExpand(true)
+	grid.Attach(confirm, 1, 2, 1, 1)
+
+	// The seed may only be requested once both entries are filled in.
+	update := func() {
+		p, _ := passphrase.GetText()
+		c, _ := confirm.GetText()
+		dialog.SetResponseSensitive(gtk.RESPONSE_OK,
+			p != "" && c == seedConfirmPhrase)
+	}
+	passphrase.Connect("changed", update)
+	confirm.Connect("changed", update)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		if rt != gtk.RESPONSE_OK {
+			dialog.Destroy()
+			return
+		}
+
+		pStr, err := passphrase.GetText()
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		passphrase.SetText("")
+		dialog.SetResponseSensitive(gtk.RESPONSE_OK, false)
+
+		go func() {
+			seed, err := exportSeed(activeBackend(), pStr)
+			glib.IdleAdd(func() {
+				if err != nil {
+					d := errorDialog("Cannot show wallet seed",
+						walletErrorMessage(err))
+					d.Run()
+					d.Destroy()
+					return
+				}
+				dialog.Destroy()
+				if d, err := createShowSeedDialog(seed); err != nil {
+					log.Print(err)
+				} else {
+					d.Run()
+					err := metadata.SetBackupTime(time.Now())
+					if err != nil {
+						log.Printf("[ERR] cannot save backup "+
+							"time: %v", err)
+					}
+				}
+			})
+		}()
+	})
+
+	return dialog, nil
+}
+
+// createShowSeedDialog creates a dialog showing the wallet seed for it to
+// be written down.  The seed can not be selected, to discourage copying
+// it to the clipboard or a file.
+func createShowSeedDialog(seed string) (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Wallet Seed")
+
+	dialog.AddButton("_Close", gtk.RESPONSE_CLOSE)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetHExpand(true)
+	grid.SetVExpand(true)
+	grid.SetRowSpacing(12)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	warning, err := gtk.LabelNew("")
+	if err != nil {
+		return nil, err
+	}
+	warning.SetMarkup(seedWarningMarkup)
+	warning.SetLineWrap(true)
+	warning.SetWidthChars(50)
+	grid.Attach(warning, 0, 0, 1, 1)
+
+	l, err := gtk.LabelNew("")
+	if err != nil {
+		return nil, err
+	}
+	l.SetMarkup("<big><tt>" + html.EscapeString(seed) + "</tt></big>")
+	l.SetLineWrap(true)
+	l.SetSelectable(false)
+	grid.Attach(l, 0, 1, 1, 1)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func() {
+		l.SetText("")
+		dialog.Destroy()
+	})
+
+	return dialog, nil
+}
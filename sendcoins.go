@@ -21,9 +21,12 @@ import (
 	"fmt"
 	"github.com/conformal/btcjson"
 	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/gdk"
 	"github.com/conformal/gotk3/glib"
 	"github.com/conformal/gotk3/gtk"
 	"log"
+	"math"
+	"strings"
 )
 
 type recipient struct {
@@ -33,16 +36,81 @@ type recipient struct {
 	label  *gtk.Entry
 	amount *gtk.SpinButton
 	combo  *gtk.ComboBox
+
+	// details holds the widgets hidden while the recipient is
+	// collapsed.
+	details   []*gtk.Widget
+	collapse  *gtk.Button
+	summary   *gtk.Label
+	collapsed bool
+
+	// selfWarning is shown while the address paid belongs to the
+	// wallet.
+	selfWarning *gtk.Label
+
+	// fiat and conversion are only created when amounts may be
+	// entered in a fiat currency.  fiatEntered records whether the
+	// BTC amount follows the fiat amount as the exchange rate
+	// changes.
+	fiat        *gtk.SpinButton
+	conversion  *gtk.Label
+	fiatEntered bool
+	converting  bool
+}
+
+// recipientDragTarget is the drag and drop target name used when
+// reordering recipients.
+const recipientDragTarget = "btcgui-recipient"
+
+// SendParams holds the parameters needed to create and send a
+// transaction.
+type SendParams struct {
+	// account is the account sending the transaction, chosen when the
+	// transaction was entered.
+	account   string
+	pairs     map[string]float64
+	labels    map[string]string
+	comment   string
+	commentTo string
+
+	// queued is set for transactions queued while btcwallet could not
+	// be reached, which are not shown in the send coins tab anymore.
+	queued bool
+
+	// interrupted is set when the connection to btcwallet was lost
+	// while sending, so the transaction may have been sent already.
+	interrupted bool
 }
 
 var (
 	recipients = list.New()
 
+	// draggedRecipient is the recipient currently being dragged to a
+	// new position, or nil if no recipient is being dragged.
+	draggedRecipient *recipient
+
+	// spendable is the confirmed balance of the wallet sent from, if
+	// known.  It must only be accessed from the GTK main event loop.
+	spendable struct {
+		amount btcutil.Amount
+		known  bool
+	}
+
 	// SendCoins holds pointers to widgets in the send coins tab.
 	SendCoins = struct {
 		Balance   *gtk.Label
 		SendBtn   *gtk.Button
 		EntryGrid *gtk.Grid
+		Comment   *gtk.Entry
+		CommentTo *gtk.Entry
+
+		// FeeEstimate shows the estimated size and fee of the
+		// transaction being entered.
+		FeeEstimate *gtk.Label
+
+		// QueueNotice shows how many transactions are queued until
+		// btcwallet can be reached.
+		QueueNotice *gtk.Label
 	}{}
 )
 
@@ -59,6 +127,8 @@ func removeRecipentFn(grid *gtk.Grid) func(*gtk.Button, *recipient) {
 		if recipients.Len() == 0 {
 			insertSendEntries(grid)
 		}
+		updateFeeEstimate()
+		noteDraftChanged()
 	}
 }
 
@@ -72,24 +142,91 @@ func createRecipient(rmFn func(*gtk.Button, *recipient)) *recipient {
 	}
 	ret.Widget = grid.Container.Widget
 
-	l, err := gtk.LabelNew("Pay To:")
+	// Recipients are reordered by dragging the handle and dropping it
+	// onto another recipient.
+	handle, err := gtk.EventBoxNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	l, err := gtk.LabelNew("\u2261")
+	if err != nil {
+		log.Fatal(err)
+	}
+	handle.Add(l)
+	handle.SetTooltipText("Drag to reorder recipients")
+	target, err := gtk.TargetEntryNew(recipientDragTarget, gtk.TARGET_SAME_APP, 0)
 	if err != nil {
 		log.Fatal(err)
 	}
-	grid.Attach(l, 0, 0, 1, 1)
+	targets := []gtk.TargetEntry{*target}
+	handle.DragSourceSet(gdk.BUTTON1_MASK, targets, gdk.ACTION_MOVE)
+	handle.Connect("drag-begin", func() {
+		draggedRecipient = ret
+	})
+	handle.Connect("drag-end", func() {
+		draggedRecipient = nil
+	})
+	grid.DragDestSet(gtk.DEST_DEFAULT_ALL, targets, gdk.ACTION_MOVE)
+	grid.Connect("drag-data-received", func() {
+		moveRecipientBefore(draggedRecipient, ret)
+	})
+
+	collapse, err := gtk.ButtonNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	collapse.SetRelief(gtk.RELIEF_NONE)
+	collapse.Connect("clicked", func() {
+		ret.setCollapsed(!ret.collapsed)
+	})
+	ret.collapse = collapse
+
+	controls, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	controls.Add(handle)
+	controls.Add(collapse)
+	grid.Attach(controls, 0, 0, 1, 1)
+
+	l, err = gtk.LabelNew("Pay To:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	grid.Attach(l, 1, 0, 1, 1)
+	l, err = gtk.LabelNew("Label:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	grid.Attach(l, 1, 1, 1, 1)
+	ret.details = append(ret.details, &l.Widget)
 	l, err = gtk.LabelNew("Amount:")
 	if err != nil {
 		log.Fatal(err)
 	}
-	grid.Attach(l, 0, 1, 1, 1)
+	grid.Attach(l, 1, 2, 1, 1)
+	ret.details = append(ret.details, &l.Widget)
 
 	payTo, err := gtk.EntryNew()
 	if err != nil {
 		log.Fatal(err)
 	}
 	payTo.SetHExpand(true)
+	payTo.Connect("changed", noteDraftChanged)
+	payTo.Connect("changed", func() {
+		ret.checkSelfTransfer()
+	})
 	ret.payTo = payTo
-	grid.Attach(payTo, 1, 0, 1, 1)
+	grid.Attach(payTo, 2, 0, 1, 1)
+
+	// The summary shows the amount while the recipient is collapsed.
+	summary, err := gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	summary.SetNoShowAll(true)
+	ret.summary = summary
+	grid.Attach(summary, 3, 0, 1, 1)
 
 	remove, err := gtk.ButtonNew()
 	if err != nil {
@@ -102,24 +239,18 @@ func createRecipient(rmFn func(*gtk.Button, *recipient)) *recipient {
 	remove.SetImage(img)
 	remove.SetTooltipText("Remove this recipient")
 	remove.Connect("clicked", rmFn, ret)
-	grid.Attach(remove, 2, 0, 1, 1)
+	grid.Attach(remove, 4, 0, 1, 1)
 
-	// TODO(jrick): Label doesn't do anything currently, so don't add
-	// to gui.
-	/*
-		l, err = gtk.LabelNew("Label:")
-		if err != nil {
-			log.Fatal(err)
-		}
-		grid.Attach(l, 0, 1, 1, 1)
-		label, err := gtk.EntryNew()
-		if err != nil {
-			log.Fatal(err)
-		}
-		label.SetHExpand(true)
-		ret.label = label
-		grid.Attach(label, 1, 1, 2, 1)
-	*/
+	label, err := gtk.EntryNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	label.SetHExpand(true)
+	label.SetTooltipText("Optional label saved locally for this payment")
+	label.Connect("changed", noteDraftChanged)
+	ret.label = label
+	grid.Attach(label, 2, 1, 3, 1)
+	ret.details = append(ret.details, &label.Widget)
 
 	amounts, err := gtk.GridNew()
 	if err != nil {
@@ -130,6 +261,7 @@ func createRecipient(rmFn func(*gtk.Button, *recipient)) *recipient {
 		log.Fatal(err)
 	}
 	amount.SetHAlign(gtk.ALIGN_START)
+	amount.Connect("value-changed", noteDraftChanged)
 	ret.amount = amount
 	amounts.Add(amount)
 
@@ -189,11 +321,115 @@ func createRecipient(rmFn func(*gtk.Button, *recipient)) *recipient {
 	}
 	amounts.Add(l)
 
-	grid.Attach(amounts, 1, 1, 1, 1)
+	if fiatEnabled() {
+		ret.addFiatEntry(amounts)
+	}
+
+	grid.Attach(amounts, 2, 2, 1, 1)
+	ret.details = append(ret.details, &amounts.Container.Widget)
+
+	selfWarning, err := gtk.LabelNew("This is a self-transfer; you will " +
+		"pay a fee to move funds to yourself.")
+	if err != nil {
+		log.Fatal(err)
+	}
+	selfWarning.SetHAlign(gtk.ALIGN_START)
+	selfWarning.SetNoShowAll(true)
+	ret.selfWarning = selfWarning
+	grid.Attach(selfWarning, 2, 3, 3, 1)
+
+	ret.setCollapsed(false)
 
 	return ret
 }
 
+// setCollapsed hides or shows the label and amount of the recipient.
+// While collapsed, the amount is summarized next to the address.
+//
+// This must be run from the GTK main event loop.
+func (r *recipient) setCollapsed(collapsed bool) {
+	r.collapsed = collapsed
+	icon, tooltip := "pan-down-symbolic", "Collapse this recipient"
+	if collapsed {
+		icon, tooltip = "pan-end-symbolic", "Expand this recipient"
+	}
+	img, err := gtk.ImageNewFromIconName(icon, gtk.ICON_SIZE_MENU)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r.collapse.SetImage(img)
+	r.collapse.SetTooltipText(tooltip)
+
+	for _, w := range r.details {
+		w.SetVisible(!collapsed)
+	}
+	if collapsed {
+		amt, _ := btcutil.NewAmount(r.amount.GetValue())
+		r.summary.SetText(formatAmount(amt))
+		r.summary.Show()
+	} else {
+		r.summary.Hide()
+	}
+}
+
+// checkSelfTransfer shows a warning below the recipient while the address
+// it pays belongs to the wallet.
+//
+// This must be run from the GTK main event loop.
+func (r *recipient) checkSelfTransfer() {
+	addr, err := r.payTo.GetText()
+	if err == nil && isOwnAddr(strings.TrimSpace(addr)) {
+		r.selfWarning.Show()
+	} else {
+		r.selfWarning.Hide()
+	}
+}
+
+// checkSelfTransfers updates the self-transfer warning of every
+// recipient, as wallet addresses are learned after recipients may have
+// been entered.
+//
+// This must be run from the GTK main event loop.
+func checkSelfTransfers() {
+	for e := recipients.Front(); e != nil; e = e.Next() {
+		e.Value.(*recipient).checkSelfTransfer()
+	}
+}
+
+// moveRecipientBefore moves recipient r before the recipient dest in
+// both the recipients list and the send coins tab.
+//
+// This must be run from the GTK main event loop.
+func moveRecipientBefore(r, dest *recipient) {
+	if r == nil || r == dest {
+		return
+	}
+	var mark, elem *list.Element
+	for e := recipients.Front(); e != nil; e = e.Next() {
+		switch e.Value {
+		case r:
+			elem = e
+		case dest:
+			mark = e
+		}
+	}
+	if mark == nil || elem == nil {
+		return
+	}
+	recipients.MoveBefore(elem, mark)
+
+	// Grids place added children after all others, so every
+	// recipient is removed and added back in the new order.
+	grid := SendCoins.EntryGrid
+	for e := recipients.Front(); e != nil; e = e.Next() {
+		grid.Remove(e.Value.(*recipient))
+	}
+	for e := recipients.Front(); e != nil; e = e.Next() {
+		grid.Add(e.Value.(*recipient))
+	}
+	noteDraftChanged()
+}
+
 func insertSendEntries(grid *gtk.Grid) {
 	rmFn := removeRecipentFn(grid)
 	r := createRecipient(rmFn)
@@ -203,8 +439,11 @@ func insertSendEntries(grid *gtk.Grid) {
 
 	recipients.PushBack(r)
 
+	// Only show the new recipient, as showing the whole grid would
+	// show the hidden details of collapsed recipients.
 	grid.Add(r)
-	grid.ShowAll()
+	r.ShowAll()
+	updateFeeEstimate()
 }
 
 func createSendCoins() *gtk.Widget {
@@ -232,6 +471,40 @@ func createSendCoins() *gtk.Widget {
 	sw.Add(entriesGrid)
 	insertSendEntries(entriesGrid)
 
+	comments, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	l, err := gtk.LabelNew("Comment:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	comments.Attach(l, 0, 0, 1, 1)
+	comment, err := gtk.EntryNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	comment.SetHExpand(true)
+	comment.SetTooltipText("Optional note recorded by the wallet with the transaction")
+	comment.Connect("changed", noteDraftChanged)
+	comments.Attach(comment, 1, 0, 1, 1)
+	SendCoins.Comment = comment
+	l, err = gtk.LabelNew("Comment to:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	comments.Attach(l, 0, 1, 1, 1)
+	commentTo, err := gtk.EntryNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	commentTo.SetHExpand(true)
+	commentTo.SetTooltipText("Optional name of the person or organization being paid")
+	commentTo.Connect("changed", noteDraftChanged)
+	comments.Attach(commentTo, 1, 1, 1, 1)
+	SendCoins.CommentTo = commentTo
+	grid.Add(comments)
+
 	bot, err := gtk.GridNew()
 	if err != nil {
 		log.Fatal(err)
@@ -247,13 +520,53 @@ func createSendCoins() *gtk.Widget {
 	})
 	bot.Add(btn)
 
-	l, err := gtk.LabelNew("Balance: ")
+	btn, err = gtk.ButtonNewWithLabel("Templates")
+	if err != nil {
+		log.Fatal(err)
+	}
+	btn.SetTooltipText("Load, save, or delete named sets of recipients")
+	btn.Connect("clicked", showTemplatesMenu)
+	bot.Add(btn)
+
+	btn, err = gtk.ButtonNewWithLabel("Scan QR")
+	if err != nil {
+		log.Fatal(err)
+	}
+	btn.SetTooltipText("Fill in a recipient from a payment QR code in " +
+		"an image or on the screen")
+	btn.Connect("clicked", showQRScanMenu)
+	bot.Add(btn)
+
+	if chooser := createWalletChooser(); chooser != nil {
+		bot.Add(chooser)
+	}
+
+	l, err = gtk.LabelNew("Balance: ")
 	if err != nil {
 		log.Fatal(err)
 	}
 	bot.Add(l)
 	SendCoins.Balance = l
 
+	l, err = gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	l.SetTooltipText("btcwallet adds the transaction fee once for " +
+		"every started kilobyte.  The estimate assumes a single input " +
+		"and a change output; spending many small inputs makes the " +
+		"transaction larger.")
+	bot.Add(l)
+	SendCoins.FeeEstimate = l
+
+	l, err = gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	l.SetNoShowAll(true)
+	bot.Add(l)
+	SendCoins.QueueNotice = l
+
 	submitBtn, err := gtk.ButtonNewWithLabel("Send")
 	if err != nil {
 		log.Fatal(err)
@@ -263,7 +576,20 @@ func createSendCoins() *gtk.Widget {
 	submitBtn.SetHExpand(true)
 	submitBtn.SetSensitive(false)
 	submitBtn.Connect("clicked", func() {
+		if dups := duplicateRecipients(); len(dups) != 0 {
+			if !confirmMergeRecipients(dups) {
+				return
+			}
+			mergeRecipients()
+		}
+		if err := lockFiatAmounts(); err != nil {
+			d := errorDialog("Cannot convert amount", err.Error())
+			d.Run()
+			d.Destroy()
+			return
+		}
 		sendTo := make(map[string]float64)
+		labels := make(map[string]string)
 		for e := recipients.Front(); e != nil; e = e.Next() {
 			r := e.Value.(*recipient)
 
@@ -307,9 +633,31 @@ func createSendCoins() *gtk.Widget {
 			*/
 
 			sendTo[addrStr] = amt
+			labels[addrStr], _ = r.label.GetText()
+		}
+
+		if !checkFeeHeadroom() {
+			return
+		}
+		if !verifyLargeSends(sendTo) {
+			return
 		}
 
-		go txSenderAndReplyListener(sendTo)
+		params := &SendParams{
+			account: currentAccount(),
+			pairs:   sendTo,
+			labels:  labels,
+		}
+		params.comment, _ = SendCoins.Comment.GetText()
+		params.commentTo, _ = SendCoins.CommentTo.GetText()
+
+		if !sendQueue.reachable {
+			if queueSend(params) {
+				resetRecipients()
+			}
+			return
+		}
+		go txSenderAndReplyListener(params)
 	})
 	SendCoins.SendBtn = submitBtn
 	bot.Add(submitBtn)
@@ -319,66 +667,200 @@ func createSendCoins() *gtk.Widget {
 	return &grid.Container.Widget
 }
 
-// txSenderAndReplyListener triggers btcgui to send btcwallet a JSON
-// request to create and send a transaction.  If sending the transaction
+// Responses of the dialog warning that no balance is left for the fee.
+const (
+	drainSubtractFee gtk.ResponseType = iota + 1
+	drainSendAnyway
+)
+
+// checkFeeHeadroom warns when the recipients are paid the whole
+// spendable balance, or so much of it that the estimated fee can not be
+// paid as well, which makes btcwallet refuse the transaction.  The fee
+// may then be subtracted from the amount of the last recipient, which is
+// left to be reviewed before sending again.  It returns whether the
+// transaction should be sent as entered.
+//
+// This must be run from the GTK main event loop.
+func checkFeeHeadroom() bool {
+	feePerKB, ok := currentTxFeeRate()
+	if !ok || !spendable.known {
+		return true
+	}
+	var total btcutil.Amount
+	var last *recipient
+	for e := recipients.Front(); e != nil; e = e.Next() {
+		r := e.Value.(*recipient)
+		amt, err := btcutil.NewAmount(r.amount.GetValue())
+		if err != nil {
+			return true
+		}
+		total += amt
+		if amt > 0 {
+			last = r
+		}
+	}
+
+	// Draining the wallet leaves no change output.
+	fee := estimateTxFee(feePerKB, estimateTxSize(1, recipients.Len()))
+	excess := total + fee - spendable.amount
+	if last == nil || total > spendable.amount || excess <= 0 {
+		return true
+	}
+
+	lastAmt, _ := btcutil.NewAmount(last.amount.GetValue())
+	canSubtract := lastAmt > excess
+	msg := fmt.Sprintf("The recipients are paid %s of the spendable "+
+		"balance of %s, leaving too little for the estimated "+
+		"transaction fee of %s.  The transaction will be refused "+
+		"unless the amount is reduced.", formatAmount(total),
+		formatAmount(spendable.amount), formatAmount(fee))
+	if canSubtract {
+		msg += fmt.Sprintf("\n\nSubtracting the fee reduces the amount "+
+			"of the last recipient by %s.", formatAmount(excess))
+	}
+	d := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL,
+		gtk.MESSAGE_WARNING, gtk.BUTTONS_NONE, msg)
+	d.SetTitle("No balance left for the fee")
+	d.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	d.AddButton("Send _Anyway", drainSendAnyway)
+	if canSubtract {
+		d.AddButton("_Subtract Fee From Amount", drainSubtractFee)
+		d.SetDefaultResponse(drainSubtractFee)
+	}
+	rt := gtk.ResponseType(d.Run())
+	d.Destroy()
+
+	switch rt {
+	case drainSubtractFee:
+		last.amount.SetValue((lastAmt - excess).ToUnit(btcutil.AmountBitcoin))
+		if last.collapsed {
+			last.setCollapsed(false)
+		}
+		setStatus("Subtracted the fee from the amount of the last " +
+			"recipient.  Review the amount and send again.")
+		return false
+	case drainSendAnyway:
+		return true
+	}
+	return false
+}
+
+// duplicateRecipients returns each address entered for more than one
+// recipient, in the order the addresses first appear.
+//
+// This must be run from the GTK main event loop.
+func duplicateRecipients() []string {
+	var dups []string
+	seen := make(map[string]int)
+	for e := recipients.Front(); e != nil; e = e.Next() {
+		addr, err := e.Value.(*recipient).payTo.GetText()
+		if err != nil || addr == "" {
+			continue
+		}
+		seen[addr]++
+		if seen[addr] == 2 {
+			dups = append(dups, addr)
+		}
+	}
+	return dups
+}
+
+// confirmMergeRecipients warns the user that multiple recipients pay the
+// same addresses and asks whether their amounts should be merged.  It
+// returns whether the user agreed to merge the recipients.
+//
+// This must be run from the GTK main event loop.
+func confirmMergeRecipients(dups []string) bool {
+	msg := "The following addresses were entered for more than one " +
+		"recipient:\n\n" + strings.Join(dups, "\n") + "\n\n" +
+		"Only a single payment can be made to each address.  Merge " +
+		"the amounts for each address and send?"
+	d := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL,
+		gtk.MESSAGE_QUESTION, gtk.BUTTONS_YES_NO, msg)
+	d.SetTitle("Duplicate recipients")
+	defer d.Destroy()
+	return gtk.ResponseType(d.Run()) == gtk.RESPONSE_YES
+}
+
+// mergeRecipients combines all recipients paying the same address into
+// the first recipient for that address by summing their amounts.  The
+// first non-empty label for the address is kept.  Merged recipients
+// are removed from the send coins tab.
+//
+// This must be run from the GTK main event loop.
+func mergeRecipients() {
+	first := make(map[string]*recipient)
+	var next *list.Element
+	for e := recipients.Front(); e != nil; e = next {
+		next = e.Next()
+		r := e.Value.(*recipient)
+		addr, err := r.payTo.GetText()
+		if err != nil || addr == "" {
+			continue
+		}
+		f, ok := first[addr]
+		if !ok {
+			first[addr] = r
+			continue
+		}
+
+		// Round the sum to the nearest satoshi to avoid floating
+		// point error.
+		sum := f.amount.GetValue() + r.amount.GetValue()
+		f.amount.SetValue(math.Floor(sum*1e8+0.5) / 1e8)
+		if label, _ := f.label.GetText(); label == "" {
+			label, _ = r.label.GetText()
+			f.label.SetText(label)
+		}
+
+		recipients.Remove(e)
+		r.Widget.Destroy()
+	}
+}
+
+// txSenderAndReplyListener requests btcwallet to create and send a
+// transaction.  If sending the transaction
 // succeeds, the recipients in the send coins notebook tab are cleared.
-// If the transaction fails because the wallet is not unlocked, the
-// unlock dialog is shown, and after a successful unlock, creating and
-// sending the tx is tried a second time.
+// If the wallet is locked, the request is sent again after it is
+// unlocked, as for all requests.  If the connection to btcwallet
+// is lost while sending, a retry after reconnecting is offered.
 //
 // This is written to be run as a goroutine executing outside of the GTK
 // main event loop.
-func txSenderAndReplyListener(sendTo map[string]float64) {
-	triggers.sendTx <- sendTo
-
-	err := <-triggerReplies.sendTx
-	// -13 is the error code for needing an unlocked wallet.
+func txSenderAndReplyListener(params *SendParams) {
+	err := sendTx(params)
 	if jsonErr, ok := err.(*btcjson.Error); ok {
-		switch jsonErr.Code {
-		case -13:
-			// Wallet must be unlocked first.  Show unlock dialog.
-			glib.IdleAdd(func() {
-				unlockSuccessful := make(chan bool)
-				go func() {
-					for {
-						success, ok := <-unlockSuccessful
-						if !ok {
-							// A closed channel indicates
-							// the dialog was cancelled.
-							// Abort sending the transaction.
-							return
-						}
-						if success {
-							// Try send again.
-							go txSenderAndReplyListener(sendTo)
-							return
-						}
-					}
-				}()
-				d, err := createUnlockDialog(unlockForTxSend, unlockSuccessful)
-				if err != nil {
-					// TODO(jrick): log error to file
-					log.Printf("[ERR] could not create unlock dialog: %v\n", err)
-					return
-				}
-				d.Run()
-				d.Destroy()
-			})
-
-		default:
-			// Generic case to display an error.
-			glib.IdleAdd(func() {
-				d := errorDialog("Unable to send transaction",
-					fmt.Sprintf("%s\nError code: %d", jsonErr.Message, jsonErr.Code))
-				d.Run()
-				d.Destroy()
-			})
+		// The unlock dialog was already shown when the wallet was
+		// locked, so the error is only seen if it was cancelled.
+		if jsonErr.Code == btcjson.ErrWalletUnlockNeeded.Code {
+			return
 		}
+		glib.IdleAdd(func() {
+			d := errorDialog("Unable to send transaction",
+				walletErrorMessage(jsonErr))
+			d.Run()
+			d.Destroy()
+		})
+		return
+	} else if isTransientSendError(err) {
+		glib.IdleAdd(func() {
+			offerSendRetry(params, err)
+		})
+		return
+	} else if err != nil {
+		glib.IdleAdd(func() {
+			d := errorDialog("Unable to send transaction", err.Error())
+			d.Run()
+			d.Destroy()
+		})
 		return
 	}
 
-	// Send was successful, so clear recipient widgets.
-	glib.IdleAdd(resetRecipients)
+	// Send was successful, so clear recipient widgets, unless the
+	// transaction was queued and they have been used since.
+	if !params.queued {
+		glib.IdleAdd(resetRecipients)
+	}
 }
 
 // resetRecipients resets the recipients list and widgets in the send
@@ -392,6 +874,39 @@ func resetRecipients() {
 	}
 	recipients.Init()
 	insertSendEntries(SendCoins.EntryGrid)
+	SendCoins.Comment.SetText("")
+	SendCoins.CommentTo.SetText("")
+	noteDraftChanged()
+}
+
+// showBitcoinURI switches to the send coins tab and fills in a single
+// recipient with the payment details of a bitcoin: URI.
+//
+// This must be run from the GTK main event loop.
+func showBitcoinURI(s string) {
+	uri, err := parseBitcoinURI(s)
+	if err != nil {
+		d := errorDialog("Invalid payment URI", err.Error())
+		d.Run()
+		d.Destroy()
+		return
+	}
+	if cfg.WatchOnly {
+		d := errorDialog("Cannot send payment",
+			"Payments can not be sent in watch-only mode.")
+		d.Run()
+		d.Destroy()
+		return
+	}
+
+	resetRecipients()
+	r := recipients.Front().Value.(*recipient)
+	r.payTo.SetText(uri.address)
+	r.amount.SetValue(uri.amount)
+	r.label.SetText(uri.label)
+	SendCoins.Comment.SetText(uri.message)
+	mainNotebook.SetCurrentPage(sendCoinsPage)
+	showMainWindow()
 }
 
 func errorDialog(title, msg string) *gtk.MessageDialog {
@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/glib"
+	"log"
+	"time"
+)
+
+// draftSaveDelay is how long after the last change to the send coins tab
+// the draft is saved, so typing saves it only once.
+const draftSaveDelay = 2 * time.Second
+
+// sendDraft is the contents of the send coins tab, saved while a payment
+// is being composed so it is not lost by quitting or a crash.
+type sendDraft struct {
+	Recipients []draftRecipient `json:"recipients"`
+	Comment    string           `json:"comment,omitempty"`
+	CommentTo  string           `json:"commentto,omitempty"`
+}
+
+// draftRecipient is a recipient of a send draft.
+type draftRecipient struct {
+	Address string  `json:"address"`
+	Label   string  `json:"label,omitempty"`
+	Amount  float64 `json:"amount"`
+}
+
+// lastDraft is the send draft as of the last change to the send coins
+// tab, draftSaveScheduled records whether saving it is already scheduled,
+// and draftRestoring is set while a saved draft is filled in.  They must
+// only be accessed from the GTK main event loop.
+var (
+	lastDraft          *sendDraft
+	draftSaveScheduled bool
+	draftRestoring     bool
+)
+
+// noteDraftChanged records the send draft after the send coins tab was
+// changed, and schedules saving it.
+//
+// This must be run from the GTK main event loop.
+func noteDraftChanged() {
+	if draftRestoring || SendCoins.CommentTo == nil {
+		return
+	}
+	lastDraft = currentSendDraft()
+	if draftSaveScheduled {
+		return
+	}
+	draftSaveScheduled = true
+	time.AfterFunc(draftSaveDelay, func() {
+		glib.IdleAdd(saveSendDraft)
+	})
+}
+
+// currentSendDraft returns the contents of the send coins tab, or nil if
+// nothing has been entered.
+//
+// This must be run from the GTK main event loop.
+func currentSendDraft() *sendDraft {
+	d := new(sendDraft)
+	empty := true
+	for e := recipients.Front(); e != nil; e = e.Next() {
+		r := e.Value.(*recipient)
+		addr, _ := r.payTo.GetText()
+		label, _ := r.label.GetText()
+		amount := r.amount.GetValue()
+		if addr != "" || label != "" || amount != 0 {
+			empty = false
+		}
+		d.Recipients = append(d.Recipients, draftRecipient{
+			Address: addr,
+			Label:   label,
+			Amount:  amount,
+		})
+	}
+	d.Comment, _ = SendCoins.Comment.GetText()
+	d.CommentTo, _ = SendCoins.CommentTo.GetText()
+	if empty && d.Comment == "" && d.CommentTo == "" {
+		return nil
+	}
+	return d
+}
+
+// saveSendDraft saves the last recorded send draft to the metadata store,
+// or removes the saved draft once the send coins tab has been cleared.
+// Nothing is saved unless the send coins tab changed since the last
+// save, so the draft of the previous launch is kept until then.
+//
+// This must be run from the GTK main event loop, or after it has quit.
+func saveSendDraft() {
+	if !draftSaveScheduled {
+		return
+	}
+	draftSaveScheduled = false
+	if err := metadata.SetSendDraft(lastDraft); err != nil {
+		log.Printf("[ERR] cannot save send draft: %v", err)
+	}
+}
+
+// restoreSendDraft fills in the send coins tab with the draft saved by
+// the last launch, if any.
+//
+// This must be run from the GTK main event loop.
+func restoreSendDraft() {
+	d := metadata.SendDraft()
+	if d == nil || len(d.Recipients) == 0 || cfg.WatchOnly {
+		return
+	}
+	draftRestoring = true
+	defer func() {
+		draftRestoring = false
+	}()
+
+	resetRecipients()
+	fillRecipients(d.Recipients)
+	SendCoins.Comment.SetText(d.Comment)
+	SendCoins.CommentTo.SetText(d.CommentTo)
+	setStatus("Restored the unsent payment of the last session")
+}
+
+// fillRecipients replaces the recipients of the send coins tab with rs.
+// The comments are kept.
+//
+// This must be run from the GTK main event loop.
+func fillRecipients(rs []draftRecipient) {
+	for e := recipients.Front(); e != nil; e = e.Next() {
+		e.Value.(*recipient).Widget.Destroy()
+	}
+	recipients.Init()
+	insertSendEntries(SendCoins.EntryGrid)
+	for i, dr := range rs {
+		if i != 0 {
+			insertSendEntries(SendCoins.EntryGrid)
+		}
+		r := recipients.Back().Value.(*recipient)
+		r.payTo.SetText(dr.Address)
+		r.label.SetText(dr.Label)
+		r.amount.SetValue(dr.Amount)
+	}
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/gtk"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// sendQueue holds the transactions composed while btcwallet could not
+// be reached, in the order they were queued.  It is only accessed from
+// the GTK main event loop.
+var sendQueue struct {
+	// reachable records whether btcgui is connected to btcwallet.
+	reachable bool
+	queued    []*SendParams
+}
+
+// setWalletReachable records whether btcwallet can be reached.  While
+// it can not, the send button stays usable so transactions may be
+// queued.
+//
+// This must be run from the GTK main event loop.
+func setWalletReachable(reachable bool) {
+	sendQueue.reachable = reachable
+	if reachable {
+		SendCoins.SendBtn.SetTooltipText("")
+		return
+	}
+	SendCoins.SendBtn.SetSensitive(!cfg.WatchOnly)
+	SendCoins.SendBtn.SetTooltipText("btcwallet can not be reached.  " +
+		"The transaction may be queued until the connection is " +
+		"reestablished.")
+}
+
+// sendSummary describes the recipients and comments of a transaction.
+func sendSummary(params *SendParams) string {
+	addrs := make([]string, 0, len(params.pairs))
+	for addr := range params.pairs {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	lines := make([]string, 0, len(addrs)+1)
+	for _, addr := range addrs {
+		amt, _ := btcutil.NewAmount(params.pairs[addr])
+		line := fmt.Sprintf("%v to %v", amt, addr)
+		if label := params.labels[addr]; label != "" {
+			line += fmt.Sprintf(" (%v)", label)
+		}
+		lines = append(lines, line)
+	}
+	if params.comment != "" {
+		lines = append(lines, "Comment: "+params.comment)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// queueSend offers to queue the transaction described by params until
+// btcwallet can be reached again, returning whether it was queued.
+//
+// This must be run from the GTK main event loop.
+func queueSend(params *SendParams) bool {
+	msg := "btcwallet can not be reached, so the transaction can not " +
+		"be sent now.\n\n" + sendSummary(params) + "\n\n" +
+		"Queue the transaction?  It will be shown for confirmation " +
+		"once the connection is reestablished."
+	d := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL,
+		gtk.MESSAGE_QUESTION, gtk.BUTTONS_YES_NO, msg)
+	d.SetTitle("Queue transaction")
+	defer d.Destroy()
+	if gtk.ResponseType(d.Run()) != gtk.RESPONSE_YES {
+		return false
+	}
+
+	params.queued = true
+	sendQueue.queued = append(sendQueue.queued, params)
+	updateQueueNotice()
+	logEvent(eventQueued, "Queued transaction while disconnected: %v",
+		strings.Replace(sendSummary(params), "\n", "; ", -1))
+	return true
+}
+
+// isTransientSendError returns whether sending a transaction failed only
+// because the connection to btcwallet was lost, or btcwallet did not
+// reply in time, so it may succeed once retried.  As btcwallet may have
+// sent the transaction either way, a retry is only sent after warning of
+// a double send.  Errors returned by btcwallet itself are permanent.
+func isTransientSendError(err error) bool {
+	if err == ErrConnectionLost || err == ErrRequestTimeout {
+		return true
+	}
+	switch err.(type) {
+	case *btcjson.Error:
+		return false
+	case net.Error:
+		return true
+	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+// offerSendRetry offers to send a transaction again after sending it
+// failed with the transient error err.  Accepted retries are queued and
+// shown for confirmation once btcwallet can be reached again, as
+// btcwallet may have sent the transaction before the connection was
+// lost or the request timed out.
+//
+// This must be run from the GTK main event loop.
+func offerSendRetry(params *SendParams, err error) {
+	what := "The connection to btcwallet was lost while sending the " +
+		"transaction"
+	if err == ErrRequestTimeout {
+		what = "btcwallet did not reply in time while sending the " +
+			"transaction, so it may have been sent"
+	}
+	msg := fmt.Sprintf("%s (%v).\n\n%s\n\nRetry sending the "+
+		"transaction once btcwallet can be reached?", what, err,
+		sendSummary(params))
+	d := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL,
+		gtk.MESSAGE_WARNING, gtk.BUTTONS_YES_NO, msg)
+	d.SetTitle("Unable to send transaction")
+	resp := gtk.ResponseType(d.Run())
+	d.Destroy()
+	if resp != gtk.RESPONSE_YES {
+		return
+	}
+
+	if !params.queued {
+		resetRecipients()
+	}
+	params.queued = true
+	params.interrupted = true
+	sendQueue.queued = append(sendQueue.queued, params)
+	updateQueueNotice()
+
+	// The connection may have been reestablished while the dialog was
+	// shown.
+	if sendQueue.reachable && SendCoins.SendBtn.GetSensitive() {
+		showQueuedSends()
+	}
+}
+
+// updateQueueNotice shows how many transactions are queued in the send
+// coins tab.
+//
+// This must be run from the GTK main event loop.
+func updateQueueNotice() {
+	atomic.StoreInt64(&queuedSendCount, int64(len(sendQueue.queued)))
+	switch n := len(sendQueue.queued); n {
+	case 0:
+		SendCoins.QueueNotice.Hide()
+		return
+	case 1:
+		SendCoins.QueueNotice.SetText("1 transaction queued")
+	default:
+		SendCoins.QueueNotice.SetText(fmt.Sprintf("%d transactions "+
+			"queued", n))
+	}
+	SendCoins.QueueNotice.Show()
+}
+
+// showQueuedSends asks for confirmation of each queued transaction,
+// now that it can be sent, sending the confirmed ones in the order they
+// were queued.
+//
+// This must be run from the GTK main event loop.
+func showQueuedSends() {
+	if len(sendQueue.queued) == 0 {
+		return
+	}
+	queued := sendQueue.queued
+	sendQueue.queued = nil
+	updateQueueNotice()
+
+	var confirmed []*SendParams
+	for _, params := range queued {
+		msg := "The connection to btcwallet is reestablished.  This " +
+			"transaction was queued while disconnected:\n\n" +
+			sendSummary(params)
+		if params.interrupted {
+			msg = "The connection to btcwallet is reestablished.  " +
+				"Sending this transaction was interrupted when the " +
+				"connection was lost:\n\n" + sendSummary(params) +
+				"\n\nbtcwallet may have sent it before the " +
+				"connection was lost.  Check the transaction " +
+				"list before sending it again."
+		}
+		d := gtk.MessageDialogNew(mainWindow, gtk.DIALOG_MODAL,
+			gtk.MESSAGE_QUESTION, gtk.BUTTONS_NONE, msg)
+		d.SetTitle("Send queued transaction")
+		d.AddButton("Discard", gtk.RESPONSE_REJECT)
+		d.AddButton("Send", gtk.RESPONSE_ACCEPT)
+		d.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+		resp := gtk.ResponseType(d.Run())
+		d.Destroy()
+		if resp == gtk.RESPONSE_ACCEPT {
+			confirmed = append(confirmed, params)
+		} else {
+			log.Print("[INF] Discarded queued transaction")
+		}
+	}
+
+	// Replies to sends are not matched to requests, so only one
+	// transaction is sent at a time.
+	go func() {
+		for _, params := range confirmed {
+			txSenderAndReplyListener(params)
+		}
+	}()
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// sessionUnlockTimeout is the timeout in seconds passed to btcwallet
+// for a session unlock.  btcwallet requires a timeout, so a very long one
+// is used and btcgui locks the wallet itself when the session ends.
+const sessionUnlockTimeout = 7 * 24 * 60 * 60
+
+// lastActivity records when the user last pressed a key or mouse button
+// in the main window.
+var lastActivity = struct {
+	sync.Mutex
+	t time.Time
+}{t: time.Now()}
+
+// noteUserActivity records user activity, delaying the end of a session
+// unlock due to inactivity.
+func noteUserActivity() {
+	lastActivity.Lock()
+	lastActivity.t = time.Now()
+	lastActivity.Unlock()
+}
+
+// idleDuration returns the time since the user was last active.
+func idleDuration() time.Duration {
+	lastActivity.Lock()
+	defer lastActivity.Unlock()
+	return time.Since(lastActivity.t)
+}
+
+// sessionIdleLocker periodically checks whether the user has been idle
+// for longer than allowed during a session unlock, and if so, locks the
+// wallet.  This must be run as a goroutine, and only returns if locking
+// idle sessions is disabled.
+func sessionIdleLocker() {
+	if cfg.SessionIdle <= 0 {
+		return
+	}
+	limit := time.Duration(cfg.SessionIdle) * time.Minute
+	for _ = range time.Tick(30 * time.Second) {
+		if sessionUnlockActive() && idleDuration() > limit {
+			// The session continues until the wallet is
+			// locked, so locking is tried again on the next
+			// check if it fails.
+			if err := lockWallet(); err != nil {
+				log.Printf("[ERR] cannot lock idle wallet: %v", err)
+				continue
+			}
+			setUnlockTimeout(0, false)
+		}
+	}
+}
@@ -23,8 +23,11 @@ import (
 
 // StatusElems holds pointers to widgets in the statusbar.
 var StatusElems struct {
-	Pb  *gtk.ProgressBar
-	Lab *gtk.Label
+	Pb          *gtk.ProgressBar
+	Lab         *gtk.Label
+	Unconfirmed *gtk.Button
+	Health      *gtk.Label
+	Retry       *gtk.Button
 }
 
 func createStatusbar() *gtk.Widget {
@@ -38,7 +41,43 @@ func createStatusbar() *gtk.Widget {
 		log.Fatal("Unable to create label:", err)
 	}
 	StatusElems.Lab = l
-	grid.Add(l)
+
+	// Clicking the connection status shows details of the connection
+	// to btcwallet.
+	eb, err := gtk.EventBoxNew()
+	if err != nil {
+		log.Fatal("Unable to create event box:", err)
+	}
+	eb.Add(l)
+	eb.SetTooltipText("Show connection details")
+	eb.Connect("button-press-event", func() bool {
+		if dialog, err := createConnDetailsDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+		return true
+	})
+	grid.Add(eb)
+
+	// A locked wallet may be unlocked without opening the unlock
+	// dialog.
+	grid.Add(createQuickUnlock())
+
+	// While waiting to reconnect to btcwallet, the next attempt may be
+	// made immediately.
+	rb, err := gtk.ButtonNewWithLabel("Retry now")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	StatusElems.Retry = rb
+	rb.SetTooltipText("Reconnect to btcwallet without waiting")
+	rb.SetRelief(gtk.RELIEF_NONE)
+	rb.SetNoShowAll(true)
+	rb.Connect("clicked", func() {
+		retryConnect()
+	})
+	grid.Add(rb)
 
 	p, err := gtk.ProgressBarNew()
 	if err != nil {
@@ -52,5 +91,47 @@ func createStatusbar() *gtk.Widget {
 	p.SetNoShowAll(true)
 	grid.Add(p)
 
+	b, err := gtk.ButtonNewWithLabel("")
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	StatusElems.Unconfirmed = b
+	b.SetTooltipText("Show unconfirmed transactions")
+	b.SetHAlign(gtk.ALIGN_END)
+	b.SetHExpand(true)
+	b.SetNoShowAll(true)
+	b.Connect("clicked", func() {
+		showPendingTxs()
+	})
+	grid.Add(b)
+
+	h, err := gtk.LabelNew("")
+	if err != nil {
+		log.Fatal("Unable to create label:", err)
+	}
+	StatusElems.Health = h
+	h.SetHAlign(gtk.ALIGN_END)
+	h.SetMarginLeft(6)
+	grid.Add(h)
+
+	// Status messages are often replaced before they are read, so the
+	// recent ones may be shown again.
+	hb, err := gtk.ButtonNew()
+	if err != nil {
+		log.Fatal("Unable to create button:", err)
+	}
+	img, err := gtk.ImageNewFromIconName("document-open-recent",
+		gtk.ICON_SIZE_MENU)
+	if err != nil {
+		log.Fatal("Unable to create image:", err)
+	}
+	hb.SetImage(img)
+	hb.SetRelief(gtk.RELIEF_NONE)
+	hb.SetTooltipText("Show recent status messages")
+	hb.Connect("clicked", func() {
+		showStatusHistory()
+	})
+	grid.Add(hb)
+
 	return &grid.Container.Widget
 }
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"time"
+)
+
+// statusHistorySize is the number of recent status messages kept.
+const statusHistorySize = 50
+
+// statusEntry is a status message and the time it was shown.
+type statusEntry struct {
+	time time.Time
+	text string
+}
+
+// statusHistory is a ring buffer of the most recent status messages, so
+// messages replaced before they were read may be looked at again.  It
+// is only accessed from the GTK main event loop.
+var statusHistory struct {
+	entries [statusHistorySize]statusEntry
+	next    int
+	n       int
+}
+
+// recordStatus adds a message to the status history without showing it
+// in the statusbar.  Repeats of the latest message are not recorded.
+//
+// This must be run from the GTK main event loop.
+func recordStatus(text string) {
+	if statusHistory.n != 0 {
+		last := (statusHistory.next + statusHistorySize - 1) % statusHistorySize
+		if statusHistory.entries[last].text == text {
+			return
+		}
+	}
+	statusHistory.entries[statusHistory.next] = statusEntry{time.Now(), text}
+	statusHistory.next = (statusHistory.next + 1) % statusHistorySize
+	if statusHistory.n < statusHistorySize {
+		statusHistory.n++
+	}
+}
+
+// setStatus shows a message in the statusbar and records it in the
+// status history.
+//
+// This must be run from the GTK main event loop.
+func setStatus(text string) {
+	StatusElems.Lab.SetText(text)
+	recordStatus(text)
+}
+
+// recentStatuses returns the recorded status messages, newest first.
+//
+// This must be run from the GTK main event loop.
+func recentStatuses() []statusEntry {
+	entries := make([]statusEntry, statusHistory.n)
+	for i := range entries {
+		j := (statusHistory.next - 1 - i + 2*statusHistorySize) % statusHistorySize
+		entries[i] = statusHistory.entries[j]
+	}
+	return entries
+}
+
+// showStatusHistory pops up a menu listing the recent status messages.
+// GTK before 3.12 has no popovers, so an insensitive menu is used
+// instead.
+//
+// This must be run from the GTK main event loop.
+func showStatusHistory() {
+	menu, err := gtk.MenuNew()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	entries := recentStatuses()
+	if len(entries) == 0 {
+		entries = []statusEntry{{text: "No status messages yet"}}
+	}
+	for _, e := range entries {
+		text := e.text
+		if !e.time.IsZero() {
+			text = e.time.Format("15:04:05") + "  " + text
+		}
+		mitem, err := gtk.MenuItemNewWithLabel(text)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		mitem.SetSensitive(false)
+		menu.Append(mitem)
+	}
+	menu.ShowAll()
+	menu.PopupAtMouseCursor(nil, nil, 1, 0)
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+)
+
+// defaultStuckBlocks is the default number of new blocks after which an
+// unconfirmed outgoing transaction is flagged as stuck.
+const defaultStuckBlocks = 6
+
+// stuckTxIcon is the icon shown in the transaction view for stuck
+// transactions.
+const stuckTxIcon = "dialog-warning"
+
+// Responses of the stuck transactions alert.
+const (
+	stuckShowTxs gtk.ResponseType = iota + 1
+	stuckSetFee
+)
+
+// stuckTxs tracks outgoing transactions which have not been mined.  since
+// maps the txid of each to the block height when it was first seen
+// unconfirmed, or -1 if the height was not yet known.  stuck holds the
+// txids already flagged as stuck.  This must only be accessed from the
+// GTK main event loop.
+var stuckTxs = struct {
+	height int32
+	since  map[string]int32
+	stuck  map[string]struct{}
+}{
+	height: -1,
+	since:  make(map[string]int32),
+	stuck:  make(map[string]struct{}),
+}
+
+// checkStuckTxs flags every tracked transaction which is still
+// unconfirmed after the number of blocks set by the stuckblocks option,
+// and alerts the user of newly stuck transactions.  Transactions which
+// have since been mined are no longer tracked.
+//
+// This must be run from the GTK main event loop.
+func checkStuckTxs(height int32) {
+	stuckTxs.height = height
+	var newlyStuck []string
+	for txid, since := range stuckTxs.since {
+		if _, ok := txWidgets.pending[txid]; !ok {
+			delete(stuckTxs.since, txid)
+			delete(stuckTxs.stuck, txid)
+			continue
+		}
+		if since < 0 {
+			stuckTxs.since[txid] = height
+			continue
+		}
+		if cfg.StuckBlocks <= 0 || height-since < int32(cfg.StuckBlocks) {
+			continue
+		}
+		if _, ok := stuckTxs.stuck[txid]; !ok {
+			stuckTxs.stuck[txid] = struct{}{}
+			newlyStuck = append(newlyStuck, txid)
+		}
+	}
+	if len(newlyStuck) == 0 {
+		return
+	}
+	for _, txid := range newlyStuck {
+		flagStuckTx(txid)
+	}
+	log.Printf("[WRN] %d sent transactions unconfirmed after %d blocks",
+		len(newlyStuck), cfg.StuckBlocks)
+	showStuckAlert(len(newlyStuck))
+}
+
+// noteOutgoingPending starts tracking attr, shown by the transaction
+// view row iter, if it is an unconfirmed outgoing transaction.  Rows of
+// transactions already flagged, such as when shown again after
+// reconnecting, are marked as stuck.
+//
+// This must be run from the GTK main event loop.
+func noteOutgoingPending(iter *gtk.TreeIter, attr *TxAttributes) {
+	if attr.Direction != Send || !attr.Pending() || attr.TxID == "" {
+		return
+	}
+	if _, ok := stuckTxs.since[attr.TxID]; !ok {
+		stuckTxs.since[attr.TxID] = stuckTxs.height
+	}
+	if _, ok := stuckTxs.stuck[attr.TxID]; ok {
+		setStuckRow(iter, attr)
+	}
+}
+
+// setStuckRow marks the transaction view row iter showing attr as stuck.
+//
+// This must be run from the GTK main event loop.
+func setStuckRow(iter *gtk.TreeIter, attr *TxAttributes) {
+	txWidgets.store.Set(iter, []int{txColType, txColIcon},
+		[]interface{}{attr.Direction.String() + " (stuck)", stuckTxIcon})
+}
+
+// flagStuckTx marks every row of the transaction view showing txid as
+// stuck.
+//
+// This must be run from the GTK main event loop.
+func flagStuckTx(txid string) {
+	model := &txWidgets.store.TreeModel
+	iter, ok := model.GetIterFirst()
+	for ok {
+		if attr := txAttrAt(model, iter); attr != nil && attr.TxID == txid {
+			setStuckRow(iter, attr)
+		}
+		ok = model.IterNext(iter)
+	}
+}
+
+// showStuckAlert tells the user that n sent transactions are stuck.
+// btcwallet can not raise the fee of a transaction already sent, so the
+// alert suggests raising the fee of future transactions instead.
+//
+// This must be run from the GTK main event loop.
+func showStuckAlert(n int) {
+	dialog := gtk.MessageDialogNew(mainWindow, 0, gtk.MESSAGE_WARNING,
+		gtk.BUTTONS_CLOSE, fmt.Sprintf("%d sent transactions have not "+
+			"been mined after %d new blocks.  The fee paid may be too "+
+			"low for miners to include them.  btcwallet can not raise "+
+			"the fee of a transaction already sent, but raising the "+
+			"transaction fee helps future transactions confirm "+
+			"quickly.", n, cfg.StuckBlocks))
+	dialog.SetTitle("Transactions not confirming")
+	dialog.AddButton("_Show Transactions", stuckShowTxs)
+	if !cfg.WatchOnly {
+		dialog.AddButton("Set Transaction _Fee...", stuckSetFee)
+	}
+	dialog.Connect("response", func(_ *gtk.MessageDialog, rt gtk.ResponseType) {
+		dialog.Destroy()
+		switch rt {
+		case stuckShowTxs:
+			showPendingTxs()
+		case stuckSetFee:
+			if d, err := createTxFeeDialog(); err != nil {
+				log.Print(err)
+			} else {
+				d.Run()
+			}
+		}
+	})
+	dialog.Show()
+}
@@ -20,10 +20,12 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/conformal/btcjson"
 	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/gdk"
 	"github.com/conformal/gotk3/glib"
 	"github.com/conformal/gotk3/gtk"
 )
@@ -54,10 +56,100 @@ func (d txDirection) String() string {
 // TxAttributes holds the information that is shown by each transaction
 // in the transactions view and overview pane.
 type TxAttributes struct {
-	Direction txDirection
-	Address   string
-	Amount    btcutil.Amount
-	Date      time.Time
+	Direction     txDirection
+	Address       string
+	Amount        btcutil.Amount
+	Date          time.Time
+	TxID          string
+	Confirmations int64
+
+	// Fee is the transaction fee paid by an outgoing transaction.  It
+	// is always zero for received transactions.
+	Fee btcutil.Amount
+
+	// Comment is the optional note recorded by the wallet when the
+	// transaction was sent.
+	Comment string
+
+	// Account is the wallet account which funded a sent transaction or
+	// received a payment.  The default account is named by the empty
+	// string.
+	Account string
+}
+
+// FromAccount returns the name of the account which funded a sent
+// transaction, or the empty string for received transactions.
+func (a *TxAttributes) FromAccount() string {
+	if a.Direction != Send {
+		return ""
+	}
+	if a.Account == "" {
+		return "default"
+	}
+	return a.Account
+}
+
+// Pending returns whether the transaction has not yet been mined into
+// a block.
+func (a *TxAttributes) Pending() bool {
+	return a.Confirmations == 0
+}
+
+// Confirmed returns whether the transaction has enough confirmations to
+// be shown as confirmed rather than pending.
+func (a *TxAttributes) Confirmed() bool {
+	return a.Confirmations >= confirmedDepth
+}
+
+// IsScriptHash returns whether the transaction address is a
+// pay-to-script-hash address, such as a multisig address.
+func (a *TxAttributes) IsScriptHash() bool {
+	addr, err := btcutil.DecodeAddress(a.Address, activeNet.Params)
+	if err != nil {
+		return false
+	}
+	_, ok := addr.(*btcutil.AddressScriptHash)
+	return ok
+}
+
+// Label returns the label saved locally for the transaction, or an
+// empty string if the transaction was not labeled.
+func (a *TxAttributes) Label() string {
+	if a.TxID == "" {
+		return ""
+	}
+	return metadata.TxLabel(a.Address, a.TxID)
+}
+
+// Tags returns the tags attached to the transaction locally.
+func (a *TxAttributes) Tags() []string {
+	if a.TxID == "" {
+		return nil
+	}
+	return metadata.TxTags(a.TxID)
+}
+
+// HasTag returns whether tag is attached to the transaction.
+func (a *TxAttributes) HasTag(tag string) bool {
+	for _, t := range a.Tags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// IconName returns the name of the icon used to show the transaction.
+// Pay-to-script-hash transactions are shown with a distinct icon.
+func (a *TxAttributes) IconName() string {
+	switch {
+	case a.IsScriptHash():
+		return "emblem-shared"
+	case a.Direction == Send:
+		return "go-next"
+	default:
+		return "go-previous"
+	}
 }
 
 func NewTxAttributesFromJSON(r *btcjson.ListTransactionsResult) (*TxAttributes, error) {
@@ -78,14 +170,41 @@ func NewTxAttributesFromJSON(r *btcjson.ListTransactionsResult) (*TxAttributes,
 		return nil, fmt.Errorf("invalid amount: %v", err)
 	}
 
+	fee, err := txFee(direction, r.Fee)
+	if err != nil {
+		return nil, err
+	}
+
 	return &TxAttributes{
-		Direction: direction,
-		Address:   r.Address,
-		Amount:    amount,
-		Date:      time.Unix(r.TimeReceived, 0),
+		Direction:     direction,
+		Address:       r.Address,
+		Amount:        amount,
+		Date:          time.Unix(r.TimeReceived, 0),
+		TxID:          r.TxID,
+		Confirmations: r.Confirmations,
+		Fee:           fee,
+		Comment:       r.Comment,
+		Account:       r.Account,
 	}, nil
 }
 
+// txFee converts the fee of a listtransactions result to the amount paid
+// by the wallet.  btcwallet reports fees of outgoing transactions as
+// negative numbers, and fees are not reported for received transactions.
+func txFee(direction txDirection, ffee float64) (btcutil.Amount, error) {
+	if direction != Send {
+		return 0, nil
+	}
+	fee, err := btcutil.NewAmount(ffee)
+	if err != nil {
+		return 0, fmt.Errorf("invalid fee: %v", err)
+	}
+	if fee < 0 {
+		fee = -fee
+	}
+	return fee, nil
+}
+
 // TODO(jrick): This must be removed.  It is only being kept around because
 // *all* responses are currently being sent as the nasty types determined
 // by encoding/json instead of the correct btcjson result type.
@@ -131,27 +250,582 @@ func NewTxAttributesFromMap(m map[string]interface{}) (*TxAttributes, error) {
 	}
 	unixDate := int64(funixDate)
 
+	// The txid, confirmations, comment, account, and fee are not
+	// required to display the transaction, so missing values are not
+	// treated as errors.
+	txid, _ := m["txid"].(string)
+	fconfs, _ := m["confirmations"].(float64)
+	comment, _ := m["comment"].(string)
+	account, _ := m["account"].(string)
+	ffee, _ := m["fee"].(float64)
+	fee, err := txFee(direction, ffee)
+	if err != nil {
+		return nil, err
+	}
+
 	return &TxAttributes{
-		Direction: direction,
-		Address:   address,
-		Amount:    amount,
-		Date:      time.Unix(unixDate, 0),
+		Direction:     direction,
+		Address:       address,
+		Amount:        amount,
+		Date:          time.Unix(unixDate, 0),
+		TxID:          txid,
+		Confirmations: int64(fconfs),
+		Fee:           fee,
+		Comment:       comment,
+		Account:       account,
 	}, nil
 }
 
+// Columns of the transaction view's list store.
+const (
+	txColDate = iota
+	txColType
+	txColAddress
+	txColAmount
+	txColPending
+	txColTxID
+	txColFee
+	txColIcon
+	txColAttr
+	txColMemo
+	txColLabel
+	txColTimestamp
+	txColSatoshis
+	txColFeeSatoshis
+	txColConfs
+	txColConfirmed
+	txColAccount
+	txColTags
+)
+
+// txCategories holds the name and test of each category which may be
+// selected to filter the transaction view, in the order shown by the
+// category dropdown.  A nil test shows all transactions.  Categories for
+// generated and immature coinbase transactions may be added here once
+// those are supported.
+var txCategories = []struct {
+	name  string
+	match func(*TxAttributes) bool
+}{
+	{"All transactions", nil},
+	{"Sent", func(a *TxAttributes) bool { return a.Direction == Send }},
+	{"Received", func(a *TxAttributes) bool { return a.Direction == Recv }},
+}
+
 var txWidgets struct {
 	store    *gtk.ListStore
+	filter   *gtk.TreeModelFilter
+	sorted   *gtk.TreeModelSort
 	treeview *gtk.TreeView
+
+	// pendingOnly, when checked, hides all transactions which have
+	// already been mined into a block.
+	pendingOnly *gtk.CheckButton
+
+	// category selects which of txCategories is shown.
+	category *gtk.ComboBoxText
+
+	// tag, if non-empty, hides all transactions without the tag.
+	// tagFilter selects the tag from tagNames, listed after the first
+	// entry which shows all tags.
+	tag       string
+	tagFilter *gtk.ComboBoxText
+	tagNames  []string
+
+	// search hides all transactions whose address, label, amount, and
+	// memo do not contain the entered text.
+	search *gtk.SearchEntry
+
+	// address, if non-empty, hides all transactions which do not
+	// involve this address.  addrFilter shows the address being
+	// filtered and is hidden when no address filter is set.
+	address         string
+	addrFilter      *gtk.Grid
+	addrFilterLabel *gtk.Label
+
+	// archiveBefore is the time before which transactions are archived
+	// and hidden unless showArchived is checked, a search is entered,
+	// or an address is filtered.  showArchived is only shown while
+	// transactions are archived.
+	archiveBefore time.Time
+	showArchived  *gtk.CheckButton
+
+	// totalFees shows the sum of all fees paid by outgoing transactions.
+	totalFees *gtk.Label
+
+	// columns holds whether the user chose to show or hide each
+	// optional column, keyed by column name.  Columns not chosen are
+	// shown by default.
+	columns map[string]bool
+
+	// pending holds the txids of all displayed transactions which have
+	// not yet been mined.  This must only be accessed from the GTK main
+	// event loop.
+	pending map[string]struct{}
+
+	// fees maps txids of outgoing transactions to the fee paid.  A
+	// transaction sending to multiple recipients is shown as multiple
+	// rows, so fees are keyed by txid to only be counted once.  This
+	// must only be accessed from the GTK main event loop.
+	fees     map[string]btcutil.Amount
+	feeTotal btcutil.Amount
+
+	// attrs holds the attributes of every transaction added to the
+	// store.  Each row saves the index of its attributes plus one in
+	// the txColAttr column, so rows which were never set, holding zero,
+	// reference no attributes.  Setting a row again replaces its
+	// attributes in place.  This must only be accessed from the GTK
+	// main event loop.
+	attrs []*TxAttributes
+}
+
+// setTxRow sets the columns of the transaction store row referenced by
+// iter to the values of attr, and updates the unconfirmed transaction
+// count if necessary.  A transaction set again once mined, as
+// updateTxRows does when btcwallet notifies it again after it is
+// included in a block, is removed from the count.
+//
+// This must be run from the GTK main event loop.
+func setTxRow(iter *gtk.TreeIter, attr *TxAttributes) {
+	const layout = "01/02/2006"
+	var fee string
+	if attr.Direction == Send {
+		fee = formatAmount(attr.Fee)
+	}
+	idx := txAttrIndex(&txWidgets.store.TreeModel, iter)
+	if idx < 0 {
+		txWidgets.attrs = append(txWidgets.attrs, attr)
+		idx = len(txWidgets.attrs) - 1
+	} else {
+		txWidgets.attrs[idx] = attr
+	}
+	txWidgets.store.Set(iter,
+		[]int{txColDate, txColType, txColAddress, txColAmount,
+			txColPending, txColTxID, txColFee, txColIcon, txColAttr,
+			txColMemo, txColLabel, txColTimestamp, txColSatoshis,
+			txColFeeSatoshis, txColConfs, txColConfirmed,
+			txColAccount, txColTags},
+		[]interface{}{attr.Date.Format(layout),
+			attr.Direction.String(),
+			attr.Address,
+			formatAmount(attr.Amount),
+			attr.Pending(),
+			attr.TxID,
+			fee,
+			attr.IconName(),
+			idx + 1,
+			attr.Comment,
+			attr.Label(),
+			attr.Date.Unix(),
+			int64(attr.Amount),
+			int64(attr.Fee),
+			attr.Confirmations,
+			attr.Confirmed(),
+			attr.FromAccount(),
+			strings.Join(attr.Tags(), ", ")})
+	if attr.Direction == Send && attr.Account != "" &&
+		!txColumnChosen("account") {
+		optionalTxColumn("account").col.SetVisible(true)
+	}
+
+	noteAddrUsage(attr)
+	notePayReqPayment(attr)
+	noteOutgoingPending(iter, attr)
+	scheduleActivityUpdate()
+
+	if attr.TxID != "" {
+		if attr.Pending() {
+			txWidgets.pending[attr.TxID] = struct{}{}
+		} else {
+			delete(txWidgets.pending, attr.TxID)
+		}
+		updatePendingCount()
+	}
+	if attr.Direction == Send && attr.TxID != "" {
+		txWidgets.feeTotal -= txWidgets.fees[attr.TxID]
+		txWidgets.feeTotal += attr.Fee
+		txWidgets.fees[attr.TxID] = attr.Fee
+		txWidgets.totalFees.SetText("Total fees paid: " +
+			formatAmount(txWidgets.feeTotal))
+	}
+}
+
+// updateTxRows sets every row of the transaction store showing the same
+// transaction input or output as attr to the values of attr.  This
+// updates transactions first shown unconfirmed once they are mined.
+//
+// This must be run from the GTK main event loop.
+func updateTxRows(attr *TxAttributes) {
+	key := txKey(attr)
+	model := &txWidgets.store.TreeModel
+	iter, ok := model.GetIterFirst()
+	for ok {
+		if a := txAttrAt(model, iter); a != nil && txKey(a) == key {
+			setTxRow(iter, attr)
+		}
+		ok = model.IterNext(iter)
+	}
+}
+
+// updatePendingCount updates the statusbar with the number of
+// unconfirmed transactions.  The count is hidden if there are no
+// unconfirmed transactions.
+//
+// This must be run from the GTK main event loop.
+func updatePendingCount() {
+	n := len(txWidgets.pending)
+	if n == 0 {
+		StatusElems.Unconfirmed.Hide()
+		return
+	}
+	StatusElems.Unconfirmed.SetLabel(fmt.Sprintf("%d unconfirmed", n))
+	StatusElems.Unconfirmed.Show()
+}
+
+// showPendingTxs switches the notebook to the transactions tab, only
+// showing transactions which have not yet been mined.
+//
+// This must be run from the GTK main event loop.
+func showPendingTxs() {
+	txWidgets.pendingOnly.SetActive(true)
+	mainNotebook.SetCurrentPage(transactionsPage)
+}
+
+// showAddressTxs switches the notebook to the transactions tab, only
+// showing transactions which involve addr.
+//
+// This must be run from the GTK main event loop.
+func showAddressTxs(addr string) {
+	setTxAddressFilter(addr)
+	mainNotebook.SetCurrentPage(transactionsPage)
+}
+
+// setTxAddressFilter filters the transaction view to only show
+// transactions involving addr, or removes the address filter if addr is
+// empty.
+//
+// This must be run from the GTK main event loop.
+func setTxAddressFilter(addr string) {
+	txWidgets.address = addr
+	if addr == "" {
+		txWidgets.addrFilter.Hide()
+	} else {
+		txWidgets.addrFilterLabel.SetText("Address: " + addr)
+		txWidgets.addrFilter.Show()
+	}
+	txWidgets.filter.Refilter()
+	noteViewState()
+}
+
+// txAttrAt returns the attributes of the transaction at iter in model,
+// or nil if the row does not reference any transaction.
+//
+// This must be run from the GTK main event loop.
+func txAttrAt(model *gtk.TreeModel, iter *gtk.TreeIter) *TxAttributes {
+	idx := txAttrIndex(model, iter)
+	if idx < 0 {
+		return nil
+	}
+	return txWidgets.attrs[idx]
+}
+
+// txAttrIndex returns the index into txWidgets.attrs of the attributes
+// referenced by the row at iter in model, or -1 if the row does not
+// reference any.
+//
+// This must be run from the GTK main event loop.
+func txAttrIndex(model *gtk.TreeModel, iter *gtk.TreeIter) int {
+	val, err := model.GetValue(iter, txColAttr)
+	if err != nil {
+		return -1
+	}
+	i, err := val.GoValue()
+	if err != nil {
+		return -1
+	}
+	n, ok := i.(int)
+	if !ok || n < 1 || n > len(txWidgets.attrs) {
+		return -1
+	}
+	return n - 1
+}
+
+// allTxAttrs returns the attributes of every transaction in the
+// transaction store, in the order they are shown.
+//
+// This must be run from the GTK main event loop.
+func allTxAttrs() []*TxAttributes {
+	model := &txWidgets.store.TreeModel
+	attrs := make([]*TxAttributes, 0, len(txWidgets.attrs))
+	iter, ok := model.GetIterFirst()
+	for ok {
+		if attr := txAttrAt(model, iter); attr != nil {
+			attrs = append(attrs, attr)
+		}
+		ok = model.IterNext(iter)
+	}
+	return attrs
+}
+
+// updateTxLabels updates the label column of every row showing the
+// transaction with the given txid after its labels were changed.
+//
+// This must be run from the GTK main event loop.
+func updateTxLabels(txid string) {
+	model := &txWidgets.store.TreeModel
+	iter, ok := model.GetIterFirst()
+	for ok {
+		if attr := txAttrAt(model, iter); attr != nil && attr.TxID == txid {
+			txWidgets.store.SetValue(iter, txColLabel, attr.Label())
+		}
+		ok = model.IterNext(iter)
+	}
+}
+
+// txVisible returns whether the transaction at iter passes all filters
+// currently set for the transaction view.
+func txVisible(model *gtk.TreeModel, iter *gtk.TreeIter) bool {
+	if !txWidgets.archiveBefore.IsZero() && !txWidgets.showArchived.GetActive() &&
+		txWidgets.address == "" {
+
+		text, _ := txWidgets.search.GetText()
+		attr := txAttrAt(model, iter)
+		if text == "" && attr != nil && txArchived(attr) {
+			return false
+		}
+	}
+	if txWidgets.pendingOnly.GetActive() {
+		val, err := model.GetValue(iter, txColPending)
+		if err != nil {
+			return false
+		}
+		pending, err := val.GoValue()
+		if err != nil {
+			return false
+		}
+		if p, ok := pending.(bool); !ok || !p {
+			return false
+		}
+	}
+	if txWidgets.address != "" {
+		attr := txAttrAt(model, iter)
+		if attr == nil || attr.Address != txWidgets.address {
+			return false
+		}
+	}
+	if txWidgets.tag != "" {
+		attr := txAttrAt(model, iter)
+		if attr == nil || !attr.HasTag(txWidgets.tag) {
+			return false
+		}
+	}
+	if i := txWidgets.category.GetActive(); i > 0 && i < len(txCategories) {
+		attr := txAttrAt(model, iter)
+		if attr == nil || !txCategories[i].match(attr) {
+			return false
+		}
+	}
+	if text, err := txWidgets.search.GetText(); err == nil && text != "" {
+		attr := txAttrAt(model, iter)
+		if attr == nil || !txMatches(attr, text) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectedTxAttrs returns the attributes of every selected transaction
+// in the transaction view, in the order they are shown.
+//
+// This must be run from the GTK main event loop.
+func selectedTxAttrs() []*TxAttributes {
+	sel, err := txWidgets.treeview.GetSelection()
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+	model := &txWidgets.sorted.TreeModel
+	var attrs []*TxAttributes
+	for _, path := range sel.GetSelectedRows(txWidgets.sorted) {
+		iter, err := model.GetIter(path)
+		if err != nil {
+			continue
+		}
+		if attr := txAttrAt(model, iter); attr != nil {
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs
+}
+
+// createTxContextMenu creates the menu shown when right clicking the
+// transaction view.
+func createTxContextMenu() *gtk.Menu {
+	menu, err := gtk.MenuNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mitem, err := gtk.MenuItemNewWithLabel("Export Selected...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		attrs := selectedTxAttrs()
+		if len(attrs) == 0 {
+			return
+		}
+		if dialog, err := createExportDialog(attrs); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	menu.Append(mitem)
+
+	mitem, err = gtk.MenuItemNewWithLabel("Edit Tags...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mitem.Connect("activate", func() {
+		attrs := selectedTxAttrs()
+		if len(attrs) == 0 {
+			return
+		}
+		if dialog, err := createTxTagsDialog(attrs); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	menu.Append(mitem)
+	menu.ShowAll()
+
+	return menu
+}
+
+// txMatches returns whether the address, label, amount, memo, or tags of
+// a transaction contain text, ignoring case.
+func txMatches(attr *TxAttributes, text string) bool {
+	text = strings.ToLower(text)
+	for _, s := range []string{attr.Address, attr.Label(),
+		attr.Amount.String(), formatAmount(attr.Amount),
+		btcString(attr.Amount), attr.Comment,
+		strings.Join(attr.Tags(), " ")} {
+
+		if strings.Contains(strings.ToLower(s), text) {
+			return true
+		}
+	}
+	return false
 }
 
 func createTransactions() *gtk.Widget {
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+
+	pendingOnly, err := gtk.CheckButtonNewWithLabel("Unconfirmed only")
+	if err != nil {
+		log.Fatal(err)
+	}
+	pendingOnly.Connect("toggled", func() {
+		noteViewState()
+		txWidgets.filter.Refilter()
+	})
+	txWidgets.pendingOnly = pendingOnly
+
+	category, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, c := range txCategories {
+		category.AppendText(c.name)
+	}
+	category.SetActive(0)
+	category.Connect("changed", func() {
+		noteViewState()
+		txWidgets.filter.Refilter()
+	})
+	txWidgets.category = category
+
+	search, err := gtk.SearchEntryNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	search.SetPlaceholderText("Search address, label, amount, memo, or tags")
+	search.SetHExpand(true)
+	search.Connect("changed", func() {
+		noteViewState()
+		txWidgets.filter.Refilter()
+	})
+	txWidgets.search = search
+
+	addrFilter, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	addrFilter.SetColumnSpacing(6)
+	addrFilterLabel, err := gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	addrFilter.Add(addrFilterLabel)
+	clearAddr, err := gtk.ButtonNewWithLabel("Show all addresses")
+	if err != nil {
+		log.Fatal(err)
+	}
+	clearAddr.Connect("clicked", func() {
+		setTxAddressFilter("")
+	})
+	addrFilter.Add(clearAddr)
+	addrFilterLabel.Show()
+	clearAddr.Show()
+	addrFilter.SetNoShowAll(true)
+	txWidgets.addrFilter = addrFilter
+	txWidgets.addrFilterLabel = addrFilterLabel
+
+	filters, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	filters.SetColumnSpacing(12)
+	filters.Add(category)
+	filters.Add(createTagFilter())
+	filters.Add(search)
+	filters.Add(pendingOnly)
+	filters.Add(createArchiveControls())
+	filters.Add(addrFilter)
+	grid.Add(filters)
+
 	sw, err := gtk.ScrolledWindowNew(nil, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
+	grid.Add(sw)
 
 	store, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING,
-		glib.TYPE_STRING, glib.TYPE_STRING)
+		glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_BOOLEAN,
+		glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_INT, glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_INT64, glib.TYPE_INT64, glib.TYPE_INT64,
+		glib.TYPE_INT64, glib.TYPE_BOOLEAN, glib.TYPE_STRING,
+		glib.TYPE_STRING)
+	if err != nil {
+		log.Fatal(err)
+	}
+	filter, err := store.FilterNew(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	filter.SetVisibleFunc(txVisible)
+
+	// Dates and amounts are displayed as formatted strings, so the
+	// columns showing them sort by hidden columns holding the Unix time
+	// and satoshi values instead.
+	sorted, err := gtk.TreeModelSortNew(filter)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -159,41 +833,99 @@ func createTransactions() *gtk.Widget {
 	if err != nil {
 		log.Fatal(err)
 	}
-	tv.SetModel(store)
+	tv.SetModel(sorted)
 	tv.SetHExpand(true)
 	tv.SetVExpand(true)
 	txWidgets.store = store
+	txWidgets.filter = filter
+	txWidgets.sorted = sorted
 	txWidgets.treeview = tv
+	txWidgets.pending = make(map[string]struct{})
+	txWidgets.fees = make(map[string]btcutil.Amount)
+	txWidgets.columns = make(map[string]bool)
 	sw.Add(tv)
 
+	sel, err := tv.GetSelection()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sel.SetMode(gtk.SELECTION_MULTIPLE)
+
+	// Right clicking shows a menu of actions on the selected
+	// transactions.  When several transactions are selected, the click
+	// is not passed on to the tree view so the selection is kept.
+	menu := createTxContextMenu()
+	tv.Connect("button-press-event", func(_ *gtk.TreeView, ev *gdk.Event) bool {
+		eb := &gdk.EventButton{Event: ev}
+		if eb.Button() != 3 {
+			return false
+		}
+		menu.PopupAtMouseCursor(nil, nil, 3, 0)
+		return sel.CountSelectedRows() > 1
+	})
+	tv.Connect("popup-menu", func() bool {
+		menu.PopupAtMouseCursor(nil, nil, 0, 0)
+		return true
+	})
+
+	tv.Connect("row-activated", func(_ *gtk.TreeView, path *gtk.TreePath) {
+		iter, err := sorted.GetIter(path)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		attr := txAttrAt(&sorted.TreeModel, iter)
+		if attr == nil {
+			return
+		}
+		if dialog, err := createTxDetailsDialog(attr); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+
+	pr, err := gtk.CellRendererPixbufNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	col, err := gtk.TreeViewColumnNewWithAttribute("", pr, "icon-name", txColIcon)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tv.AppendColumn(col)
+
 	cr, err := gtk.CellRendererTextNew()
 	if err != nil {
 		log.Fatal(err)
 	}
-	col, err := gtk.TreeViewColumnNewWithAttribute("Date", cr, "text", 0)
+	col, err = gtk.TreeViewColumnNewWithAttribute("Date", cr, "text", txColDate)
 	if err != nil {
 		log.Fatal(err)
 	}
+	col.SetSortColumnID(txColTimestamp)
 	tv.AppendColumn(col)
 
 	cr, err = gtk.CellRendererTextNew()
 	if err != nil {
 		log.Fatal(err)
 	}
-	col, err = gtk.TreeViewColumnNewWithAttribute("Type", cr, "text", 1)
+	col, err = gtk.TreeViewColumnNewWithAttribute("Type", cr, "text", txColType)
 	if err != nil {
 		log.Fatal(err)
 	}
+	col.SetSortColumnID(txColType)
 	tv.AppendColumn(col)
 
 	cr, err = gtk.CellRendererTextNew()
 	if err != nil {
 		log.Fatal(err)
 	}
-	col, err = gtk.TreeViewColumnNewWithAttribute("Address", cr, "text", 2)
+	col, err = gtk.TreeViewColumnNewWithAttribute("Address", cr, "text", txColAddress)
 	if err != nil {
 		log.Fatal(err)
 	}
+	col.SetSortColumnID(txColAddress)
 	col.SetExpand(true)
 	tv.AppendColumn(col)
 
@@ -201,11 +933,112 @@ func createTransactions() *gtk.Widget {
 	if err != nil {
 		log.Fatal(err)
 	}
-	col, err = gtk.TreeViewColumnNewWithAttribute("Amount", cr, "text", 3)
+	col, err = gtk.TreeViewColumnNewWithAttribute("Label", cr, "text", txColLabel)
 	if err != nil {
 		log.Fatal(err)
 	}
+	col.SetSortColumnID(txColLabel)
 	tv.AppendColumn(col)
 
-	return &sw.Bin.Container.Widget
+	cr, err = gtk.CellRendererTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	col, err = gtk.TreeViewColumnNewWithAttribute("Amount", cr, "text", txColAmount)
+	if err != nil {
+		log.Fatal(err)
+	}
+	col.AddAttribute(cr, "sensitive", txColConfirmed)
+	col.SetSortColumnID(txColSatoshis)
+	tv.AppendColumn(col)
+
+	cr, err = gtk.CellRendererTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	col, err = gtk.TreeViewColumnNewWithAttribute("Fee", cr, "text", txColFee)
+	if err != nil {
+		log.Fatal(err)
+	}
+	col.SetSortColumnID(txColFeeSatoshis)
+	addOptionalTxColumn("fee", col)
+	tv.AppendColumn(col)
+
+	// Amounts are greyed out until the transaction has confirmedDepth
+	// confirmations, counted up in place as blocks are connected.
+	cr, err = gtk.CellRendererTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	col, err = gtk.TreeViewColumnNewWithAttribute("Conf.", cr, "text", txColConfs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	col.AddAttribute(cr, "sensitive", txColConfirmed)
+	col.SetSortColumnID(txColConfs)
+	addOptionalTxColumn("confirmations", col)
+	tv.AppendColumn(col)
+
+	cr, err = gtk.CellRendererTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	col, err = gtk.TreeViewColumnNewWithAttribute("From Account", cr,
+		"text", txColAccount)
+	if err != nil {
+		log.Fatal(err)
+	}
+	col.SetSortColumnID(txColAccount)
+	addOptionalTxColumn("account", col)
+	tv.AppendColumn(col)
+
+	cr, err = gtk.CellRendererTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	col, err = gtk.TreeViewColumnNewWithAttribute("Tags", cr, "text", txColTags)
+	if err != nil {
+		log.Fatal(err)
+	}
+	col.SetSortColumnID(txColTags)
+	tv.AppendColumn(col)
+
+	cr, err = gtk.CellRendererTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	col, err = gtk.TreeViewColumnNewWithAttribute("Memo", cr, "text", txColMemo)
+	if err != nil {
+		log.Fatal(err)
+	}
+	col.SetSortColumnID(txColMemo)
+	addOptionalTxColumn("memo", col)
+	tv.AppendColumn(col)
+
+	cr, err = gtk.CellRendererTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	col, err = gtk.TreeViewColumnNewWithAttribute("Transaction ID", cr,
+		"text", txColTxID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	col.SetSortColumnID(txColTxID)
+	addOptionalTxColumn("txid", col)
+	tv.AppendColumn(col)
+
+	// Right clicking a column header shows a menu choosing the
+	// optional columns shown.
+	createTxColumnMenu(tv)
+
+	totalFees, err := gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	totalFees.SetHAlign(gtk.ALIGN_END)
+	txWidgets.totalFees = totalFees
+	grid.Add(totalFees)
+
+	return &grid.Container.Widget
 }
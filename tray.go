@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/gtk"
+)
+
+// trayIconName is the name of the icon shown in the system tray.
+const trayIconName = "network-transmit-receive"
+
+// statusIcon is the system tray icon, or nil if no tray icon is shown.
+var statusIcon *gtk.StatusIcon
+
+// createStatusIcon adds an icon to the system tray which toggles the
+// visibility of the main window when clicked.  Hiding the window locks
+// the GUI if a PIN is set.
+//
+// This must be run from the GTK main event loop.
+func createStatusIcon() (*gtk.StatusIcon, error) {
+	icon, err := gtk.StatusIconNewFromIconName(trayIconName)
+	if err != nil {
+		return nil, err
+	}
+	icon.SetTooltipText("btcgui")
+	icon.Connect("activate", func() {
+		if mainWindow.GetVisible() {
+			lockGUI()
+		} else {
+			showMainWindow()
+		}
+	})
+	return icon, nil
+}
+
+// showMainWindow shows and raises the main window, which may have been
+// hidden in the system tray, once the GUI PIN is entered if required.
+//
+// This must be run from the GTK main event loop.
+func showMainWindow() {
+	if !unlockGUI() {
+		return
+	}
+	mainWindow.Show()
+	mainWindow.Present()
+}
@@ -18,116 +18,134 @@ package main
 
 import (
 	"github.com/conformal/gotk3/gtk"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
-// Using `` for string literals here is too messy, so concat "" strings.
+// Tutorial pages are loaded from a directory of resource files so they
+// may be changed or translated without recompiling.  Each page is a file
+// of Pango markup with the extension tutorialMarkupExt, and pages are
+// shown sorted by filename.  A page may be illustrated by an image of the
+// same name with the extension tutorialImageExt.  Translations are kept
+// in subdirectories named by locale, such as "de" or "pt_BR".
 const (
-	welcomeText = "<b>Welcome to btcgui alpha!</b>\n" +
-		"\n" +
-		"The following steps will prepare you to begin using " +
-		"btcgui.\n" +
-		"\n" +
-		"Click Next to open the next tip, or Close to skip the " +
-		"rest of the tutorial.\n"
-
-	disclaimerText = "<b>Disclaimer</b>\n" +
-		"\n" +
-		"btcgui is still alpha-level development software and is " +
-		"not yet ready to replace other Bitcoin wallet software.\n" +
-		"\n" +
-		"Running btcgui on the main Bitcoin network is currently " +
-		"disabled by default until the software has matured.\n" +
-		"\n" +
-		"btcgui is primarly written as a Unix GUI, and will be rough " +
-		"around the edges on other platforms.  Development of native " +
-		"applications for other platforms is planned for the future."
-
-	connectText = "<b>Multiprocess Wallet Design</b>\n" +
-		"\n" +
-		"btcgui does not store your Bitcoin wallet or create and " +
-		"send transactions, but instead connects to another program, " +
-		"btcwallet, for wallet services.  Due to this design, btcgui " +
-		"will not function if disconnected from btcwallet and almost " +
-		"all btcgui features will be disabled.\n" +
-		"\n" +
-		"If btcgui is not currently connected to btcwallet, a " +
-		"warning message will be displayed in the btcgui statusbar. " +
-		"If this happens, first check that btcwallet is running and " +
-		"not blocked behind a firewall.  If a connection can still " +
-		"not be established, it's possible the wrong ports are being " +
-		"used."
-
-	createWalletText = "<b>Creating a Wallet</b>\n" +
-		"\n" +
-		"When first connecting a websocket client such as btcgui to " +
-		"btcwallet, a dialog will open asking for a wallet " +
-		"passphrase.  btcwallet does not support wallets with " +
-		"unencrypted private keys, and will not autogenerate wallets " +
-		"for this reason.\n" +
-		"\n" +
-		"To create this wallet, enter and repeat a passphrase in " +
-		"the passphrase dialog and press OK.  btcwallet will create " +
-		"the encrypted wallet and begin notifying btcgui of changes " +
-		"to the wallet, such as new account balances."
-
-	receiveText = "<b>Receiving Funds</b>\n" +
-		"\n" +
-		"Bitcoins can be received by giving a payment address from " +
-		"your wallet to other Bitcoin users.  Payment addresses can " +
-		"be managed under the \"Receive Coins\" tab.  From this " +
-		"view, all addresses for your wallet can be viewed and " +
-		"copied to your clipboard. New addresses can also be " +
-		"generated if you do not wish to reuse an older address.\n" +
-		"\n" +
-		"If neither you nor anyone you know has testnet Bitcoins, " +
-		"an online Bitcoin testnet faucet can be used to receive " +
-		"testnet coins.\n" +
-		"\n" +
-		"Of the standard script types, btcwallet only supports " +
-		"receiving Pay to Pubkey Hash transactions. Receiving " +
-		"from transactions with other standard script types will " +
-		"be added in later versions."
-
-	sendText = "<b>Sending Funds</b>\n" +
-		"\n" +
-		"To send Bitcoins to others, open the \"Send Coins\" tab. " +
-		"Enter the payment addresses and Bitcoin amounts to send " +
-		"for one or more recipient (adding additional recipients as " +
-		"needed).  A wallet must be unlocked before new " +
-		"transactions can be created.\n" +
-		"\n" +
-		"Currently, btcwallet only creates Pay to Pubkey Hash " +
-		"transactions.  Generating other standard transactions " +
-		"will be added in later versions."
-
-	futureFeaturesText = "<b>Future Features</b>\n" +
-		"\n" +
-		"btcgui alpha is still under heavy development and is " +
-		"missing features needed for an everyday wallet " +
-		"application.\n" +
-		"\n" +
-		"Features like maintaining an address book for others' " +
-		"addresses and multiple account support are planned for " +
-		"future versions."
-
-	feedbackText = "<b>Feedback is appreciated!</b>\n" +
-		"\n" +
-		"Stop by Conformal's " +
-		"<a href=\"https://opensource.conformal.com/wiki/IRC_server\">IRC server</a> " +
-		"(channel #btcd) to let us know what you think!"
+	tutorialDirname   = "tutorial"
+	tutorialMarkupExt = ".markup"
+	tutorialImageExt  = ".png"
 )
 
-// dialogMessages holds the dialog messages successively shown in the
-// tutorial dialog.
-var dialogMessages = []string{
-	welcomeText,
-	disclaimerText,
-	connectText,
-	createWalletText,
-	receiveText,
-	sendText,
-	futureFeaturesText,
-	feedbackText,
+// missingTutorialText is shown when no tutorial pages can be found.
+const missingTutorialText = "<b>Welcome to btcgui alpha!</b>\n" +
+	"\n" +
+	"The tutorial could not be shown because its files were not " +
+	"found.  Use the tutorialdir option to set the directory " +
+	"holding the tutorial files."
+
+// tutorialPage is a single page of the tutorial.
+type tutorialPage struct {
+	markup string
+
+	// image is the path of the image illustrating the page, or empty
+	// if the page has no image.
+	image string
+}
+
+// tutorialDirs returns the directories to search for tutorial pages, in
+// order of preference.
+func tutorialDirs() []string {
+	var dirs []string
+	if cfg != nil && cfg.TutorialDir != "" {
+		dirs = append(dirs, cfg.TutorialDir)
+	}
+	dirs = append(dirs,
+		filepath.Join(btcguiHomeDir, tutorialDirname),
+		filepath.Join(filepath.Dir(os.Args[0]), tutorialDirname))
+
+	// When installed with go get, the tutorial remains in the source
+	// directory.
+	pkg, err := build.Import("github.com/conformal/btcgui", "", build.FindOnly)
+	if err == nil {
+		dirs = append(dirs, filepath.Join(pkg.Dir, tutorialDirname))
+	}
+	return dirs
+}
+
+// tutorialLocales returns the locale subdirectories to search for
+// translated tutorial pages, from most to least specific, as set by the
+// LC_ALL, LC_MESSAGES, or LANG environment variables.  For example, a
+// locale of "pt_BR.UTF-8" returns "pt_BR" and "pt".
+func tutorialLocales() []string {
+	var locale string
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale = os.Getenv(env); locale != "" {
+			break
+		}
+	}
+	if i := strings.IndexAny(locale, ".@"); i != -1 {
+		locale = locale[:i]
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return nil
+	}
+	locales := []string{locale}
+	if i := strings.Index(locale, "_"); i != -1 {
+		locales = append(locales, locale[:i])
+	}
+	return locales
+}
+
+// readTutorialPages reads all tutorial pages in dir.
+func readTutorialPages(dir string) ([]tutorialPage, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, fi := range infos {
+		if !fi.IsDir() && filepath.Ext(fi.Name()) == tutorialMarkupExt {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+
+	pages := make([]tutorialPage, 0, len(names))
+	for _, name := range names {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		page := tutorialPage{markup: strings.TrimRight(string(b), "\n")}
+		image := filepath.Join(dir,
+			strings.TrimSuffix(name, tutorialMarkupExt)+tutorialImageExt)
+		if fileExists(image) {
+			page.image = image
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// loadTutorialPages returns the tutorial pages for the current locale,
+// falling back to untranslated pages.  If no pages can be found, a single
+// page explaining that the tutorial is missing is returned.
+func loadTutorialPages() []tutorialPage {
+	for _, dir := range tutorialDirs() {
+		for _, locale := range tutorialLocales() {
+			pages, err := readTutorialPages(filepath.Join(dir, locale))
+			if err == nil && len(pages) != 0 {
+				return pages
+			}
+		}
+		pages, err := readTutorialPages(dir)
+		if err == nil && len(pages) != 0 {
+			return pages
+		}
+	}
+	return []tutorialPage{{markup: missingTutorialText}}
 }
 
 // CreateTutorialDialog opens a tutorial dialog explaining usage for a
@@ -178,16 +196,32 @@ func CreateTutorialDialog(appWindow *gtk.Window) (*gtk.Dialog, error) {
 	nb.Show()
 
 	// Create messages and append each in a notebook page.
-	for _, msg := range dialogMessages {
+	pages := loadTutorialPages()
+	for _, page := range pages {
+		pageGrid, err := gtk.GridNew()
+		if err != nil {
+			return nil, err
+		}
+		pageGrid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+		pageGrid.Show()
+		if page.image != "" {
+			img, err := gtk.ImageNewFromFile(page.image)
+			if err != nil {
+				return nil, err
+			}
+			img.Show()
+			pageGrid.Add(img)
+		}
 		lbl, err := gtk.LabelNew("")
 		if err != nil {
 			return nil, err
 		}
-		lbl.SetMarkup(msg)
+		lbl.SetMarkup(page.markup)
 		lbl.SetLineWrap(true)
 		lbl.Show()
 		lbl.SetAlignment(0, 0)
-		nb.AppendPage(lbl, nil)
+		pageGrid.Add(lbl)
+		nb.AppendPage(pageGrid, nil)
 	}
 	nb.SetShowTabs(false)
 	grid.Add(nb)
@@ -201,6 +235,7 @@ func CreateTutorialDialog(appWindow *gtk.Window) (*gtk.Dialog, error) {
 	if err != nil {
 		return nil, err
 	}
+	nextPage.SetSensitive(len(pages) > 1)
 	prevPage.Connect("clicked", func() {
 		nb.PrevPage()
 		pagen := nb.GetCurrentPage()
@@ -212,7 +247,7 @@ func CreateTutorialDialog(appWindow *gtk.Window) (*gtk.Dialog, error) {
 	nextPage.Connect("clicked", func() {
 		nb.NextPage()
 		pagen := nb.GetCurrentPage()
-		if pagen == len(dialogMessages)-1 {
+		if pagen == len(pages)-1 {
 			nextPage.SetSensitive(false)
 		}
 		prevPage.SetSensitive(true)
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"time"
+)
+
+// archiveClearResponse is the response of the archive dialog button
+// unarchiving all transactions.
+const archiveClearResponse gtk.ResponseType = 1
+
+// txArchived returns whether a transaction is archived.
+//
+// This must be run from the GTK main event loop.
+func txArchived(attr *TxAttributes) bool {
+	return attr.Date.Before(txWidgets.archiveBefore)
+}
+
+// setArchiveDate archives all transactions before t, or unarchives all
+// transactions if t is the zero time, and saves the archive date.
+// Archived transactions are hidden from the transaction list but are
+// still searched and exported.
+//
+// This must be run from the GTK main event loop.
+func setArchiveDate(t time.Time) {
+	if err := metadata.SetArchiveDate(t); err != nil {
+		log.Printf("[ERR] cannot save archive date: %v", err)
+	}
+	showArchiveDate(t)
+	txWidgets.filter.Refilter()
+}
+
+// showArchiveDate shows the checkbox revealing archived transactions
+// while transactions before t are archived.
+//
+// This must be run from the GTK main event loop.
+func showArchiveDate(t time.Time) {
+	txWidgets.archiveBefore = t
+	if t.IsZero() {
+		txWidgets.showArchived.Hide()
+		return
+	}
+	txWidgets.showArchived.SetLabel("Show archived (before " +
+		t.Format("January 2006") + ")")
+	txWidgets.showArchived.Show()
+}
+
+// createArchiveControls creates the button of the transactions tab
+// choosing which transactions are archived, and the checkbox showing
+// archived transactions.
+func createArchiveControls() *gtk.Widget {
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	grid.SetColumnSpacing(6)
+
+	btn, err := gtk.ButtonNewWithLabel("Archive...")
+	if err != nil {
+		log.Fatal(err)
+	}
+	btn.SetTooltipText("Hide transactions older than a chosen month " +
+		"from the list.  Archived transactions are still searched " +
+		"and exported.")
+	btn.Connect("clicked", func() {
+		if dialog, err := createArchiveDialog(); err != nil {
+			log.Print(err)
+		} else {
+			dialog.Run()
+		}
+	})
+	grid.Add(btn)
+
+	show, err := gtk.CheckButtonNewWithLabel("Show archived")
+	if err != nil {
+		log.Fatal(err)
+	}
+	show.SetNoShowAll(true)
+	show.Connect("toggled", func() {
+		txWidgets.filter.Refilter()
+	})
+	grid.Add(show)
+	txWidgets.showArchived = show
+	showArchiveDate(metadata.ArchiveDate())
+
+	return &grid.Container.Widget
+}
+
+// createArchiveDialog creates a dialog choosing the month before which
+// transactions are archived, or unarchiving all transactions.
+func createArchiveDialog() (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Archive transactions")
+
+	dialog.AddButton("_Archive", gtk.RESPONSE_OK)
+	dialog.AddButton("_Unarchive All", archiveClearResponse)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+	dialog.SetResponseSensitive(archiveClearResponse,
+		!txWidgets.archiveBefore.IsZero())
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetColumnSpacing(6)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	l, err := gtk.LabelNew("Transactions before the chosen month are " +
+		"hidden from the list, but are still found by searches and " +
+		"included in exports.")
+	if err != nil {
+		return nil, err
+	}
+	l.SetLineWrap(true)
+	l.SetWidthChars(50)
+	grid.Attach(l, 0, 0, 3, 1)
+
+	t := txWidgets.archiveBefore
+	if t.IsZero() {
+		t = time.Now().AddDate(-1, 0, 0)
+	}
+	before, err := newMonthChooser(grid, 1, "Archive before:", t)
+	if err != nil {
+		return nil, err
+	}
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		switch rt {
+		case gtk.RESPONSE_OK:
+			setArchiveDate(before.Time())
+		case archiveClearResponse:
+			setArchiveDate(time.Time{})
+		}
+		dialog.Destroy()
+	})
+
+	return dialog, nil
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/gdk"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+)
+
+// txOptionalColumn is a column of the transaction list which can be
+// shown or hidden from the context menu of the column headers.
+type txOptionalColumn struct {
+	// key names the column in the saved view state.
+	key   string
+	title string
+
+	// shown is whether the column is shown unless the user chose
+	// otherwise.
+	shown bool
+
+	col  *gtk.TreeViewColumn
+	item *gtk.CheckMenuItem
+}
+
+// txOptionalColumns are the columns of the transaction list which can be
+// hidden, in the order they are listed by the header context menu.  The
+// account column is shown by default only once a transaction is sent
+// from an account other than the default account.
+var txOptionalColumns = []*txOptionalColumn{
+	{key: "txid", title: "Transaction ID"},
+	{key: "fee", title: "Fee", shown: true},
+	{key: "confirmations", title: "Confirmations", shown: true},
+	{key: "account", title: "From Account"},
+	{key: "memo", title: "Memo", shown: true},
+}
+
+// txColumnMenuUpdating is set while the header context menu is updated
+// to match the visible columns, so the updates are not taken as choices
+// of the user.  This must only be accessed from the GTK main event loop.
+var txColumnMenuUpdating bool
+
+// optionalTxColumn returns the optional column named by key, or nil if
+// there is none.
+func optionalTxColumn(key string) *txOptionalColumn {
+	for _, c := range txOptionalColumns {
+		if c.key == key {
+			return c
+		}
+	}
+	return nil
+}
+
+// addOptionalTxColumn makes col the optional column named by key, setting
+// its default visibility.
+//
+// This must be run from the GTK main event loop.
+func addOptionalTxColumn(key string, col *gtk.TreeViewColumn) {
+	c := optionalTxColumn(key)
+	if c == nil {
+		log.Fatalf("no optional transaction column %q", key)
+	}
+	c.col = col
+	col.SetVisible(c.shown)
+}
+
+// txColumnChosen returns whether the user chose to show or hide the
+// optional column named by key, rather than it being shown by default.
+//
+// This must be run from the GTK main event loop.
+func txColumnChosen(key string) bool {
+	_, ok := txWidgets.columns[key]
+	return ok
+}
+
+// setTxColumns shows or hides each optional column as chosen by columns,
+// keyed by column name.  Columns not in columns are shown by default.
+//
+// This must be run from the GTK main event loop.
+func setTxColumns(columns map[string]bool) {
+	txWidgets.columns = make(map[string]bool)
+	for key, visible := range columns {
+		c := optionalTxColumn(key)
+		if c == nil {
+			continue
+		}
+		txWidgets.columns[key] = visible
+		c.col.SetVisible(visible)
+	}
+}
+
+// txColumnsCopy returns a copy of the columns the user chose to show or
+// hide, to be saved with the view state.
+//
+// This must be run from the GTK main event loop.
+func txColumnsCopy() map[string]bool {
+	if len(txWidgets.columns) == 0 {
+		return nil
+	}
+	columns := make(map[string]bool, len(txWidgets.columns))
+	for key, visible := range txWidgets.columns {
+		columns[key] = visible
+	}
+	return columns
+}
+
+// createTxColumnMenu creates the menu listing each optional column of the
+// transaction list with a check item showing or hiding it.  The menu is
+// shown by right clicking any column header of tv.  Choices are saved
+// with the view state, so the layout is restored on the next launch.
+//
+// This must be run from the GTK main event loop.
+func createTxColumnMenu(tv *gtk.TreeView) {
+	menu, err := gtk.MenuNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, c := range txOptionalColumns {
+		c := c
+		item, err := gtk.CheckMenuItemNewWithLabel(c.title)
+		if err != nil {
+			log.Fatal(err)
+		}
+		item.Connect("toggled", func() {
+			if txColumnMenuUpdating {
+				return
+			}
+			visible := item.GetActive()
+			txWidgets.columns[c.key] = visible
+			c.col.SetVisible(visible)
+			noteViewState()
+		})
+		menu.Append(item)
+		c.item = item
+	}
+	menu.ShowAll()
+
+	popup := func(_ *gtk.Widget, ev *gdk.Event) bool {
+		eb := &gdk.EventButton{Event: ev}
+		if eb.Button() != 3 {
+			return false
+		}
+		txColumnMenuUpdating = true
+		for _, c := range txOptionalColumns {
+			c.item.SetActive(c.col.GetVisible())
+		}
+		txColumnMenuUpdating = false
+		menu.PopupAtMouseCursor(nil, nil, 3, 0)
+		return true
+	}
+	for i := 0; ; i++ {
+		col := tv.GetColumn(i)
+		if col == nil {
+			break
+		}
+		button, err := col.GetButton()
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		button.Connect("button-press-event", popup)
+	}
+}
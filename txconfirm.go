@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+)
+
+// confirmedDepth is the number of confirmations after which a
+// transaction is shown as confirmed rather than pending.
+const confirmedDepth = 6
+
+// txConfsHeight is the block height the confirmations shown in the
+// transaction view were last counted at, or -1 if not yet known.  This
+// must only be accessed from the GTK main event loop.
+var txConfsHeight int32 = -1
+
+// setTxConfirmations updates the confirmation columns of the
+// transaction view row iter after the confirmations of attr changed.
+// A transaction no longer unconfirmed is removed from the unconfirmed
+// count, and no longer shown as stuck.
+//
+// This must be run from the GTK main event loop.
+func setTxConfirmations(iter *gtk.TreeIter, attr *TxAttributes) {
+	txWidgets.store.Set(iter,
+		[]int{txColConfs, txColConfirmed, txColPending},
+		[]interface{}{attr.Confirmations, attr.Confirmed(),
+			attr.Pending()})
+	if attr.Pending() {
+		return
+	}
+	if _, ok := txWidgets.pending[attr.TxID]; ok {
+		delete(txWidgets.pending, attr.TxID)
+		updatePendingCount()
+	}
+	txWidgets.store.Set(iter, []int{txColType, txColIcon},
+		[]interface{}{attr.Direction.String(), attr.IconName()})
+}
+
+// updateTxConfirmations counts the confirmations of every mined
+// transaction in the transaction view up to the new block height,
+// updating the rows in place rather than requesting all transactions
+// again.  The confirmations of unconfirmed transactions are requested,
+// as any may have been mined in the new blocks.
+//
+// This must be run from the GTK main event loop.
+func updateTxConfirmations(height int32) {
+	last := txConfsHeight
+	txConfsHeight = height
+	if last < 0 || height <= last {
+		// Blocks disconnected from the chain are not yet handled,
+		// so confirmations are never counted down.
+		return
+	}
+	delta := int64(height - last)
+
+	model := &txWidgets.store.TreeModel
+	iter, ok := model.GetIterFirst()
+	for ok {
+		if attr := txAttrAt(model, iter); attr != nil && !attr.Pending() {
+			attr.Confirmations += delta
+			setTxConfirmations(iter, attr)
+		}
+		ok = model.IterNext(iter)
+	}
+
+	txids := make([]string, 0, len(txWidgets.pending))
+	for txid := range txWidgets.pending {
+		txids = append(txids, txid)
+	}
+	if len(txids) != 0 {
+		go requestPendingConfirmations(txids)
+	}
+}
+
+// requestPendingConfirmations requests the confirmations of each
+// unconfirmed transaction in txids, updating the rows of those which
+// were mined.
+func requestPendingConfirmations(txids []string) {
+	b := activeBackend()
+	if b == nil {
+		return
+	}
+
+	for _, txid := range txids {
+		tx, err := b.GetTransaction(txid)
+		if err != nil {
+			log.Printf("[ERR] cannot get transaction %v: %v", txid, err)
+			continue
+		}
+		confs, _ := tx["confirmations"].(float64)
+		if confs <= 0 {
+			continue
+		}
+		id, n := txid, int64(confs)
+		glib.IdleAdd(func() {
+			setTxidConfirmations(id, n)
+		})
+	}
+}
+
+// setTxidConfirmations sets the confirmations of every row of the
+// transaction view showing txid.
+//
+// This must be run from the GTK main event loop.
+func setTxidConfirmations(txid string, confs int64) {
+	model := &txWidgets.store.TreeModel
+	iter, ok := model.GetIterFirst()
+	for ok {
+		if attr := txAttrAt(model, iter); attr != nil && attr.TxID == txid {
+			attr.Confirmations = confs
+			setTxConfirmations(iter, attr)
+		}
+		ok = model.IterNext(iter)
+	}
+}
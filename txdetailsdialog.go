@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"strings"
+)
+
+// addDetailsRow appends a row to a details grid, showing a name and a
+// selectable value.  The value label is returned so it may be updated
+// later.
+func addDetailsRow(grid *gtk.Grid, row int, name, value string) (*gtk.Label, error) {
+	l, err := gtk.LabelNew(name)
+	if err != nil {
+		return nil, err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	l.SetVAlign(gtk.ALIGN_START)
+	grid.Attach(l, 0, row, 1, 1)
+
+	v, err := gtk.LabelNew(value)
+	if err != nil {
+		return nil, err
+	}
+	v.SetHAlign(gtk.ALIGN_START)
+	v.SetSelectable(true)
+	v.SetLineWrap(true)
+	grid.Attach(v, 1, row, 1, 1)
+
+	return v, nil
+}
+
+// Responses of the transaction details dialog buttons which copy details
+// instead of closing the dialog.
+const (
+	txDetailsCopyAddress gtk.ResponseType = iota + 1
+	txDetailsCopyTxID
+)
+
+// createTxDetailsDialog creates a dialog showing all known details of a
+// transaction.  If the transaction address is a pay-to-script-hash
+// address, the redeem script is requested from btcwallet and shown once
+// the reply is received.
+func createTxDetailsDialog(attr *TxAttributes) (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Transaction details")
+
+	dialog.AddButton("Copy _Address", txDetailsCopyAddress)
+	dialog.AddButton("Copy _Transaction ID", txDetailsCopyTxID)
+	dialog.AddButton("_Close", gtk.RESPONSE_CLOSE)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetHExpand(true)
+	grid.SetVExpand(true)
+	grid.SetColumnSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	rows := []struct {
+		name  string
+		value string
+	}{
+		{"Date:", attr.Date.Format("Jan 2, 2006 at 3:04 PM")},
+		{"Type:", attr.Direction.String()},
+		{"Address:", attr.Address},
+		{"Amount:", formatAmount(attr.Amount)},
+		{"Transaction ID:", attr.TxID},
+		{"Confirmations:", fmt.Sprintf("%d", attr.Confirmations)},
+	}
+	if attr.Direction == Send {
+		rows = append(rows, struct {
+			name  string
+			value string
+		}{"Fee:", formatAmount(attr.Fee)})
+		if attr.Account != "" {
+			rows = append(rows, struct {
+				name  string
+				value string
+			}{"From account:", attr.FromAccount()})
+		}
+	}
+	if label := attr.Label(); label != "" {
+		rows = append(rows, struct {
+			name  string
+			value string
+		}{"Label:", label})
+	}
+	if tags := attr.Tags(); len(tags) != 0 {
+		rows = append(rows, struct {
+			name  string
+			value string
+		}{"Tags:", strings.Join(tags, ", ")})
+	}
+	if attr.Comment != "" {
+		rows = append(rows, struct {
+			name  string
+			value string
+		}{"Comment:", attr.Comment})
+	}
+	for i, r := range rows {
+		if _, err := addDetailsRow(grid, i, r.name, r.value); err != nil {
+			return nil, err
+		}
+	}
+
+	if attr.IsScriptHash() {
+		script, err := addDetailsRow(grid, len(rows), "Redeem script:",
+			"Requesting from btcwallet...")
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			details, err := validateAddress(attr.Address)
+			glib.IdleAdd(func() {
+				script.SetText(redeemScriptText(details, err))
+			})
+		}()
+	}
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		switch rt {
+		case txDetailsCopyAddress:
+			copyToClipboard(attr.Address)
+		case txDetailsCopyTxID:
+			copyToClipboard(attr.TxID)
+		default:
+			dialog.Destroy()
+		}
+	})
+
+	return dialog, nil
+}
+
+// redeemScriptText formats the details of a pay-to-script-hash address
+// replied by a validateaddress request, or the error requesting them, as
+// text describing the redeem script.
+func redeemScriptText(details map[string]interface{}, err error) string {
+	if err != nil {
+		return "Unavailable: " + err.Error()
+	}
+	hex, ok := details["hex"].(string)
+	if !ok {
+		return "Unknown (script is not in this wallet)"
+	}
+	text := hex
+	if script, ok := details["script"].(string); ok {
+		text = fmt.Sprintf("%s (%s)", hex, script)
+	}
+	nreq, ok := details["sigsrequired"].(float64)
+	addrs, _ := details["addresses"].([]interface{})
+	if ok && len(addrs) != 0 {
+		text += fmt.Sprintf("\nRequires %d of %d signatures",
+			int(nreq), len(addrs))
+	}
+	return text
+}
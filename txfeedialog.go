@@ -17,8 +17,10 @@
 package main
 
 import (
+	"github.com/conformal/btcutil"
 	"github.com/conformal/gotk3/glib"
 	"github.com/conformal/gotk3/gtk"
+	"log"
 )
 
 const txFeeMessage = "Optional transaction fee to help make sure transactions are processed quickly."
@@ -63,6 +65,29 @@ func createTxFeeDialog() (*gtk.Dialog, error) {
 	}
 	grid.Add(spinb)
 
+	// Show what the fee buys for a typical transaction spending one
+	// input and paying one recipient and change.
+	estimate, err := gtk.LabelNew("")
+	if err != nil {
+		return nil, err
+	}
+	estimate.SetHAlign(gtk.ALIGN_START)
+	grid.Add(estimate)
+	updateEstimate := func() {
+		feePerKB, err := btcutil.NewAmount(spinb.GetValue())
+		if err != nil {
+			estimate.SetText("")
+			return
+		}
+		estimate.SetText("Fee per kilobyte.  For a typical payment: " +
+			feeEstimateText(feePerKB, 1, 2))
+	}
+	if feePerKB, ok := currentTxFeeRate(); ok {
+		spinb.SetValue(feePerKB.ToUnit(btcutil.AmountBitcoin))
+	}
+	updateEstimate()
+	spinb.Connect("value-changed", updateEstimate)
+
 	dialog.SetTransientFor(mainWindow)
 	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
 	dialog.ShowAll()
@@ -70,13 +95,15 @@ func createTxFeeDialog() (*gtk.Dialog, error) {
 	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
 		switch rt {
 		case gtk.RESPONSE_OK:
-			fee := spinb.GetValue()
+			fee, err := btcutil.NewAmount(spinb.GetValue())
+			if err != nil {
+				log.Print(err)
+				return
+			}
 			go func() {
-				triggers.setTxFee <- fee
-
-				if err := <-triggerReplies.setTxFeeErr; err != nil {
+				if err := setTxFee(fee); err != nil {
 					d := errorDialog("Error setting transaction fee:",
-						err.Error())
+						walletErrorMessage(err))
 					d.Run()
 					d.Destroy()
 				} else {
@@ -0,0 +1,252 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"strings"
+)
+
+// parseTags splits a comma separated list of tags, dropping empty and
+// repeated tags.
+func parseTags(s string) []string {
+	var tags []string
+	seen := make(map[string]struct{})
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		tags = append(tags, t)
+	}
+	return tags
+}
+
+// createTagFilter creates the dropdown of the transactions tab choosing
+// a tag to only show transactions with the tag.
+func createTagFilter() *gtk.Widget {
+	combo, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	combo.SetTooltipText("Only show transactions with a tag")
+	txWidgets.tagFilter = combo
+	refreshTagFilter()
+	combo.Connect("changed", func() {
+		i := combo.GetActive()
+		tag := ""
+		if i > 0 && i <= len(txWidgets.tagNames) {
+			tag = txWidgets.tagNames[i-1]
+		}
+		if tag != txWidgets.tag {
+			txWidgets.tag = tag
+			noteViewState()
+			txWidgets.filter.Refilter()
+		}
+	})
+	return &combo.Widget
+}
+
+// refreshTagFilter lists every tag in the tag dropdown, keeping the
+// selected tag selected.  A selected tag which is no longer attached to
+// any transaction stays listed so the filter does not change under the
+// user.
+//
+// This must be run from the GTK main event loop.
+func refreshTagFilter() {
+	tags := metadata.AllTxTags()
+	if txWidgets.tag != "" {
+		found := false
+		for _, t := range tags {
+			if t == txWidgets.tag {
+				found = true
+			}
+		}
+		if !found {
+			tags = append(tags, txWidgets.tag)
+		}
+	}
+	txWidgets.tagNames = tags
+
+	combo := txWidgets.tagFilter
+	combo.RemoveAll()
+	combo.AppendText("All tags")
+	active := 0
+	for i, t := range tags {
+		combo.AppendText(t)
+		if t == txWidgets.tag {
+			active = i + 1
+		}
+	}
+	combo.SetActive(active)
+}
+
+// setTxTagFilter filters the transaction view to only show transactions
+// with tag, or removes the tag filter if tag is empty.
+//
+// This must be run from the GTK main event loop.
+func setTxTagFilter(tag string) {
+	txWidgets.tag = tag
+	refreshTagFilter()
+	txWidgets.filter.Refilter()
+	noteViewState()
+}
+
+// updateTxTags updates the tags column of every row showing the
+// transaction with the given txid after its tags were changed.
+//
+// This must be run from the GTK main event loop.
+func updateTxTags(txid string) {
+	model := &txWidgets.store.TreeModel
+	iter, ok := model.GetIterFirst()
+	for ok {
+		if attr := txAttrAt(model, iter); attr != nil && attr.TxID == txid {
+			txWidgets.store.SetValue(iter, txColTags,
+				strings.Join(attr.Tags(), ", "))
+		}
+		ok = model.IterNext(iter)
+	}
+}
+
+// commonTags returns the tags attached to every transaction of attrs, in
+// the order of the first transaction.
+func commonTags(attrs []*TxAttributes) []string {
+	var common []string
+	for _, t := range attrs[0].Tags() {
+		all := true
+		for _, attr := range attrs[1:] {
+			if !attr.HasTag(t) {
+				all = false
+				break
+			}
+		}
+		if all {
+			common = append(common, t)
+		}
+	}
+	return common
+}
+
+// setTxTags replaces the tags common to all transactions of attrs with
+// tags, keeping any other tags attached to each transaction.
+//
+// This must be run from the GTK main event loop.
+func setTxTags(attrs []*TxAttributes, common, tags []string) {
+	done := make(map[string]struct{})
+	for _, attr := range attrs {
+		if _, ok := done[attr.TxID]; ok || attr.TxID == "" {
+			continue
+		}
+		done[attr.TxID] = struct{}{}
+
+		var kept []string
+		for _, t := range attr.Tags() {
+			if !containsString(common, t) && !containsString(tags, t) {
+				kept = append(kept, t)
+			}
+		}
+		if err := metadata.SetTxTags(attr.TxID, append(kept, tags...)); err != nil {
+			log.Printf("[ERR] cannot save transaction tags: %v", err)
+			return
+		}
+		updateTxTags(attr.TxID)
+	}
+	refreshTagFilter()
+	txWidgets.filter.Refilter()
+}
+
+// containsString returns whether s is an element of list.
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// createTxTagsDialog creates a dialog editing the tags of the
+// transactions of attrs.  When several transactions are edited, only the
+// tags attached to all of them are shown and replaced.
+func createTxTagsDialog(attrs []*TxAttributes) (*gtk.Dialog, error) {
+	dialog, err := gtk.DialogNew()
+	if err != nil {
+		return nil, err
+	}
+	dialog.SetTitle("Edit tags")
+
+	dialog.AddButton("_OK", gtk.RESPONSE_OK)
+	dialog.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return nil, err
+	}
+	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
+	grid.SetRowSpacing(6)
+	b, err := dialog.GetContentArea()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(grid)
+
+	msg := "Tags of this transaction, separated by commas:"
+	if len(attrs) > 1 {
+		msg = "Tags of all selected transactions, separated by commas:"
+	}
+	l, err := gtk.LabelNew(msg)
+	if err != nil {
+		return nil, err
+	}
+	l.SetHAlign(gtk.ALIGN_START)
+	grid.Add(l)
+
+	common := commonTags(attrs)
+	entry, err := gtk.EntryNew()
+	if err != nil {
+		return nil, err
+	}
+	entry.SetText(strings.Join(common, ", "))
+	entry.SetPlaceholderText("rent, donations")
+	entry.SetActivatesDefault(true)
+	entry.SetWidthChars(40)
+	grid.Add(entry)
+
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	dialog.ShowAll()
+
+	dialog.Connect("response", func(_ *gtk.Dialog, rt gtk.ResponseType) {
+		if rt == gtk.RESPONSE_OK {
+			text, err := entry.GetText()
+			if err != nil {
+				log.Print(err)
+			} else {
+				setTxTags(attrs, common, parseTags(text))
+			}
+		}
+		dialog.Destroy()
+	})
+
+	return dialog, nil
+}
@@ -17,6 +17,7 @@
 package main
 
 import (
+	"github.com/conformal/gotk3/gdk"
 	"github.com/conformal/gotk3/glib"
 	"github.com/conformal/gotk3/gtk"
 	"log"
@@ -26,6 +27,10 @@ import (
 type UnlockParams struct {
 	passphrase string
 	timeout    int64
+
+	// session is set if the wallet is unlocked until the user locks
+	// it, is idle, or quits btcgui, rather than for timeout seconds.
+	session bool
 }
 
 // UnlockText specifies the title and message to be shown in an
@@ -47,8 +52,32 @@ var (
 		Message: "Wallet must be unlocked to generate new addresses.\n" +
 			"The wallet will automatically lock after the timeout has expired.",
 	}
+	unlockForSigning = &UnlockText{
+		Title: "Sign transaction",
+		Message: "Wallet must be unlocked to sign the transaction.\n" +
+			"The wallet will automatically lock after the timeout has expired.",
+	}
+	unlockForRequest = &UnlockText{
+		Title: "Unlock wallet",
+		Message: "Wallet must be unlocked to complete the request.\n" +
+			"The wallet will automatically lock after the timeout has expired.",
+	}
 )
 
+// keyCapsLock is the key value of the Caps Lock key.
+const keyCapsLock = 0xffe5
+
+// capsLockOn returns whether Caps Lock is on after the key press ev.  The
+// modifier state of an event is the state before the key was pressed, so
+// it is inverted when the key is Caps Lock itself.
+func capsLockOn(ev *gdk.EventKey) bool {
+	on := gdk.ModifierType(ev.State())&gdk.LOCK_MASK != 0
+	if ev.KeyVal() == keyCapsLock {
+		on = !on
+	}
+	return on
+}
+
 // createUnlockDialog creates a dialog to enter a passphrase and unlock
 // an encrypted wallet.  If an OK response is received, the passphrase will
 // be used to attempt a wallet unlock.
@@ -107,11 +136,34 @@ func createUnlockDialog(reason *UnlockText,
 	})
 	grid.Attach(passphrase, 1, 1, 1, 1)
 
+	showEntryText, err := gtk.CheckButtonNewWithLabel("Show passphrase")
+	if err != nil {
+		return nil, err
+	}
+	showEntryText.Connect("toggled", func() {
+		passphrase.SetVisibility(showEntryText.GetActive())
+	})
+	grid.Attach(showEntryText, 1, 2, 1, 1)
+
+	// Failed unlocks are most often caused by typing with Caps Lock
+	// on, so warn about it while the passphrase is entered.
+	capsLock, err := gtk.LabelNew("Caps Lock is on")
+	if err != nil {
+		return nil, err
+	}
+	capsLock.SetHAlign(gtk.ALIGN_START)
+	capsLock.SetNoShowAll(true)
+	grid.Attach(capsLock, 1, 3, 1, 1)
+	passphrase.Connect("key-press-event", func(_ *gtk.Entry, ev *gdk.Event) bool {
+		capsLock.SetVisible(capsLockOn(&gdk.EventKey{Event: ev}))
+		return false
+	})
+
 	lbl, err = gtk.LabelNew("Timeout (s)")
 	if err != nil {
 		return nil, err
 	}
-	grid.Attach(lbl, 0, 2, 1, 1)
+	grid.Attach(lbl, 0, 4, 1, 1)
 
 	timeout, err := gtk.SpinButtonNewWithRange(0, float64(1<<64-1), 1)
 	if err != nil {
@@ -121,7 +173,19 @@ func createUnlockDialog(reason *UnlockText,
 	timeout.Connect("activate", func() {
 		dialog.Emit("response", gtk.RESPONSE_OK, nil)
 	})
-	grid.Attach(timeout, 1, 2, 1, 1)
+	grid.Attach(timeout, 1, 4, 1, 1)
+
+	session, err := gtk.CheckButtonNewWithLabel("Unlock until I lock or quit")
+	if err != nil {
+		return nil, err
+	}
+	session.SetTooltipText("Keep the wallet unlocked for many operations " +
+		"in a row.  The wallet is locked again when btcgui exits or " +
+		"after a period of inactivity.")
+	session.Connect("toggled", func() {
+		timeout.SetSensitive(!session.GetActive())
+	})
+	grid.Attach(session, 0, 5, 2, 1)
 
 	dialog.SetTransientFor(mainWindow)
 	dialog.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
@@ -139,15 +203,17 @@ func createUnlockDialog(reason *UnlockText,
 				return
 			}
 
-			timeoutSecs := timeout.GetValueAsInt()
+			params := &UnlockParams{
+				passphrase: pStr,
+				timeout:    int64(timeout.GetValueAsInt()),
+			}
+			if session.GetActive() {
+				params.timeout = sessionUnlockTimeout
+				params.session = true
+			}
 
 			go func() {
-				triggers.unlockWallet <- &UnlockParams{
-					pStr,
-					int64(timeoutSecs),
-				}
-
-				if ok := <-triggerReplies.unlockSuccessful; ok {
+				if err := unlockWallet(params); err == nil {
 					if success != nil {
 						success <- true
 					}
@@ -169,7 +235,7 @@ func createUnlockDialog(reason *UnlockText,
 				}
 			}()
 
-		case gtk.RESPONSE_CANCEL:
+		case gtk.RESPONSE_CANCEL, gtk.RESPONSE_DELETE_EVENT:
 			if success != nil {
 				close(success)
 			}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/conformal/btcgui/rpc"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/gotk3/glib"
+	"log"
+	"sync"
+)
+
+// noRetryMethods are the requests which are never retried after
+// unlocking the wallet, as they manage the lock state themselves.
+var noRetryMethods = map[string]bool{
+	seedExportMethod:         true,
+	"walletpassphrase":       true,
+	"walletpassphrasechange": true,
+	"walletlock":             true,
+}
+
+// unlockOnDemand holds the channels waiting for the result of the unlock
+// dialog shown for refused requests.  Only one dialog is shown for all
+// requests refused at the same time.
+var unlockOnDemand struct {
+	sync.Mutex
+	waiters []chan bool
+}
+
+// unlockReason returns the text of the unlock dialog shown when a
+// request for method is refused with err, and whether the request may
+// succeed after unlocking the wallet.
+func unlockReason(method string, err *btcjson.Error) (*UnlockText, bool) {
+	if cfg.WatchOnly || noRetryMethods[method] {
+		return nil, false
+	}
+	switch err.Code {
+	case btcjson.ErrWalletUnlockNeeded.Code:
+		if method == "sendmany" || method == "sendfrom" ||
+			method == "sendtoaddress" {
+			return unlockForTxSend, true
+		}
+		if method == "signrawtransaction" {
+			return unlockForSigning, true
+		}
+		return unlockForRequest, true
+	case btcjson.ErrWalletKeypoolRanOut.Code:
+		// btcwallet refills the keypool once unlocked.
+		return unlockForKeypool, true
+	}
+	return nil, false
+}
+
+// retryAfterUnlock handles a request refused because the wallet is
+// locked by asking for the passphrase and sending the request msg with
+// id again, so the reply handler f sees the reply to the retried request
+// instead.  If the wallet is not unlocked, f is called with the original
+// reply.  It returns false, without calling f, if the request can not be
+// retried.  It is the Refused hook of every session with btcwallet.
+func retryAfterUnlock(id uint64, msg []byte, f rpc.ReplyHandler,
+	result interface{}, jsonErr *btcjson.Error) bool {
+
+	if msg == nil {
+		return false
+	}
+	var req struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return false
+	}
+	reason, ok := unlockReason(req.Method, jsonErr)
+	if !ok {
+		return false
+	}
+
+	go func() {
+		if !requestUnlock(reason) {
+			f(result, jsonErr)
+			return
+		}
+
+		if err := resendRequest(id, msg, f); err != nil {
+			f(nil, err)
+		}
+	}()
+	return true
+}
+
+// resendRequest sends the request msg with id again over the current
+// connection to btcwallet, calling f with its reply.
+func resendRequest(id uint64, msg []byte, f rpc.ReplyHandler) error {
+	c := activeClient()
+	if c == nil {
+		return ErrConnectionLost
+	}
+	return c.Resend(id, msg, f)
+}
+
+// requestUnlock shows the unlock dialog with reason, unless it is
+// already shown for another request, and returns whether the wallet was
+// unlocked.
+//
+// This is written to be run outside of the GTK main event loop.
+func requestUnlock(reason *UnlockText) bool {
+	done := make(chan bool, 1)
+	unlockOnDemand.Lock()
+	unlockOnDemand.waiters = append(unlockOnDemand.waiters, done)
+	first := len(unlockOnDemand.waiters) == 1
+	unlockOnDemand.Unlock()
+
+	if first {
+		go func() {
+			unlocked := showUnlockOnDemand(reason)
+			unlockOnDemand.Lock()
+			for _, c := range unlockOnDemand.waiters {
+				c <- unlocked
+			}
+			unlockOnDemand.waiters = nil
+			unlockOnDemand.Unlock()
+		}()
+	}
+	return <-done
+}
+
+// showUnlockOnDemand shows the unlock dialog with reason until the
+// wallet is unlocked or the dialog is cancelled, returning whether the
+// wallet was unlocked.  Failed unlocks leave the dialog open to try
+// again.
+func showUnlockOnDemand(reason *UnlockText) bool {
+	success := make(chan bool)
+	glib.IdleAdd(func() {
+		dialog, err := createUnlockDialog(reason, success)
+		if err != nil {
+			log.Printf("[ERR] could not create unlock dialog: %v", err)
+			close(success)
+			return
+		}
+		dialog.Run()
+	})
+	for ok := range success {
+		if ok {
+			return true
+		}
+	}
+	return false
+}
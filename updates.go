@@ -17,21 +17,18 @@
 package main
 
 import (
-	"crypto/tls"
-	"crypto/x509"
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/conformal/btcgui/rpc"
 	"github.com/conformal/btcjson"
 	"github.com/conformal/btcutil"
 	"github.com/conformal/btcws"
-	"github.com/conformal/go-socks"
 	"github.com/conformal/gotk3/glib"
-	"github.com/conformal/websocket"
 	"log"
-	"net/http"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -47,278 +44,54 @@ var (
 
 	// ErrConnectionLost describes an error where a connection to
 	// another process was lost.
-	ErrConnectionLost = errors.New("connection lost")
+	ErrConnectionLost = rpc.ErrConnectionLost
+
+	// ErrRequestTimeout describes an error where btcwallet did not
+	// reply to a request in time, although it may have carried it out.
+	ErrRequestTimeout = rpc.ErrRequestTimeout
+
+	// ErrWatchOnly describes an error where a request to unlock the
+	// wallet or spend funds was refused because btcgui is running in
+	// watch-only mode.
+	ErrWatchOnly = errors.New("not allowed in watch-only mode")
+
+	// ErrImportAddrUnsupported describes an error where an address was
+	// imported into a btcwallet which can not watch addresses without
+	// their private keys.
+	ErrImportAddrUnsupported = errors.New("the connected btcwallet " +
+		"does not support importing addresses")
+
+	// ErrSeedUnsupported describes an error where the wallet seed was
+	// requested from a btcwallet which can not export it.
+	ErrSeedUnsupported = errors.New("the connected btcwallet does not " +
+		"support exporting the wallet seed")
 )
 
-var (
-	// NewJSONID is used to receive the next unique JSON ID for
-	// btcwallet requests, starting from zero and incrementing by one
-	// after each read.
-	NewJSONID = make(chan uint64)
-
-	// replyHandlers maps between a uint64 sequence id for json
-	// messages and replies, and a function to handle the returned
-	// result.  Mutex protects against multiple writes.
-	replyHandlers = struct {
-		sync.RWMutex
-		m map[uint64]func(interface{}, *btcjson.Error)
-	}{
-		m: make(map[uint64]func(interface{}, *btcjson.Error)),
-	}
-
-	// Channels filled from fetchFuncs and read by updateFuncs.
-	updateChans = struct {
-		addrs              chan []string
-		balance            chan btcutil.Amount
-		btcdConnected      chan bool
-		btcwalletConnected chan bool
-		bcHeight           chan int32
-		bcHeightRemote     chan int32
-		lockState          chan bool
-		unconfirmed        chan btcutil.Amount
-		appendTx           chan *TxAttributes
-		prependTx          chan *TxAttributes
-		appendOverviewTx   chan *TxAttributes
-		prependOverviewTx  chan *TxAttributes
-	}{
-		addrs:              make(chan []string),
-		balance:            make(chan btcutil.Amount),
-		btcdConnected:      make(chan bool),
-		btcwalletConnected: make(chan bool),
-		bcHeight:           make(chan int32),
-		bcHeightRemote:     make(chan int32),
-		lockState:          make(chan bool),
-		unconfirmed:        make(chan btcutil.Amount),
-		appendTx:           make(chan *TxAttributes),
-		prependTx:          make(chan *TxAttributes),
-		appendOverviewTx:   make(chan *TxAttributes),
-		prependOverviewTx:  make(chan *TxAttributes),
-	}
-
-	triggers = struct {
-		newAddr      chan int
-		newWallet    chan *NewWalletParams
-		lockWallet   chan int
-		unlockWallet chan *UnlockParams
-		sendTx       chan map[string]float64
-		setTxFee     chan float64
-	}{
-		newAddr:      make(chan int),
-		newWallet:    make(chan *NewWalletParams),
-		lockWallet:   make(chan int),
-		unlockWallet: make(chan *UnlockParams),
-		sendTx:       make(chan map[string]float64),
-		setTxFee:     make(chan float64),
-	}
-
-	triggerReplies = struct {
-		newAddr           chan interface{}
-		unlockSuccessful  chan bool
-		walletCreationErr chan error
-		sendTx            chan error
-		setTxFeeErr       chan error
-	}{
-		newAddr:           make(chan interface{}),
-		unlockSuccessful:  make(chan bool),
-		walletCreationErr: make(chan error),
-		sendTx:            make(chan error),
-		setTxFeeErr:       make(chan error),
-	}
-
-	walletReqFuncs = []func(*websocket.Conn){
-		cmdGetAddressesByAccount,
-		cmdGetBalance,
-		cmdGetBlockCount,
-		cmdGetUnconfirmedBalance,
-		cmdListAllTransactions,
-		cmdWalletIsLocked,
-	}
-	updateFuncs = [](func()){
-		updateAddresses,
-		updateBalance,
-		updateConnectionState,
-		updateLockState,
-		updateProgress,
-		updateTransactions,
-		updateUnconfirmed,
-	}
-)
-
-// JSONIDGenerator sends incremental integers across a channel.  This
-// is meant to provide a unique value for the JSON ID field for btcwallet
-// messages.
-func JSONIDGenerator(c chan uint64) {
-	var n uint64
-	for {
-		c <- n
-		n++
-	}
-}
-
 var updateOnce sync.Once
 
-// ListenAndUpdate opens a websocket connection to a btcwallet
-// instance and initiates requests to fill the GUI with relevant
-// information.
-func ListenAndUpdate(certificates []byte, c chan error) {
-	// Start each updater func in a goroutine.  Use a sync.Once to
-	// ensure there are no duplicate updater functions running.
-	updateOnce.Do(func() {
-		for _, f := range updateFuncs {
-			go f()
-		}
-	})
-
-	pool := x509.NewCertPool()
-	pool.AppendCertsFromPEM(certificates)
-	tlsConfig := &tls.Config{
-		RootCAs:    pool,
-		MinVersion: tls.VersionTLS12,
-	}
-
-	// Create a websocket dialer that will be used to make the connection.
-	// It is modified by the proxy setting below as needed.
-	dialer := websocket.Dialer{TLSClientConfig: tlsConfig}
-
-	if cfg.Proxy != "" {
-		proxy := &socks.Proxy{
-			Addr:     cfg.Proxy,
-			Username: cfg.ProxyUser,
-			Password: cfg.ProxyPass,
-		}
-		dialer.NetDial = proxy.Dial
-	}
-
-	// btcwallet requires basic authorization, so we use a custom config
-	// with the Authorization header set.
-	login := cfg.Username + ":" + cfg.Password
-	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-	requestHeader := make(http.Header)
-	requestHeader.Add("Authorization", auth)
-
-	// Connect to websocket.
-	url := fmt.Sprintf("wss://%s/ws", cfg.RPCConnect)
-	ws, _, err := dialer.Dial(url, requestHeader)
-	if err != nil {
-		log.Printf("[ERR] cannot create websocket config: %v", err)
-		c <- ErrConnectionRefused
-		return
-	}
-	c <- nil
-
-	// Buffered channel for replies and notifications from btcwallet.
-	replies := make(chan []byte, 100)
-
-	go func() {
-		for {
-			// Receive message from wallet
-
-			_, msg, err := ws.ReadMessage()
-			if err != nil {
-				close(replies)
-				return
-			}
-			replies <- msg
-		}
-	}()
-
-	for _, f := range walletReqFuncs {
-		go f(ws)
-	}
-
-	for {
-		select {
-		case r, ok := <-replies:
-			if !ok {
-				// btcwallet connection lost.
-				c <- ErrConnectionLost
-				return
-			}
-
-			// Handle message here.
-			go ProcessBtcwalletMessage(r)
-
-		case <-triggers.newAddr:
-			go cmdGetNewAddress(ws)
-
-		case params := <-triggers.newWallet:
-			go cmdCreateEncryptedWallet(ws, params)
-
-		case <-triggers.lockWallet:
-			go cmdWalletLock(ws)
-
-		case params := <-triggers.unlockWallet:
-			go cmdWalletPassphrase(ws, params)
-
-		case pairs := <-triggers.sendTx:
-			go cmdSendMany(ws, pairs)
-
-		case fee := <-triggers.setTxFee:
-			go cmdSetTxFee(ws, fee)
-		}
-	}
+// startUpdaters subscribes each updater of the GUI to the events it
+// shows and starts it.  Subscribing before any event is published
+// ensures no event is missed.
+func startUpdaters() {
+	go updateAddresses(walletEvents.Subscribe(AddressesChanged{}))
+	go updateBalances(walletEvents.Subscribe(BalanceChanged{}))
+	go updateConnectionState(walletEvents.Subscribe(ConnStateChanged{}))
+	go updateHealth()
+	go updateLockState(walletEvents.Subscribe(LockStateChanged{}))
+	go updateProgress(walletEvents.Subscribe(BlockHeightChanged{}))
+	go updateTransactions(walletEvents.Subscribe(TxAppended{}, TxMined{}))
+	go updateOverviewTxs(walletEvents.Subscribe(TxAppended{}))
 }
 
-// ProcessBtcwalletMessage unmarshalls the JSON notification or
-// reply received from btcwallet and decides how to handle it.
-func ProcessBtcwalletMessage(b []byte) {
-	// Idea: instead of reading btcwallet messages from just one
-	// websocket connection, maybe use two so the same connection isn't
-	// used for both notifications and responses?  Should make handling
-	// must faster as unnecessary unmarshal attempts could be avoided.
-
-	// Check for notifications first.
-	if req, err := btcjson.ParseMarshaledCmd(b); err == nil {
-		// btcwallet should not be sending Requests except for
-		// notifications.  Check for a nil id.
-		if req.Id() != nil {
-			// Invalid response
-			log.Printf("[WRN] btcwallet sent a non-notification JSON-RPC Request (Id: %v)",
-				req.Id())
-			return
-		}
-
-		// Message is a notification.  Check the method and dispatch
-		// correct handler, or if no handler, log a warning.
-		if ntfnHandler, ok := notificationHandlers[req.Method()]; ok {
-			ntfnHandler(req)
-		} else {
-			// No handler; log warning.
-			log.Printf("[WRN] unhandled notification with method %v",
-				req.Method())
-		}
-		return
-	}
-
-	// b is not a Request notification, so it must be a Response.
-	// Attempt to parse it as one and handle.
-	var r btcjson.Reply
-	if err := json.Unmarshal(b, &r); err != nil {
-		log.Print("[WRN] Unable to unmarshal btcwallet message as notification or response")
-		return
-	}
-
-	// Check for a valid ID.  btcgui only sends numbers as IDs, so
-	// perform an appropiate type check.
-	if r.Id == nil {
-		// Responses with no IDs cannot be handled.
-		log.Print("[WRN] Unable to process btcwallet response without ID")
-		return
-	}
-	id, ok := (*r.Id).(float64)
-	if !ok {
-		log.Printf("[WRN] Unable to process btcwallet response with non-number ID %v",
-			*r.Id)
-		return
-	}
-
-	replyHandlers.Lock()
-	defer replyHandlers.Unlock()
-	if f, ok := replyHandlers.m[uint64(id)]; ok {
-		delete(replyHandlers.m, uint64(id))
-		f(r.Result, r.Error)
+// handleNotification dispatches a notification to its handler, or logs
+// a warning if there is no handler.
+func handleNotification(req btcjson.Cmd) {
+	if ntfnHandler, ok := notificationHandlers[req.Method()]; ok {
+		ntfnHandler(req)
 	} else {
-		log.Print("[WRN] No handler for btcwallet response")
+		// No handler; log warning.
+		log.Printf("[WRN] unhandled notification with method %v",
+			req.Method())
 	}
 }
 
@@ -343,7 +116,9 @@ func handleBlockConnectedNtfn(n btcjson.Cmd) {
 		return
 	}
 
-	updateChans.bcHeight <- bcn.Height
+	walletEvents.Publish(BlockHeightChanged{bcn.Height})
+	noteBlockConnected(bcn.Hash)
+	noteSyncBlock(bcn.Hash)
 }
 
 // handleBlockDisconnectedNtfn handles btcd/btcwallet blockdisconnected
@@ -371,7 +146,10 @@ func handleBtcdConnectedNtfn(n btcjson.Cmd) {
 		return
 	}
 
-	updateChans.btcdConnected <- bcn.Connected
+	walletEvents.Publish(ConnStateChanged{
+		Btcd:      true,
+		Connected: bcn.Connected,
+	})
 }
 
 // handleTxNtfn handles btcwallet newtx notifications by updating the GUI
@@ -383,17 +161,28 @@ func handleTxNtfn(n btcjson.Cmd) {
 		return
 	}
 
-	// TODO(jrick): do proper filtering and display
-	// tx details for all accounts.
-	if tn.Account == "" {
+	// Only transactions of the selected account are shown.
+	if tn.Account == currentAccount() {
 		attr, err := NewTxAttributesFromJSON(tn.Details)
 		if err != nil {
 			log.Printf("[ERR] %v handler: bad details: %v",
 				n.Method(), err)
 			return
 		}
-		updateChans.prependOverviewTx <- attr
-		updateChans.prependTx <- attr
+		switch markTxSeen(attr) {
+		case txSeenNew:
+			if attr.Direction == Recv {
+				logEvent(eventReceived, "%v: %v to %v", attr.TxID,
+					attr.Amount, attr.Address)
+			}
+			walletEvents.Publish(TxAppended{
+				Tx:       attr,
+				New:      true,
+				Overview: true,
+			})
+		case txSeenMined:
+			walletEvents.Publish(TxMined{attr})
+		}
 	}
 }
 
@@ -406,17 +195,26 @@ func handleAccountBalanceNtfn(n btcjson.Cmd) {
 		return
 	}
 
-	// TODO(jrick): do proper filtering and display all
-	// account balances somewhere
-	if abn.Account == "" {
+	// Only the balances of the selected account are shown, while other
+	// accounts may add an account to the selector.
+	if abn.Account == currentAccount() {
 		bal, _ := btcutil.NewAmount(abn.Balance)
+		kind := BalanceUnconfirmed
 		if abn.Confirmed {
-			updateChans.balance <- bal
-		} else {
-			updateChans.unconfirmed <- bal
+			kind = BalanceConfirmed
 		}
+		walletEvents.Publish(BalanceChanged{kind, bal})
+	} else if abn.Confirmed {
+		// Other accounts only change the total of all accounts.
+		glib.IdleAdd(func() {
+			if Overview.AllAccounts.GetActive() {
+				go fetchTotalBalance()
+			}
+			if !accountListed(abn.Account) {
+				go refreshAccounts()
+			}
+		})
 	}
-
 }
 
 // handleWalletLockStateNtfn handles btcwallet walletlockstate notifications
@@ -429,453 +227,373 @@ func handleWalletLockStateNtfn(n btcjson.Cmd) {
 		return
 	}
 
-	// TODO(jrick): do proper filtering and display all
-	// account balances somewhere
-	if wlsn.Account == "" {
-		updateChans.lockState <- wlsn.Locked
+	if wlsn.Account == currentAccount() {
+		walletEvents.Publish(LockStateChanged{wlsn.Locked})
 	}
 }
 
-// cmdGetNewAddress requests a new wallet address.
+// newAddress requests a new address for the selected account from the
+// active session.  Refilling an empty keypool requires an unlocked
+// wallet, which is asked for before any error is returned.
 //
-// TODO(jrick): support non-default accounts
-func cmdGetNewAddress(ws *websocket.Conn) {
-	var err error
-	defer func() {
-		if err != nil {
-
-		}
-	}()
-
-	n := <-NewJSONID
-	msg, err := btcjson.CreateMessageWithId("getnewaddress", n, "")
+// This is written to be run outside of the GTK main event loop.
+func newAddress() (string, error) {
+	b := activeBackend()
+	if b == nil {
+		return "", ErrConnectionLost
+	}
+	addr, err := b.GetNewAddress(currentAccount())
 	if err != nil {
-		triggerReplies.newAddr <- err
-		return
+		return "", walletError(err)
 	}
+	return addr, nil
+}
 
-	replyHandlers.Lock()
-	replyHandlers.m[n] = func(result interface{}, err *btcjson.Error) {
-		switch {
-		case err == nil:
-			if addr, ok := result.(string); ok {
-				triggerReplies.newAddr <- addr
-			}
-
-		case err.Code == btcjson.ErrWalletKeypoolRanOut.Code:
-			success := make(chan bool)
-			glib.IdleAdd(func() {
-				dialog, err := createUnlockDialog(unlockForKeypool, success)
-				if err != nil {
-					log.Print(err)
-					success <- false
-					return
-				}
-				dialog.Run()
-			})
-			if <-success {
-				triggers.newAddr <- 1
-			}
-
-		default: // all other non-nil errors
-			triggerReplies.newAddr <- errors.New(err.Message)
-		}
+// createWallet requests btcwallet to create a new wallet (or account),
+// encrypted with the supplied passphrase, and then requests all
+// wallet-related info again, now that the default wallet is available.
+//
+// This is written to be run outside of the GTK main event loop.
+func createWallet(params *NewWalletParams) error {
+	b := activeBackend()
+	if b == nil {
+		return ErrConnectionLost
 	}
-	replyHandlers.Unlock()
-
-	if err = ws.WriteMessage(websocket.TextMessage, msg); err != nil {
-		replyHandlers.Lock()
-		delete(replyHandlers.m, n)
-		replyHandlers.Unlock()
-		triggerReplies.newAddr <- err
+	if err := b.CreateEncryptedWallet(params.passphrase); err != nil {
+		return walletError(err)
 	}
+	b.Go(func() {
+		requestWalletState(b)
+	})
+	return nil
 }
 
-// cmdCreateEncryptedWallet requests btcwallet to create a new wallet
-// (or account), encrypted with the supplied passphrase.
-func cmdCreateEncryptedWallet(ws *websocket.Conn, params *NewWalletParams) {
-	n := <-NewJSONID
-	cmd := btcws.NewCreateEncryptedWalletCmd(n, params.passphrase)
-	msg, err := json.Marshal(cmd)
-	if err != nil {
-		triggerReplies.walletCreationErr <- err
-		return
+// lockWallet locks the currently-opened wallet.  The GUI will be updated
+// after a "btcwallet:newwalletlockstate" notification is sent.
+//
+// This is written to be run outside of the GTK main event loop.
+func lockWallet() error {
+	b := activeBackend()
+	if b == nil {
+		return ErrConnectionLost
 	}
+	return walletError(b.WalletLock())
+}
 
-	replyHandlers.Lock()
-	replyHandlers.m[n] = func(result interface{}, err *btcjson.Error) {
-		if err != nil {
-			triggerReplies.walletCreationErr <- errors.New(err.Message)
-		} else {
-			triggerReplies.walletCreationErr <- nil
+// unlockTimeout records when a wallet unlocked by btcgui will be locked
+// again by btcwallet.  A zero time indicates the wallet is locked.
+// session is set if the wallet was unlocked for a session, which lasts
+// until the wallet is locked by the user, by btcgui after the user is
+// idle, or when btcgui exits.
+var unlockTimeout struct {
+	sync.Mutex
+	expires time.Time
+	session bool
+}
 
-			// Request all wallet-related info again, now that the
-			// default wallet is available.
-			for _, f := range walletReqFuncs {
-				go f(ws)
-			}
-		}
+// setUnlockTimeout records that the wallet was unlocked for timeout
+// seconds, or that it was locked if timeout is zero.
+func setUnlockTimeout(timeout int64, session bool) {
+	unlockTimeout.Lock()
+	if timeout == 0 {
+		unlockTimeout.expires = time.Time{}
+		session = false
+	} else {
+		unlockTimeout.expires = time.Now().Add(time.Duration(timeout) * time.Second)
 	}
-	replyHandlers.Unlock()
+	unlockTimeout.session = session
+	unlockTimeout.Unlock()
+}
 
-	if err = ws.WriteMessage(websocket.TextMessage, msg); err != nil {
-		replyHandlers.Lock()
-		delete(replyHandlers.m, n)
-		replyHandlers.Unlock()
-		triggerReplies.walletCreationErr <- err
-	}
+// unlockActive returns whether the wallet was unlocked by btcgui and the
+// unlock timeout has not yet expired.
+func unlockActive() bool {
+	unlockTimeout.Lock()
+	defer unlockTimeout.Unlock()
+	return time.Now().Before(unlockTimeout.expires)
 }
 
-// cmdGetAddressesByAccount requests all addresses for an account.
+// unlockRemaining returns the seconds left of an unlock by btcgui, or
+// zero if the wallet was not unlocked by btcgui or the unlock expired,
+// and whether it is a session unlock.
+func unlockRemaining() (int64, bool) {
+	unlockTimeout.Lock()
+	defer unlockTimeout.Unlock()
+	remaining := int64(unlockTimeout.expires.Sub(time.Now()) / time.Second)
+	if remaining <= 0 {
+		return 0, false
+	}
+	return remaining, unlockTimeout.session
+}
+
+// sessionUnlockActive returns whether the wallet was unlocked by btcgui
+// for a session which has not yet ended.
+func sessionUnlockActive() bool {
+	unlockTimeout.Lock()
+	defer unlockTimeout.Unlock()
+	return unlockTimeout.session && time.Now().Before(unlockTimeout.expires)
+}
+
+// unlockWallet requests wallet to store the encryption passphrase for
+// the currently-opened wallet in memory for a given number of seconds.
 //
-// TODO(jrick): support non-default accounts.
-// TODO(jrick): stop throwing away errors.
-func cmdGetAddressesByAccount(ws *websocket.Conn) {
-	n := <-NewJSONID
-	msg, err := btcjson.CreateMessageWithId("getaddressesbyaccount", n, "")
-	if err != nil {
-		updateChans.addrs <- []string{}
+// This is written to be run outside of the GTK main event loop.
+func unlockWallet(params *UnlockParams) error {
+	if cfg.WatchOnly {
+		return ErrWatchOnly
 	}
-
-	replyHandlers.Lock()
-	replyHandlers.m[n] = func(result interface{}, err *btcjson.Error) {
-		if r, ok := result.([]interface{}); ok {
-			addrs := []string{}
-			for _, v := range r {
-				addrs = append(addrs, v.(string))
-			}
-			updateChans.addrs <- addrs
-		} else {
-			if err.Code == btcjson.ErrWalletInvalidAccountName.Code {
-				glib.IdleAdd(func() {
-					if dialog, err := createNewWalletDialog(); err != nil {
-						dialog.Run()
-					}
-				})
-			}
-			updateChans.addrs <- []string{}
-		}
+	b := activeBackend()
+	if b == nil {
+		return ErrConnectionLost
 	}
-	replyHandlers.Unlock()
-
-	if err = ws.WriteMessage(websocket.TextMessage, msg); err != nil {
-		replyHandlers.Lock()
-		delete(replyHandlers.m, n)
-		replyHandlers.Unlock()
-		updateChans.addrs <- []string{}
+	if err := b.WalletPassphrase(params.passphrase, params.timeout); err != nil {
+		return walletError(err)
 	}
+
+	// Activity in the unlock dialog is not seen by the main window, so
+	// start the idle time of a session unlock now.
+	noteUserActivity()
+	setUnlockTimeout(params.timeout, params.session)
+	return nil
 }
 
-// cmdGetBalance requests the current balance (calculated with the default
-// one confirmation).
-func cmdGetBalance(ws *websocket.Conn) {
-	n := <-NewJSONID
-	cmd, err := btcjson.NewGetBalanceCmd(n)
-	if err != nil {
-		log.Printf("[ERR] cannot create getbalance command.")
-		return
+// sendTx requests wallet to create a new transaction to one or more
+// recipients.  Any comments are recorded by the wallet with the
+// transaction.  Errors replied by btcwallet are returned as a
+// *btcjson.Error.
+//
+// This is written to be run outside of the GTK main event loop.
+func sendTx(params *SendParams) error {
+	b := activeBackend()
+	if b == nil {
+		return ErrConnectionLost
 	}
-	msg, _ := cmd.MarshalJSON()
-
-	replyHandlers.Lock()
-	replyHandlers.m[n] = func(result interface{}, err *btcjson.Error) {
-		if err != nil {
-			log.Printf("[ERR] getbalance: %v", err)
-			return
-		}
+	return sendMany(b, params)
+}
 
-		if result == nil {
-			return
-		}
+// sendMany sends the transaction described by params with b, saving the
+// labels of each recipient and recording it in the event journal.
+func sendMany(b WalletBackend, params *SendParams) error {
+	if cfg.WatchOnly {
+		return ErrWatchOnly
+	}
 
-		fbal, ok := result.(float64)
-		if !ok {
-			log.Printf("[ERR] getbalance reply is not a number.")
-			return
+	// Unlike sendtoaddress, sendmany has no comment-to parameter, so it
+	// is recorded as part of the comment instead.
+	comment := params.comment
+	if params.commentTo != "" {
+		if comment != "" {
+			comment += " "
 		}
-		bal, _ := btcutil.NewAmount(fbal)
-		updateChans.balance <- bal
+		comment += "(to: " + params.commentTo + ")"
 	}
-	replyHandlers.Unlock()
 
-	if err = ws.WriteMessage(websocket.TextMessage, msg); err != nil {
-		replyHandlers.Lock()
-		delete(replyHandlers.m, n)
-		replyHandlers.Unlock()
-		updateChans.addrs <- []string{}
+	txid, err := b.SendMany(params.account, params.pairs, comment)
+	if err != nil {
+		return err
 	}
+	saveTxLabels(txid, params.labels)
+	logEvent(eventSent, "%v", sendDetails(txid, params))
+	return nil
 }
 
-// cmdGetUnconfirmedBalance requests the current unconfirmed balance.
-func cmdGetUnconfirmedBalance(ws *websocket.Conn) {
-	n := <-NewJSONID
-	cmd, err := btcws.NewGetUnconfirmedBalanceCmd(n)
-	if err != nil {
-		log.Printf("[ERR] cannot create getunconfirmedbalance command.")
-		return
+// sendDetails describes a sent transaction for the event journal.
+func sendDetails(txid string, params *SendParams) string {
+	addrs := make([]string, 0, len(params.pairs))
+	for addr := range params.pairs {
+		addrs = append(addrs, addr)
 	}
-	msg, _ := cmd.MarshalJSON()
-
-	replyHandlers.Lock()
-	replyHandlers.m[n] = func(result interface{}, err *btcjson.Error) {
-		if err != nil {
-			log.Printf("[ERR] getunconfirmedbalance: %v", err)
-			return
-		}
-
-		if result == nil {
-			return
-		}
-
-		fbal, ok := result.(float64)
-		if !ok {
-			log.Printf("[ERR] getunconfirmedbalance reply is not a number.")
-			return
-		}
-		bal, _ := btcutil.NewAmount(fbal)
-		updateChans.unconfirmed <- bal
+	sort.Strings(addrs)
+	s := txid + ":"
+	if params.account != "" {
+		s = fmt.Sprintf("%s (from account %s):", txid, params.account)
 	}
-	replyHandlers.Unlock()
-
-	if err = ws.WriteMessage(websocket.TextMessage, msg); err != nil {
-		replyHandlers.Lock()
-		delete(replyHandlers.m, n)
-		replyHandlers.Unlock()
-		updateChans.addrs <- []string{}
+	for _, addr := range addrs {
+		amt, _ := btcutil.NewAmount(params.pairs[addr])
+		s += fmt.Sprintf(" %v to %v,", amt, addr)
 	}
+	return strings.TrimSuffix(s, ",")
 }
 
-// cmdGetBlockCount request the height of the best chain.
-func cmdGetBlockCount(ws *websocket.Conn) {
-	n := <-NewJSONID
-	cmd, err := btcjson.NewGetBlockCountCmd(n)
-	if err != nil {
-		log.Printf("[ERR] cannot create getblockcount command.")
-		return
-	}
-	mcmd, _ := cmd.MarshalJSON()
-
-	replyHandlers.Lock()
-	replyHandlers.m[n] = func(result interface{}, err *btcjson.Error) {
-		if err != nil {
-			log.Printf("[ERR] getblockcount: %v", err)
-			return
+// saveTxLabels saves the labels of each recipient of a sent
+// transaction to the metadata store and updates any rows of the
+// transaction view already showing the transaction.
+func saveTxLabels(txid string, labels map[string]string) {
+	nonEmpty := false
+	for _, label := range labels {
+		if label != "" {
+			nonEmpty = true
+			break
 		}
-
-		if result == nil {
-			return
-		}
-
-		fcount, ok := result.(float64)
-		if !ok {
-			log.Printf("[ERR] getblockcount result is not a number.")
-			return
-		}
-
-		updateChans.bcHeight <- int32(fcount)
 	}
-	replyHandlers.Unlock()
-
-	if err = ws.WriteMessage(websocket.TextMessage, mcmd); err != nil {
-		replyHandlers.Lock()
-		delete(replyHandlers.m, n)
-		replyHandlers.Unlock()
+	if !nonEmpty {
+		return
+	}
+	if err := metadata.SetTxLabels(txid, labels); err != nil {
+		log.Printf("[ERR] cannot save transaction labels: %v", err)
 	}
+	glib.IdleAdd(func() {
+		updateTxLabels(txid)
+	})
 }
 
-// cmdListAllTransactions requests all transactions for the default account.
+// setTxFee requests wallet to set the global transaction fee added to
+// newly-created transactions and awarded to the block miner who includes
+// the transaction.  Errors replied by btcwallet are returned as a
+// *btcjson.Error.
 //
-// TODO(jrick): support non-default accounts.
-func cmdListAllTransactions(ws *websocket.Conn) {
-	n := <-NewJSONID
-	cmd, err := btcws.NewListAllTransactionsCmd(n, "")
-	if err != nil {
-		log.Printf("[ERR] cannot create listalltransactions command.")
-		return
+// This is written to be run outside of the GTK main event loop.
+func setTxFee(feePerKB btcutil.Amount) error {
+	b := activeBackend()
+	if b == nil {
+		return ErrConnectionLost
 	}
-	mcmd, _ := cmd.MarshalJSON()
-
-	replyHandlers.Lock()
-	replyHandlers.m[n] = func(result interface{}, err *btcjson.Error) {
-		if err != nil {
-			log.Printf("[ERR] listtransactions: %v", err)
-			return
-		}
-
-		if result == nil {
-			return
-		}
-
-		vr, ok := result.([]interface{})
-		if !ok {
-			log.Printf("[ERR] listalltransactions reply is not an array.")
-			return
-		}
-		for i, r := range vr {
-			m, ok := r.(map[string]interface{})
-			if !ok {
-				log.Print("[ERR] listalltransactions: reply is not an array of JSON objects.")
-				return
-			}
-
-			txAttr, err := NewTxAttributesFromMap(m)
-			if err != nil {
-				log.Printf("[ERR] listalltransactions: %v", err)
-				return
-			}
-
-			updateChans.appendTx <- txAttr
-
-			if i < NOverviewTxs {
-				updateChans.appendOverviewTx <- txAttr
-			}
-		}
-	}
-	replyHandlers.Unlock()
-
-	if err = ws.WriteMessage(websocket.TextMessage, mcmd); err != nil {
-		replyHandlers.Lock()
-		delete(replyHandlers.m, n)
-		replyHandlers.Unlock()
+	if err := b.SetTxFee(feePerKB); err != nil {
+		return err
 	}
+	setTxFeeRate(feePerKB)
+	return nil
 }
 
-// cmdWalletIsLocked requests the current lock state of the
-// currently-opened wallet.
+// validateAddress requests details about an address from btcwallet,
+// including the redeem script of pay-to-script-hash addresses known by
+// the wallet.
 //
-// TODO(jrick): stop throwing away errors.
-func cmdWalletIsLocked(ws *websocket.Conn) {
-	n := <-NewJSONID
-	m := btcjson.Message{
-		Jsonrpc: "1.0",
-		Id:      n,
-		Method:  "walletislocked",
-		Params:  []interface{}{},
+// This is written to be run outside of the GTK main event loop.
+func validateAddress(addr string) (map[string]interface{}, error) {
+	b := activeBackend()
+	if b == nil {
+		return nil, ErrConnectionLost
 	}
-	msg, err := json.Marshal(&m)
+	details, err := b.ValidateAddress(addr)
 	if err != nil {
-		return
-	}
-
-	replyHandlers.Lock()
-	replyHandlers.m[n] = func(result interface{}, err *btcjson.Error) {
-		if r, ok := result.(bool); ok {
-			updateChans.lockState <- r
-		}
-	}
-	replyHandlers.Unlock()
-
-	if err = ws.WriteMessage(websocket.TextMessage, msg); err != nil {
-		replyHandlers.Lock()
-		delete(replyHandlers.m, n)
-		replyHandlers.Unlock()
-		// TODO(jrick): what to send here?
+		return nil, walletError(err)
 	}
+	return details, nil
 }
 
-// cmdWalletLock locks the currently-opened wallet.  A reply handler
-// is not set up because the GUI will be updated after a
-// "btcwallet:newwalletlockstate" notification is sent.
-func cmdWalletLock(ws *websocket.Conn) error {
-	n := <-NewJSONID
-	msg, err := btcjson.CreateMessageWithId("walletlock", n)
+// addrGroupings requests the groups of wallet addresses whose common
+// ownership has been made public by spending from several of them in a
+// single transaction.
+//
+// This is written to be run outside of the GTK main event loop.
+func addrGroupings() ([][]rpc.AddressGroupEntry, error) {
+	b := activeBackend()
+	if b == nil {
+		return nil, ErrConnectionLost
+	}
+	groups, err := b.ListAddressGroupings()
 	if err != nil {
-		return err
+		return nil, walletError(err)
 	}
-
-	// We don't actually care about the reply and rely on the
-	// notification to update widgets, but adding an empty handler
-	// prevents warnings printed to logging output.
-	replyHandlers.Lock()
-	replyHandlers.m[n] = func(result interface{}, err *btcjson.Error) {}
-	replyHandlers.Unlock()
-
-	return ws.WriteMessage(websocket.TextMessage, msg)
+	return groups, nil
 }
 
-// cmdWalletPassphrase requests wallet to store the encryption
-// passphrase for the currently-opened wallet in memory for a given
-// number of seconds.
-func cmdWalletPassphrase(ws *websocket.Conn, params *UnlockParams) error {
-	n := <-NewJSONID
-	m := btcjson.Message{
-		Jsonrpc: "1.0",
-		Id:      n,
-		Method:  "walletpassphrase",
-		Params: []interface{}{
-			params.passphrase,
-			params.timeout,
-		},
+// importKeys imports each private key and address of params into the
+// wallet of b, one at a time, sending the result of each import to
+// params.results, which is closed once done.  Rescanning the blockchain
+// after every import would scan it once per entry, so only the last
+// import rescans, finding the transactions of every entry at once.
+// btcwallet has no separate rescan request, so if the last import fails,
+// the blockchain is not rescanned at all.  Once done, the wallet
+// addresses are requested again to show the imported addresses.  A nil b
+// fails every import as the connection is lost.
+//
+// This is written to be run as a goroutine executing outside of the GTK
+// main event loop.
+func importKeys(b WalletBackend, params *ImportParams) {
+	defer close(params.results)
+	if b == nil {
+		for _ = range params.entries {
+			params.results <- ErrConnectionLost
+		}
+		return
 	}
-	msg, _ := json.Marshal(&m)
+	for i, e := range params.entries {
+		if cfg.WatchOnly && e.isKey {
+			params.results <- ErrWatchOnly
+			continue
+		}
 
-	replyHandlers.Lock()
-	replyHandlers.m[n] = func(result interface{}, err *btcjson.Error) {
-		triggerReplies.unlockSuccessful <- err == nil
+		rescan := i == len(params.entries)-1
+		var err error
+		if e.isKey {
+			err = b.ImportPrivKey(e.key, e.label, rescan)
+		} else {
+			err = b.ImportAddress(e.key, e.label, rescan)
+		}
+		jsonErr, ok := err.(*btcjson.Error)
+		if ok && !e.isKey && jsonErr.Code == btcjson.ErrMethodNotFound.Code {
+			err = ErrImportAddrUnsupported
+		}
+		params.results <- walletError(err)
 	}
-	replyHandlers.Unlock()
-
-	return ws.WriteMessage(websocket.TextMessage, msg)
+	fetchAddresses(b)
 }
 
-// cmdSendMany requests wallet to create a new transaction to one or
-// more recipients.
+// exportSeed checks the wallet passphrase by unlocking the wallet of b
+// with it and then requests the wallet seed.  btcwallet is first asked
+// for the seed before unlocking, as a locked wallet refuses the request
+// as locked rather than as unknown, so the wallet is never unlocked for a
+// btcwallet which can not export the seed.  The unlock state is
+// restored afterwards: a wallet which was locked is locked again, while
+// an unlock by btcgui still active is renewed for its remaining time.
 //
-// TODO(jrick): support non-default accounts
-func cmdSendMany(ws *websocket.Conn, pairs map[string]float64) error {
-	n := <-NewJSONID
-	m := btcjson.Message{
-		Jsonrpc: "1.0",
-		Id:      n,
-		Method:  "sendmany",
-		Params: []interface{}{
-			"",
-			pairs,
-		},
+// This is written to be run outside of the GTK main event loop.
+func exportSeed(b WalletBackend, passphrase string) (string, error) {
+	if cfg.WatchOnly {
+		return "", ErrWatchOnly
 	}
-	msg, err := json.Marshal(m)
-	if err != nil {
-		log.Print(err.Error())
-		return err
+	if b == nil {
+		return "", ErrConnectionLost
 	}
 
-	replyHandlers.Lock()
-	replyHandlers.m[n] = func(result interface{}, err *btcjson.Error) {
-		if err != nil {
-			triggerReplies.sendTx <- err
-		} else {
-			// success
-			triggerReplies.sendTx <- nil
-		}
+	seed, err := requestSeed(b)
+	wasLocked := false
+	if jsonErr, ok := err.(*btcjson.Error); ok &&
+		jsonErr.Code == btcjson.ErrWalletUnlockNeeded.Code {
+		wasLocked = true
+	} else if err != nil {
+		return "", walletError(err)
 	}
-	replyHandlers.Unlock()
 
-	return ws.WriteMessage(websocket.TextMessage, msg)
-}
+	remaining, session := unlockRemaining()
+	timeout := int64(seedUnlockTimeout / time.Second)
+	if !wasLocked && remaining > 0 {
+		timeout = remaining
+	} else {
+		session = false
+	}
+	if err := b.WalletPassphrase(passphrase, timeout); err != nil {
+		return "", walletError(err)
+	}
+	setUnlockTimeout(timeout, session)
+	if !wasLocked {
+		return seed, nil
+	}
 
-// cmdSetTxFee requests wallet to set the global transaction fee added
-// to newly-created transactions and awarded to the block miner who
-// includes the transaction.
-func cmdSetTxFee(ws *websocket.Conn, fee float64) error {
-	n := <-NewJSONID
-	msg, err := btcjson.CreateMessageWithId("settxfee", n, fee)
+	seed, err = requestSeed(b)
+	if lerr := b.WalletLock(); lerr != nil {
+		log.Printf("[ERR] cannot lock wallet after exporting "+
+			"seed: %v", lerr)
+	} else {
+		setUnlockTimeout(0, false)
+	}
 	if err != nil {
-		triggerReplies.setTxFeeErr <- err
-		return err // TODO(jrick): this gets thrown away so just send via triggerReplies.
+		return "", walletError(err)
 	}
+	return seed, nil
+}
 
-	replyHandlers.Lock()
-	replyHandlers.m[n] = func(result interface{}, err *btcjson.Error) {
-		if err != nil {
-			triggerReplies.setTxFeeErr <- err
-		} else {
-			// success
-			triggerReplies.setTxFeeErr <- nil
-		}
+// requestSeed requests the wallet seed from b, returning
+// ErrSeedUnsupported if btcwallet can not export it.
+func requestSeed(b WalletBackend) (string, error) {
+	seed, err := b.ExportWalletSeed()
+	if jsonErr, ok := err.(*btcjson.Error); ok &&
+		jsonErr.Code == btcjson.ErrMethodNotFound.Code {
+		return "", ErrSeedUnsupported
 	}
-	replyHandlers.Unlock()
-
-	return ws.WriteMessage(websocket.TextMessage, msg)
+	return seed, err
 }
 
 // strSliceEqual checks if each string in a is equal to each string in b.
@@ -893,15 +611,17 @@ func strSliceEqual(a, b []string) bool {
 
 // updateConnectionState listens for connection status changes to btcd
 // and btcwallet, updating the GUI when necessary.
-func updateConnectionState() {
+func updateConnectionState(events <-chan Event) {
 	// Statusbar messages for various connection states.
 	btcdd := "Disconnected from btcd"
 	btcwc := "Established connection to btcwallet"
-	btcwd := "Disconnected from btcwallet.  Attempting reconnect..."
+	btcwd := "Disconnected from btcwallet"
 
-	for {
-		select {
-		case conn := <-updateChans.btcwalletConnected:
+	for e := range events {
+		ev := e.(ConnStateChanged)
+		conn := ev.Connected
+		switch {
+		case !ev.Btcd:
 			if conn {
 				glib.IdleAdd(func() {
 					//MenuBar.Settings.New.SetSensitive(true)
@@ -909,7 +629,9 @@ func updateConnectionState() {
 					MenuBar.Settings.TxFee.SetSensitive(true)
 					// Lock/Unlock sensitivity is set by wallet notification.
 					RecvCoins.NewAddrBtn.SetSensitive(true)
-					StatusElems.Lab.SetText(btcwc)
+					RecvCoins.PregenBtn.SetSensitive(true)
+					setWalletReachable(true)
+					setStatus(btcwc)
 					StatusElems.Pb.Hide()
 				})
 			} else {
@@ -918,22 +640,32 @@ func updateConnectionState() {
 					//MenuBar.Settings.Encrypt.SetSensitive(false)
 					MenuBar.Settings.Lock.SetSensitive(false)
 					MenuBar.Settings.Unlock.SetSensitive(false)
+					setQuickUnlockShown(false)
 					MenuBar.Settings.TxFee.SetSensitive(false)
-					SendCoins.SendBtn.SetSensitive(false)
 					RecvCoins.NewAddrBtn.SetSensitive(false)
-					StatusElems.Lab.SetText(btcwd)
+					RecvCoins.PregenBtn.SetSensitive(false)
+					setWalletReachable(false)
+					setStatus(btcwd)
 					StatusElems.Pb.Hide()
+
+					// Whether btcd is connected is unknown
+					// until btcwallet is reached again.
+					showBtcdInfoBar(false)
 				})
 			}
-		case conn := <-updateChans.btcdConnected:
-			if conn {
+		case ev.Btcd:
+			glib.IdleAdd(func() {
+				showBtcdInfoBar(!conn)
+			})
+			if conn && !cfg.WatchOnly {
 				glib.IdleAdd(func() {
 					SendCoins.SendBtn.SetSensitive(true)
+					showQueuedSends()
 				})
-			} else {
+			} else if !conn {
 				glib.IdleAdd(func() {
 					SendCoins.SendBtn.SetSensitive(false)
-					StatusElems.Lab.SetText(btcdd)
+					setStatus(btcdd)
 					StatusElems.Pb.Hide()
 				})
 			}
@@ -943,141 +675,217 @@ func updateConnectionState() {
 
 // updateAddresses listens for new wallet addresses, updating the GUI when
 // necessary.
-func updateAddresses() {
-	for {
-		addrs := <-updateChans.addrs
+func updateAddresses(events <-chan Event) {
+	for e := range events {
+		addrs := e.(AddressesChanged).Addrs
 		glib.IdleAdd(func() {
 			RecvCoins.Store.Clear()
+			ownAddrs = make(map[string]struct{}, len(addrs))
+			setOwnAddresses(addrs)
 		})
 		for i := range addrs {
 			addr := addrs[i]
 			glib.IdleAdd(func() {
-				iter := RecvCoins.Store.Append()
-				RecvCoins.Store.Set(iter, []int{1},
-					[]interface{}{addr})
+				appendRecvAddr(addr, "")
 			})
 		}
+		glib.IdleAdd(func() {
+			if RecvCoins.ShowChange.GetActive() {
+				go showChangeAddrs()
+			}
+		})
+		go refreshUnusedAddrs()
 	}
 }
 
-// updateBalance listens for new wallet account balances, updating the GUI
-// when necessary.
-func updateBalance() {
-	for {
-		balance, ok := <-updateChans.balance
-		if !ok {
-			return
+// updateHealth periodically updates the statusbar indicator showing the
+// average round-trip time of btcwallet requests, logging a warning when
+// the connection becomes slow or requests begin failing.
+func updateHealth() {
+	var last connHealth
+	for _ = range time.Tick(2 * time.Second) {
+		info := connDetails()
+		health := info.health()
+		if health != last {
+			var msg string
+			switch health {
+			case healthSlow:
+				msg = fmt.Sprintf("btcwallet is responding slowly "+
+					"(average %v)", info.avgRTT)
+			case healthErrors:
+				msg = fmt.Sprintf("%.0f%% of recent btcwallet "+
+					"requests failed", info.errorRate()*100)
+			}
+			if msg != "" {
+				log.Printf("[WRN] %s", msg)
+				glib.IdleAdd(func() {
+					recordStatus(msg)
+				})
+			}
+			last = health
+		}
+
+		var color, tooltip string
+		switch health {
+		case healthGood:
+			color = "darkgreen"
+			tooltip = "Connection to btcwallet is healthy"
+		case healthSlow:
+			color = "orange"
+			tooltip = "btcwallet is responding slowly"
+		case healthErrors:
+			color = "red"
+			tooltip = fmt.Sprintf("%.0f%% of recent requests failed",
+				info.errorRate()*100)
+		}
+		var markup string
+		if color != "" {
+			rtt := info.avgRTT - info.avgRTT%time.Millisecond
+			markup = fmt.Sprintf(`<span foreground="%s">●</span> %v`,
+				color, rtt)
 		}
-		balStr := balance.String()
 		glib.IdleAdd(func() {
-			Overview.Balance.SetMarkup("<b>" + balStr + "</b>")
-			SendCoins.Balance.SetText("Balance: " + balStr)
+			StatusElems.Health.SetMarkup(markup)
+			StatusElems.Health.SetTooltipText(tooltip)
 		})
 	}
 }
 
-// updateBalance listens for new wallet account unconfirmed balances, updating
-// the GUI when necessary.
-func updateUnconfirmed() {
-	for {
-		unconfirmed, ok := <-updateChans.unconfirmed
-		if !ok {
-			return
+// updateBalances listens for new balances of the selected account,
+// updating the GUI when necessary.
+func updateBalances(events <-chan Event) {
+	for e := range events {
+		ev := e.(BalanceChanged)
+		amount := ev.Amount
+		balStr := formatAmount(amount)
+		switch ev.Kind {
+		case BalanceConfirmed:
+			_, selected := walletList()
+			glib.IdleAdd(func() {
+				setWalletBalances(selected, &amount, nil, nil)
+				defaultBalance = balStr
+				showOverviewBalance()
+				spendable.amount = amount
+				spendable.known = true
+				SendCoins.Balance.SetText("Balance: " + balStr)
+				setTitleBalance(balStr)
+			})
+
+		case BalanceUnconfirmed:
+			_, selected := walletList()
+			glib.IdleAdd(func() {
+				setWalletBalances(selected, nil, &amount, nil)
+				Overview.Unconfirmed.SetMarkup("<b>" + balStr + "</b>")
+			})
+
+		case BalanceImmature:
+			glib.IdleAdd(func() {
+				Overview.Immature.SetMarkup("<b>" + balStr + "</b>")
+			})
+
+		case BalanceLocked:
+			glib.IdleAdd(func() {
+				Overview.Locked.SetMarkup("<b>" + balStr + "</b>")
+			})
 		}
-		balStr := "<b>" + unconfirmed.String() + "</b>"
-		glib.IdleAdd(func() {
-			Overview.Unconfirmed.SetMarkup(balStr)
-		})
 	}
 }
 
 // updateLockState updates the application widgets due to a change in
 // the currently-open wallet's lock state.
-func updateLockState() {
-	for {
-		locked, ok := <-updateChans.lockState
-		if !ok {
-			return
+func updateLockState(events <-chan Event) {
+	// Only changes of the lock state are recorded in the event journal,
+	// not the state first reported after connecting.
+	var known, wasLocked bool
+	for e := range events {
+		locked := e.(LockStateChanged).Locked
+		if locked {
+			setUnlockTimeout(0, false)
+		}
+		if known && locked != wasLocked {
+			if locked {
+				logEvent(eventLocked, "Wallet locked")
+			} else {
+				logEvent(eventUnlocked, "Wallet unlocked")
+			}
+		}
+		known, wasLocked = true, locked
+
+		if cfg.WatchOnly {
+			// The wallet is never unlocked in watch-only mode,
+			// so keep both menu items insensitive.
+			continue
 		}
 
 		if locked {
 			glib.IdleAdd(func() {
 				MenuBar.Settings.Lock.SetSensitive(false)
 				MenuBar.Settings.Unlock.SetSensitive(true)
+				setQuickUnlockShown(true)
 			})
 		} else {
 			glib.IdleAdd(func() {
 				MenuBar.Settings.Lock.SetSensitive(true)
 				MenuBar.Settings.Unlock.SetSensitive(false)
+				setQuickUnlockShown(false)
 			})
 		}
 	}
 }
 
-// XXX spilt this?
-func updateProgress() {
-	for {
-		bcHeight, ok := <-updateChans.bcHeight
-		if !ok {
-			return
-		}
-
-		// TODO(jrick) this can go back when remote height is updated.
-		/*
-			bcHeightRemote, ok := <-updateChans.bcHeightRemote
-			if !ok {
-				return
-			}
-
-			if bcHeight >= 0 && bcHeightRemote >= 0 {
-				percentDone := float64(bcHeight) / float64(bcHeightRemote)
-				if percentDone < 1 {
-					s := fmt.Sprintf("%d of ~%d blocks", bcHeight,
-						bcHeightRemote)
-					glib.IdleAdd(StatusElems.Lab.SetText,
-						"Updating blockchain...")
-					glib.IdleAdd(StatusElems.Pb.SetText, s)
-					glib.IdleAdd(StatusElems.Pb.SetFraction, percentDone)
-					glib.IdleAdd(StatusElems.Pb.Show)
-				} else {
-					s := fmt.Sprintf("%d blocks", bcHeight)
-					glib.IdleAdd(StatusElems.Lab.SetText, s)
-					glib.IdleAdd(StatusElems.Pb.Hide)
-				}
-			} else if bcHeight >= 0 && bcHeightRemote == -1 {
-				s := fmt.Sprintf("%d blocks", bcHeight)
-				glib.IdleAdd(StatusElems.Lab.SetText, s)
-				glib.IdleAdd(StatusElems.Pb.Hide)
-			} else {
-				glib.IdleAdd(StatusElems.Lab.SetText,
-					"Error getting blockchain height")
-				glib.IdleAdd(StatusElems.Pb.Hide)
-			}
-		*/
-
-		s := fmt.Sprintf("%d blocks", bcHeight)
+// updateProgress listens for new heights of the best chain, updating
+// the statusbar and transaction confirmations.
+func updateProgress(events <-chan Event) {
+	for e := range events {
+		height := e.(BlockHeightChanged).Height
+		s := fmt.Sprintf("%d blocks", height)
 		glib.IdleAdd(func() {
-			StatusElems.Lab.SetText(s)
-			StatusElems.Pb.Hide()
+			// The progress bar shows the time of the newest
+			// block while catching up, and is hidden otherwise.
+			setStatus(s)
+			updateTxConfirmations(height)
+			checkStuckTxs(height)
 		})
 	}
 }
 
-func updateTransactions() {
-	for {
-		select {
-		case attr := <-updateChans.appendTx:
+// updateTransactions listens for transactions to show, adding them to
+// the transaction list, and for shown transactions since mined, updating
+// their rows.
+func updateTransactions(events <-chan Event) {
+	for e := range events {
+		switch ev := e.(type) {
+		case TxAppended:
+			attr := ev.Tx
+			if ev.New {
+				glib.IdleAdd(func() {
+					setTxRow(txWidgets.store.Prepend(), attr)
+				})
+			} else {
+				glib.IdleAdd(func() {
+					setTxRow(txWidgets.store.Append(), attr)
+				})
+			}
+
+		case TxMined:
+			attr := ev.Tx
 			glib.IdleAdd(func() {
-				iter := txWidgets.store.Append()
-				const layout = "01/02/2006"
-				txWidgets.store.Set(iter, []int{0, 1, 2, 3},
-					[]interface{}{attr.Date.Format(layout),
-						attr.Direction.String(),
-						attr.Address,
-						attr.Amount.String()})
+				updateTxRows(attr)
 			})
+		}
+	}
+}
 
-		case attr := <-updateChans.appendOverviewTx:
+// updateOverviewTxs listens for transactions to show, adding the most
+// recent ones to the Overview.
+func updateOverviewTxs(events <-chan Event) {
+	for e := range events {
+		ev := e.(TxAppended)
+		if !ev.Overview {
+			continue
+		}
+		attr := ev.Tx
+		if ev.New {
 			glib.IdleAdd(func() {
 				txLabel, err := createTxLabel(attr)
 				if err != nil {
@@ -1086,32 +894,21 @@ func updateTransactions() {
 				}
 
 				if len(Overview.TxList) == NOverviewTxs {
-					first := Overview.TxList[0]
-					copy(Overview.TxList, Overview.TxList[1:])
-					Overview.TxList[NOverviewTxs-1] = txLabel
-					Overview.Txs.Remove(first)
-					first.Destroy()
+					last := Overview.TxList[NOverviewTxs-1]
+					copy(Overview.TxList[1:], Overview.TxList)
+					Overview.TxList[0] = txLabel
+					Overview.Txs.Remove(last)
+					last.Destroy()
 				} else {
 					Overview.TxList = append(Overview.TxList, txLabel)
 				}
 
-				Overview.Txs.Add(txLabel)
+				Overview.Txs.InsertRow(0)
+				Overview.Txs.Attach(txLabel, 0, 0, 1, 1)
 
 				txLabel.ShowAll()
 			})
-
-		case attr := <-updateChans.prependTx:
-			glib.IdleAdd(func() {
-				iter := txWidgets.store.Prepend()
-				const layout = "01/02/2006"
-				txWidgets.store.Set(iter, []int{0, 1, 2, 3},
-					[]interface{}{attr.Date.Format(layout),
-						attr.Direction.String(),
-						attr.Address,
-						attr.Amount.String()})
-			})
-
-		case attr := <-updateChans.prependOverviewTx:
+		} else {
 			glib.IdleAdd(func() {
 				txLabel, err := createTxLabel(attr)
 				if err != nil {
@@ -1120,17 +917,16 @@ func updateTransactions() {
 				}
 
 				if len(Overview.TxList) == NOverviewTxs {
-					last := Overview.TxList[NOverviewTxs-1]
-					copy(Overview.TxList[1:], Overview.TxList)
-					Overview.TxList[0] = txLabel
-					Overview.Txs.Remove(last)
-					last.Destroy()
+					first := Overview.TxList[0]
+					copy(Overview.TxList, Overview.TxList[1:])
+					Overview.TxList[NOverviewTxs-1] = txLabel
+					Overview.Txs.Remove(first)
+					first.Destroy()
 				} else {
 					Overview.TxList = append(Overview.TxList, txLabel)
 				}
 
-				Overview.Txs.InsertRow(0)
-				Overview.Txs.Attach(txLabel, 0, 0, 1, 1)
+				Overview.Txs.Add(txLabel)
 
 				txLabel.ShowAll()
 			})
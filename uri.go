@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// bitcoinURIScheme is the scheme of BIP0021 payment URIs.
+const bitcoinURIScheme = "bitcoin"
+
+// bitcoinURI is a parsed BIP0021 bitcoin: payment URI.
+type bitcoinURI struct {
+	address string
+	amount  float64
+	label   string
+	message string
+}
+
+// String returns the bitcoin: URI for a payment.  Empty parameters and a
+// zero amount are omitted.
+func (u *bitcoinURI) String() string {
+	var params []string
+	if u.amount > 0 {
+		params = append(params, "amount="+
+			strconv.FormatFloat(u.amount, 'f', -1, 64))
+	}
+	if u.label != "" {
+		params = append(params, "label="+uriEscape(u.label))
+	}
+	if u.message != "" {
+		params = append(params, "message="+uriEscape(u.message))
+	}
+	s := bitcoinURIScheme + ":" + u.address
+	if len(params) != 0 {
+		s += "?" + strings.Join(params, "&")
+	}
+	return s
+}
+
+// uriEscape percent-encodes a URI parameter value.  Spaces are encoded
+// as %20 rather than +, which BIP0021 does not treat as a space.
+func uriEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+// isBitcoinURI returns whether s appears to be a bitcoin: URI.
+func isBitcoinURI(s string) bool {
+	return strings.HasPrefix(strings.ToLower(s), bitcoinURIScheme+":")
+}
+
+// parseBitcoinURI parses a bitcoin: payment URI.  As required by
+// BIP0021, URIs with unknown parameters prefixed by "req-" are rejected.
+func parseBitcoinURI(s string) (*bitcoinURI, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ToLower(u.Scheme) != bitcoinURIScheme {
+		return nil, fmt.Errorf("'%v' is not a bitcoin URI", s)
+	}
+
+	// bitcoin:address is opaque, but bitcoin://address is also seen.
+	addr := u.Opaque
+	if addr == "" {
+		addr = u.Host
+	}
+	if addr == "" {
+		return nil, errors.New("bitcoin URI does not include an address")
+	}
+
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, err
+	}
+	ret := &bitcoinURI{
+		address: addr,
+		label:   q.Get("label"),
+		message: q.Get("message"),
+	}
+	if amt := q.Get("amount"); amt != "" {
+		ret.amount, err = strconv.ParseFloat(amt, 64)
+		if err != nil || ret.amount < 0 {
+			return nil, fmt.Errorf("invalid amount '%v'", amt)
+		}
+	}
+	for k := range q {
+		if strings.HasPrefix(k, "req-") {
+			return nil, fmt.Errorf("unsupported required parameter '%v'", k)
+		}
+	}
+	return ret, nil
+}
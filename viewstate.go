@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/gotk3/gdk"
+	"log"
+)
+
+// Key values of the digit keys used by the tab switching shortcuts.
+const (
+	key1 = 0x031
+	key9 = 0x039
+)
+
+// viewState is the state of the main window restored on the next launch:
+// the active notebook page, the transaction filters, and the optional
+// transaction columns the user chose to show or hide.
+type viewState struct {
+	Page          int             `json:"page"`
+	TxCategory    int             `json:"txcategory"`
+	TxSearch      string          `json:"txsearch"`
+	TxPendingOnly bool            `json:"txpendingonly"`
+	TxAddress     string          `json:"txaddress"`
+	TxTag         string          `json:"txtag"`
+	TxColumns     map[string]bool `json:"txcolumns,omitempty"`
+}
+
+// lastViewState is the view state as of the last page switch or filter
+// change, saved when the application exits.  This must only be accessed
+// from the GTK main event loop.
+var lastViewState viewState
+
+// noteViewState records the current view state to be saved on exit.
+//
+// This must be run from the GTK main event loop.
+func noteViewState() {
+	if mainNotebook == nil || txWidgets.search == nil {
+		return
+	}
+	search, _ := txWidgets.search.GetText()
+	lastViewState = viewState{
+		Page:          mainNotebook.GetCurrentPage(),
+		TxCategory:    txWidgets.category.GetActive(),
+		TxSearch:      search,
+		TxPendingOnly: txWidgets.pendingOnly.GetActive(),
+		TxAddress:     txWidgets.address,
+		TxTag:         txWidgets.tag,
+		TxColumns:     txColumnsCopy(),
+	}
+}
+
+// saveViewState saves the last view state to the metadata store.  Nothing
+// is saved if the main window was never created, so the state of the
+// previous launch is kept.
+func saveViewState() {
+	if mainNotebook == nil {
+		return
+	}
+	if err := metadata.SetViewState(lastViewState); err != nil {
+		log.Printf("[ERR] cannot save window state: %v", err)
+	}
+}
+
+// restoreViewState shows the page and transaction filters of the view
+// state saved by the last launch.  Pages which no longer exist, such as
+// the address book when it has since been disabled, are not restored.
+//
+// This must be run from the GTK main event loop.
+func restoreViewState() {
+	st := metadata.ViewState()
+	if st.TxCategory > 0 && st.TxCategory < len(txCategories) {
+		txWidgets.category.SetActive(st.TxCategory)
+	}
+	txWidgets.search.SetText(st.TxSearch)
+	txWidgets.pendingOnly.SetActive(st.TxPendingOnly)
+	if st.TxAddress != "" {
+		setTxAddressFilter(st.TxAddress)
+	}
+	if st.TxTag != "" {
+		setTxTagFilter(st.TxTag)
+	}
+	setTxColumns(st.TxColumns)
+	if st.Page > 0 && st.Page < mainNotebook.GetNPages() &&
+		!(cfg.WatchOnly && st.Page == sendCoinsPage) {
+		mainNotebook.SetCurrentPage(st.Page)
+	}
+	noteViewState()
+}
+
+// switchPageShortcut switches to the notebook page of a Ctrl+1 through
+// Ctrl+9 key press, returning whether the key press was handled.
+//
+// This must be run from the GTK main event loop.
+func switchPageShortcut(ev *gdk.EventKey) bool {
+	if gdk.ModifierType(ev.State())&gdk.CONTROL_MASK == 0 {
+		return false
+	}
+	key := ev.KeyVal()
+	if key < key1 || key > key9 {
+		return false
+	}
+	page := int(key - key1)
+	if page >= mainNotebook.GetNPages() ||
+		(cfg.WatchOnly && page == sendCoinsPage) {
+		return false
+	}
+	mainNotebook.SetCurrentPage(page)
+	return true
+}
@@ -0,0 +1,326 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/conformal/btcgui/rpc"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"github.com/conformal/gotk3/gtk"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultWalletName is the name of the rpcconnect wallet when the
+// walletname option is unset.
+const defaultWalletName = "Default"
+
+// walletEndpoint is a btcwallet shown by btcgui.  Every wallet is
+// connected to with the same username, password and CA file.
+type walletEndpoint struct {
+	name string
+	addr string
+}
+
+// String returns the name and endpoint of w.
+func (w walletEndpoint) String() string {
+	return fmt.Sprintf("%s (%s)", w.name, w.addr)
+}
+
+// parseWalletOption parses a wallet option of the form name=endpoint.
+func parseWalletOption(s string) (walletEndpoint, error) {
+	i := strings.Index(s, "=")
+	if i == -1 || strings.TrimSpace(s[:i]) == "" ||
+		strings.TrimSpace(s[i+1:]) == "" {
+		return walletEndpoint{}, fmt.Errorf("The wallet option '%s' "+
+			"is not of the form name=host:port", s)
+	}
+	return walletEndpoint{
+		name: strings.TrimSpace(s[:i]),
+		addr: strings.TrimSpace(s[i+1:]),
+	}, nil
+}
+
+// wallets holds every configured wallet, starting with the rpcconnect
+// wallet.  The selected wallet is the one connected to for sending and
+// every tab but the Overview, while the balances of all others are
+// polled for the Overview breakdown.
+var wallets struct {
+	sync.Mutex
+	list     []walletEndpoint
+	selected int
+}
+
+// initWallets sets the configured wallets from cfg, selecting the
+// rpcconnect wallet.
+func initWallets() {
+	list := []walletEndpoint{{name: cfg.WalletName, addr: cfg.RPCConnect}}
+	for _, opt := range cfg.Wallets {
+		if w, err := parseWalletOption(opt); err == nil {
+			list = append(list, w)
+		}
+	}
+	wallets.Lock()
+	wallets.list = list
+	wallets.selected = 0
+	wallets.Unlock()
+}
+
+// walletList returns every configured wallet and the index of the
+// selected wallet.
+func walletList() ([]walletEndpoint, int) {
+	wallets.Lock()
+	defer wallets.Unlock()
+	return wallets.list, wallets.selected
+}
+
+// selectedWallet returns the wallet connected to for sending.
+func selectedWallet() walletEndpoint {
+	wallets.Lock()
+	defer wallets.Unlock()
+	return wallets.list[wallets.selected]
+}
+
+// selectWallet selects the wallet at index i of the configured wallets,
+// closing the connection to the previously selected wallet so the
+// selected wallet is connected to next.  The state kept about the
+// previous wallet is forgotten when connecting to the selected wallet.
+func selectWallet(i int) {
+	wallets.Lock()
+	if i == wallets.selected || i < 0 || i >= len(wallets.list) {
+		wallets.Unlock()
+		return
+	}
+	wallets.selected = i
+	wallets.Unlock()
+
+	c := activeClient()
+	if c != nil {
+		c.Close()
+	}
+}
+
+// walletRow holds the labels of a wallet in the Overview breakdown and
+// the last balances shown, or nil if unknown.
+type walletRow struct {
+	balance     *gtk.Label
+	unconfirmed *gtk.Label
+	status      *gtk.Label
+	bal         *btcutil.Amount
+	unconf      *btcutil.Amount
+}
+
+// walletBreakdown holds the rows of the Overview breakdown, one for each
+// configured wallet, and the label of their combined total.  It is empty
+// if only one wallet is configured.  This must only be accessed from the
+// GTK main event loop.
+var walletBreakdown struct {
+	rows  []*walletRow
+	total *gtk.Label
+}
+
+// createWalletBreakdown creates the Overview breakdown of the balances of
+// each configured wallet and their combined total, or returns nil if
+// there is only one wallet.
+func createWalletBreakdown() *gtk.Widget {
+	list, _ := walletList()
+	if len(list) < 2 {
+		return nil
+	}
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	grid.SetColumnSpacing(12)
+
+	header, err := gtk.LabelNew("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	header.SetMarkup("<b>All Wallets</b>")
+	header.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(header, 0, 0, 4, 1)
+
+	newLabel := func(text string, col, row int) *gtk.Label {
+		l, err := gtk.LabelNew(text)
+		if err != nil {
+			log.Fatal(err)
+		}
+		l.SetHAlign(gtk.ALIGN_START)
+		grid.Attach(l, col, row, 1, 1)
+		return l
+	}
+	newLabel("Wallet", 0, 1)
+	newLabel("Spendable", 1, 1)
+	newLabel("Unconfirmed", 2, 1)
+	newLabel("Status", 3, 1)
+
+	walletBreakdown.rows = make([]*walletRow, len(list))
+	for i, w := range list {
+		name := newLabel(w.name, 0, i+2)
+		name.SetTooltipText(w.addr)
+		walletBreakdown.rows[i] = &walletRow{
+			balance:     newLabel("", 1, i+2),
+			unconfirmed: newLabel("", 2, i+2),
+			status:      newLabel("Connecting...", 3, i+2),
+		}
+	}
+	newLabel("Combined", 0, len(list)+2)
+	walletBreakdown.total = newLabel("", 1, len(list)+2)
+
+	return &grid.Container.Widget
+}
+
+// setWalletBalances shows the balance and unconfirmed balance of the
+// wallet at index i of the configured wallets in the Overview breakdown,
+// or err if they could not be requested.  Nil balances are left
+// unchanged.
+//
+// This must be run from the GTK main event loop.
+func setWalletBalances(i int, bal, unconf *btcutil.Amount, err error) {
+	if i >= len(walletBreakdown.rows) {
+		return
+	}
+	row := walletBreakdown.rows[i]
+	if err != nil {
+		row.status.SetText(err.Error())
+		return
+	}
+	row.status.SetText("Connected")
+	if bal != nil {
+		row.bal = bal
+		row.balance.SetText(formatAmount(*bal))
+	}
+	if unconf != nil {
+		row.unconf = unconf
+		row.unconfirmed.SetText(formatAmount(*unconf))
+	}
+
+	var total btcutil.Amount
+	for _, r := range walletBreakdown.rows {
+		if r.bal != nil {
+			total += *r.bal
+		}
+	}
+	walletBreakdown.total.SetMarkup("<b>" + formatAmount(total) + "</b>")
+}
+
+// monitorWallets polls the balances of every configured wallet but the
+// selected wallet every httpPollInterval with HTTP POST requests, showing
+// them in the Overview breakdown.  It does nothing if only one wallet is
+// configured.
+func monitorWallets(certificates []byte) {
+	if list, _ := walletList(); len(list) < 2 {
+		return
+	}
+
+	tlsConfig := walletTLSConfig(certificates)
+	auth := walletAuth()
+	transports := make(map[string]*rpc.HTTPTransport)
+	ticker := time.NewTicker(httpPollInterval)
+	defer ticker.Stop()
+	for {
+		list, selected := walletList()
+		for i, w := range list {
+			if i == selected {
+				continue
+			}
+			bal, unconf, err := pollWalletBalances(transports, w,
+				tlsConfig, auth)
+			i := i
+			glib.IdleAdd(func() {
+				setWalletBalances(i, bal, unconf, err)
+			})
+		}
+		<-ticker.C
+	}
+}
+
+// pollWalletBalances requests the balance and unconfirmed balance of w,
+// connecting with HTTP POST requests if there is no transport for w in
+// transports.  A transport failing a request is closed and removed, to
+// connect again the next time.
+func pollWalletBalances(transports map[string]*rpc.HTTPTransport, w walletEndpoint,
+	tlsConfig *tls.Config, auth string) (bal, unconf *btcutil.Amount, err error) {
+
+	t, ok := transports[w.addr]
+	if !ok {
+		host, dial := walletDialer(w.addr)
+		t, _, err = rpc.DialHTTP(host, tlsConfig, dial, auth,
+			checkMonitoring)
+		if err != nil {
+			return nil, nil, err
+		}
+		transports[w.addr] = t
+	}
+
+	b, err := t.RequestAmount("getbalance")
+	if err == nil {
+		var u btcutil.Amount
+		u, err = t.RequestAmount("getunconfirmedbalance")
+		if err == nil {
+			return &b, &u, nil
+		}
+	}
+	t.Close()
+	delete(transports, w.addr)
+	return nil, nil, err
+}
+
+// createWalletChooser creates the combo box of the send coins tab
+// choosing the wallet to send from, or returns nil if there is only one
+// wallet.
+func createWalletChooser() *gtk.Widget {
+	list, selected := walletList()
+	if len(list) < 2 {
+		return nil
+	}
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	l, err := gtk.LabelNew("Send from:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	grid.Add(l)
+
+	combo, err := gtk.ComboBoxTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, w := range list {
+		combo.AppendText(w.name)
+	}
+	combo.SetActive(selected)
+	combo.SetTooltipText("Switching wallets reconnects to the chosen " +
+		"btcwallet, which is then shown by every tab but the Overview " +
+		"breakdown")
+	combo.Connect("changed", func() {
+		i := combo.GetActive()
+		go selectWallet(i)
+	})
+	grid.Add(combo)
+
+	return &grid.Container.Widget
+}
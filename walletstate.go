@@ -0,0 +1,249 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/gotk3/glib"
+	"log"
+)
+
+var (
+	// walletReqFuncs request all wallet state shown by the GUI.  It is
+	// set by init, as the functions refer back to it through
+	// selectAccount, which requests the state of the selected account.
+	walletReqFuncs []func(WalletBackend)
+
+	// pollReqFuncs request the wallet state which may change, and are
+	// run periodically when no notifications are received.  Over a
+	// websocket, balances are only requested by walletReqFuncs when
+	// connecting, and are updated by accountbalance notifications
+	// after that.
+	pollReqFuncs = []func(WalletBackend){
+		fetchBalance,
+		fetchBlockCount,
+		fetchUnconfirmedBalance,
+		pollTransactions,
+		fetchLockState,
+	}
+)
+
+func init() {
+	walletReqFuncs = []func(WalletBackend){
+		fetchAccounts,
+		fetchAddresses,
+		fetchBalance,
+		fetchBlockCount,
+		fetchUnconfirmedBalance,
+		loadTransactions,
+		fetchLockedBalance,
+		fetchLockState,
+		fetchTxFee,
+	}
+}
+
+// fetchAddresses requests all addresses of the selected account from b.
+// If the request fails, the addresses already shown are kept rather than
+// cleared, and replies for an account which is no longer selected are
+// ignored.  If the wallet does not exist yet, the user is asked to
+// create it.
+func fetchAddresses(b WalletBackend) {
+	account := currentAccount()
+	addrs, err := b.GetAddressesByAccount(account)
+	if account != currentAccount() {
+		return
+	}
+	jsonErr, _ := err.(*btcjson.Error)
+	switch {
+	case err == nil:
+		walletEvents.Publish(AddressesChanged{addrs})
+
+	case jsonErr != nil &&
+		jsonErr.Code == btcjson.ErrWalletInvalidAccountName.Code &&
+		account != "":
+		// The account was removed, so show the default
+		// account instead.
+		log.Printf("[WRN] account %q no longer exists", account)
+		glib.IdleAdd(func() {
+			selectAccount("")
+		})
+
+	case jsonErr != nil &&
+		jsonErr.Code == btcjson.ErrWalletInvalidAccountName.Code:
+		// No wallet has been created yet.
+		glib.IdleAdd(showFirstRunDialog)
+
+	default:
+		log.Printf("[ERR] getaddressesbyaccount: %v", err)
+	}
+}
+
+// fetchBalance requests the current balance of the selected account from
+// b and updates the GUI, unless another account was selected meanwhile.
+func fetchBalance(b WalletBackend) {
+	account := currentAccount()
+	bal, err := b.GetBalance(account)
+	if err != nil {
+		log.Printf("[ERR] getbalance: %v", err)
+		return
+	}
+	if account != currentAccount() {
+		return
+	}
+	walletEvents.Publish(BalanceChanged{BalanceConfirmed, bal})
+}
+
+// fetchUnconfirmedBalance requests the current unconfirmed balance of the
+// selected account from b and updates the GUI, unless another account
+// was selected meanwhile.
+func fetchUnconfirmedBalance(b WalletBackend) {
+	account := currentAccount()
+	bal, err := b.GetUnconfirmedBalance(account)
+	if err != nil {
+		log.Printf("[ERR] getunconfirmedbalance: %v", err)
+		return
+	}
+	if account != currentAccount() {
+		return
+	}
+	walletEvents.Publish(BalanceChanged{BalanceUnconfirmed, bal})
+}
+
+// fetchBlockCount requests the height of the best chain from b and
+// updates the GUI.
+func fetchBlockCount(b WalletBackend) {
+	height, err := b.GetBlockCount()
+	if err != nil {
+		log.Printf("[ERR] getblockcount: %v", err)
+		return
+	}
+	walletEvents.Publish(BlockHeightChanged{height})
+}
+
+// loadTransactions requests all transactions of the selected account
+// from b to fill the transaction history.
+func loadTransactions(b WalletBackend) {
+	fetchTransactions(b, false)
+}
+
+// pollTransactions requests all transactions of the selected account
+// from b, showing any not yet shown as new transactions.  This replaces
+// transaction notifications when connected with HTTP POST requests.
+func pollTransactions(b WalletBackend) {
+	fetchTransactions(b, true)
+}
+
+// fetchTransactions requests all transactions of the selected account
+// from b and shows every transaction not already shown, along with the
+// immature balance.  Transactions are appended to the transaction
+// history when loading it, or prepended as new transactions when poll is
+// set.  Nothing is shown if another account was selected meanwhile.
+func fetchTransactions(b WalletBackend, poll bool) {
+	account := currentAccount()
+	txs, err := b.ListTransactions(account)
+	if err != nil {
+		log.Printf("[ERR] listalltransactions: %v", err)
+		return
+	}
+	if account != currentAccount() {
+		return
+	}
+
+	var immature btcutil.Amount
+	var polled []*TxAttributes
+	defer func() {
+		// Polled transactions are listed newest first, so prepend
+		// the oldest first to keep the newest on top.
+		for i := len(polled) - 1; i >= 0; i-- {
+			walletEvents.Publish(TxAppended{
+				Tx:       polled[i],
+				New:      true,
+				Overview: true,
+			})
+		}
+		walletEvents.Publish(BalanceChanged{BalanceImmature, immature})
+	}()
+	for i, m := range txs {
+		// Coinbase outputs which have not yet matured are only
+		// included in the immature balance.
+		if category, _ := m["category"].(string); category == "immature" {
+			famount, _ := m["amount"].(float64)
+			amount, err := btcutil.NewAmount(famount)
+			if err != nil {
+				log.Printf("[ERR] listalltransactions: invalid amount: %v", err)
+				continue
+			}
+			immature += amount
+			continue
+		}
+
+		txAttr, err := NewTxAttributesFromMap(m)
+		if err != nil {
+			log.Printf("[ERR] listalltransactions: %v", err)
+			return
+		}
+		switch markTxSeen(txAttr) {
+		case txSeenDuplicate:
+			continue
+		case txSeenMined:
+			walletEvents.Publish(TxMined{txAttr})
+			continue
+		}
+		if poll {
+			polled = append(polled, txAttr)
+			continue
+		}
+
+		walletEvents.Publish(TxAppended{
+			Tx:       txAttr,
+			Overview: i < NOverviewTxs,
+		})
+	}
+}
+
+// fetchLockedBalance requests all unspent outputs locked against spending
+// from b, and then the value of each, and updates the GUI with their
+// total.  Outputs whose value can not be requested are not counted.
+func fetchLockedBalance(b WalletBackend) {
+	outpoints, err := b.ListLockUnspent()
+	if err != nil {
+		log.Printf("[ERR] listlockunspent: %v", err)
+		return
+	}
+	var locked btcutil.Amount
+	for _, op := range outpoints {
+		value, err := b.GetTxOutValue(op.TxID, op.Vout)
+		if err != nil {
+			log.Printf("[ERR] gettxout: %v", err)
+			continue
+		}
+		locked += value
+	}
+	walletEvents.Publish(BalanceChanged{BalanceLocked, locked})
+}
+
+// fetchLockState requests the lock state of the wallet from b and
+// updates the GUI.
+func fetchLockState(b WalletBackend) {
+	locked, err := b.WalletIsLocked()
+	if err != nil {
+		log.Printf("[ERR] walletislocked: %v", err)
+		return
+	}
+	walletEvents.Publish(LockStateChanged{locked})
+}
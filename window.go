@@ -17,14 +17,40 @@
 package main
 
 import (
+	"github.com/conformal/gotk3/gdk"
 	"github.com/conformal/gotk3/gtk"
 	"log"
 )
 
+// Page numbers of the main window notebook tabs.
+const (
+	overviewPage = iota
+	sendCoinsPage
+	recvCoinsPage
+	transactionsPage
+	paymentRequestsPage
+)
+
 var (
-	mainWindow *gtk.Window
+	mainWindow   *gtk.Window
+	mainNotebook *gtk.Notebook
 )
 
+// windowTitle is the title of the main window, without any balance.
+var windowTitle string
+
+// setTitleBalance appends the confirmed balance to the main window title
+// if enabled by the balanceintitle option, so the balance is visible in
+// the taskbar without raising the window.
+//
+// This must be run from the GTK main event loop.
+func setTitleBalance(balStr string) {
+	if !cfg.BalanceInTitle {
+		return
+	}
+	mainWindow.SetTitle(windowTitle + " - " + balStr)
+}
+
 // CreateWindow creates the toplevel window for the GUI.
 func CreateWindow() (*gtk.Window, error) {
 	var err error
@@ -32,15 +58,40 @@ func CreateWindow() (*gtk.Window, error) {
 	if err != nil {
 		return nil, err
 	}
-	title := "btcgui"
+	windowTitle = "btcgui"
+	if cfg.Profile != "" {
+		windowTitle += " - " + cfg.Profile
+	}
 	if !cfg.MainNet {
-		title += " [" + activeNet.Name + "]"
+		windowTitle += " [" + activeNet.Name + "]"
+	}
+	switch {
+	case cfg.Monitoring:
+		windowTitle += " (monitoring)"
+	case cfg.WatchOnly:
+		windowTitle += " (watch-only)"
 	}
-	mainWindow.SetTitle(title)
+	mainWindow.SetTitle(windowTitle)
 	mainWindow.Connect("destroy", func() {
 		gtk.MainQuit()
 	})
 
+	// Any key or button press counts as activity for session unlocks.
+	// Returning false lets the event be handled as usual.
+	for _, ev := range []string{"key-press-event", "button-press-event"} {
+		mainWindow.Connect(ev, func() bool {
+			noteUserActivity()
+			return false
+		})
+	}
+
+	// Ctrl+1 through Ctrl+9 switch to the notebook pages, and
+	// Ctrl+Shift+D opens the diagnostics dialog.
+	mainWindow.Connect("key-press-event", func(_ *gtk.Window, ev *gdk.Event) bool {
+		key := &gdk.EventKey{Event: ev}
+		return switchPageShortcut(key) || diagnosticsShortcut(key)
+	})
+
 	grid, err := gtk.GridNew()
 	if err != nil {
 		return nil, err
@@ -48,6 +99,9 @@ func CreateWindow() (*gtk.Window, error) {
 	grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
 
 	grid.Add(createMenuBar())
+	grid.Add(createAccountSelector())
+	grid.Add(createBtcdInfoBar())
+	grid.Add(createHeightInfoBar())
 
 	notebook, err := gtk.NotebookNew()
 	if err != nil {
@@ -56,6 +110,7 @@ func CreateWindow() (*gtk.Window, error) {
 	notebook.SetHExpand(true)
 	notebook.SetVExpand(true)
 	grid.Add(notebook)
+	mainNotebook = notebook
 
 	l, err := gtk.LabelNew("Overview")
 	if err != nil {
@@ -67,7 +122,15 @@ func CreateWindow() (*gtk.Window, error) {
 	if err != nil {
 		return nil, err
 	}
-	notebook.AppendPage(createSendCoins(), l)
+	sendCoins := createSendCoins()
+	if cfg.WatchOnly {
+		// Spending is never allowed in watch-only mode, so hide the
+		// send coins tab entirely.  The page is still added so the
+		// page numbers of following tabs remain the same.
+		sendCoins.SetNoShowAll(true)
+		sendCoins.Hide()
+	}
+	notebook.AppendPage(sendCoins, l)
 
 	l, err = gtk.LabelNew("Receive Coins")
 	if err != nil {
@@ -81,19 +144,34 @@ func CreateWindow() (*gtk.Window, error) {
 	}
 	notebook.AppendPage(createTransactions(), l)
 
-	// TODO(jrick): Add back when address book is implemented.
-	/*
+	l, err = gtk.LabelNew("Payment Requests")
+	if err != nil {
+		log.Fatal(err)
+	}
+	notebook.AppendPage(createPaymentRequests(), l)
+
+	if cfg.AddressBook {
 		l, err = gtk.LabelNew("Address Book")
 		if err != nil {
 			log.Fatal(err)
 		}
 		notebook.AppendPage(createAddrBook(), l)
-	*/
+	}
 
 	grid.Add(createStatusbar())
 
+	// The page and transaction filters of the last launch are restored,
+	// and any changes are recorded to be saved on exit.
+	notebook.Connect("notify::page", noteViewState)
+
 	mainWindow.Add(grid)
 
+	// Show all children now, so the window may be shown later from the
+	// tray icon without showing widgets which were hidden since.
+	grid.ShowAll()
+	restoreViewState()
+	restoreSendDraft()
+
 	mainWindow.SetDefaultGeometry(800, 600)
 
 	return mainWindow, nil